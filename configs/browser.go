@@ -0,0 +1,47 @@
+package configs
+
+import (
+	"log"
+	"os"
+	"rerng_addicted_api/pkg/utils"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+type BrowserConfig struct {
+	BrowserBin         string
+	MaxBrowsers        int
+	MaxPagesPerBrowser int
+	IdleTimeout        time.Duration
+	AcquireTimeout     time.Duration
+}
+
+func Browser() *BrowserConfig {
+	err := godotenv.Load()
+	if err != nil {
+		log.Printf("No .env file found, using system environment variables")
+	}
+
+	// empty bin lets rod look up (or download) a chromium build
+	browser_bin := os.Getenv("BROWSER_BIN")
+	max_browsers := utils.GetenvInt("BROWSER_MAX_BROWSERS", 2)
+	max_pages := utils.GetenvInt("BROWSER_MAX_PAGES", 6)
+	idle_timeout := utils.GetenvInt("BROWSER_IDLE_TIMEOUT", 300)
+	acquire_timeout := utils.GetenvInt("BROWSER_ACQUIRE_TIMEOUT", 60)
+
+	if max_browsers < 1 {
+		max_browsers = 1
+	}
+	if max_pages < 1 {
+		max_pages = 1
+	}
+
+	return &BrowserConfig{
+		BrowserBin:         browser_bin,
+		MaxBrowsers:        max_browsers,
+		MaxPagesPerBrowser: max_pages,
+		IdleTimeout:        time.Duration(idle_timeout) * time.Second,
+		AcquireTimeout:     time.Duration(acquire_timeout) * time.Second,
+	}
+}
@@ -0,0 +1,37 @@
+package configs
+
+import (
+	"log"
+	"rerng_addicted_api/pkg/utils"
+
+	"github.com/joho/godotenv"
+)
+
+type CatalogConfig struct {
+	// SuggestLimit is how many suggestions are returned when the client does not ask, SuggestMaxLimit caps it
+	SuggestLimit    int
+	SuggestMaxLimit int
+	// PopularDays is the window of daily search counts merged into suggestions
+	PopularDays int
+	// PopularSize is how many distinct queries each day keeps, the least searched are dropped
+	PopularSize int
+}
+
+func Catalog() *CatalogConfig {
+	err := godotenv.Load()
+	if err != nil {
+		log.Printf("No .env file found, using system environment variables")
+	}
+
+	suggest_limit := utils.GetenvInt("CATALOG_SUGGEST_LIMIT", 8)
+	suggest_max_limit := utils.GetenvInt("CATALOG_SUGGEST_MAX_LIMIT", 20)
+	popular_days := utils.GetenvInt("CATALOG_POPULAR_DAYS", 7)
+	popular_size := utils.GetenvInt("CATALOG_POPULAR_SIZE", 1000)
+
+	return &CatalogConfig{
+		SuggestLimit:    suggest_limit,
+		SuggestMaxLimit: suggest_max_limit,
+		PopularDays:     popular_days,
+		PopularSize:     popular_size,
+	}
+}
@@ -0,0 +1,72 @@
+package configs
+
+import (
+	"log"
+	"os"
+	"rerng_addicted_api/pkg/utils"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+type ImageConfig struct {
+	// StorageDir holds mirrored images, laid out by content hash
+	StorageDir   string
+	MaxBytes     int64
+	FetchTimeout time.Duration
+	// MaxDimension caps the width and height clients can ask resized thumbnails for
+	MaxDimension int
+	// Sizes are the widths and heights a resize request snaps up to, ascending,
+	// so a serie only ever has a handful of variants on disk
+	Sizes []int
+	// MaxPixels is the largest original, in pixels, that is decoded for resizing
+	MaxPixels   int64
+	JPEGQuality int
+}
+
+func Image() *ImageConfig {
+	err := godotenv.Load()
+	if err != nil {
+		log.Printf("No .env file found, using system environment variables")
+	}
+
+	storage_dir := os.Getenv("IMAGE_STORAGE_DIR")
+	if storage_dir == "" {
+		storage_dir = "./storage/images"
+	}
+	max_bytes := utils.GetenvInt("IMAGE_MAX_BYTES", 10*1024*1024)
+	fetch_timeout := utils.GetenvInt("IMAGE_FETCH_TIMEOUT", 20)
+	max_dimension := utils.GetenvInt("IMAGE_MAX_DIMENSION", 1600)
+	max_pixels := utils.GetenvInt("IMAGE_MAX_PIXELS", 40_000_000)
+	jpeg_quality := utils.GetenvInt("IMAGE_JPEG_QUALITY", 82)
+
+	// IMAGE_SIZES=160,320,640
+	raw_sizes := os.Getenv("IMAGE_SIZES")
+	if raw_sizes == "" {
+		raw_sizes = "80,160,240,320,480,640,960,1280,1600"
+	}
+	sizes := []int{}
+	for _, raw := range strings.Split(raw_sizes, ",") {
+		size, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err == nil && size > 0 && size <= max_dimension && !slices.Contains(sizes, size) {
+			sizes = append(sizes, size)
+		}
+	}
+	if len(sizes) == 0 {
+		sizes = append(sizes, max_dimension)
+	}
+	slices.Sort(sizes)
+
+	return &ImageConfig{
+		StorageDir:   storage_dir,
+		MaxBytes:     int64(max_bytes),
+		FetchTimeout: time.Duration(fetch_timeout) * time.Second,
+		MaxDimension: max_dimension,
+		Sizes:        sizes,
+		MaxPixels:    int64(max_pixels),
+		JPEGQuality:  jpeg_quality,
+	}
+}
@@ -0,0 +1,52 @@
+package configs
+
+import (
+	"log"
+	"rerng_addicted_api/pkg/utils"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+type JobConfig struct {
+	Workers      int
+	PollInterval time.Duration
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	StaleAfter   time.Duration
+}
+
+func Job() *JobConfig {
+	err := godotenv.Load()
+	if err != nil {
+		log.Printf("No .env file found, using system environment variables")
+	}
+
+	workers := utils.GetenvInt("JOB_WORKERS", 2)
+	poll_interval := utils.GetenvInt("JOB_POLL_INTERVAL", 5)
+	max_attempts := utils.GetenvInt("JOB_MAX_ATTEMPTS", 3)
+	base_backoff := utils.GetenvInt("JOB_BASE_BACKOFF", 30)
+	max_backoff := utils.GetenvInt("JOB_MAX_BACKOFF", 1800)
+	// a running job without a heartbeat for this long is assumed orphaned by a crashed process and requeued
+	stale_after := utils.GetenvInt("JOB_STALE_AFTER", 300)
+
+	if workers < 1 {
+		workers = 1
+	}
+	if stale_after < 4 {
+		stale_after = 4
+	}
+	if max_attempts < 1 {
+		max_attempts = 1
+	}
+
+	return &JobConfig{
+		Workers:      workers,
+		PollInterval: time.Duration(poll_interval) * time.Second,
+		MaxAttempts:  max_attempts,
+		BaseBackoff:  time.Duration(base_backoff) * time.Second,
+		MaxBackoff:   time.Duration(max_backoff) * time.Second,
+		StaleAfter:   time.Duration(stale_after) * time.Second,
+	}
+}
@@ -0,0 +1,86 @@
+package configs
+
+import (
+	"log"
+	"os"
+	"rerng_addicted_api/pkg/utils"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+type ProxyConfig struct {
+	// SigningKey signs proxy urls, it falls back to the jwt secret when unset
+	SigningKey string
+	// URLTTL is how long a signed proxy url stays valid
+	URLTTL time.Duration
+	// AllowedHosts lists the upstream domains each provider serves media from, subdomains included
+	AllowedHosts map[string][]string
+	// AllowPrivate lets the proxy reach private addresses, only meant for local mocks
+	AllowPrivate bool
+	// SegmentCacheDir holds cached hls segments and keys, SegmentCacheMaxBytes bounds it and 0 turns it off
+	SegmentCacheDir      string
+	SegmentCacheMaxBytes int64
+	// SegmentMaxBytes is the largest single segment worth caching
+	SegmentMaxBytes int64
+	// MediaCacheTTL is how long a media url discovered by the browser is reused,
+	// SpeedCacheTTL how long a client's measured chunk size is
+	MediaCacheTTL time.Duration
+	SpeedCacheTTL time.Duration
+}
+
+func Proxy() *ProxyConfig {
+	err := godotenv.Load()
+	if err != nil {
+		log.Printf("No .env file found, using system environment variables")
+	}
+
+	signing_key := os.Getenv("PROXY_SIGNING_KEY")
+	if signing_key == "" {
+		signing_key = os.Getenv("JWT_SECRET_KEY")
+	}
+	// anyone could sign proxy urls with an empty key
+	if signing_key == "" {
+		log.Fatal("PROXY_SIGNING_KEY or JWT_SECRET_KEY must be set to sign proxy urls")
+	}
+	url_ttl := utils.GetenvInt("PROXY_URL_TTL", 6*60*60)
+
+	// PROXY_ALLOWED_HOSTS=kisskh=kisskh.co|kisskh.id,other=cdn.example.org
+	allowed_hosts := map[string][]string{}
+	for _, pair := range strings.Split(os.Getenv("PROXY_ALLOWED_HOSTS"), ",") {
+		name, hosts, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || name == "" {
+			continue
+		}
+		for _, host := range strings.Split(hosts, "|") {
+			host = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(host)), "*.")
+			if host != "" {
+				allowed_hosts[strings.TrimSpace(name)] = append(allowed_hosts[strings.TrimSpace(name)], host)
+			}
+		}
+	}
+	allow_private := os.Getenv("PROXY_ALLOW_PRIVATE") == "true"
+
+	segment_cache_dir := os.Getenv("PROXY_SEGMENT_CACHE_DIR")
+	if segment_cache_dir == "" {
+		segment_cache_dir = "./storage/segments"
+	}
+	segment_cache_max_mb := utils.GetenvInt("PROXY_SEGMENT_CACHE_MAX_MB", 2048)
+	segment_max_mb := utils.GetenvInt("PROXY_SEGMENT_MAX_MB", 32)
+	media_cache_ttl := utils.GetenvInt("PROXY_MEDIA_CACHE_TTL", 30*60)
+	speed_cache_ttl := utils.GetenvInt("PROXY_SPEED_CACHE_TTL", 10*60)
+
+	return &ProxyConfig{
+		SigningKey:   signing_key,
+		URLTTL:       time.Duration(url_ttl) * time.Second,
+		AllowedHosts: allowed_hosts,
+		AllowPrivate: allow_private,
+
+		SegmentCacheDir:      segment_cache_dir,
+		SegmentCacheMaxBytes: int64(segment_cache_max_mb) * 1024 * 1024,
+		SegmentMaxBytes:      int64(segment_max_mb) * 1024 * 1024,
+		MediaCacheTTL:        time.Duration(media_cache_ttl) * time.Second,
+		SpeedCacheTTL:        time.Duration(speed_cache_ttl) * time.Second,
+	}
+}
@@ -0,0 +1,48 @@
+package configs
+
+import (
+	"log"
+	"os"
+	"rerng_addicted_api/pkg/utils"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+type RefreshConfig struct {
+	Enabled  bool
+	Interval time.Duration
+	Statuses []string
+}
+
+func Refresh() *RefreshConfig {
+	err := godotenv.Load()
+	if err != nil {
+		log.Printf("No .env file found, using system environment variables")
+	}
+
+	enabled := strings.ToLower(os.Getenv("REFRESH_ENABLED")) != "false"
+	interval := utils.GetenvInt("REFRESH_INTERVAL", 21600)
+	if interval < 60 {
+		interval = 60
+	}
+
+	// series statuses (as stored in tbl_series.status) that are still releasing episodes
+	statuses := []string{}
+	raw_statuses := os.Getenv("REFRESH_STATUSES")
+	if raw_statuses == "" {
+		raw_statuses = "Ongoing,Upcoming"
+	}
+	for _, status := range strings.Split(raw_statuses, ",") {
+		if status = strings.TrimSpace(status); status != "" {
+			statuses = append(statuses, status)
+		}
+	}
+
+	return &RefreshConfig{
+		Enabled:  enabled,
+		Interval: time.Duration(interval) * time.Second,
+		Statuses: statuses,
+	}
+}
@@ -0,0 +1,52 @@
+package configs
+
+import (
+	"log"
+	"os"
+	"rerng_addicted_api/pkg/utils"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+type ScrapingConfig struct {
+	// upstream origin per provider name, unset providers use their built-in url
+	BaseURLs map[string]string
+
+	// how long cached search / detail responses are served as fresh
+	SearchCacheTTL time.Duration
+	DetailCacheTTL time.Duration
+	// how long past freshness an entry is still served while it is refreshed in the background
+	CacheStaleTTL time.Duration
+}
+
+func Scraping() *ScrapingConfig {
+	err := godotenv.Load()
+	if err != nil {
+		log.Printf("No .env file found, using system environment variables")
+	}
+
+	// SCRAPING_BASE_URLS=kisskh=http://127.0.0.1:9090,other=https://example.org
+	base_urls := map[string]string{}
+	for _, pair := range strings.Split(os.Getenv("SCRAPING_BASE_URLS"), ",") {
+		name, base_url, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || name == "" || base_url == "" {
+			continue
+		}
+		base_urls[strings.TrimSpace(name)] = strings.TrimSpace(base_url)
+	}
+
+	// search results default to the generic redis expiry
+	redis_expire := utils.GetenvInt("REDIS_EXPIRE", 60)
+	search_ttl := utils.GetenvInt("SCRAPING_SEARCH_CACHE_TTL", redis_expire)
+	detail_ttl := utils.GetenvInt("SCRAPING_DETAIL_CACHE_TTL", 600)
+	stale_ttl := utils.GetenvInt("SCRAPING_CACHE_STALE_TTL", 3600)
+
+	return &ScrapingConfig{
+		BaseURLs:       base_urls,
+		SearchCacheTTL: time.Duration(search_ttl) * time.Second,
+		DetailCacheTTL: time.Duration(detail_ttl) * time.Second,
+		CacheStaleTTL:  time.Duration(stale_ttl) * time.Second,
+	}
+}
@@ -0,0 +1,54 @@
+package configs
+
+import (
+	"log"
+	"os"
+	"rerng_addicted_api/pkg/utils"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+type SourceConfig struct {
+	Enabled       bool
+	CheckInterval time.Duration
+	// RecheckAfter is how old a probe result may get before the episode is probed again
+	RecheckAfter time.Duration
+	BatchSize    int
+	Concurrency  int
+	ProbeTimeout time.Duration
+}
+
+func Source() *SourceConfig {
+	err := godotenv.Load()
+	if err != nil {
+		log.Printf("No .env file found, using system environment variables")
+	}
+
+	enabled := strings.ToLower(os.Getenv("SOURCE_CHECK_ENABLED")) != "false"
+	check_interval := utils.GetenvInt("SOURCE_CHECK_INTERVAL", 900)
+	recheck_after := utils.GetenvInt("SOURCE_RECHECK_AFTER", 21600)
+	batch_size := utils.GetenvInt("SOURCE_CHECK_BATCH", 50)
+	concurrency := utils.GetenvInt("SOURCE_CHECK_CONCURRENCY", 4)
+	probe_timeout := utils.GetenvInt("SOURCE_PROBE_TIMEOUT", 10)
+
+	if check_interval < 60 {
+		check_interval = 60
+	}
+	if batch_size < 1 {
+		batch_size = 1
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	return &SourceConfig{
+		Enabled:       enabled,
+		CheckInterval: time.Duration(check_interval) * time.Second,
+		RecheckAfter:  time.Duration(recheck_after) * time.Second,
+		BatchSize:     batch_size,
+		Concurrency:   concurrency,
+		ProbeTimeout:  time.Duration(probe_timeout) * time.Second,
+	}
+}
@@ -0,0 +1,63 @@
+package configs
+
+import (
+	"log"
+	"rerng_addicted_api/pkg/utils"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+type UpstreamConfig struct {
+	RatePerSecond    int
+	Burst            int
+	MaxRetries       int
+	BaseBackoff      time.Duration
+	MaxBackoff       time.Duration
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	SessionTTL       time.Duration
+	RequestTimeout   time.Duration
+}
+
+func Upstream() *UpstreamConfig {
+	err := godotenv.Load()
+	if err != nil {
+		log.Printf("No .env file found, using system environment variables")
+	}
+
+	rate := utils.GetenvInt("UPSTREAM_RATE", 5)
+	burst := utils.GetenvInt("UPSTREAM_BURST", 10)
+	max_retries := utils.GetenvInt("UPSTREAM_MAX_RETRIES", 3)
+	base_backoff := utils.GetenvInt("UPSTREAM_BASE_BACKOFF_MS", 500)
+	max_backoff := utils.GetenvInt("UPSTREAM_MAX_BACKOFF_MS", 8000)
+	breaker_threshold := utils.GetenvInt("UPSTREAM_BREAKER_THRESHOLD", 5)
+	breaker_cooldown := utils.GetenvInt("UPSTREAM_BREAKER_COOLDOWN", 30)
+	session_ttl := utils.GetenvInt("UPSTREAM_SESSION_TTL", 1800)
+	request_timeout := utils.GetenvInt("UPSTREAM_REQUEST_TIMEOUT", 15)
+
+	if rate < 1 {
+		rate = 1
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	if max_retries < 0 {
+		max_retries = 0
+	}
+	if breaker_threshold < 1 {
+		breaker_threshold = 1
+	}
+
+	return &UpstreamConfig{
+		RatePerSecond:    rate,
+		Burst:            burst,
+		MaxRetries:       max_retries,
+		BaseBackoff:      time.Duration(base_backoff) * time.Millisecond,
+		MaxBackoff:       time.Duration(max_backoff) * time.Millisecond,
+		BreakerThreshold: breaker_threshold,
+		BreakerCooldown:  time.Duration(breaker_cooldown) * time.Second,
+		SessionTTL:       time.Duration(session_ttl) * time.Second,
+		RequestTimeout:   time.Duration(request_timeout) * time.Second,
+	}
+}
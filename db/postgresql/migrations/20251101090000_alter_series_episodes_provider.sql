@@ -0,0 +1,53 @@
+-- +goose Up
+ALTER TABLE tbl_series
+ADD COLUMN IF NOT EXISTS provider VARCHAR(50) NOT NULL DEFAULT 'kisskh';
+
+ALTER TABLE tbl_episodes
+ADD COLUMN IF NOT EXISTS provider VARCHAR(50) NOT NULL DEFAULT 'kisskh';
+
+CREATE INDEX IF NOT EXISTS idx_series_provider ON tbl_series(provider);
+CREATE INDEX IF NOT EXISTS idx_episodes_provider ON tbl_episodes(provider);
+
+-- id becomes an internal key, the provider's own id moves to external_id so two providers can reuse an id
+ALTER TABLE tbl_series
+ADD COLUMN IF NOT EXISTS external_id BIGINT;
+
+ALTER TABLE tbl_episodes
+ADD COLUMN IF NOT EXISTS external_id BIGINT;
+
+UPDATE tbl_series SET external_id = id WHERE external_id IS NULL;
+UPDATE tbl_episodes SET external_id = id WHERE external_id IS NULL;
+
+ALTER TABLE tbl_series ALTER COLUMN external_id SET NOT NULL;
+ALTER TABLE tbl_episodes ALTER COLUMN external_id SET NOT NULL;
+
+CREATE SEQUENCE IF NOT EXISTS tbl_series_id_seq OWNED BY tbl_series.id;
+CREATE SEQUENCE IF NOT EXISTS tbl_episodes_id_seq OWNED BY tbl_episodes.id;
+
+SELECT setval('tbl_series_id_seq', COALESCE((SELECT MAX(id) FROM tbl_series), 0) + 1, false);
+SELECT setval('tbl_episodes_id_seq', COALESCE((SELECT MAX(id) FROM tbl_episodes), 0) + 1, false);
+
+ALTER TABLE tbl_series ALTER COLUMN id SET DEFAULT nextval('tbl_series_id_seq');
+ALTER TABLE tbl_episodes ALTER COLUMN id SET DEFAULT nextval('tbl_episodes_id_seq');
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_series_provider_external_id ON tbl_series(provider, external_id);
+CREATE UNIQUE INDEX IF NOT EXISTS uq_episodes_provider_external_id ON tbl_episodes(provider, external_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS uq_episodes_provider_external_id;
+DROP INDEX IF EXISTS uq_series_provider_external_id;
+
+ALTER TABLE tbl_episodes ALTER COLUMN id DROP DEFAULT;
+ALTER TABLE tbl_series ALTER COLUMN id DROP DEFAULT;
+
+DROP SEQUENCE IF EXISTS tbl_episodes_id_seq;
+DROP SEQUENCE IF EXISTS tbl_series_id_seq;
+
+ALTER TABLE tbl_episodes DROP COLUMN IF EXISTS external_id;
+ALTER TABLE tbl_series DROP COLUMN IF EXISTS external_id;
+
+DROP INDEX IF EXISTS idx_episodes_provider;
+DROP INDEX IF EXISTS idx_series_provider;
+
+ALTER TABLE tbl_episodes DROP COLUMN IF EXISTS provider;
+ALTER TABLE tbl_series DROP COLUMN IF EXISTS provider;
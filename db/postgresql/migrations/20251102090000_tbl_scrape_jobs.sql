@@ -0,0 +1,56 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS tbl_scrape_jobs (
+    id BIGSERIAL PRIMARY KEY,
+    job_type VARCHAR(20) NOT NULL,
+    provider VARCHAR(50) NOT NULL DEFAULT 'kisskh',
+    series_key BIGINT NOT NULL,
+    -- episodes like 10.5 exist upstream, a whole number would resolve the wrong one
+    episode_number NUMERIC(5,2) NOT NULL DEFAULT 0,
+    state VARCHAR(20) NOT NULL DEFAULT 'queued',
+    attempts INT NOT NULL DEFAULT 0,
+    max_attempts INT NOT NULL DEFAULT 3,
+    run_after TIMESTAMP NOT NULL DEFAULT NOW(),
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP,
+    locked_by VARCHAR(100),
+    last_error TEXT,
+    episodes_total INT NOT NULL DEFAULT 0,
+    episodes_resolved INT NOT NULL DEFAULT 0,
+    episodes_failed INT NOT NULL DEFAULT 0,
+
+    status_id INTEGER NOT NULL DEFAULT 1,
+    "order" INTEGER DEFAULT 1,
+    created_at TIMESTAMP DEFAULT NOW(),
+    created_by BIGINT,
+    updated_at TIMESTAMP,
+    updated_by BIGINT,
+    deleted_at TIMESTAMP,
+    deleted_by BIGINT
+);
+
+CREATE INDEX IF NOT EXISTS idx_scrape_jobs_pending ON tbl_scrape_jobs(run_after) WHERE state = 'queued';
+CREATE INDEX IF NOT EXISTS idx_scrape_jobs_series_key ON tbl_scrape_jobs(series_key);
+
+-- at most one queued or running job per target
+CREATE UNIQUE INDEX IF NOT EXISTS uq_scrape_jobs_active
+    ON tbl_scrape_jobs(job_type, provider, series_key, episode_number)
+    WHERE state IN ('queued', 'running');
+
+CREATE TABLE IF NOT EXISTS tbl_scrape_job_episodes (
+    id BIGSERIAL PRIMARY KEY,
+    job_id BIGINT NOT NULL REFERENCES tbl_scrape_jobs(id) ON DELETE CASCADE,
+    episode_number NUMERIC(5,2) NOT NULL,
+    state VARCHAR(20) NOT NULL,
+    error TEXT,
+    attempt INT NOT NULL DEFAULT 1,
+
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP
+);
+
+ALTER TABLE tbl_scrape_job_episodes
+ADD CONSTRAINT uq_scrape_job_episode UNIQUE (job_id, episode_number);
+
+-- +goose Down
+DROP TABLE IF EXISTS tbl_scrape_job_episodes;
+DROP TABLE IF EXISTS tbl_scrape_jobs;
@@ -0,0 +1,29 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS tbl_refresh_runs (
+    id BIGSERIAL PRIMARY KEY,
+    trigger VARCHAR(20) NOT NULL DEFAULT 'schedule',
+    state VARCHAR(20) NOT NULL DEFAULT 'running',
+    series_checked INT NOT NULL DEFAULT 0,
+    jobs_enqueued INT NOT NULL DEFAULT 0,
+    error TEXT,
+    started_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMP,
+
+    created_at TIMESTAMP DEFAULT NOW(),
+    created_by BIGINT
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_runs_started_at ON tbl_refresh_runs(started_at);
+
+ALTER TABLE tbl_scrape_jobs
+ADD COLUMN IF NOT EXISTS refresh_run_id BIGINT REFERENCES tbl_refresh_runs(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_scrape_jobs_refresh_run_id ON tbl_scrape_jobs(refresh_run_id);
+
+CREATE INDEX IF NOT EXISTS idx_series_status ON tbl_series(status);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_series_status;
+DROP INDEX IF EXISTS idx_scrape_jobs_refresh_run_id;
+ALTER TABLE tbl_scrape_jobs DROP COLUMN IF EXISTS refresh_run_id;
+DROP TABLE IF EXISTS tbl_refresh_runs;
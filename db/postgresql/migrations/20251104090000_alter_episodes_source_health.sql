@@ -0,0 +1,21 @@
+-- +goose Up
+ALTER TABLE tbl_episodes
+ADD COLUMN IF NOT EXISTS origin_src VARCHAR(1000),
+ADD COLUMN IF NOT EXISTS is_stale BOOLEAN NOT NULL DEFAULT FALSE,
+ADD COLUMN IF NOT EXISTS check_error TEXT,
+ADD COLUMN IF NOT EXISTS last_checked_at TIMESTAMP,
+ADD COLUMN IF NOT EXISTS last_resolved_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_episodes_last_checked_at ON tbl_episodes(last_checked_at NULLS FIRST);
+CREATE INDEX IF NOT EXISTS idx_episodes_is_stale ON tbl_episodes(is_stale) WHERE is_stale;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_episodes_is_stale;
+DROP INDEX IF EXISTS idx_episodes_last_checked_at;
+
+ALTER TABLE tbl_episodes
+DROP COLUMN IF EXISTS last_resolved_at,
+DROP COLUMN IF EXISTS last_checked_at,
+DROP COLUMN IF EXISTS check_error,
+DROP COLUMN IF EXISTS is_stale,
+DROP COLUMN IF EXISTS origin_src;
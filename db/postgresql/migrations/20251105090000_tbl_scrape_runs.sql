@@ -0,0 +1,49 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS tbl_scrape_runs (
+    id BIGSERIAL PRIMARY KEY,
+    trigger VARCHAR(20) NOT NULL,
+    provider VARCHAR(50) NOT NULL,
+    series_key BIGINT NOT NULL,
+    episode_number NUMERIC(5,2) NOT NULL DEFAULT 0,
+    state VARCHAR(20) NOT NULL DEFAULT 'running',
+    series_upserted INT NOT NULL DEFAULT 0,
+    episodes_upserted INT NOT NULL DEFAULT 0,
+    episodes_failed INT NOT NULL DEFAULT 0,
+    changes_count INT NOT NULL DEFAULT 0,
+    error TEXT,
+    started_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMP,
+    duration_ms BIGINT,
+    rolled_back_at TIMESTAMP,
+    rolled_back_by BIGINT,
+
+    created_at TIMESTAMP DEFAULT NOW(),
+    created_by BIGINT
+);
+
+CREATE INDEX IF NOT EXISTS idx_scrape_runs_series_key ON tbl_scrape_runs(series_key);
+CREATE INDEX IF NOT EXISTS idx_scrape_runs_started_at ON tbl_scrape_runs(started_at);
+
+CREATE TABLE IF NOT EXISTS tbl_catalog_changes (
+    id BIGSERIAL PRIMARY KEY,
+    run_id BIGINT REFERENCES tbl_scrape_runs(id) ON DELETE SET NULL,
+    entity VARCHAR(20) NOT NULL,
+    entity_id BIGINT NOT NULL,
+    series_id BIGINT NOT NULL,
+    action VARCHAR(20) NOT NULL,
+    field VARCHAR(50),
+    old_value TEXT,
+    new_value TEXT,
+    rolled_back BOOLEAN NOT NULL DEFAULT FALSE,
+
+    created_at TIMESTAMP DEFAULT NOW(),
+    created_by BIGINT
+);
+
+CREATE INDEX IF NOT EXISTS idx_catalog_changes_run_id ON tbl_catalog_changes(run_id);
+CREATE INDEX IF NOT EXISTS idx_catalog_changes_entity ON tbl_catalog_changes(entity, entity_id);
+CREATE INDEX IF NOT EXISTS idx_catalog_changes_series_id ON tbl_catalog_changes(series_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS tbl_catalog_changes;
+DROP TABLE IF EXISTS tbl_scrape_runs;
@@ -0,0 +1,34 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS tbl_episode_renditions (
+    id BIGSERIAL PRIMARY KEY,
+    episode_id BIGINT NOT NULL REFERENCES tbl_episodes(id) ON DELETE CASCADE,
+    bandwidth INT NOT NULL DEFAULT 0,
+    average_bandwidth INT NOT NULL DEFAULT 0,
+    width INT NOT NULL DEFAULT 0,
+    height INT NOT NULL DEFAULT 0,
+    codecs VARCHAR(255) NOT NULL DEFAULT '',
+    frame_rate NUMERIC(6,3) NOT NULL DEFAULT 0,
+    src VARCHAR(1000) NOT NULL,
+    origin_src VARCHAR(1000) NOT NULL,
+
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_episode_renditions_episode_id ON tbl_episode_renditions(episode_id);
+
+-- renditions are upserted by their upstream playlist
+CREATE UNIQUE INDEX IF NOT EXISTS uq_episode_renditions_origin ON tbl_episode_renditions(episode_id, origin_src);
+
+-- tallest rendition of the stored source, a rise means a better quality became available
+ALTER TABLE tbl_episodes
+ADD COLUMN IF NOT EXISTS max_height INT;
+
+-- set when a re-resolve finds a taller rendition than the stored one, admins filter the source list by it
+ALTER TABLE tbl_episodes
+ADD COLUMN IF NOT EXISTS quality_upgraded_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE tbl_episodes DROP COLUMN IF EXISTS quality_upgraded_at;
+ALTER TABLE tbl_episodes DROP COLUMN IF EXISTS max_height;
+DROP TABLE IF EXISTS tbl_episode_renditions;
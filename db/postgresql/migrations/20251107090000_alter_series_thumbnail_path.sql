@@ -0,0 +1,7 @@
+-- +goose Up
+-- local copy of the thumbnail, relative to IMAGE_STORAGE_DIR, thumbnail keeps the original url
+ALTER TABLE tbl_series
+ADD COLUMN IF NOT EXISTS thumbnail_path VARCHAR(255);
+
+-- +goose Down
+ALTER TABLE tbl_series DROP COLUMN IF EXISTS thumbnail_path;
@@ -0,0 +1,79 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS tbl_genres (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    slug VARCHAR(120) NOT NULL UNIQUE,
+
+    created_at TIMESTAMP DEFAULT NOW(),
+    created_by BIGINT,
+    updated_at TIMESTAMP,
+    updated_by BIGINT
+);
+
+CREATE TABLE IF NOT EXISTS tbl_tags (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    slug VARCHAR(120) NOT NULL UNIQUE,
+
+    created_at TIMESTAMP DEFAULT NOW(),
+    created_by BIGINT,
+    updated_at TIMESTAMP,
+    updated_by BIGINT
+);
+
+CREATE TABLE IF NOT EXISTS tbl_people (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    slug VARCHAR(280) NOT NULL UNIQUE,
+    thumbnail VARCHAR(500),
+
+    created_at TIMESTAMP DEFAULT NOW(),
+    created_by BIGINT,
+    updated_at TIMESTAMP,
+    updated_by BIGINT
+);
+
+-- source is 'scrape' or 'manual', a scrape never replaces the links an admin set by hand
+CREATE TABLE IF NOT EXISTS tbl_serie_genres (
+    series_id BIGINT NOT NULL REFERENCES tbl_series(id) ON DELETE CASCADE,
+    genre_id INT NOT NULL REFERENCES tbl_genres(id) ON DELETE CASCADE,
+    source VARCHAR(20) NOT NULL DEFAULT 'scrape',
+    "order" INTEGER DEFAULT 1,
+    created_at TIMESTAMP DEFAULT NOW(),
+    created_by BIGINT,
+    PRIMARY KEY (series_id, genre_id)
+);
+
+CREATE TABLE IF NOT EXISTS tbl_serie_tags (
+    series_id BIGINT NOT NULL REFERENCES tbl_series(id) ON DELETE CASCADE,
+    tag_id INT NOT NULL REFERENCES tbl_tags(id) ON DELETE CASCADE,
+    source VARCHAR(20) NOT NULL DEFAULT 'scrape',
+    "order" INTEGER DEFAULT 1,
+    created_at TIMESTAMP DEFAULT NOW(),
+    created_by BIGINT,
+    PRIMARY KEY (series_id, tag_id)
+);
+
+CREATE TABLE IF NOT EXISTS tbl_serie_people (
+    series_id BIGINT NOT NULL REFERENCES tbl_series(id) ON DELETE CASCADE,
+    person_id INT NOT NULL REFERENCES tbl_people(id) ON DELETE CASCADE,
+    role VARCHAR(50) NOT NULL,
+    character_name VARCHAR(255),
+    source VARCHAR(20) NOT NULL DEFAULT 'scrape',
+    "order" INTEGER DEFAULT 1,
+    created_at TIMESTAMP DEFAULT NOW(),
+    created_by BIGINT,
+    PRIMARY KEY (series_id, person_id, role)
+);
+
+CREATE INDEX IF NOT EXISTS idx_serie_genres_genre_id ON tbl_serie_genres(genre_id);
+CREATE INDEX IF NOT EXISTS idx_serie_tags_tag_id ON tbl_serie_tags(tag_id);
+CREATE INDEX IF NOT EXISTS idx_serie_people_person_id ON tbl_serie_people(person_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS tbl_serie_people;
+DROP TABLE IF EXISTS tbl_serie_tags;
+DROP TABLE IF EXISTS tbl_serie_genres;
+DROP TABLE IF EXISTS tbl_people;
+DROP TABLE IF EXISTS tbl_tags;
+DROP TABLE IF EXISTS tbl_genres;
@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- kept up to date by postgres on every insert and upsert, 'simple' because titles come in many languages
+ALTER TABLE tbl_series
+ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', COALESCE(title, '')), 'A') ||
+    setweight(to_tsvector('simple', COALESCE(label, '')), 'B') ||
+    setweight(to_tsvector('simple', COALESCE(description, '')), 'C')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_series_search_vector ON tbl_series USING GIN (search_vector);
+-- typo tolerant title matching
+CREATE INDEX IF NOT EXISTS idx_series_title_trgm ON tbl_series USING GIN (title gin_trgm_ops);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_series_title_trgm;
+DROP INDEX IF EXISTS idx_series_search_vector;
+ALTER TABLE tbl_series DROP COLUMN IF EXISTS search_vector;
@@ -1,43 +1,201 @@
 package main
 
 import (
+	"bufio"
+	"flag"
 	"fmt"
-	"log"
 	"os"
+	"rerng_addicted_api/configs"
+	"rerng_addicted_api/internal/admin/history"
 	"rerng_addicted_api/internal/admin/scraping"
+	"rerng_addicted_api/pkg/browser"
+	"rerng_addicted_api/pkg/imagestore"
 	share "rerng_addicted_api/pkg/model"
+	"rerng_addicted_api/pkg/netguard"
+	"strconv"
+	"strings"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
 
+// series seeded when neither --keys nor --file is given
+var default_keys = []int{11698, 11764, 11694, 11739, 11521, 11526, 11706, 11705, 11674, 11652}
+
 func main() {
+	code, err := run()
+	if err != nil {
+		fmt.Println("❌", err)
+	}
+	os.Exit(code)
+}
+
+// run returns the exit code instead of exiting itself, so its deferred cleanup (the browsers above all) always runs
+func run() (int, error) {
+	keys_flag := flag.String("keys", "", "comma separated series keys to seed")
+	file_flag := flag.String("file", "", "file with one series key per line, # starts a comment")
+	provider := flag.String("provider", scraping.DefaultProvider, "source provider to scrape from")
+	dry_run := flag.Bool("dry-run", false, "scrape and print the diff without writing to the database")
+	only_missing := flag.Bool("only-missing", false, "skip series that are already stored")
+	parallel := flag.Int("parallel", 1, "number of series scraped at the same time")
+	flag.Parse()
+
+	keys, err := seedKeys(*keys_flag, *file_flag)
+	if err != nil {
+		return 2, err
+	}
+
 	// 1️⃣ Load environment variables
-	err := godotenv.Load()
+	err = godotenv.Load()
 	if err != nil {
-		log.Fatalf("Error loading .env file")
+		return 1, fmt.Errorf("error loading .env file: %w", err)
 	}
 
 	dbURL := os.Getenv("DATABASE_URL")
 	if dbURL == "" {
-		log.Fatal("❌ DATABASE_URL not set in environment")
+		return 1, fmt.Errorf("DATABASE_URL not set in environment")
 	}
 
 	// 2️⃣ Connect to PostgreSQL
 	db, err := sqlx.Connect("postgres", dbURL)
 	if err != nil {
-		log.Fatalf("❌ Failed to connect to DB: %v", err)
+		return 1, fmt.Errorf("failed to connect to DB: %w", err)
 	}
 	defer db.Close()
 
 	fmt.Println("✅ Connected to database successfully")
 
 	// 3️⃣ Create the repo instance
-	scraping := scraping.NewScrapingService(db, &share.UserContext{})
+	browser_pool := browser.NewPool()
+	defer browser_pool.Close()
+
+	providers := scraping.NewProviders(scraping.ProviderDeps{
+		Browser:  browser_pool,
+		Guard:    netguard.Default(),
+		BaseURLs: configs.Scraping().BaseURLs,
+	})
+	scraping_service := scraping.NewScrapingService(db, &share.UserContext{}, providers, nil)
+	scraping_service.Trigger = history.TriggerSeed
+	scraping_service.Images = imagestore.NewStore(configs.Image(), netguard.Default())
 
 	// 4️⃣ Run seeding process
-	scraping.Seed()
+	if *dry_run {
+		fmt.Println("🧪 Dry run, nothing will be written")
+	}
+	fmt.Printf("🌱 Seeding %d series from %s with %d worker(s)\n", len(keys), *provider, *parallel)
+
+	report, seed_err := scraping_service.Seed(*provider, scraping.SeedOptions{
+		Keys:        keys,
+		DryRun:      *dry_run,
+		OnlyMissing: *only_missing,
+		Parallel:    *parallel,
+	})
+	if seed_err != nil {
+		return 1, fmt.Errorf("seeding failed: %w", seed_err.Err)
+	}
 
+	printReport(report)
+
+	if report.HasFailures() {
+		fmt.Println("⚠️ Seeding finished with failures")
+		return 1, nil
+	}
 	fmt.Println("🎬 Done seeding all data.")
+	return 0, nil
+}
+
+// seedKeys merges keys from --keys and --file, falling back to the default list
+func seedKeys(keys_flag, file_flag string) ([]int, error) {
+	raw := []string{}
+	if keys_flag != "" {
+		raw = append(raw, strings.Split(keys_flag, ",")...)
+	}
+
+	if file_flag != "" {
+		file, err := os.Open(file_flag)
+		if err != nil {
+			return nil, fmt.Errorf("failed opening key file: %w", err)
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if i := strings.Index(line, "#"); i >= 0 {
+				line = line[:i]
+			}
+			raw = append(raw, line)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed reading key file: %w", err)
+		}
+	}
+
+	if keys_flag == "" && file_flag == "" {
+		return default_keys, nil
+	}
+
+	keys := []int{}
+	seen := map[int]bool{}
+	for _, value := range raw {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		key, err := strconv.Atoi(value)
+		if err != nil || key <= 0 {
+			return nil, fmt.Errorf("invalid series key %q", value)
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no series keys given")
+	}
+	return keys, nil
+}
+
+func printReport(report *scraping.SeedReport) {
+	fmt.Println("\n📋 Seed diff")
+	for _, result := range report.Results {
+		title := result.Title
+		if title == "" {
+			title = "-"
+		}
+		fmt.Printf("  [%s] %d %s\n", result.State, result.SerieID, title)
+		if result.Error != "" {
+			fmt.Printf("      error: %s\n", result.Error)
+		}
+		if len(result.NewEpisodes) > 0 {
+			fmt.Printf("      + new episodes: %s\n", formatNumbers(result.NewEpisodes))
+		}
+		if len(result.ChangedSources) > 0 {
+			fmt.Printf("      ~ changed sources: %s\n", formatNumbers(result.ChangedSources))
+		}
+		if len(result.FailedEpisodes) > 0 {
+			fmt.Printf("      ! failed episodes: %s\n", formatNumbers(result.FailedEpisodes))
+		}
+	}
+
+	fmt.Println("\n📊 Summary")
+	fmt.Printf("  new series:      %d\n", report.New)
+	fmt.Printf("  updated series:  %d\n", report.Updated)
+	fmt.Printf("  unchanged:       %d\n", report.Unchanged)
+	fmt.Printf("  skipped:         %d\n", report.Skipped)
+	fmt.Printf("  failed series:   %d\n", report.Failed)
+	fmt.Printf("  new episodes:    %d\n", report.NewEpisodes)
+	fmt.Printf("  changed sources: %d\n", report.ChangedSources)
+	fmt.Printf("  failed episodes: %d\n", report.FailedEpisodes)
+}
+
+func formatNumbers(numbers []float64) string {
+	parts := make([]string, len(numbers))
+	for i, number := range numbers {
+		parts[i] = strconv.FormatFloat(number, 'f', -1, 64)
+	}
+	return strings.Join(parts, ", ")
 }
@@ -1,11 +1,24 @@
 package handler
 
 import (
+	"rerng_addicted_api/configs"
 	"rerng_addicted_api/internal/admin/auth"
+	"rerng_addicted_api/internal/admin/history"
+	"rerng_addicted_api/internal/admin/job"
 	scraping "rerng_addicted_api/internal/admin/scraping"
+	"rerng_addicted_api/internal/admin/serie"
+	"rerng_addicted_api/internal/admin/source"
 	auth_front "rerng_addicted_api/internal/front/auth"
+	serie_front "rerng_addicted_api/internal/front/serie"
 	"rerng_addicted_api/internal/front/user"
+	"rerng_addicted_api/internal/shared/image"
 	"rerng_addicted_api/internal/shared/proxy"
+	"rerng_addicted_api/pkg/browser"
+	"rerng_addicted_api/pkg/imagestore"
+	"rerng_addicted_api/pkg/netguard"
+	"rerng_addicted_api/pkg/proxyurl"
+	"rerng_addicted_api/pkg/redis"
+	"rerng_addicted_api/pkg/segcache"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/jmoiron/sqlx"
@@ -20,52 +33,95 @@ type ServiceHandler struct {
 
 // register modules route to front service
 type FrontService struct {
-	AuthRoute *auth_front.AuthRoute
-	UserRoute *user.UserRoute
+	AuthRoute  *auth_front.AuthRoute
+	UserRoute  *user.UserRoute
+	SerieRoute *serie_front.SerieRoute
 }
 
 // register modules route to admin service
 type AdminService struct {
 	AuthRoute     *auth.AuthRoute
 	ScrapingRoute *scraping.ScrapingRoute
+	JobRoute      *job.JobRoute
+	SourceRoute   *source.SourceRoute
+	HistoryRoute  *history.HistoryRoute
+	SerieRoute    *serie.SerieRoute
 }
 
 type SharedService struct {
 	ProxyRoute *proxy.ProxyRoute
+	ImageRoute *image.ImageRoute
 }
 
 func NewFrontService(app *fiber.App, db_pool *sqlx.DB) *FrontService {
 	au := auth_front.NewRoute(app, db_pool).RegisterAuthRoute()
 	user := user.NewUserRoute(app, db_pool).RegisterUserRoute()
+	catalog_config := configs.Catalog()
+	popular := serie_front.NewPopularQueries(redis.NewOptionalRedis(), catalog_config)
+	se := serie_front.NewRoute(app, db_pool, popular, catalog_config).RegisterSerieRoute()
 
 	return &FrontService{
-		AuthRoute: au,
-		UserRoute: user,
+		AuthRoute:  au,
+		UserRoute:  user,
+		SerieRoute: se,
 	}
 }
 
-func NewAdminService(app *fiber.App, db_pool *sqlx.DB) *AdminService {
+func NewAdminService(app *fiber.App, db_pool *sqlx.DB, browser_pool *browser.Pool, images *imagestore.Store) *AdminService {
+	// one provider set shared by the scraping endpoints and the background job worker
+	scraping_config := configs.Scraping()
+	providers := scraping.NewProviders(scraping.ProviderDeps{
+		Browser:  browser_pool,
+		Guard:    netguard.Default(),
+		BaseURLs: scraping_config.BaseURLs,
+	})
+	cache := scraping.NewResponseCache(redis.NewOptionalRedis(), scraping_config)
+	worker := job.NewWorker(db_pool, providers, images, configs.Job()).Start()
+	scheduler := job.NewScheduler(db_pool, worker, configs.Refresh()).Start()
+	monitor := source.NewMonitor(db_pool, providers, configs.Source()).Start()
+
 	au := auth.NewRoute(app, db_pool).RegisterAuthRoute()
-	sc := scraping.NewRoute(app, db_pool).RegisterScrapingRoute()
+	jb := job.NewRoute(app, db_pool, worker, scheduler).RegisterJobRoute()
+	sc := scraping.NewRoute(app, db_pool, providers, cache, images, browser_pool).RegisterScrapingRoute()
+	so := source.NewRoute(app, db_pool, monitor).RegisterSourceRoute()
+	hi := history.NewRoute(app, db_pool).RegisterHistoryRoute()
+	se := serie.NewRoute(app, db_pool).RegisterSerieRoute()
 
 	return &AdminService{
 		AuthRoute:     au,
 		ScrapingRoute: sc,
+		JobRoute:      jb,
+		SourceRoute:   so,
+		HistoryRoute:  hi,
+		SerieRoute:    se,
 	}
 }
 
-func NewSharedService(app *fiber.App, db_pool *sqlx.DB) *SharedService {
-	pr := proxy.NewRoute(app, db_pool).RegisterProxyRoute()
+func NewSharedService(app *fiber.App, db_pool *sqlx.DB, browser_pool *browser.Pool, images *imagestore.Store) *SharedService {
+	proxy_config := configs.Proxy()
+	proxy_deps := proxy.ProxyDeps{
+		Signer:   proxyurl.Default(),
+		Guard:    netguard.Default(),
+		Segments: segcache.NewCache(proxy_config),
+		// instances share discovered media urls through redis when there is one
+		Redis: redis.NewOptionalRedis(),
+	}
+	pr := proxy.NewRoute(app, db_pool, browser_pool, proxy_deps, proxy_config).RegisterProxyRoute()
+	im := image.NewRoute(app, db_pool, images, configs.Image()).RegisterImageRoute()
 
 	return &SharedService{
 		ProxyRoute: pr,
+		ImageRoute: im,
 	}
 }
 
-func NewServiceHandlers(app *fiber.App, db_pool *sqlx.DB) *ServiceHandler {
+func NewServiceHandlers(app *fiber.App, db_pool *sqlx.DB, browser_pool *browser.Pool) *ServiceHandler {
+	// the scrapers mirror into the same store the image route serves from
+	images := imagestore.NewStore(configs.Image(), netguard.Default())
+
 	front := NewFrontService(app, db_pool)
-	admin := NewAdminService(app, db_pool)
-	shared := NewSharedService(app, db_pool)
+	admin := NewAdminService(app, db_pool, browser_pool, images)
+	shared := NewSharedService(app, db_pool, browser_pool, images)
 
 	return &ServiceHandler{
 		Front:  front,
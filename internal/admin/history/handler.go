@@ -0,0 +1,174 @@
+package history
+
+import (
+	"fmt"
+	"net/http"
+	response "rerng_addicted_api/pkg/http/response"
+	custom_log "rerng_addicted_api/pkg/logs"
+	types "rerng_addicted_api/pkg/model"
+	"rerng_addicted_api/pkg/utils"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jmoiron/sqlx"
+)
+
+type HistoryHandler struct {
+	DBPool         *sqlx.DB
+	HistoryService func(c *fiber.Ctx) *HistoryService
+}
+
+func NewHistoryHandler(db_pool *sqlx.DB) *HistoryHandler {
+	return &HistoryHandler{
+		DBPool: db_pool,
+		HistoryService: func(c *fiber.Ctx) *HistoryService {
+			var uCtx types.UserContext
+			// convert map to UserContext struct
+			uCtx, ok := c.Locals("UserContext").(types.UserContext)
+			if !ok {
+				custom_log.NewCustomLog("user_context_failed", "UserContext missing or invalid", "warn")
+				uCtx = types.UserContext{}
+			}
+
+			return NewHistoryService(db_pool, &uCtx)
+		},
+	}
+}
+
+func (hh *HistoryHandler) ShowRuns(c *fiber.Ctx) error {
+	var req HistoryShowRequest
+
+	v := utils.NewValidator()
+	if err := req.bind(c, v); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("scrape_run_show_failed", nil, c),
+				-2300,
+				err,
+			),
+		)
+	}
+
+	resp, err := hh.HistoryService(c).ShowRuns(req)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate(err.MessageID, nil, c),
+				-2300,
+				fmt.Errorf("%s", utils.Translate(err.Err.Error(), nil, c)),
+			),
+		)
+	}
+
+	return c.Status(http.StatusOK).JSON(
+		response.NewResponseWithPaging(
+			utils.Translate("scrape_run_show_success", nil, c),
+			2300,
+			resp,
+			req.PageOptions.Page,
+			req.PageOptions.Perpage,
+			resp.Total,
+		),
+	)
+}
+
+func (hh *HistoryHandler) ShowRun(c *fiber.Ctx) error {
+	id, err_con := strconv.Atoi(c.Params("id"))
+	if err_con != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("scrape_run_show_failed", nil, c),
+				-2301,
+				fmt.Errorf("%s", utils.Translate("invalid_scrape_run_id", nil, c)),
+			),
+		)
+	}
+
+	resp, err := hh.HistoryService(c).ShowRun(id)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate(err.MessageID, nil, c),
+				-2301,
+				fmt.Errorf("%s", utils.Translate(err.Err.Error(), nil, c)),
+			),
+		)
+	}
+
+	return c.Status(http.StatusOK).JSON(
+		response.NewResponse(
+			utils.Translate("scrape_run_show_success", nil, c),
+			2301,
+			resp,
+		),
+	)
+}
+
+func (hh *HistoryHandler) ShowChanges(c *fiber.Ctx) error {
+	var req HistoryShowRequest
+
+	v := utils.NewValidator()
+	if err := req.bind(c, v); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("catalog_change_show_failed", nil, c),
+				-2302,
+				err,
+			),
+		)
+	}
+
+	resp, err := hh.HistoryService(c).ShowChanges(req)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate(err.MessageID, nil, c),
+				-2302,
+				fmt.Errorf("%s", utils.Translate(err.Err.Error(), nil, c)),
+			),
+		)
+	}
+
+	return c.Status(http.StatusOK).JSON(
+		response.NewResponseWithPaging(
+			utils.Translate("catalog_change_show_success", nil, c),
+			2302,
+			resp,
+			req.PageOptions.Page,
+			req.PageOptions.Perpage,
+			resp.Total,
+		),
+	)
+}
+
+func (hh *HistoryHandler) Rollback(c *fiber.Ctx) error {
+	id, err_con := strconv.Atoi(c.Params("id"))
+	if err_con != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("scrape_run_rollback_failed", nil, c),
+				-2303,
+				fmt.Errorf("%s", utils.Translate("invalid_scrape_run_id", nil, c)),
+			),
+		)
+	}
+
+	resp, err := hh.HistoryService(c).Rollback(id)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate(err.MessageID, nil, c),
+				-2303,
+				fmt.Errorf("%s", utils.Translate(err.Err.Error(), nil, c)),
+			),
+		)
+	}
+
+	return c.Status(http.StatusOK).JSON(
+		response.NewResponse(
+			utils.Translate("scrape_run_rollback_success", nil, c),
+			2303,
+			resp,
+		),
+	)
+}
@@ -0,0 +1,122 @@
+package history
+
+import (
+	"fmt"
+	types "rerng_addicted_api/pkg/model"
+	"rerng_addicted_api/pkg/utils"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// what started a scrape run
+const (
+	TriggerAPI    = "api"
+	TriggerJob    = "job"
+	TriggerSeed   = "seed"
+	TriggerSource = "source"
+)
+
+// scrape run states
+const (
+	RunRunning    = "running"
+	RunSucceeded  = "succeeded"
+	RunFailed     = "failed"
+	RunRolledBack = "rolled_back"
+)
+
+type ScrapeRun struct {
+	ID               int        `db:"id" json:"id"`
+	Trigger          string     `db:"trigger" json:"trigger"`
+	Provider         string     `db:"provider" json:"provider"`
+	SeriesKey        int        `db:"series_key" json:"series_key"`
+	EpisodeNumber    float64    `db:"episode_number" json:"episode_number"`
+	State            string     `db:"state" json:"state"`
+	SeriesUpserted   int        `db:"series_upserted" json:"series_upserted"`
+	EpisodesUpserted int        `db:"episodes_upserted" json:"episodes_upserted"`
+	EpisodesFailed   int        `db:"episodes_failed" json:"episodes_failed"`
+	ChangesCount     int        `db:"changes_count" json:"changes_count"`
+	Error            *string    `db:"error" json:"error"`
+	StartedAt        time.Time  `db:"started_at" json:"started_at"`
+	FinishedAt       *time.Time `db:"finished_at" json:"finished_at"`
+	DurationMs       *int64     `db:"duration_ms" json:"duration_ms"`
+	RolledBackAt     *time.Time `db:"rolled_back_at" json:"rolled_back_at"`
+	RolledBackBy     *int       `db:"rolled_back_by" json:"rolled_back_by"`
+	CreatedBy        *int       `db:"created_by" json:"created_by"`
+}
+
+// RunCounts is what a finished scrape run reports about its writes
+type RunCounts struct {
+	SeriesUpserted   int
+	EpisodesUpserted int
+	EpisodesFailed   int
+}
+
+type CatalogChange struct {
+	ID         int       `db:"id" json:"id"`
+	RunID      *int      `db:"run_id" json:"run_id"`
+	Entity     string    `db:"entity" json:"entity"`
+	EntityID   int       `db:"entity_id" json:"entity_id"`
+	SeriesID   int       `db:"series_id" json:"series_id"`
+	Action     string    `db:"action" json:"action"`
+	Field      *string   `db:"field" json:"field"`
+	OldValue   *string   `db:"old_value" json:"old_value"`
+	NewValue   *string   `db:"new_value" json:"new_value"`
+	RolledBack bool      `db:"rolled_back" json:"rolled_back"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+	CreatedBy  *int      `db:"created_by" json:"created_by"`
+}
+
+type ScrapeRunDetail struct {
+	ScrapeRun
+	Changes []CatalogChange `json:"changes"`
+}
+
+type ScrapeRunsResponse struct {
+	ScrapeRuns []ScrapeRun `json:"scrape_runs"`
+	Total      int         `json:"-"`
+}
+
+type ScrapeRunDetailResponse struct {
+	ScrapeRuns []ScrapeRunDetail `json:"scrape_runs"`
+}
+
+type CatalogChangesResponse struct {
+	CatalogChanges []CatalogChange `json:"catalog_changes"`
+	Total          int             `json:"-"`
+}
+
+type RollbackResponse struct {
+	RunID    int `json:"run_id"`
+	Reverted int `json:"reverted"`
+	// Conflicts are changes left alone because the value was changed again after this run
+	Conflicts []CatalogChange `json:"conflicts"`
+}
+
+type HistoryShowRequest struct {
+	PageOptions types.Paging   `json:"paging_options" query:"paging_options" validate:"required"`
+	Sorts       []types.Sort   `json:"sorts,omitempty" query:"sorts"`
+	Filters     []types.Filter `json:"filters,omitempty" query:"filters"`
+}
+
+func (r *HistoryShowRequest) bind(c *fiber.Ctx, v *utils.Validator) error {
+	if err := c.QueryParser(r); err != nil {
+		return err
+	}
+
+	// fix `Filter.Value` nil when http query params failed parse to json type `interface{}`
+	for i := range r.Filters {
+		value := c.Query(fmt.Sprintf("filters[%d][value]", i))
+		if int_value, err := strconv.Atoi(value); err == nil {
+			r.Filters[i].Value = int_value
+		} else {
+			r.Filters[i].Value = value
+		}
+	}
+
+	if err := v.Validate(r, c); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,340 @@
+package history
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"rerng_addicted_api/internal/admin/serie"
+	custom_log "rerng_addicted_api/pkg/logs"
+	types "rerng_addicted_api/pkg/model"
+	postgres "rerng_addicted_api/pkg/postgres"
+	"rerng_addicted_api/pkg/responses"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const run_columns = `
+	r.id, r.trigger, r.provider, r.series_key, r.episode_number, r.state,
+	r.series_upserted, r.episodes_upserted, r.episodes_failed, r.changes_count, r.error,
+	r.started_at, r.finished_at, r.duration_ms, r.rolled_back_at, r.rolled_back_by, r.created_by`
+
+const change_columns = `
+	c.id, c.run_id, c.entity, c.entity_id, c.series_id, c.action, c.field,
+	c.old_value, c.new_value, c.rolled_back, c.created_at, c.created_by`
+
+// columns admins may filter and sort the run list by
+var run_list_columns = map[string]bool{
+	"r.id":             true,
+	"r.trigger":        true,
+	"r.provider":       true,
+	"r.series_key":     true,
+	"r.episode_number": true,
+	"r.state":          true,
+	"r.started_at":     true,
+	"r.duration_ms":    true,
+	"r.created_by":     true,
+}
+
+// columns admins may filter and sort the change list by
+var change_list_columns = map[string]bool{
+	"c.id":         true,
+	"c.run_id":     true,
+	"c.entity":     true,
+	"c.entity_id":  true,
+	"c.series_id":  true,
+	"c.action":     true,
+	"c.field":      true,
+	"c.created_at": true,
+}
+
+// tables and columns a rollback may write, per change entity
+var rollback_targets = map[string]struct {
+	table  string
+	fields []string
+}{
+	serie.EntitySerie:   {"tbl_series", serie.TrackedSerieFields},
+	serie.EntityEpisode: {"tbl_episodes", serie.TrackedEpisodeFields},
+}
+
+type HistoryRepo interface {
+	ShowRuns(req HistoryShowRequest) (*ScrapeRunsResponse, *responses.ErrorResponse)
+	ShowRun(id int) (*ScrapeRunDetailResponse, *responses.ErrorResponse)
+	ShowChanges(req HistoryShowRequest) (*CatalogChangesResponse, *responses.ErrorResponse)
+	Rollback(id int) (*RollbackResponse, *responses.ErrorResponse)
+}
+
+type HistoryRepoImpl struct {
+	DBPool      *sqlx.DB
+	UserContext *types.UserContext
+}
+
+func NewHistoryRepoImpl(db_pool *sqlx.DB, user_context *types.UserContext) *HistoryRepoImpl {
+	return &HistoryRepoImpl{
+		DBPool:      db_pool,
+		UserContext: user_context,
+	}
+}
+
+// allowed keeps only the filters and sorts on allowlisted columns and falls back to newest first
+func allowed(req HistoryShowRequest, columns map[string]bool, default_sort string) ([]types.Filter, []types.Sort) {
+	filters := []types.Filter{}
+	for _, f := range req.Filters {
+		if columns[f.Property] {
+			filters = append(filters, f)
+		}
+	}
+	sorts := []types.Sort{}
+	for _, s := range req.Sorts {
+		if columns[s.Property] {
+			sorts = append(sorts, s)
+		}
+	}
+	if len(sorts) == 0 {
+		sorts = append(sorts, types.Sort{Property: default_sort, Direction: "desc"})
+	}
+	return filters, sorts
+}
+
+func (h *HistoryRepoImpl) ShowRuns(req HistoryShowRequest) (*ScrapeRunsResponse, *responses.ErrorResponse) {
+	filters, sorts := allowed(req, run_list_columns, "r.id")
+
+	sql_limit := postgres.BuildPaging(req.PageOptions.Page, req.PageOptions.Perpage)
+	sql_order_by := postgres.BuildSQLSort(sorts)
+	sql_filters, args_filters := postgres.BuildSQLFilter(filters)
+
+	where_clause := ""
+	if sql_filters != "" {
+		where_clause = "WHERE " + sql_filters
+	}
+
+	runs := []ScrapeRun{}
+	query := fmt.Sprintf(`SELECT %s FROM tbl_scrape_runs r %s %s %s`, run_columns, where_clause, sql_order_by, sql_limit)
+	if err := h.DBPool.Select(&runs, query, args_filters...); err != nil {
+		custom_log.NewCustomLog("scrape_run_show_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("scrape_run_show_failed", fmt.Errorf("database_error"))
+	}
+
+	var total int
+	count_query := fmt.Sprintf(`SELECT COUNT(*) FROM tbl_scrape_runs r %s`, where_clause)
+	if err := h.DBPool.Get(&total, count_query, args_filters...); err != nil {
+		custom_log.NewCustomLog("scrape_run_show_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("scrape_run_show_failed", fmt.Errorf("database_error"))
+	}
+
+	return &ScrapeRunsResponse{
+		ScrapeRuns: runs,
+		Total:      total,
+	}, nil
+}
+
+func (h *HistoryRepoImpl) ShowRun(id int) (*ScrapeRunDetailResponse, *responses.ErrorResponse) {
+	var detail ScrapeRunDetail
+	err := h.DBPool.Get(&detail.ScrapeRun, fmt.Sprintf(`SELECT %s FROM tbl_scrape_runs r WHERE r.id = $1`, run_columns), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("scrape_run_show_failed", fmt.Errorf("scrape_run_not_found"))
+	}
+	if err != nil {
+		custom_log.NewCustomLog("scrape_run_show_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("scrape_run_show_failed", fmt.Errorf("database_error"))
+	}
+
+	detail.Changes = []CatalogChange{}
+	err = h.DBPool.Select(&detail.Changes, fmt.Sprintf(`SELECT %s FROM tbl_catalog_changes c WHERE c.run_id = $1 ORDER BY c.id`, change_columns), id)
+	if err != nil {
+		custom_log.NewCustomLog("scrape_run_show_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("scrape_run_show_failed", fmt.Errorf("database_error"))
+	}
+
+	return &ScrapeRunDetailResponse{
+		ScrapeRuns: []ScrapeRunDetail{detail},
+	}, nil
+}
+
+func (h *HistoryRepoImpl) ShowChanges(req HistoryShowRequest) (*CatalogChangesResponse, *responses.ErrorResponse) {
+	filters, sorts := allowed(req, change_list_columns, "c.id")
+
+	sql_limit := postgres.BuildPaging(req.PageOptions.Page, req.PageOptions.Perpage)
+	sql_order_by := postgres.BuildSQLSort(sorts)
+	sql_filters, args_filters := postgres.BuildSQLFilter(filters)
+
+	where_clause := ""
+	if sql_filters != "" {
+		where_clause = "WHERE " + sql_filters
+	}
+
+	changes := []CatalogChange{}
+	query := fmt.Sprintf(`SELECT %s FROM tbl_catalog_changes c %s %s %s`, change_columns, where_clause, sql_order_by, sql_limit)
+	if err := h.DBPool.Select(&changes, query, args_filters...); err != nil {
+		custom_log.NewCustomLog("catalog_change_show_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("catalog_change_show_failed", fmt.Errorf("database_error"))
+	}
+
+	var total int
+	count_query := fmt.Sprintf(`SELECT COUNT(*) FROM tbl_catalog_changes c %s`, where_clause)
+	if err := h.DBPool.Get(&total, count_query, args_filters...); err != nil {
+		custom_log.NewCustomLog("catalog_change_show_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("catalog_change_show_failed", fmt.Errorf("database_error"))
+	}
+
+	return &CatalogChangesResponse{
+		CatalogChanges: changes,
+		Total:          total,
+	}, nil
+}
+
+// StartRun opens a history row for one scrape, the caller closes it with FinishRun
+func (h *HistoryRepoImpl) StartRun(trigger string, provider string, series_key int, episode_number float64) (int, error) {
+	var id int
+	err := h.DBPool.Get(&id, `
+		INSERT INTO tbl_scrape_runs (trigger, provider, series_key, episode_number, state, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, trigger, provider, series_key, episode_number, RunRunning, h.UserContext.Id)
+	if err != nil {
+		return 0, fmt.Errorf("failed starting scrape run: %w", err)
+	}
+	return id, nil
+}
+
+// FinishRun stores the outcome and counts, the change count comes from the recorded changes
+func (h *HistoryRepoImpl) FinishRun(id int, counts RunCounts, run_err string) error {
+	state := RunSucceeded
+	var err_value *string
+	if run_err != "" {
+		state = RunFailed
+		err_value = &run_err
+	}
+
+	_, err := h.DBPool.Exec(`
+		UPDATE tbl_scrape_runs
+		SET state = $2, series_upserted = $3, episodes_upserted = $4, episodes_failed = $5, error = $6,
+			changes_count = (SELECT COUNT(*) FROM tbl_catalog_changes WHERE run_id = $1),
+			finished_at = NOW(),
+			duration_ms = (EXTRACT(EPOCH FROM (NOW() - started_at)) * 1000)::BIGINT
+		WHERE id = $1
+	`, id, state, counts.SeriesUpserted, counts.EpisodesUpserted, counts.EpisodesFailed, err_value)
+	if err != nil {
+		return fmt.Errorf("failed finishing scrape run %d: %w", id, err)
+	}
+	return nil
+}
+
+// Rollback restores the values a run overwrote and soft deletes the rows it created. A field that was
+// changed again after the run is left alone and reported as a conflict.
+func (h *HistoryRepoImpl) Rollback(id int) (*RollbackResponse, *responses.ErrorResponse) {
+	tx, err := h.DBPool.Beginx()
+	if err != nil {
+		custom_log.NewCustomLog("scrape_run_rollback_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("scrape_run_rollback_failed", fmt.Errorf("database_error"))
+	}
+	defer tx.Rollback()
+
+	var state string
+	err = tx.Get(&state, `SELECT state FROM tbl_scrape_runs WHERE id = $1 FOR UPDATE`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("scrape_run_rollback_failed", fmt.Errorf("scrape_run_not_found"))
+	}
+	if err != nil {
+		custom_log.NewCustomLog("scrape_run_rollback_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("scrape_run_rollback_failed", fmt.Errorf("database_error"))
+	}
+	switch state {
+	case RunRunning:
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("scrape_run_rollback_failed", fmt.Errorf("scrape_run_still_running"))
+	case RunRolledBack:
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("scrape_run_rollback_failed", fmt.Errorf("scrape_run_already_rolled_back"))
+	}
+
+	changes := []CatalogChange{}
+	err = tx.Select(&changes, fmt.Sprintf(`
+		SELECT %s FROM tbl_catalog_changes c
+		WHERE c.run_id = $1 AND NOT c.rolled_back
+		ORDER BY c.id DESC
+	`, change_columns), id)
+	if err != nil {
+		custom_log.NewCustomLog("scrape_run_rollback_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("scrape_run_rollback_failed", fmt.Errorf("database_error"))
+	}
+
+	resp := &RollbackResponse{
+		RunID:     id,
+		Conflicts: []CatalogChange{},
+	}
+	for _, change := range changes {
+		reverted, err := h.revert(tx, change)
+		if err != nil {
+			custom_log.NewCustomLog("scrape_run_rollback_failed", err.Error(), "error")
+			return nil, (&responses.ErrorResponse{}).NewErrorResponse("scrape_run_rollback_failed", fmt.Errorf("database_error"))
+		}
+		if !reverted {
+			resp.Conflicts = append(resp.Conflicts, change)
+			continue
+		}
+
+		if _, err := tx.Exec(`UPDATE tbl_catalog_changes SET rolled_back = TRUE WHERE id = $1`, change.ID); err != nil {
+			custom_log.NewCustomLog("scrape_run_rollback_failed", err.Error(), "error")
+			return nil, (&responses.ErrorResponse{}).NewErrorResponse("scrape_run_rollback_failed", fmt.Errorf("database_error"))
+		}
+		resp.Reverted++
+	}
+
+	_, err = tx.Exec(`
+		UPDATE tbl_scrape_runs SET state = $2, rolled_back_at = NOW(), rolled_back_by = $3 WHERE id = $1
+	`, id, RunRolledBack, h.UserContext.Id)
+	if err != nil {
+		custom_log.NewCustomLog("scrape_run_rollback_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("scrape_run_rollback_failed", fmt.Errorf("database_error"))
+	}
+
+	if err := tx.Commit(); err != nil {
+		custom_log.NewCustomLog("scrape_run_rollback_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("scrape_run_rollback_failed", fmt.Errorf("database_error"))
+	}
+	return resp, nil
+}
+
+// revert undoes one change, it returns false when the row no longer holds what the run wrote
+func (h *HistoryRepoImpl) revert(tx *sqlx.Tx, change CatalogChange) (bool, error) {
+	target, ok := rollback_targets[change.Entity]
+	if !ok {
+		return false, nil
+	}
+
+	var res sql.Result
+	var err error
+	switch change.Action {
+	case serie.ActionInsert:
+		res, err = tx.Exec(fmt.Sprintf(`
+			UPDATE %s SET deleted_at = NOW(), deleted_by = $2 WHERE id = $1 AND deleted_at IS NULL
+		`, target.table), change.EntityID, h.UserContext.Id)
+	case serie.ActionUpdate:
+		if change.Field == nil || !contains(target.fields, *change.Field) {
+			return false, nil
+		}
+		field := *change.Field
+		res, err = tx.Exec(fmt.Sprintf(`
+			UPDATE %s SET %s = $2, updated_at = NOW(), updated_by = $4
+			WHERE id = $1 AND %s::text IS NOT DISTINCT FROM $3::text
+		`, target.table, field, field), change.EntityID, change.OldValue, change.NewValue, h.UserContext.Id)
+	default:
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed reverting change %d: %w", change.ID, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
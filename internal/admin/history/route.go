@@ -0,0 +1,34 @@
+package history
+
+import (
+	"rerng_addicted_api/pkg/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jmoiron/sqlx"
+)
+
+type HistoryRoute struct {
+	App            *fiber.App
+	DBPool         *sqlx.DB
+	HistoryHandler *HistoryHandler
+}
+
+func NewRoute(app *fiber.App, db_pool *sqlx.DB) *HistoryRoute {
+	return &HistoryRoute{
+		App:            app,
+		DBPool:         db_pool,
+		HistoryHandler: NewHistoryHandler(db_pool),
+	}
+}
+
+func (hr *HistoryRoute) RegisterHistoryRoute() *HistoryRoute {
+	// the scraping prefix also serves public endpoints, so the middleware goes on each route
+	history := hr.App.Group("/api/v1/admin/scraping")
+
+	history.Get("/runs", middlewares.NewJwtMiddleware(hr.DBPool), hr.HistoryHandler.ShowRuns)
+	history.Get("/runs/:id", middlewares.NewJwtMiddleware(hr.DBPool), hr.HistoryHandler.ShowRun)
+	history.Post("/runs/:id/rollback", middlewares.NewJwtMiddleware(hr.DBPool), hr.HistoryHandler.Rollback)
+	history.Get("/changes", middlewares.NewJwtMiddleware(hr.DBPool), hr.HistoryHandler.ShowChanges)
+
+	return hr
+}
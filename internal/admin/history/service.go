@@ -0,0 +1,45 @@
+package history
+
+import (
+	types "rerng_addicted_api/pkg/model"
+	"rerng_addicted_api/pkg/responses"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type HistoryServiceCreator interface {
+	ShowRuns(req HistoryShowRequest) (*ScrapeRunsResponse, *responses.ErrorResponse)
+	ShowRun(id int) (*ScrapeRunDetailResponse, *responses.ErrorResponse)
+	ShowChanges(req HistoryShowRequest) (*CatalogChangesResponse, *responses.ErrorResponse)
+	Rollback(id int) (*RollbackResponse, *responses.ErrorResponse)
+}
+
+type HistoryService struct {
+	DBPool      *sqlx.DB
+	UserContext *types.UserContext
+	HistoryRepo *HistoryRepoImpl
+}
+
+func NewHistoryService(db_pool *sqlx.DB, user_context *types.UserContext) *HistoryService {
+	return &HistoryService{
+		DBPool:      db_pool,
+		UserContext: user_context,
+		HistoryRepo: NewHistoryRepoImpl(db_pool, user_context),
+	}
+}
+
+func (h *HistoryService) ShowRuns(req HistoryShowRequest) (*ScrapeRunsResponse, *responses.ErrorResponse) {
+	return h.HistoryRepo.ShowRuns(req)
+}
+
+func (h *HistoryService) ShowRun(id int) (*ScrapeRunDetailResponse, *responses.ErrorResponse) {
+	return h.HistoryRepo.ShowRun(id)
+}
+
+func (h *HistoryService) ShowChanges(req HistoryShowRequest) (*CatalogChangesResponse, *responses.ErrorResponse) {
+	return h.HistoryRepo.ShowChanges(req)
+}
+
+func (h *HistoryService) Rollback(id int) (*RollbackResponse, *responses.ErrorResponse) {
+	return h.HistoryRepo.Rollback(id)
+}
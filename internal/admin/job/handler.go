@@ -0,0 +1,261 @@
+package job
+
+import (
+	"fmt"
+	"net/http"
+	response "rerng_addicted_api/pkg/http/response"
+	custom_log "rerng_addicted_api/pkg/logs"
+	types "rerng_addicted_api/pkg/model"
+	"rerng_addicted_api/pkg/utils"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jmoiron/sqlx"
+)
+
+type JobHandler struct {
+	DBPool     *sqlx.DB
+	JobService func(c *fiber.Ctx) *JobService
+}
+
+func NewJobHandler(db_pool *sqlx.DB, worker *Worker, scheduler *Scheduler) *JobHandler {
+	return &JobHandler{
+		DBPool: db_pool,
+		JobService: func(c *fiber.Ctx) *JobService {
+			var uCtx types.UserContext
+			// convert map to UserContext struct
+			uCtx, ok := c.Locals("UserContext").(types.UserContext)
+			if !ok {
+				custom_log.NewCustomLog("user_context_failed", "UserContext missing or invalid", "warn")
+				uCtx = types.UserContext{}
+			}
+
+			return NewJobService(db_pool, &uCtx, worker, scheduler)
+		},
+	}
+}
+
+func (jh *JobHandler) Show(c *fiber.Ctx) error {
+	var req JobShowRequest
+
+	v := utils.NewValidator()
+	if err := req.bind(c, v); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("job_show_failed", nil, c),
+				-2100,
+				err,
+			),
+		)
+	}
+
+	resp, err := jh.JobService(c).Show(req)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate(err.MessageID, nil, c),
+				-2100,
+				fmt.Errorf("%s", utils.Translate(err.Err.Error(), nil, c)),
+			),
+		)
+	}
+
+	return c.Status(http.StatusOK).JSON(
+		response.NewResponseWithPaging(
+			utils.Translate("job_show_success", nil, c),
+			2100,
+			resp,
+			req.PageOptions.Page,
+			req.PageOptions.Perpage,
+			resp.Total,
+		),
+	)
+}
+
+func (jh *JobHandler) ShowOne(c *fiber.Ctx) error {
+	id, err_con := strconv.Atoi(c.Params("id"))
+	if err_con != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("job_show_failed", nil, c),
+				-2101,
+				fmt.Errorf("%s", utils.Translate("invalid_job_id", nil, c)),
+			),
+		)
+	}
+
+	resp, err := jh.JobService(c).ShowOne(id)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate(err.MessageID, nil, c),
+				-2101,
+				fmt.Errorf("%s", utils.Translate(err.Err.Error(), nil, c)),
+			),
+		)
+	}
+
+	return c.Status(http.StatusOK).JSON(
+		response.NewResponse(
+			utils.Translate("job_show_success", nil, c),
+			2101,
+			resp,
+		),
+	)
+}
+
+func (jh *JobHandler) Create(c *fiber.Ctx) error {
+	var req JobNewRequest
+
+	v := utils.NewValidator()
+	if err := req.bind(c, v); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("job_create_failed", nil, c),
+				-2102,
+				err,
+			),
+		)
+	}
+
+	resp, err := jh.JobService(c).Create(req)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate(err.MessageID, nil, c),
+				-2102,
+				fmt.Errorf("%s", utils.Translate(err.Err.Error(), nil, c)),
+			),
+		)
+	}
+
+	return c.Status(http.StatusOK).JSON(
+		response.NewResponse(
+			utils.Translate("job_create_success", nil, c),
+			2102,
+			resp,
+		),
+	)
+}
+
+func (jh *JobHandler) Cancel(c *fiber.Ctx) error {
+	id, err_con := strconv.Atoi(c.Params("id"))
+	if err_con != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("job_cancel_failed", nil, c),
+				-2103,
+				fmt.Errorf("%s", utils.Translate("invalid_job_id", nil, c)),
+			),
+		)
+	}
+
+	resp, err := jh.JobService(c).Cancel(id)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate(err.MessageID, nil, c),
+				-2103,
+				fmt.Errorf("%s", utils.Translate(err.Err.Error(), nil, c)),
+			),
+		)
+	}
+
+	return c.Status(http.StatusOK).JSON(
+		response.NewResponse(
+			utils.Translate("job_cancel_success", nil, c),
+			2103,
+			resp,
+		),
+	)
+}
+
+func (jh *JobHandler) Retry(c *fiber.Ctx) error {
+	id, err_con := strconv.Atoi(c.Params("id"))
+	if err_con != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("job_retry_failed", nil, c),
+				-2104,
+				fmt.Errorf("%s", utils.Translate("invalid_job_id", nil, c)),
+			),
+		)
+	}
+
+	resp, err := jh.JobService(c).Retry(id)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate(err.MessageID, nil, c),
+				-2104,
+				fmt.Errorf("%s", utils.Translate(err.Err.Error(), nil, c)),
+			),
+		)
+	}
+
+	return c.Status(http.StatusOK).JSON(
+		response.NewResponse(
+			utils.Translate("job_retry_success", nil, c),
+			2104,
+			resp,
+		),
+	)
+}
+
+func (jh *JobHandler) ShowRefreshRuns(c *fiber.Ctx) error {
+	var req JobShowRequest
+
+	v := utils.NewValidator()
+	if err := req.bind(c, v); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("refresh_run_show_failed", nil, c),
+				-2105,
+				err,
+			),
+		)
+	}
+
+	resp, err := jh.JobService(c).ShowRefreshRuns(req)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate(err.MessageID, nil, c),
+				-2105,
+				fmt.Errorf("%s", utils.Translate(err.Err.Error(), nil, c)),
+			),
+		)
+	}
+
+	return c.Status(http.StatusOK).JSON(
+		response.NewResponseWithPaging(
+			utils.Translate("refresh_run_show_success", nil, c),
+			2105,
+			resp,
+			req.PageOptions.Page,
+			req.PageOptions.Perpage,
+			resp.Total,
+		),
+	)
+}
+
+func (jh *JobHandler) RunRefresh(c *fiber.Ctx) error {
+	resp, err := jh.JobService(c).RunRefresh()
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate(err.MessageID, nil, c),
+				-2106,
+				fmt.Errorf("%s", utils.Translate(err.Err.Error(), nil, c)),
+			),
+		)
+	}
+
+	return c.Status(http.StatusOK).JSON(
+		response.NewResponse(
+			utils.Translate("refresh_run_success", nil, c),
+			2106,
+			resp,
+		),
+	)
+}
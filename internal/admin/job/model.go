@@ -0,0 +1,179 @@
+package job
+
+import (
+	"fmt"
+	"rerng_addicted_api/internal/admin/scraping"
+	types "rerng_addicted_api/pkg/model"
+	"rerng_addicted_api/pkg/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// job types
+const (
+	TypeSeries  = "series"
+	TypeEpisode = "episode"
+	TypeRefresh = "refresh"
+)
+
+// job states
+const (
+	StateQueued    = "queued"
+	StateRunning   = "running"
+	StateSucceeded = "succeeded"
+	StatePartial   = "partial"
+	StateFailed    = "failed"
+	StateCancelled = "cancelled"
+)
+
+// refresh run triggers
+const (
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+)
+
+// per-episode outcome states
+const (
+	EpisodeResolved = "resolved"
+	EpisodeFailed   = "failed"
+)
+
+type Job struct {
+	ID               int        `db:"id" json:"id"`
+	JobType          string     `db:"job_type" json:"job_type"`
+	Provider         string     `db:"provider" json:"provider"`
+	SeriesKey        int        `db:"series_key" json:"series_key"`
+	EpisodeNumber    float64    `db:"episode_number" json:"episode_number"`
+	State            string     `db:"state" json:"state"`
+	Attempts         int        `db:"attempts" json:"attempts"`
+	MaxAttempts      int        `db:"max_attempts" json:"max_attempts"`
+	RunAfter         time.Time  `db:"run_after" json:"run_after"`
+	StartedAt        *time.Time `db:"started_at" json:"started_at"`
+	FinishedAt       *time.Time `db:"finished_at" json:"finished_at"`
+	LockedBy         *string    `db:"locked_by" json:"locked_by"`
+	LastError        *string    `db:"last_error" json:"last_error"`
+	EpisodesTotal    int        `db:"episodes_total" json:"episodes_total"`
+	EpisodesResolved int        `db:"episodes_resolved" json:"episodes_resolved"`
+	EpisodesFailed   int        `db:"episodes_failed" json:"episodes_failed"`
+	RefreshRunID     *int       `db:"refresh_run_id" json:"refresh_run_id"`
+	CreatedAt        time.Time  `db:"created_at" json:"created_at"`
+	CreatedBy        *int       `db:"created_by" json:"created_by"`
+	UpdatedAt        *time.Time `db:"updated_at" json:"updated_at"`
+	UpdatedBy        *int       `db:"updated_by" json:"updated_by"`
+}
+
+type JobEpisode struct {
+	ID            int        `db:"id" json:"id"`
+	JobID         int        `db:"job_id" json:"job_id"`
+	EpisodeNumber float64    `db:"episode_number" json:"episode_number"`
+	State         string     `db:"state" json:"state"`
+	Error         *string    `db:"error" json:"error"`
+	Attempt       int        `db:"attempt" json:"attempt"`
+	CreatedAt     time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt     *time.Time `db:"updated_at" json:"updated_at"`
+}
+
+type JobDetail struct {
+	Job
+	Episodes []JobEpisode `json:"episodes"`
+}
+
+type JobsResponse struct {
+	Jobs  []Job `json:"jobs"`
+	Total int   `json:"-"`
+}
+
+type JobDetailResponse struct {
+	Jobs []JobDetail `json:"jobs"`
+}
+
+type RefreshRun struct {
+	ID            int        `db:"id" json:"id"`
+	Trigger       string     `db:"trigger" json:"trigger"`
+	State         string     `db:"state" json:"state"`
+	SeriesChecked int        `db:"series_checked" json:"series_checked"`
+	JobsEnqueued  int        `db:"jobs_enqueued" json:"jobs_enqueued"`
+	Error         *string    `db:"error" json:"error"`
+	StartedAt     time.Time  `db:"started_at" json:"started_at"`
+	FinishedAt    *time.Time `db:"finished_at" json:"finished_at"`
+	CreatedBy     *int       `db:"created_by" json:"created_by"`
+	// outcome of the refresh jobs enqueued by this run
+	JobsPending   int `db:"jobs_pending" json:"jobs_pending"`
+	JobsSucceeded int `db:"jobs_succeeded" json:"jobs_succeeded"`
+	JobsFailed    int `db:"jobs_failed" json:"jobs_failed"`
+	NewEpisodes   int `db:"new_episodes" json:"new_episodes"`
+}
+
+type RefreshRunsResponse struct {
+	RefreshRuns []RefreshRun `json:"refresh_runs"`
+	Total       int          `json:"-"`
+}
+
+// OngoingSerie is a stored series the scheduler re-checks for new episodes
+type OngoingSerie struct {
+	ExternalID int    `db:"external_id"`
+	Provider   string `db:"provider"`
+}
+
+type JobNewRequest struct {
+	JobType       string  `json:"job_type" validate:"required,oneof=series episode refresh"`
+	Provider      string  `json:"provider"`
+	SeriesKey     int     `json:"series_key" validate:"required,min=1"`
+	EpisodeNumber float64 `json:"episode_number" validate:"min=0"`
+	MaxAttempts   int     `json:"max_attempts" validate:"min=0,max=10"`
+}
+
+func (r *JobNewRequest) bind(c *fiber.Ctx, v *utils.Validator) error {
+	if err := c.BodyParser(r); err != nil {
+		return err
+	}
+
+	r.JobType = strings.ToLower(strings.TrimSpace(r.JobType))
+	r.Provider = strings.TrimSpace(r.Provider)
+	if r.Provider == "" {
+		r.Provider = scraping.DefaultProvider
+	}
+
+	if err := v.Validate(r, c); err != nil {
+		return err
+	}
+
+	// only episode jobs target a single episode, 0 means the whole series
+	if r.JobType == TypeEpisode && r.EpisodeNumber <= 0 {
+		return fmt.Errorf("episode_number is required for episode jobs")
+	}
+	if r.JobType != TypeEpisode {
+		r.EpisodeNumber = 0
+	}
+	return nil
+}
+
+type JobShowRequest struct {
+	PageOptions types.Paging   `json:"paging_options" query:"paging_options" validate:"required"`
+	Sorts       []types.Sort   `json:"sorts,omitempty" query:"sorts"`
+	Filters     []types.Filter `json:"filters,omitempty" query:"filters"`
+}
+
+func (r *JobShowRequest) bind(c *fiber.Ctx, v *utils.Validator) error {
+	if err := c.QueryParser(r); err != nil {
+		return err
+	}
+
+	// fix `Filter.Value` nil when http query params failed parse to json type `interface{}`
+	for i := range r.Filters {
+		value := c.Query(fmt.Sprintf("filters[%d][value]", i))
+		if int_value, err := strconv.Atoi(value); err == nil {
+			r.Filters[i].Value = int_value
+		} else {
+			r.Filters[i].Value = value
+		}
+	}
+
+	if err := v.Validate(r, c); err != nil {
+		return err
+	}
+	return nil
+}
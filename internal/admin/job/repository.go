@@ -0,0 +1,458 @@
+package job
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	custom_log "rerng_addicted_api/pkg/logs"
+	types "rerng_addicted_api/pkg/model"
+	postgres "rerng_addicted_api/pkg/postgres"
+	"rerng_addicted_api/pkg/responses"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const job_columns = `
+	j.id, j.job_type, j.provider, j.series_key, j.episode_number, j.state, j.attempts, j.max_attempts,
+	j.run_after, j.started_at, j.finished_at, j.locked_by, j.last_error,
+	j.episodes_total, j.episodes_resolved, j.episodes_failed, j.refresh_run_id,
+	j.created_at, j.created_by, j.updated_at, j.updated_by`
+
+// columns admins may filter and sort the job list by
+var job_list_columns = map[string]bool{
+	"j.id":             true,
+	"j.job_type":       true,
+	"j.provider":       true,
+	"j.series_key":     true,
+	"j.episode_number": true,
+	"j.state":          true,
+	"j.attempts":       true,
+	"j.run_after":      true,
+	"j.created_at":     true,
+	"j.finished_at":    true,
+}
+
+type JobRepo interface {
+	Show(req JobShowRequest) (*JobsResponse, *responses.ErrorResponse)
+	ShowOne(id int) (*JobDetailResponse, *responses.ErrorResponse)
+	Create(req JobNewRequest, max_attempts int) (*JobDetailResponse, *responses.ErrorResponse)
+	Cancel(id int) (*JobDetailResponse, *responses.ErrorResponse)
+	Retry(id int) (*JobDetailResponse, *responses.ErrorResponse)
+	ShowRefreshRuns(req JobShowRequest) (*RefreshRunsResponse, *responses.ErrorResponse)
+}
+
+type JobRepoImpl struct {
+	DBPool      *sqlx.DB
+	UserContext *types.UserContext
+}
+
+func NewJobRepoImpl(db_pool *sqlx.DB, user_context *types.UserContext) *JobRepoImpl {
+	return &JobRepoImpl{
+		DBPool:      db_pool,
+		UserContext: user_context,
+	}
+}
+
+func (j *JobRepoImpl) Show(req JobShowRequest) (*JobsResponse, *responses.ErrorResponse) {
+	filters := []types.Filter{}
+	for _, f := range req.Filters {
+		if job_list_columns[f.Property] {
+			filters = append(filters, f)
+		}
+	}
+	sorts := []types.Sort{}
+	for _, s := range req.Sorts {
+		if job_list_columns[s.Property] {
+			sorts = append(sorts, s)
+		}
+	}
+	if len(sorts) == 0 {
+		sorts = append(sorts, types.Sort{Property: "j.id", Direction: "desc"})
+	}
+
+	sql_limit := postgres.BuildPaging(req.PageOptions.Page, req.PageOptions.Perpage)
+	sql_order_by := postgres.BuildSQLSort(sorts)
+	sql_filters, args_filters := postgres.BuildSQLFilter(filters)
+
+	where_clause := "WHERE j.deleted_at IS NULL"
+	if sql_filters != "" {
+		where_clause += " AND " + sql_filters
+	}
+
+	jobs := []Job{}
+	query := fmt.Sprintf(`SELECT %s FROM tbl_scrape_jobs j %s %s %s`, job_columns, where_clause, sql_order_by, sql_limit)
+	if err := j.DBPool.Select(&jobs, query, args_filters...); err != nil {
+		custom_log.NewCustomLog("job_show_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("job_show_failed", fmt.Errorf("database_error"))
+	}
+
+	var total int
+	count_query := fmt.Sprintf(`SELECT COUNT(*) FROM tbl_scrape_jobs j %s`, where_clause)
+	if err := j.DBPool.Get(&total, count_query, args_filters...); err != nil {
+		custom_log.NewCustomLog("job_show_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("job_show_failed", fmt.Errorf("database_error"))
+	}
+
+	return &JobsResponse{
+		Jobs:  jobs,
+		Total: total,
+	}, nil
+}
+
+func (j *JobRepoImpl) ShowOne(id int) (*JobDetailResponse, *responses.ErrorResponse) {
+	var detail JobDetail
+	err := j.DBPool.Get(&detail.Job, fmt.Sprintf(`SELECT %s FROM tbl_scrape_jobs j WHERE j.id = $1 AND j.deleted_at IS NULL`, job_columns), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("job_show_failed", fmt.Errorf("job_not_found"))
+	}
+	if err != nil {
+		custom_log.NewCustomLog("job_show_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("job_show_failed", fmt.Errorf("database_error"))
+	}
+
+	detail.Episodes = []JobEpisode{}
+	err = j.DBPool.Select(&detail.Episodes, `
+		SELECT id, job_id, episode_number, state, error, attempt, created_at, updated_at
+		FROM tbl_scrape_job_episodes
+		WHERE job_id = $1
+		ORDER BY episode_number
+	`, id)
+	if err != nil {
+		custom_log.NewCustomLog("job_show_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("job_show_failed", fmt.Errorf("database_error"))
+	}
+
+	return &JobDetailResponse{
+		Jobs: []JobDetail{detail},
+	}, nil
+}
+
+// Create queues a job, when the same target is already queued or running that job is returned instead
+func (j *JobRepoImpl) Create(req JobNewRequest, max_attempts int) (*JobDetailResponse, *responses.ErrorResponse) {
+	var id int
+	err := j.DBPool.Get(&id, `
+		INSERT INTO tbl_scrape_jobs (job_type, provider, series_key, episode_number, max_attempts, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (job_type, provider, series_key, episode_number) WHERE state IN ('queued', 'running')
+		DO NOTHING
+		RETURNING id
+	`, req.JobType, req.Provider, req.SeriesKey, req.EpisodeNumber, max_attempts, j.UserContext.Id)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = j.DBPool.Get(&id, `
+			SELECT id FROM tbl_scrape_jobs
+			WHERE job_type = $1 AND provider = $2 AND series_key = $3 AND episode_number = $4
+				AND state IN ('queued', 'running')
+		`, req.JobType, req.Provider, req.SeriesKey, req.EpisodeNumber)
+	}
+	if err != nil {
+		custom_log.NewCustomLog("job_create_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("job_create_failed", fmt.Errorf("database_error"))
+	}
+
+	return j.ShowOne(id)
+}
+
+// Cancel stops a queued job, a running job keeps its worker busy but its result is discarded
+func (j *JobRepoImpl) Cancel(id int) (*JobDetailResponse, *responses.ErrorResponse) {
+	res, err := j.DBPool.Exec(`
+		UPDATE tbl_scrape_jobs
+		SET state = $2, finished_at = NOW(), updated_at = NOW(), updated_by = $3
+		WHERE id = $1 AND state IN ('queued', 'running') AND deleted_at IS NULL
+	`, id, StateCancelled, j.UserContext.Id)
+	if err != nil {
+		custom_log.NewCustomLog("job_cancel_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("job_cancel_failed", fmt.Errorf("database_error"))
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		if _, err := j.ShowOne(id); err != nil {
+			return nil, err
+		}
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("job_cancel_failed", fmt.Errorf("job_not_cancellable"))
+	}
+
+	return j.ShowOne(id)
+}
+
+// Retry puts a finished (failed, partial or cancelled) job back in the queue with a fresh attempt budget
+func (j *JobRepoImpl) Retry(id int) (*JobDetailResponse, *responses.ErrorResponse) {
+	res, err := j.DBPool.Exec(`
+		UPDATE tbl_scrape_jobs
+		SET state = $2, attempts = 0, run_after = NOW(), started_at = NULL, finished_at = NULL,
+			locked_by = NULL, last_error = NULL, updated_at = NOW(), updated_by = $3
+		WHERE id = $1 AND state IN ('failed', 'partial', 'cancelled') AND deleted_at IS NULL
+	`, id, StateQueued, j.UserContext.Id)
+	if err != nil {
+		var pq_err *pq.Error
+		if errors.As(err, &pq_err) && pq_err.Code == "23505" {
+			return nil, (&responses.ErrorResponse{}).NewErrorResponse("job_retry_failed", fmt.Errorf("job_already_active"))
+		}
+		custom_log.NewCustomLog("job_retry_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("job_retry_failed", fmt.Errorf("database_error"))
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		if _, err := j.ShowOne(id); err != nil {
+			return nil, err
+		}
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("job_retry_failed", fmt.Errorf("job_not_retryable"))
+	}
+
+	return j.ShowOne(id)
+}
+
+// Claim locks the oldest due job for worker_id, it returns nil when the queue is empty.
+// A due job that already used all its attempts is failed on the spot instead of run again.
+func (j *JobRepoImpl) Claim(worker_id string) (*Job, error) {
+	for {
+		var job Job
+		err := j.DBPool.Get(&job, fmt.Sprintf(`
+			UPDATE tbl_scrape_jobs j
+			SET state = CASE WHEN j.attempts >= j.max_attempts THEN '%[1]s' ELSE '%[2]s' END,
+				attempts = CASE WHEN j.attempts >= j.max_attempts THEN j.attempts ELSE j.attempts + 1 END,
+				started_at = CASE WHEN j.attempts >= j.max_attempts THEN j.started_at ELSE NOW() END,
+				finished_at = CASE WHEN j.attempts >= j.max_attempts THEN NOW() END,
+				locked_by = CASE WHEN j.attempts >= j.max_attempts THEN NULL ELSE $1 END,
+				last_error = CASE WHEN j.attempts >= j.max_attempts THEN COALESCE(j.last_error, 'attempts exhausted') ELSE j.last_error END,
+				updated_at = NOW()
+			WHERE j.id = (
+				SELECT id FROM tbl_scrape_jobs
+				WHERE state = '%[3]s' AND run_after <= NOW() AND deleted_at IS NULL
+				ORDER BY run_after, id
+				FOR UPDATE SKIP LOCKED
+				LIMIT 1
+			)
+			RETURNING %[4]s
+		`, StateFailed, StateRunning, StateQueued, job_columns), worker_id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if job.State == StateFailed {
+			custom_log.NewCustomLog("job_attempts_exhausted", fmt.Sprintf("job %d failed after %d attempts", job.ID, job.Attempts), "warn")
+			continue
+		}
+		return &job, nil
+	}
+}
+
+// Heartbeat marks a running job as still alive, RequeueStale only takes jobs whose heartbeat stopped
+func (j *JobRepoImpl) Heartbeat(job *Job) error {
+	_, err := j.DBPool.Exec(`
+		UPDATE tbl_scrape_jobs SET updated_at = NOW()
+		WHERE id = $1 AND locked_by = $2 AND state = $3
+	`, job.ID, job.LockedBy, StateRunning)
+	return err
+}
+
+// RequeueStale hands jobs whose worker stopped sending heartbeats back to the queue,
+// a job that already used all its attempts is failed so a job that crashes the process is not retried forever
+func (j *JobRepoImpl) RequeueStale(stale_after time.Duration) (int64, error) {
+	res, err := j.DBPool.Exec(`
+		UPDATE tbl_scrape_jobs
+		SET state = CASE WHEN attempts >= max_attempts THEN $1 ELSE $2 END,
+			finished_at = CASE WHEN attempts >= max_attempts THEN NOW() END,
+			locked_by = NULL, run_after = NOW(), updated_at = NOW(),
+			last_error = 'worker lost while running'
+		WHERE state = $3 AND COALESCE(updated_at, started_at) < NOW() - make_interval(secs => $4)
+	`, StateFailed, StateQueued, StateRunning, stale_after.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// RecordEpisode stores the latest outcome of one episode and refreshes the job counters
+func (j *JobRepoImpl) RecordEpisode(job_id int, episode_number float64, state string, ep_err string, attempt int) error {
+	var err_value *string
+	if ep_err != "" {
+		err_value = &ep_err
+	}
+
+	_, err := j.DBPool.Exec(`
+		INSERT INTO tbl_scrape_job_episodes (job_id, episode_number, state, error, attempt)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (job_id, episode_number) DO UPDATE SET
+			state = EXCLUDED.state,
+			error = EXCLUDED.error,
+			attempt = EXCLUDED.attempt,
+			updated_at = NOW()
+	`, job_id, episode_number, state, err_value, attempt)
+	if err != nil {
+		return fmt.Errorf("failed recording episode %.2f of job %d: %w", episode_number, job_id, err)
+	}
+
+	_, err = j.DBPool.Exec(`
+		UPDATE tbl_scrape_jobs SET
+			episodes_resolved = (SELECT COUNT(*) FROM tbl_scrape_job_episodes WHERE job_id = $1 AND state = $2),
+			episodes_failed = (SELECT COUNT(*) FROM tbl_scrape_job_episodes WHERE job_id = $1 AND state = $3),
+			updated_at = NOW()
+		WHERE id = $1
+	`, job_id, EpisodeResolved, EpisodeFailed)
+	if err != nil {
+		return fmt.Errorf("failed updating counters of job %d: %w", job_id, err)
+	}
+	return nil
+}
+
+func (j *JobRepoImpl) SetTotal(job_id int, total int) error {
+	_, err := j.DBPool.Exec(`UPDATE tbl_scrape_jobs SET episodes_total = $2, updated_at = NOW() WHERE id = $1`, job_id, total)
+	return err
+}
+
+// Finish records the final state, a job cancelled while it ran keeps its cancelled state
+func (j *JobRepoImpl) Finish(job *Job, state string, last_error string) error {
+	var err_value *string
+	if last_error != "" {
+		err_value = &last_error
+	}
+
+	_, err := j.DBPool.Exec(`
+		UPDATE tbl_scrape_jobs
+		SET state = $3, last_error = $4, finished_at = NOW(), locked_by = NULL, updated_at = NOW()
+		WHERE id = $1 AND locked_by = $2 AND state = $5
+	`, job.ID, job.LockedBy, state, err_value, StateRunning)
+	return err
+}
+
+// Reschedule queues a failed attempt again after the backoff delay
+func (j *JobRepoImpl) Reschedule(job *Job, delay time.Duration, last_error string) error {
+	_, err := j.DBPool.Exec(`
+		UPDATE tbl_scrape_jobs
+		SET state = $3, last_error = $4, run_after = NOW() + make_interval(secs => $5),
+			locked_by = NULL, updated_at = NOW()
+		WHERE id = $1 AND locked_by = $2 AND state = $6
+	`, job.ID, job.LockedBy, StateQueued, last_error, delay.Seconds(), StateRunning)
+	return err
+}
+
+// refresh runs are serialised across processes with this advisory lock key
+const refresh_lock_key = 74120501
+
+const refresh_run_columns = `
+	r.id, r.trigger, r.state, r.series_checked, r.jobs_enqueued, r.error, r.started_at, r.finished_at, r.created_by,
+	COUNT(j.id) FILTER (WHERE j.state IN ('queued', 'running')) AS jobs_pending,
+	COUNT(j.id) FILTER (WHERE j.state = 'succeeded') AS jobs_succeeded,
+	COUNT(j.id) FILTER (WHERE j.state IN ('failed', 'partial')) AS jobs_failed,
+	COALESCE(SUM(j.episodes_resolved), 0) AS new_episodes`
+
+// StartRefreshRun opens a run log row, when force is false it returns nil if a run started within interval
+func (j *JobRepoImpl) StartRefreshRun(trigger string, interval time.Duration, force bool) (*RefreshRun, error) {
+	tx, err := j.DBPool.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// only one process decides at a time, the others see the fresh row once this commits
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, refresh_lock_key); err != nil {
+		return nil, err
+	}
+
+	if !force {
+		var recent bool
+		err := tx.Get(&recent, `
+			SELECT EXISTS (
+				SELECT 1 FROM tbl_refresh_runs
+				WHERE started_at > NOW() - make_interval(secs => $1)
+			)
+		`, interval.Seconds())
+		if err != nil {
+			return nil, err
+		}
+		if recent {
+			return nil, nil
+		}
+	}
+
+	var created_by *int
+	if j.UserContext.Id != 0 {
+		created_by = &j.UserContext.Id
+	}
+
+	var run RefreshRun
+	err = tx.Get(&run, `
+		INSERT INTO tbl_refresh_runs (trigger, state, created_by)
+		VALUES ($1, $2, $3)
+		RETURNING id, trigger, state, series_checked, jobs_enqueued, error, started_at, finished_at, created_by
+	`, trigger, StateRunning, created_by)
+	if err != nil {
+		return nil, err
+	}
+
+	return &run, tx.Commit()
+}
+
+func (j *JobRepoImpl) FinishRefreshRun(run_id int, checked int, enqueued int, run_err error) error {
+	state := StateSucceeded
+	var err_value *string
+	if run_err != nil {
+		state = StateFailed
+		msg := run_err.Error()
+		err_value = &msg
+	}
+
+	_, err := j.DBPool.Exec(`
+		UPDATE tbl_refresh_runs
+		SET state = $2, series_checked = $3, jobs_enqueued = $4, error = $5, finished_at = NOW()
+		WHERE id = $1
+	`, run_id, state, checked, enqueued, err_value)
+	return err
+}
+
+// OngoingSeries lists stored series whose status means new episodes may still appear
+func (j *JobRepoImpl) OngoingSeries(statuses []string) ([]OngoingSerie, error) {
+	series := []OngoingSerie{}
+	err := j.DBPool.Select(&series, `
+		SELECT external_id, provider FROM tbl_series
+		WHERE status = ANY($1) AND deleted_at IS NULL
+		ORDER BY id
+	`, pq.Array(statuses))
+	return series, err
+}
+
+// EnqueueRefresh queues a refresh job for a run, it reports false when one is already pending for the series
+func (j *JobRepoImpl) EnqueueRefresh(run_id int, serie OngoingSerie, max_attempts int) (bool, error) {
+	res, err := j.DBPool.Exec(`
+		INSERT INTO tbl_scrape_jobs (job_type, provider, series_key, episode_number, max_attempts, refresh_run_id)
+		VALUES ($1, $2, $3, 0, $4, $5)
+		ON CONFLICT (job_type, provider, series_key, episode_number) WHERE state IN ('queued', 'running')
+		DO NOTHING
+	`, TypeRefresh, serie.Provider, serie.ExternalID, max_attempts, run_id)
+	if err != nil {
+		return false, err
+	}
+	affected, _ := res.RowsAffected()
+	return affected > 0, nil
+}
+
+func (j *JobRepoImpl) ShowRefreshRuns(req JobShowRequest) (*RefreshRunsResponse, *responses.ErrorResponse) {
+	sql_limit := postgres.BuildPaging(req.PageOptions.Page, req.PageOptions.Perpage)
+
+	runs := []RefreshRun{}
+	err := j.DBPool.Select(&runs, fmt.Sprintf(`
+		SELECT %s
+		FROM tbl_refresh_runs r
+		LEFT JOIN tbl_scrape_jobs j ON j.refresh_run_id = r.id
+		GROUP BY r.id
+		ORDER BY r.id DESC
+		%s
+	`, refresh_run_columns, sql_limit))
+	if err != nil {
+		custom_log.NewCustomLog("refresh_run_show_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("refresh_run_show_failed", fmt.Errorf("database_error"))
+	}
+
+	var total int
+	if err := j.DBPool.Get(&total, `SELECT COUNT(*) FROM tbl_refresh_runs`); err != nil {
+		custom_log.NewCustomLog("refresh_run_show_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("refresh_run_show_failed", fmt.Errorf("database_error"))
+	}
+
+	return &RefreshRunsResponse{
+		RefreshRuns: runs,
+		Total:       total,
+	}, nil
+}
@@ -0,0 +1,41 @@
+package job
+
+import (
+	"rerng_addicted_api/pkg/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jmoiron/sqlx"
+)
+
+type JobRoute struct {
+	App        *fiber.App
+	DBPool     *sqlx.DB
+	Worker     *Worker
+	Scheduler  *Scheduler
+	JobHandler *JobHandler
+}
+
+func NewRoute(app *fiber.App, db_pool *sqlx.DB, worker *Worker, scheduler *Scheduler) *JobRoute {
+	return &JobRoute{
+		App:        app,
+		DBPool:     db_pool,
+		Worker:     worker,
+		Scheduler:  scheduler,
+		JobHandler: NewJobHandler(db_pool, worker, scheduler),
+	}
+}
+
+func (jr *JobRoute) RegisterJobRoute() *JobRoute {
+	jobs := jr.App.Group("/api/v1/admin/scraping/jobs", middlewares.NewJwtMiddleware(jr.DBPool))
+
+	jobs.Get("/refresh/runs", jr.JobHandler.ShowRefreshRuns)
+	jobs.Post("/refresh/run", jr.JobHandler.RunRefresh)
+
+	jobs.Get("/", jr.JobHandler.Show)
+	jobs.Get("/:id", jr.JobHandler.ShowOne)
+	jobs.Post("/", jr.JobHandler.Create)
+	jobs.Post("/:id/cancel", jr.JobHandler.Cancel)
+	jobs.Post("/:id/retry", jr.JobHandler.Retry)
+
+	return jr
+}
@@ -0,0 +1,116 @@
+package job
+
+import (
+	"fmt"
+	"rerng_addicted_api/configs"
+	custom_log "rerng_addicted_api/pkg/logs"
+	types "rerng_addicted_api/pkg/model"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// how often the scheduler checks whether a refresh run is due
+const scheduler_tick = time.Minute
+
+// Scheduler periodically enqueues refresh jobs for series that are still airing.
+// The refresh job itself diffs the provider listing against tbl_episodes and only resolves new episodes.
+type Scheduler struct {
+	DBPool *sqlx.DB
+	Worker *Worker
+	Config *configs.RefreshConfig
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+func NewScheduler(db_pool *sqlx.DB, worker *Worker, config *configs.RefreshConfig) *Scheduler {
+	return &Scheduler{
+		DBPool: db_pool,
+		Worker: worker,
+		Config: config,
+		stop:   make(chan struct{}),
+	}
+}
+
+func (s *Scheduler) Start() *Scheduler {
+	if !s.Config.Enabled {
+		fmt.Println("⏸️  Refresh scheduler disabled")
+		return s
+	}
+
+	s.wg.Add(1)
+	go s.loop()
+	return s
+}
+
+func (s *Scheduler) Stop() {
+	s.once.Do(func() {
+		close(s.stop)
+	})
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(scheduler_tick)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.Run(&types.UserContext{}, TriggerSchedule, false); err != nil {
+			custom_log.NewCustomLog("refresh_run_failed", err.Error(), "error")
+		}
+
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run enqueues one refresh job per ongoing series, unless forced it is a no-op while the last run is younger than the interval
+func (s *Scheduler) Run(user_context *types.UserContext, trigger string, force bool) (*RefreshRun, error) {
+	repo := NewJobRepoImpl(s.DBPool, user_context)
+
+	run, err := repo.StartRefreshRun(trigger, s.Config.Interval, force)
+	if err != nil || run == nil {
+		return nil, err
+	}
+
+	series, err := repo.OngoingSeries(s.Config.Statuses)
+	enqueued := 0
+	if err == nil {
+		for _, serie := range series {
+			created, enqueue_err := repo.EnqueueRefresh(run.ID, serie, s.Worker.Config.MaxAttempts)
+			if enqueue_err != nil {
+				err = enqueue_err
+				break
+			}
+			if created {
+				enqueued++
+			}
+		}
+	}
+
+	if finish_err := repo.FinishRefreshRun(run.ID, len(series), enqueued, err); finish_err != nil {
+		custom_log.NewCustomLog("refresh_run_failed", finish_err.Error(), "error")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("🔄 Refresh run #%d: %d ongoing series, %d jobs queued\n", run.ID, len(series), enqueued)
+	if enqueued > 0 {
+		s.Worker.Notify()
+	}
+
+	run.State = StateSucceeded
+	run.SeriesChecked = len(series)
+	run.JobsEnqueued = enqueued
+	run.JobsPending = enqueued
+	return run, nil
+}
@@ -0,0 +1,96 @@
+package job
+
+import (
+	"fmt"
+	custom_log "rerng_addicted_api/pkg/logs"
+	types "rerng_addicted_api/pkg/model"
+	"rerng_addicted_api/pkg/responses"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type JobServiceCreator interface {
+	Show(req JobShowRequest) (*JobsResponse, *responses.ErrorResponse)
+	ShowOne(id int) (*JobDetailResponse, *responses.ErrorResponse)
+	Create(req JobNewRequest) (*JobDetailResponse, *responses.ErrorResponse)
+	Cancel(id int) (*JobDetailResponse, *responses.ErrorResponse)
+	Retry(id int) (*JobDetailResponse, *responses.ErrorResponse)
+	ShowRefreshRuns(req JobShowRequest) (*RefreshRunsResponse, *responses.ErrorResponse)
+	RunRefresh() (*RefreshRunsResponse, *responses.ErrorResponse)
+}
+
+type JobService struct {
+	DBPool      *sqlx.DB
+	UserContext *types.UserContext
+	Worker      *Worker
+	Scheduler   *Scheduler
+	JobRepo     *JobRepoImpl
+}
+
+func NewJobService(db_pool *sqlx.DB, user_context *types.UserContext, worker *Worker, scheduler *Scheduler) *JobService {
+	return &JobService{
+		DBPool:      db_pool,
+		UserContext: user_context,
+		Worker:      worker,
+		Scheduler:   scheduler,
+		JobRepo:     NewJobRepoImpl(db_pool, user_context),
+	}
+}
+
+func (js *JobService) Show(req JobShowRequest) (*JobsResponse, *responses.ErrorResponse) {
+	return js.JobRepo.Show(req)
+}
+
+func (js *JobService) ShowOne(id int) (*JobDetailResponse, *responses.ErrorResponse) {
+	return js.JobRepo.ShowOne(id)
+}
+
+func (js *JobService) Create(req JobNewRequest) (*JobDetailResponse, *responses.ErrorResponse) {
+	// reject unknown providers up front instead of failing every attempt
+	if _, err := js.Worker.Providers.Get(req.Provider); err != nil {
+		return nil, err
+	}
+
+	max_attempts := req.MaxAttempts
+	if max_attempts == 0 {
+		max_attempts = js.Worker.Config.MaxAttempts
+	}
+
+	resp, err := js.JobRepo.Create(req, max_attempts)
+	if err != nil {
+		return nil, err
+	}
+	js.Worker.Notify()
+	return resp, nil
+}
+
+func (js *JobService) Cancel(id int) (*JobDetailResponse, *responses.ErrorResponse) {
+	return js.JobRepo.Cancel(id)
+}
+
+func (js *JobService) Retry(id int) (*JobDetailResponse, *responses.ErrorResponse) {
+	resp, err := js.JobRepo.Retry(id)
+	if err != nil {
+		return nil, err
+	}
+	js.Worker.Notify()
+	return resp, nil
+}
+
+func (js *JobService) ShowRefreshRuns(req JobShowRequest) (*RefreshRunsResponse, *responses.ErrorResponse) {
+	return js.JobRepo.ShowRefreshRuns(req)
+}
+
+// RunRefresh starts a refresh run right away, regardless of when the last scheduled one ran
+func (js *JobService) RunRefresh() (*RefreshRunsResponse, *responses.ErrorResponse) {
+	run, err := js.Scheduler.Run(js.UserContext, TriggerManual, true)
+	if err != nil {
+		custom_log.NewCustomLog("refresh_run_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("refresh_run_failed", fmt.Errorf("database_error"))
+	}
+
+	return &RefreshRunsResponse{
+		RefreshRuns: []RefreshRun{*run},
+		Total:       1,
+	}, nil
+}
@@ -0,0 +1,244 @@
+package job
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"rerng_addicted_api/configs"
+	"rerng_addicted_api/internal/admin/history"
+	"rerng_addicted_api/internal/admin/scraping"
+	"rerng_addicted_api/pkg/imagestore"
+	custom_log "rerng_addicted_api/pkg/logs"
+	types "rerng_addicted_api/pkg/model"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Worker pulls scrape jobs from tbl_scrape_jobs and runs them with bounded concurrency.
+// Several API processes can run workers against the same table, rows are claimed with SKIP LOCKED.
+type Worker struct {
+	DBPool    *sqlx.DB
+	Providers *scraping.Providers
+	Images    *imagestore.Store
+	Config    *configs.JobConfig
+
+	id   string
+	wake chan struct{}
+	stop chan struct{}
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+func NewWorker(db_pool *sqlx.DB, providers *scraping.Providers, images *imagestore.Store, config *configs.JobConfig) *Worker {
+	host, _ := os.Hostname()
+	return &Worker{
+		DBPool:    db_pool,
+		Providers: providers,
+		Images:    images,
+		Config:    config,
+		id:        fmt.Sprintf("%s-%d", host, os.Getpid()),
+		wake:      make(chan struct{}, config.Workers),
+		stop:      make(chan struct{}),
+	}
+}
+
+func (w *Worker) Start() *Worker {
+	for n := 0; n < w.Config.Workers; n++ {
+		w.wg.Add(1)
+		go w.loop(fmt.Sprintf("%s-%d", w.id, n+1))
+	}
+	return w
+}
+
+// Stop lets running jobs finish and waits for every loop to exit
+func (w *Worker) Stop() {
+	w.once.Do(func() {
+		close(w.stop)
+	})
+	w.wg.Wait()
+}
+
+// Notify wakes an idle loop so a new job starts without waiting for the next poll
+func (w *Worker) Notify() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (w *Worker) loop(worker_id string) {
+	defer w.wg.Done()
+
+	repo := NewJobRepoImpl(w.DBPool, &types.UserContext{})
+	ticker := time.NewTicker(w.Config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if count, err := repo.RequeueStale(w.Config.StaleAfter); err != nil {
+			custom_log.NewCustomLog("job_requeue_failed", err.Error(), "error")
+		} else if count > 0 {
+			custom_log.NewCustomLog("job_requeued", fmt.Sprintf("%d stale jobs requeued or failed", count), "warn")
+		}
+
+		// drain the queue before going back to sleep
+		for {
+			select {
+			case <-w.stop:
+				return
+			default:
+			}
+
+			job, err := repo.Claim(worker_id)
+			if err != nil {
+				custom_log.NewCustomLog("job_claim_failed", err.Error(), "error")
+				break
+			}
+			if job == nil {
+				break
+			}
+			w.process(repo, job)
+		}
+
+		select {
+		case <-w.stop:
+			return
+		case <-w.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Worker) process(repo *JobRepoImpl, job *Job) {
+	fmt.Printf("🛠️  Job #%d (%s %s/%d) attempt %d/%d\n", job.ID, job.JobType, job.Provider, job.SeriesKey, job.Attempts, job.MaxAttempts)
+
+	// scrape history credits the admin who queued the job
+	user_context := &types.UserContext{}
+	if job.CreatedBy != nil {
+		user_context.Id = *job.CreatedBy
+	}
+
+	stop_heartbeat := w.heartbeat(repo, job)
+
+	// jobs always want live data, so no response cache here
+	service := scraping.NewScrapingService(w.DBPool, user_context, w.Providers, nil)
+	service.Trigger = history.TriggerJob
+	service.Images = w.Images
+
+	// episode events arrive concurrently during a deep scrape
+	var (
+		failed_mu sync.Mutex
+		failed    int
+	)
+	progress := func(event scraping.ProgressEvent) {
+		var err error
+		switch event.Event {
+		case scraping.ProgressSeriesFetched:
+			err = repo.SetTotal(job.ID, event.Total)
+		case scraping.ProgressEpisodeResolved:
+			err = repo.RecordEpisode(job.ID, *event.Episode, EpisodeResolved, "", job.Attempts)
+		case scraping.ProgressEpisodeFailed:
+			failed_mu.Lock()
+			failed++
+			failed_mu.Unlock()
+			err = repo.RecordEpisode(job.ID, *event.Episode, EpisodeFailed, event.Error, job.Attempts)
+		}
+		if err != nil {
+			custom_log.NewCustomLog("job_progress_failed", err.Error(), "error")
+		}
+	}
+
+	var job_err string
+	switch job.JobType {
+	case TypeSeries:
+		if _, err := service.GetDetail(job.Provider, strconv.Itoa(job.SeriesKey), progress); err != nil {
+			job_err = err.Err.Error()
+		}
+	case TypeEpisode:
+		number := job.EpisodeNumber
+		progress(scraping.ProgressEvent{Event: scraping.ProgressSeriesFetched, Total: 1})
+		if _, err := service.GetEpisodes(job.Provider, job.SeriesKey, job.EpisodeNumber); err != nil {
+			job_err = err.Err.Error()
+			progress(scraping.ProgressEvent{Event: scraping.ProgressEpisodeFailed, Episode: &number, Error: job_err})
+			break
+		}
+		progress(scraping.ProgressEvent{Event: scraping.ProgressEpisodeResolved, Episode: &number})
+	case TypeRefresh:
+		if _, err := service.Refresh(job.Provider, job.SeriesKey, progress); err != nil {
+			job_err = err.Err.Error()
+		}
+	default:
+		job_err = fmt.Sprintf("unknown job type %q", job.JobType)
+	}
+
+	stop_heartbeat()
+
+	var err error
+	switch {
+	case job_err != "" && job.Attempts < job.MaxAttempts:
+		delay := w.backoff(job.Attempts)
+		fmt.Printf("🔁 Job #%d failed (%s), retrying in %s\n", job.ID, job_err, delay)
+		err = repo.Reschedule(job, delay, job_err)
+	case job_err != "":
+		fmt.Printf("❌ Job #%d failed: %s\n", job.ID, job_err)
+		err = repo.Finish(job, StateFailed, job_err)
+	case failed > 0:
+		fmt.Printf("⚠️  Job #%d finished with %d failed episodes\n", job.ID, failed)
+		err = repo.Finish(job, StatePartial, fmt.Sprintf("%d episodes failed", failed))
+	default:
+		fmt.Printf("✅ Job #%d done\n", job.ID)
+		err = repo.Finish(job, StateSucceeded, "")
+	}
+	if err != nil {
+		custom_log.NewCustomLog("job_finish_failed", err.Error(), "error")
+	}
+}
+
+// heartbeat bumps the job's updated_at while it runs so a long scrape is never mistaken for one
+// orphaned by a crashed process, the returned func stops it
+func (w *Worker) heartbeat(repo *JobRepoImpl, job *Job) func() {
+	interval := w.Config.StaleAfter / 4
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := repo.Heartbeat(job); err != nil {
+					custom_log.NewCustomLog("job_heartbeat_failed", err.Error(), "error")
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// backoff doubles the delay per attempt up to MaxBackoff, with jitter so retries do not line up
+func (w *Worker) backoff(attempts int) time.Duration {
+	delay := w.Config.BaseBackoff
+	for i := 1; i < attempts && delay < w.Config.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > w.Config.MaxBackoff {
+		delay = w.Config.MaxBackoff
+	}
+	if half := int64(delay / 2); half > 0 {
+		delay += time.Duration(rand.Int63n(half))
+	}
+	return delay
+}
@@ -0,0 +1,198 @@
+package scraping
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"rerng_addicted_api/configs"
+	custom_log "rerng_addicted_api/pkg/logs"
+	"rerng_addicted_api/pkg/responses"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// cache status reported in the X-Cache header
+const (
+	CacheHit    = "HIT"
+	CacheMiss   = "MISS"
+	CacheStale  = "STALE"
+	CacheBypass = "BYPASS"
+)
+
+// cached response kinds, also the second segment of the redis key
+const (
+	CacheKindSearch = "search"
+	CacheKindDetail = "detail"
+)
+
+const (
+	cache_prefix = "scraping"
+	// locks live outside the entry keyspace so no searched keyword can collide with one
+	cache_lock_prefix = "lock:"
+	// a background refresh holds this lock so only one process revalidates an entry
+	cache_lock_ttl = 60 * time.Second
+)
+
+// ResponseCache keeps provider responses in redis and serves stale entries while they are refreshed
+type ResponseCache struct {
+	Redis  *redis.Client
+	Config *configs.ScrapingConfig
+}
+
+type cache_entry struct {
+	StoredAt int64           `json:"stored_at"`
+	Data     json.RawMessage `json:"data"`
+}
+
+// NewResponseCache is nil without a redis client, a nil cache loads every response
+func NewResponseCache(client *redis.Client, config *configs.ScrapingConfig) *ResponseCache {
+	if client == nil {
+		return nil
+	}
+	return &ResponseCache{
+		Redis:  client,
+		Config: config,
+	}
+}
+
+func (rc *ResponseCache) ttl(kind string) time.Duration {
+	if kind == CacheKindSearch {
+		return rc.Config.SearchCacheTTL
+	}
+	return rc.Config.DetailCacheTTL
+}
+
+func cacheKey(kind string, provider string, key string) string {
+	return fmt.Sprintf("%s:%s:%s:%s", cache_prefix, kind, provider, strings.ToLower(strings.TrimSpace(key)))
+}
+
+// Store writes a fresh value, errors are only logged since the cache is best effort
+func (rc *ResponseCache) Store(kind string, provider string, key string, value interface{}) {
+	if rc == nil {
+		return
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		custom_log.NewCustomLog("cache_store_failed", err.Error(), "error")
+		return
+	}
+	entry, _ := json.Marshal(cache_entry{StoredAt: time.Now().Unix(), Data: data})
+
+	ttl := rc.ttl(kind) + rc.Config.CacheStaleTTL
+	if err := rc.Redis.Set(context.Background(), cacheKey(kind, provider, key), entry, ttl).Err(); err != nil {
+		custom_log.NewCustomLog("cache_store_failed", err.Error(), "error")
+	}
+}
+
+// Purge deletes one entry, or every entry of a kind and/or provider when key is empty
+func (rc *ResponseCache) Purge(kind string, provider string, key string) (int64, error) {
+	ctx := context.Background()
+
+	if key != "" && kind != "" && provider != "" {
+		return rc.Redis.Del(ctx, cacheKey(kind, provider, key)).Result()
+	}
+
+	// given parts match literally, only the missing ones are wildcards
+	kind_pattern, provider_pattern, key_pattern := "*", "*", "*"
+	if kind != "" {
+		kind_pattern = globEscape(kind)
+	}
+	if provider != "" {
+		provider_pattern = globEscape(provider)
+	}
+	if key != "" {
+		key_pattern = globEscape(strings.ToLower(strings.TrimSpace(key)))
+	}
+	pattern := fmt.Sprintf("%s:%s:%s:%s", cache_prefix, kind_pattern, provider_pattern, key_pattern)
+
+	var purged int64
+	iter := rc.Redis.Scan(ctx, 0, pattern, 500).Iterator()
+	batch := []string{}
+	for iter.Next(ctx) {
+		batch = append(batch, iter.Val())
+		if len(batch) == 500 {
+			n, err := rc.Redis.Del(ctx, batch...).Result()
+			if err != nil {
+				return purged, err
+			}
+			purged += n
+			batch = batch[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return purged, err
+	}
+	if len(batch) > 0 {
+		n, err := rc.Redis.Del(ctx, batch...).Result()
+		if err != nil {
+			return purged, err
+		}
+		purged += n
+	}
+	return purged, nil
+}
+
+// globEscape quotes the characters SCAN treats as a pattern
+func globEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// cached serves kind/provider/key from rc, calling load on a miss. A stale entry is returned right away
+// and reloaded in the background. A nil cache always loads and reports BYPASS.
+func cached[T any](rc *ResponseCache, kind string, provider string, key string, load func() (*T, *responses.ErrorResponse)) (*T, string, *responses.ErrorResponse) {
+	if rc == nil {
+		resp, err := load()
+		return resp, CacheBypass, err
+	}
+
+	ctx := context.Background()
+	redis_key := cacheKey(kind, provider, key)
+
+	raw, redis_err := rc.Redis.Get(ctx, redis_key).Bytes()
+	if redis_err != nil && redis_err != redis.Nil {
+		custom_log.NewCustomLog("cache_read_failed", redis_err.Error(), "error")
+		resp, err := load()
+		return resp, CacheBypass, err
+	}
+
+	if redis_err == nil {
+		var entry cache_entry
+		var value T
+		if json.Unmarshal(raw, &entry) == nil && json.Unmarshal(entry.Data, &value) == nil {
+			age := time.Since(time.Unix(entry.StoredAt, 0))
+			if age < rc.ttl(kind) {
+				return &value, CacheHit, nil
+			}
+
+			// revalidate once across processes, everyone else keeps serving the stale copy
+			lock_key := cache_lock_prefix + redis_key
+			if ok, _ := rc.Redis.SetNX(ctx, lock_key, 1, cache_lock_ttl).Result(); ok {
+				go func() {
+					defer rc.Redis.Del(context.Background(), lock_key)
+					if fresh, err := load(); err == nil {
+						rc.Store(kind, provider, key, fresh)
+					}
+				}()
+			}
+			return &value, CacheStale, nil
+		}
+	}
+
+	resp, err := load()
+	if err != nil {
+		return nil, CacheMiss, err
+	}
+	rc.Store(kind, provider, key, resp)
+	return resp, CacheMiss, nil
+}
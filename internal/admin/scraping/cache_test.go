@@ -0,0 +1,23 @@
+package scraping
+
+import "testing"
+
+func TestGlobEscape(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "running man", want: "running man"},
+		{in: "*", want: `\*`},
+		{in: "who?", want: `who\?`},
+		{in: "[ep]", want: `\[ep\]`},
+		{in: `a\b`, want: `a\\b`},
+		{in: "เกม*", want: `เกม\*`},
+	}
+
+	for _, tt := range tests {
+		if got := globEscape(tt.in); got != tt.want {
+			t.Errorf("globEscape(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -5,13 +5,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"rerng_addicted_api/pkg/browser"
 	response "rerng_addicted_api/pkg/http/response"
+	"rerng_addicted_api/pkg/imagestore"
 	custom_log "rerng_addicted_api/pkg/logs"
 	types "rerng_addicted_api/pkg/model"
+	"rerng_addicted_api/pkg/responses"
 	"rerng_addicted_api/pkg/utils"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/jmoiron/sqlx"
@@ -19,12 +21,14 @@ import (
 
 type ScrapingHandler struct {
 	DBPool          *sqlx.DB
+	BrowserPool     *browser.Pool
 	ScrapingService func(c *fiber.Ctx) *ScrapingService
 }
 
-func NewScrapingHandler(db_pool *sqlx.DB) *ScrapingHandler {
+func NewScrapingHandler(db_pool *sqlx.DB, providers *Providers, cache *ResponseCache, images *imagestore.Store, browser_pool *browser.Pool) *ScrapingHandler {
 	return &ScrapingHandler{
-		DBPool: db_pool,
+		DBPool:      db_pool,
+		BrowserPool: browser_pool,
 		ScrapingService: func(c *fiber.Ctx) *ScrapingService {
 			var uCtx types.UserContext
 			// convert map to UserContext struct
@@ -34,15 +38,18 @@ func NewScrapingHandler(db_pool *sqlx.DB) *ScrapingHandler {
 				uCtx = types.UserContext{}
 			}
 
-			return NewScrapingService(db_pool, &uCtx)
+			service := NewScrapingService(db_pool, &uCtx, providers, cache)
+			service.Images = images
+			return service
 		},
 	}
 }
 
 func (sc *ScrapingHandler) Search(c *fiber.Ctx) error {
 	keyword := c.Query("keyword")
+	provider := c.Query("provider", DefaultProvider)
 
-	resp, err := sc.ScrapingService(c).Search(keyword)
+	resp, cache_status, err := sc.ScrapingService(c).Search(provider, keyword)
 	if err != nil {
 		return c.Status(http.StatusBadRequest).JSON(
 			response.NewResponseError(
@@ -53,6 +60,7 @@ func (sc *ScrapingHandler) Search(c *fiber.Ctx) error {
 		)
 	}
 
+	c.Set("X-Cache", cache_status)
 	return c.Status(http.StatusOK).JSON(
 		response.NewResponse(
 			utils.Translate("scraping_success", nil, c),
@@ -74,7 +82,7 @@ func (sc *ScrapingHandler) ViewDetail(c *fiber.Ctx) error {
 		)
 	}
 
-	resp, err := sc.ScrapingService(c).ViewDetail(key)
+	resp, cache_status, err := sc.ScrapingService(c).ViewDetail(c.Query("provider", DefaultProvider), key)
 	if err != nil {
 		return c.Status(http.StatusBadRequest).JSON(
 			response.NewResponseError(
@@ -85,6 +93,7 @@ func (sc *ScrapingHandler) ViewDetail(c *fiber.Ctx) error {
 		)
 	}
 
+	c.Set("X-Cache", cache_status)
 	return c.Status(http.StatusOK).JSON(
 		response.NewResponse(
 			utils.Translate("scraping_success", nil, c),
@@ -118,50 +127,53 @@ func (sc *ScrapingHandler) GetDetail(c *fiber.Ctx) error {
 	}
 
 	scrapingService := sc.ScrapingService(c)
+	provider := c.Query("provider", DefaultProvider)
 
 	c.Set("Content-Type", "text/event-stream")
 	c.Set("Cache-Control", "no-cache")
 	c.Set("Connection", "keep-alive")
 
 	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
-		totalSteps := 20
-		maxFakePercent := 80 // only go up to 80% before service finishes
-
-		// Fake progress up to 80%
-		for i := 0; i <= totalSteps*maxFakePercent/100; i++ {
-			filled := strings.Repeat("█", i)
-			empty := strings.Repeat("░", totalSteps-i)
-			percent := (i * 100) / totalSteps
-			fmt.Fprintf(w, "data: [%s%s] %d%%\n\n", filled, empty, percent)
-			w.Flush()
-			time.Sleep(200 * time.Millisecond)
+		// the scrape runs in its own goroutine and pushes progress here, the writer drains it in order
+		events := make(chan ProgressEvent, 64)
+		var (
+			resp *SeriesDeepDetailsResponse
+			err  *responses.ErrorResponse
+		)
+		go func() {
+			defer close(events)
+			resp, err = scrapingService.GetDetail(provider, key, func(event ProgressEvent) {
+				events <- event
+			})
+		}()
+
+		// keep draining after the client leaves so the scrape (and its db upsert) can finish
+		connected := true
+		for event := range events {
+			if connected {
+				connected = writeSSE(w, event.Event, event) == nil
+			}
+		}
+		if !connected {
+			return
 		}
 
-		// Do actual scraping (real work)
-		resp, err := scrapingService.GetDetail(key)
 		if err != nil {
-			result, _ := json.Marshal(response.NewResponseError(
+			writeSSE(w, "error", response.NewResponseError(
 				translate(err.MessageID, nil),
 				-2001,
 				fmt.Errorf("%s", translate(err.Err.Error(), nil)),
 			))
-			fmt.Fprintf(w, "data: %s\n\n", string(result))
 			fmt.Fprintf(w, "event: done\ndata: error\n\n")
 			w.Flush()
 			return
 		}
 
-		// Jump progress to 100%
-		fmt.Fprintf(w, "data: [%s] 100%%\n\n", strings.Repeat("█", totalSteps))
-		w.Flush()
-
-		// Send final data
-		result, _ := json.Marshal(response.NewResponse(
+		writeSSE(w, "result", response.NewResponse(
 			translate("scraping_success", nil),
 			2001,
 			resp,
 		))
-		fmt.Fprintf(w, "data: %s\n\n", string(result))
 		fmt.Fprintf(w, "event: done\ndata: complete\n\n")
 		w.Flush()
 	})
@@ -196,7 +208,7 @@ func (sc *ScrapingHandler) GetEpisode(c *fiber.Ctx) error {
 		)
 	}
 
-	ep_num, err_con := strconv.Atoi(ep_num_Str)
+	ep_num, err_con := strconv.ParseFloat(ep_num_Str, 64)
 	if err_con != nil {
 		return c.Status(http.StatusBadRequest).JSON(
 			response.NewResponseError(
@@ -207,7 +219,7 @@ func (sc *ScrapingHandler) GetEpisode(c *fiber.Ctx) error {
 		)
 	}
 
-	resp, err := sc.ScrapingService(c).GetEpisodes(key, ep_num)
+	resp, err := sc.ScrapingService(c).GetEpisodes(c.Query("provider", DefaultProvider), key, ep_num)
 	if err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(
 			response.NewResponseError(
@@ -226,3 +238,58 @@ func (sc *ScrapingHandler) GetEpisode(c *fiber.Ctx) error {
 		),
 	)
 }
+
+// PurgeCache drops cached search/detail responses, narrowed by the kind, provider and key query params
+func (sc *ScrapingHandler) PurgeCache(c *fiber.Ctx) error {
+	kind := c.Query("kind")
+	if kind != "" && kind != CacheKindSearch && kind != CacheKindDetail {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("cache_purge_failed", nil, c),
+				-2004,
+				fmt.Errorf("%s", utils.Translate("invalid_cache_kind", nil, c)),
+			),
+		)
+	}
+
+	resp, err := sc.ScrapingService(c).PurgeCache(kind, c.Query("provider"), c.Query("key"))
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(
+			response.NewResponseError(
+				utils.Translate(err.MessageID, nil, c),
+				-2004,
+				fmt.Errorf("%s", utils.Translate(err.Err.Error(), nil, c)),
+			),
+		)
+	}
+
+	return c.Status(http.StatusOK).JSON(
+		response.NewResponse(
+			utils.Translate("cache_purge_success", nil, c),
+			2004,
+			resp,
+		),
+	)
+}
+
+func (sc *ScrapingHandler) BrowserHealth(c *fiber.Ctx) error {
+	return c.Status(http.StatusOK).JSON(
+		response.NewResponse(
+			utils.Translate("browser_health_success", nil, c),
+			2003,
+			sc.BrowserPool.Health(),
+		),
+	)
+}
+
+// writeSSE sends one named server-sent event with a JSON payload, an error means the client is gone
+func writeSSE(w *bufio.Writer, event string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		return err
+	}
+	return w.Flush()
+}
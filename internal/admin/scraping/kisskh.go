@@ -0,0 +1,711 @@
+package scraping
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"rerng_addicted_api/configs"
+	"rerng_addicted_api/internal/admin/serie"
+	"rerng_addicted_api/pkg/browser"
+	"rerng_addicted_api/pkg/hls"
+	custom_log "rerng_addicted_api/pkg/logs"
+	"rerng_addicted_api/pkg/netguard"
+	"rerng_addicted_api/pkg/proxyurl"
+	"rerng_addicted_api/pkg/responses"
+	"rerng_addicted_api/pkg/upstream"
+	"rerng_addicted_api/pkg/utils"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-rod/rod"
+)
+
+const (
+	kisskh_name       = "kisskh"
+	kisskh_base_url   = "https://kisskh.co"
+	kisskh_user_agent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 Chrome/115.0 Safari/537.36"
+)
+
+func init() {
+	RegisterProvider(kisskh_name, func(deps ProviderDeps) SourceProvider {
+		provider := NewKisskhProvider(deps.Browser)
+		provider.BaseURL = deps.BaseURL(kisskh_name, kisskh_base_url)
+		provider.Transport = deps.Transport
+		provider.Guard = deps.Guard
+		return provider
+	})
+}
+
+// EpisodeSniffer opens an episode page and reports the video url and subtitle api path it loads
+type EpisodeSniffer func(ep_url string, attempts int) (video_url string, sub_path string, err error)
+
+type KisskhProvider struct {
+	BaseURL string
+	Browser *browser.Pool
+	// Transport is used by every upstream http call, nil means http.DefaultTransport
+	Transport http.RoundTripper
+	// Guard checks the media urls the sniffer reports before they are fetched, nil skips the check
+	Guard *netguard.Guard
+	// UpstreamConfig tunes rate limit, retries and circuit breaker, nil loads it from the environment
+	UpstreamConfig *configs.UpstreamConfig
+	// Sniff resolves episode pages, it defaults to driving a pooled headless browser
+	Sniff EpisodeSniffer
+
+	upstream_once   sync.Once
+	upstream_client *upstream.Client
+}
+
+func NewKisskhProvider(browser_pool *browser.Pool) *KisskhProvider {
+	k := &KisskhProvider{
+		BaseURL: kisskh_base_url,
+		Browser: browser_pool,
+	}
+	k.Sniff = k.sniffEpisode
+	return k
+}
+
+// upstream returns the shared client for BaseURL, built on first use so BaseURL and Transport can be set after construction
+func (k *KisskhProvider) upstream() *upstream.Client {
+	k.upstream_once.Do(func() {
+		config := k.UpstreamConfig
+		if config == nil {
+			config = configs.Upstream()
+		}
+		k.upstream_client = upstream.NewClient(k.BaseURL, kisskh_user_agent, k.Transport, config)
+	})
+	return k.upstream_client
+}
+
+func (k *KisskhProvider) Name() string {
+	return kisskh_name
+}
+
+// getJSON calls an API path with the session cookies and decodes the body into out
+func (k *KisskhProvider) getJSON(api_path string, referer string, out interface{}) *responses.ErrorResponse {
+	err := k.upstream().GetJSON(context.Background(), api_path, map[string]string{"Referer": referer}, out)
+	if err == nil {
+		return nil
+	}
+
+	custom_log.NewCustomLog("scraping_failed", err.Error(), "error")
+	var status_err *upstream.StatusError
+	switch {
+	case errors.Is(err, upstream.ErrDecode):
+		return (&responses.ErrorResponse{}).NewErrorResponse("scraping_failed", fmt.Errorf("parse_data_failed"))
+	case errors.As(err, &status_err):
+		return (&responses.ErrorResponse{}).NewErrorResponse("scraping_failed", fmt.Errorf("fetch_api_failed"))
+	default:
+		// circuit open or the origin is unreachable
+		return (&responses.ErrorResponse{}).NewErrorResponse("scraping_failed", fmt.Errorf("original_source_error"))
+	}
+}
+
+func (k *KisskhProvider) Search(keyword string) (*SeriesResponse, *responses.ErrorResponse) {
+	var series_json []serie.SerieJSON
+	if err := k.getJSON(fmt.Sprintf("/api/DramaList/Search?q=%s&type=0", url.QueryEscape(keyword)), k.BaseURL+"/", &series_json); err != nil {
+		return nil, err
+	}
+
+	series := make([]serie.Serie, len(series_json))
+	for i, s := range series_json {
+		series[i] = serie.Serie{
+			ID:            s.ID,
+			Title:         s.Title,
+			EpisodesCount: s.EpisodesCount,
+			Label:         s.Label,
+			FavoriteID:    s.FavoriteID,
+			Thumbnail:     s.Thumbnail,
+			Provider:      kisskh_name,
+		}
+	}
+
+	return &SeriesResponse{
+		Series: series,
+	}, nil
+}
+
+func (k *KisskhProvider) ViewDetail(key string) (*SeriesDetailsResponse, *responses.ErrorResponse) {
+	var serie_detail_json serie.SerieDetailJSON
+	if err := k.getJSON(fmt.Sprintf("/api/DramaList/Drama/%s", key), k.BaseURL+"/", &serie_detail_json); err != nil {
+		return nil, err
+	}
+
+	episodes := make([]serie.Episode, len(serie_detail_json.Episodes))
+	for i, ep := range serie_detail_json.Episodes {
+		episodes[i] = serie.Episode{
+			ID:     ep.ID,
+			Number: ep.Number,
+			Sub:    ep.Sub,
+		}
+	}
+
+	serie_detail := serie.SerieDetail{
+		ID:            serie_detail_json.ID,
+		Title:         serie_detail_json.Title,
+		Description:   serie_detail_json.Description,
+		ReleaseDate:   serie_detail_json.ReleaseDate,
+		Trailer:       serie_detail_json.Trailer,
+		Country:       serie_detail_json.Country,
+		Status:        serie_detail_json.Status,
+		Type:          serie_detail_json.Type,
+		NextEpDateID:  serie_detail_json.NextEpDateID,
+		Episodes:      episodes,
+		EpisodesCount: serie_detail_json.EpisodesCount,
+		Label:         serie_detail_json.Label,
+		FavoriteID:    serie_detail_json.FavoriteID,
+		Thumbnail:     serie_detail_json.Thumbnail,
+		Provider:      kisskh_name,
+	}
+
+	return &SeriesDetailsResponse{
+		SeriesDetails: []serie.SerieDetail{
+			serie_detail,
+		},
+	}, nil
+}
+
+func (k *KisskhProvider) GetDeepDetail(key string, progress ProgressFunc) (*SeriesDeepDetailsResponse, *responses.ErrorResponse) {
+	// fetch series info
+	var serie_detail_json serie.SerieDeepDetailJSON
+	if err := k.getJSON(fmt.Sprintf("/api/DramaList/Drama/%s", key), k.BaseURL+"/", &serie_detail_json); err != nil {
+		return nil, err
+	}
+
+	episodes := make([]serie.EpisodeDeep, len(serie_detail_json.Episodes))
+	for i, ep := range serie_detail_json.Episodes {
+		subtitles := make([]serie.Subtitle, len(ep.Subtitles))
+		for j, sub := range ep.Subtitles {
+			subtitles[j] = serie.Subtitle{
+				Src:     sub.Src,
+				Label:   sub.Label,
+				Lang:    sub.Lang,
+				Default: sub.Default,
+			}
+		}
+
+		episodes[i] = serie.EpisodeDeep{
+			ID:         ep.ID,
+			SeriesID:   ep.SeriesID,
+			Number:     ep.Number,
+			Sub:        ep.Sub,
+			Source:     ep.Source,
+			Subtitles:  subtitles,
+			Provider:   kisskh_name,
+			Renditions: []serie.Rendition{},
+		}
+	}
+
+	var release_date *time.Time
+	if serie_detail_json.ReleaseDate != "" {
+		parsedTime, err := time.Parse("2006-01-02T15:04:05", serie_detail_json.ReleaseDate)
+		if err == nil {
+			release_date = &parsedTime
+		} else {
+			custom_log.NewCustomLog("date_parse_failed", err.Error(), "warning")
+		}
+	}
+
+	// the drama endpoint carries no genres or cast, so Genres, Tags and People stay nil
+	serie_detail := serie.SerieDeepDetail{
+		ID:            serie_detail_json.ID,
+		Title:         serie_detail_json.Title,
+		Description:   serie_detail_json.Description,
+		ReleaseDate:   release_date,
+		Trailer:       serie_detail_json.Trailer,
+		Country:       serie_detail_json.Country,
+		Status:        serie_detail_json.Status,
+		Type:          serie_detail_json.Type,
+		NextEpDateID:  serie_detail_json.NextEpDateID,
+		Episodes:      episodes,
+		EpisodesCount: serie_detail_json.EpisodesCount,
+		Label:         serie_detail_json.Label,
+		FavoriteID:    serie_detail_json.FavoriteID,
+		Thumbnail:     serie_detail_json.Thumbnail,
+		Provider:      kisskh_name,
+	}
+
+	total := len(serie_detail.Episodes)
+	progress.Emit(ProgressEvent{
+		Event:   ProgressSeriesFetched,
+		Message: serie_detail.Title,
+		SerieID: serie_detail.ID,
+		Total:   total,
+	})
+
+	// resolve episodes on pooled browser pages, a few at a time
+	concurrency := 6
+	limit := make(chan struct{}, concurrency)
+
+	var (
+		wg       sync.WaitGroup
+		done_mu  sync.Mutex
+		done_cnt int
+	)
+	// finished counts an episode as handled and returns its position for the progress event
+	finished := func() int {
+		done_mu.Lock()
+		defer done_mu.Unlock()
+		done_cnt++
+		return done_cnt
+	}
+
+	for i := range serie_detail.Episodes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			limit <- struct{}{}
+			defer func() { <-limit }()
+
+			ep := &serie_detail.Episodes[i]
+			fmt.Println("🎬 Processing Episode:", ep.Number)
+
+			// try to scrap up to 3 times
+			video_url, sub_path, err := k.Sniff(k.episodeURL(serie_detail.Title, serie_detail.ID, ep.ID, ep.Number), 3)
+			if err != nil {
+				fmt.Printf("❌ Failed to find video for ep %.0f after retries: %v\n", ep.Number, err)
+				progress.Emit(ProgressEvent{
+					Event:   ProgressEpisodeFailed,
+					Message: fmt.Sprintf("episode %.0f failed", ep.Number),
+					SerieID: serie_detail.ID,
+					Episode: &ep.Number,
+					Current: finished(),
+					Total:   total,
+					Error:   err.Error(),
+				})
+				return
+			}
+			fmt.Printf("✅ Found video for ep %.0f: %s\n", ep.Number, video_url)
+
+			ep.Source = k.proxySource(video_url, url.QueryEscape(video_url))
+			ep.OriginSource = video_url
+			ep.Renditions = k.fetchRenditions(video_url)
+
+			// handle subtitle fetching
+			if sub_path != "" {
+				if subs, err := k.fetchSubtitles(serie_detail.Title, sub_path); err == nil {
+					ep.Subtitles = subs
+					fmt.Printf("✅ Parsed %d subtitles for ep %.0f\n", len(subs), ep.Number)
+					progress.Emit(ProgressEvent{
+						Event:   ProgressSubtitlesParsed,
+						Message: fmt.Sprintf("parsed %d subtitles for episode %.0f", len(subs), ep.Number),
+						SerieID: serie_detail.ID,
+						Episode: &ep.Number,
+						Count:   len(subs),
+					})
+				}
+			}
+
+			progress.Emit(ProgressEvent{
+				Event:   ProgressEpisodeResolved,
+				Message: fmt.Sprintf("episode %.0f resolved", ep.Number),
+				SerieID: serie_detail.ID,
+				Episode: &ep.Number,
+				Current: finished(),
+				Total:   total,
+			})
+		}(i)
+	}
+
+	wg.Wait()
+
+	return &SeriesDeepDetailsResponse{
+		SeriesDeepDetails: []serie.SerieDeepDetail{serie_detail},
+	}, nil
+}
+
+func (k *KisskhProvider) ResolveEpisode(key int, number float64) (*EpisodesResponse, *responses.ErrorResponse) {
+	// fetch series detail
+	var serie_detail_json serie.SerieDeepDetailJSON
+	if err := k.getJSON(fmt.Sprintf("/api/DramaList/Drama/%d", key), k.BaseURL+"/", &serie_detail_json); err != nil {
+		return nil, err
+	}
+
+	// find the requested episode
+	var target_ep *serie.EpisodeDeepJSON
+	for _, ep := range serie_detail_json.Episodes {
+		if ep.Number == number {
+			target_ep = &ep
+			break
+		}
+	}
+	if target_ep == nil {
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("scraping_failed", fmt.Errorf("episode %g not found in series %d", number, key))
+	}
+
+	// navigate to episode and wait for video with retry
+	ep_url := k.episodeURL(serie_detail_json.Title, serie_detail_json.ID, target_ep.ID, target_ep.Number)
+	video_url, sub_path, sniff_err := k.Sniff(ep_url, 3)
+	if sniff_err != nil {
+		custom_log.NewCustomLog("scraping_failed", sniff_err.Error(), "error")
+		err_msg := &responses.ErrorResponse{}
+		return nil, err_msg.NewErrorResponse(
+			"scraping_failed",
+			fmt.Errorf("no video found for episode %g", number),
+		)
+	}
+	fmt.Println("✅ Found video:", video_url)
+
+	// mp4 sources are proxied by page url, the proxy rediscovers the media itself
+	proxy_video := k.proxySource(video_url, url.QueryEscape(ep_url))
+
+	// fetch subtitles
+	subtitles := []serie.Subtitle{}
+	if sub_path != "" {
+		if subs, err := k.fetchSubtitles(serie_detail_json.Title, sub_path); err == nil {
+			subtitles = subs
+		}
+	}
+
+	return &EpisodesResponse{
+		Episodes: []serie.EpisodeDeep{
+			{
+				ID:           target_ep.ID,
+				SeriesID:     key,
+				Number:       target_ep.Number,
+				Sub:          target_ep.Sub,
+				Source:       proxy_video,
+				Subtitles:    subtitles,
+				Provider:     kisskh_name,
+				OriginSource: video_url,
+				Renditions:   k.fetchRenditions(video_url),
+			},
+		},
+	}, nil
+}
+
+func (k *KisskhProvider) GetSubtitles(title string, sub_path string) (*SubtitlesResponse, *responses.ErrorResponse) {
+	subs, err := k.fetchSubtitles(title, sub_path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SubtitlesResponse{
+		Subtitles: subs,
+	}, nil
+}
+
+// fetchSubtitles reads the subtitle list behind sub_path and points every track at our subtitle proxy
+func (k *KisskhProvider) fetchSubtitles(title string, sub_path string) ([]serie.Subtitle, *responses.ErrorResponse) {
+	var subs_json []serie.SubtitleJSON
+	if err := k.getJSON(sub_path, fmt.Sprintf("%s/Drama/%s", k.BaseURL, slugify(title)), &subs_json); err != nil {
+		return nil, err
+	}
+
+	proxy_base := proxyBase()
+	subs := make([]serie.Subtitle, len(subs_json))
+	for j, sub := range subs_json {
+		trimmed := strings.TrimPrefix(sub.Src, "https://")
+		trimmed = strings.TrimPrefix(trimmed, "http://")
+		subs[j] = serie.Subtitle{
+			Src:     proxy_base + proxyurl.Default().Sign(proxyurl.WithProvider("/subtitle/"+trimmed, k.Name()), ""),
+			Label:   sub.Label,
+			Lang:    sub.Lang,
+			Default: sub.Default,
+		}
+	}
+	return subs, nil
+}
+
+// fetchRenditions reads the master playlist behind video_url and lists its variants, proxied like the src.
+// Renditions are optional, a failure is logged and leaves the episode with its single src.
+func (k *KisskhProvider) fetchRenditions(video_url string) []serie.Rendition {
+	renditions := []serie.Rendition{}
+	if !strings.Contains(video_url, ".m3u8") {
+		return renditions
+	}
+
+	base, err := url.Parse(video_url)
+	if err != nil {
+		return renditions
+	}
+	req, err := http.NewRequest(http.MethodGet, video_url, nil)
+	if err != nil {
+		return renditions
+	}
+	// same headers the m3u8 proxy sends
+	req.Header.Set("User-Agent", kisskh_user_agent)
+	req.Header.Set("Referer", "https://"+base.Host)
+	req.Header.Set("Origin", "https://"+base.Host)
+
+	client := &http.Client{Transport: k.Transport, Timeout: 15 * time.Second}
+	if k.Guard != nil {
+		// the playlist url comes from the page, so it gets the same checks as the proxy gives it
+		if _, err := k.Guard.CheckURL(req.Context(), k.Name(), video_url); err != nil {
+			custom_log.NewCustomLog("renditions_failed", err.Error(), "warn")
+			return renditions
+		}
+		client = k.Guard.Client(k.Name(), 15*time.Second)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		custom_log.NewCustomLog("renditions_failed", err.Error(), "warn")
+		return renditions
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		custom_log.NewCustomLog("renditions_failed", fmt.Sprintf("%s returned %d", video_url, resp.StatusCode), "warn")
+		return renditions
+	}
+
+	master, err := hls.ParseMaster(io.LimitReader(resp.Body, 1<<20), base)
+	if err != nil {
+		custom_log.NewCustomLog("renditions_failed", err.Error(), "warn")
+		return renditions
+	}
+	for _, variant := range master.Variants {
+		renditions = append(renditions, serie.Rendition{
+			Bandwidth:        variant.Bandwidth,
+			AverageBandwidth: variant.AverageBandwidth,
+			Width:            variant.Width,
+			Height:           variant.Height,
+			Codecs:           variant.Codecs,
+			FrameRate:        variant.FrameRate,
+			Src:              k.proxySource(variant.URI, ""),
+			OriginSource:     variant.URI,
+		})
+	}
+	return renditions
+}
+
+func (k *KisskhProvider) episodeURL(title string, serie_id int, ep_id int, ep_number float64) string {
+	return fmt.Sprintf(
+		"%s/Drama/%s/Episode-%d?id=%d&ep=%d&page=0&pageSize=100",
+		k.BaseURL,
+		slugify(title),
+		int(ep_number),
+		serie_id,
+		ep_id,
+	)
+}
+
+// sniffEpisode loads an episode page on a pooled browser page and returns the first video url
+// and subtitle path the sniffer reports, each attempt gets a fresh page
+func (k *KisskhProvider) sniffEpisode(ep_url string, attempts int) (string, string, error) {
+	if k.Browser == nil {
+		return "", "", fmt.Errorf("no browser pool configured")
+	}
+
+	var last_err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		var video_url, sub_path string
+
+		err := k.Browser.WithPage(context.Background(), func(page *rod.Page) error {
+			page.MustNavigate(ep_url).MustWaitLoad()
+			page.Eval(kisskh_sniff_js)
+			page.Eval(`() => { const v = document.querySelector('video'); if (v) { v.muted = true; v.play && v.play().catch(()=>{}); } }`)
+
+			val, err := page.Timeout(5 * time.Second).Eval(`() => window.waitForVideo`)
+			if err != nil {
+				return fmt.Errorf("wait for video: %w", err)
+			}
+
+			// convert gson.JSON to map
+			if url_json, ok := val.Value.Map()["url"]; ok {
+				video_url = url_json.Str()
+			}
+			if video_url == "" {
+				return fmt.Errorf("no video url reported")
+			}
+
+			if sub, err := page.Eval(`() => window.__sub_found ? window.__sub_found.url : null`); err == nil {
+				sub_path = sub.Value.Str()
+			}
+			return nil
+		})
+		if err == nil {
+			return video_url, sub_path, nil
+		}
+		last_err = err
+
+		if attempt < attempts-1 {
+			backoff := time.Duration(2+attempt*3) * time.Second
+			fmt.Printf("🔁 Retrying %s (attempt %d/%d) after %v...\n", ep_url, attempt+1, attempts-1, backoff)
+			time.Sleep(backoff)
+		}
+	}
+	return "", "", last_err
+}
+
+// proxyBase is the absolute url the proxy routes are mounted at, signed paths are appended to it
+func proxyBase() string {
+	host := os.Getenv("API_HOST")
+	port := utils.GetenvInt("API_PORT", 8585)
+	return fmt.Sprintf("http://%s:%d%s", host, port, proxyurl.Mount)
+}
+
+// proxySource maps a sniffed video url to the matching proxy endpoint, signed so the proxy accepts it
+// and tagged so it checks the url against this provider's hosts, mp4_target is what the mp4 proxy receives in its url query
+func (k *KisskhProvider) proxySource(video_url string, mp4_target string) string {
+	proxy_base := proxyBase()
+
+	mime := getMimeFromURL(video_url)
+	if strings.Contains(video_url, ".m3u8") || mime == "application/vnd.apple.mpegurl" {
+		trimmed := strings.TrimPrefix(video_url, "https://")
+		trimmed = strings.TrimPrefix(trimmed, "http://")
+		return proxy_base + proxyurl.Default().Sign(proxyurl.WithProvider("/m3u8/"+trimmed, k.Name()), "")
+	} else if strings.Contains(video_url, ".mp4") || mime == "video/mp4" {
+		return proxy_base + proxyurl.Default().Sign(proxyurl.WithProvider("/mp4?url="+mp4_target, k.Name()), "")
+	}
+	return video_url
+}
+
+func getMimeFromURL(u string) string {
+	if strings.Contains(u, ".m3u8") {
+		return "application/vnd.apple.mpegurl"
+	}
+	if strings.Contains(u, ".mp4") {
+		return "video/mp4"
+	}
+	return ""
+}
+
+func slugify(title string) string {
+	slug := strings.TrimSpace(title)
+	slug = strings.ReplaceAll(slug, " ", "-")
+	slug = strings.ReplaceAll(slug, "(", "-")
+	slug = strings.ReplaceAll(slug, ")", "-")
+
+	// Collapse multiple dashes
+	re := regexp.MustCompile(`-+`)
+	slug = re.ReplaceAllString(slug, "-")
+
+	return slug
+}
+
+// sniffer injected into episode pages, reports the first video/subtitle request it sees
+const kisskh_sniff_js = `() => {
+		if (window.__scrape_sniffer_ready) return;
+		window.__scrape_sniffer_ready = true;
+		window.__scrape_sniffer_results = [];
+
+		const push_result = (t, info) => {
+			try {
+				window.__scrape_sniffer_results.push({ t, info, ts: Date.now() });
+				if (t === 'xhr_video' || t === 'video_element') window.__video_found = info;
+				if (t === 'xhr_sub') window.__sub_found = info;
+			} catch (e) {}
+		};
+
+		// Hook XMLHttpRequest
+		const orig_open = XMLHttpRequest.prototype.open;
+		const orig_send = XMLHttpRequest.prototype.send;
+		XMLHttpRequest.prototype.open = function(m, u) { this.__url = u; return orig_open.apply(this, arguments); };
+		XMLHttpRequest.prototype.send = function() {
+			const url = this.__url || "";
+			this.addEventListener('load', function() {
+				const type = this.getResponseHeader('content-type') || "";
+				if (url.includes('.m3u8') || url.includes('/hls') || type.includes('application/vnd.apple.mpegurl'))
+					push_result('xhr_video', { url });
+				else if (url.includes('/api/Sub/'))
+					push_result('xhr_sub', { url });
+			});
+			return orig_send.apply(this, arguments);
+		};
+
+		// Hook fetch
+		const orig_fetch = window.fetch;
+		window.fetch = async (i, init) => {
+			const req_url = typeof i === 'string' ? i : (i && i.url) || "";
+			try {
+				if (req_url && (req_url.includes('.m3u8') || req_url.includes('/hls')))
+					push_result('xhr_video', { url: req_url });
+			} catch (e) {}
+			const resp = await orig_fetch(i, init);
+			try {
+				const type = resp && resp.headers && resp.headers.get ? (resp.headers.get('content-type') || "") : "";
+				if (type.includes('application/vnd.apple.mpegurl'))
+					push_result('xhr_video', { url: req_url });
+			} catch (e) {}
+			return resp;
+		};
+
+		// Watch <video>
+		const watch_video = v => {
+			if (!v || v.__watched) return;
+			v.__watched = true;
+			const report = () => {
+				const s = v.currentSrc || v.src || "";
+				if (s.includes('.mp4') || s.includes('.m3u8')) push_result('video_element', { url: s });
+				else if (s.startsWith('blob:')) push_result('video_blob', { url: s });
+			};
+			report();
+			v.addEventListener('loadedmetadata', report);
+			new MutationObserver(report).observe(v, { attributes: true, attributeFilter: ['src'] });
+		};
+		document.querySelectorAll('video').forEach(watch_video);
+
+		// Watch <iframe> for src OR data-src attributes (countdown or lazyload)
+		const isPlayerOrCountdown = src => {
+			if (!src || typeof src !== 'string') return false;
+			const s = src.toLowerCase();
+			return s.includes('countdown') || s.includes('tickcounter') || s.includes('/player/') || s.includes('.m3u8') || s.includes('/hls');
+		};
+
+		const normalizeURL = u => {
+			if (!u) return '';
+			if (u.startsWith('//')) return 'https:' + u;
+			return u;
+		};
+
+		const watch_iframe = ifr => {
+			if (!ifr || ifr.__iframe_watched) return;
+			ifr.__iframe_watched = true;
+			const report = () => {
+				try {
+					let src = ifr.getAttribute('src') || "";
+					let dataSrc = ifr.getAttribute('data-src') || "";
+					let finalSrc = src || dataSrc;
+					finalSrc = normalizeURL(finalSrc);
+					if (isPlayerOrCountdown(finalSrc)) {
+						push_result('video_element', { url: finalSrc });
+					}
+				} catch (e) {}
+			};
+			report();
+
+			// Watch for src or data-src changes
+			new MutationObserver(() => report()).observe(ifr, { attributes: true, attributeFilter: ['src', 'data-src'] });
+		};
+
+		// Existing iframes
+		document.querySelectorAll('iframe').forEach(watch_iframe);
+
+		// Watch DOM changes
+		new MutationObserver(muts => {
+			muts.forEach(m => {
+				m.addedNodes.forEach(n => {
+					try {
+						const tag = (n && n.tagName) ? n.tagName.toUpperCase() : '';
+						if (tag === 'VIDEO') watch_video(n);
+						if (tag === 'IFRAME') watch_iframe(n);
+						if (n.querySelectorAll) {
+							n.querySelectorAll('video').forEach(watch_video);
+							n.querySelectorAll('iframe').forEach(watch_iframe);
+						}
+					} catch (e) {}
+				});
+			});
+		}).observe(document.body, { childList: true, subtree: true });
+
+		window.waitForVideo = new Promise(r => {
+			const check = () => {
+				if (window.__video_found) return r(window.__video_found);
+				try {
+					for (const it of window.__scrape_sniffer_results) {
+						if (it && (it.t === 'video_element' || it.t === 'xhr_video')) {
+							window.__video_found = it.info;
+							return r(it.info);
+						}
+					}
+				} catch (e) {}
+				setTimeout(check, 500);
+			};
+			check();
+		});
+	}`
@@ -0,0 +1,364 @@
+package scraping_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"rerng_addicted_api/configs"
+	"rerng_addicted_api/internal/admin/scraping"
+	"rerng_addicted_api/internal/admin/scraping/kisskhtest"
+	"rerng_addicted_api/internal/admin/serie"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	// signed proxy urls need a key, the real one comes from the environment
+	os.Setenv("PROXY_SIGNING_KEY", "kisskh-test")
+	os.Exit(m.Run())
+}
+
+// fastUpstream keeps failing calls from sleeping through retries and backoff
+func fastUpstream() *configs.UpstreamConfig {
+	return &configs.UpstreamConfig{
+		RatePerSecond:    1000,
+		Burst:            1000,
+		MaxRetries:       0,
+		BreakerThreshold: 100,
+		BreakerCooldown:  time.Second,
+		SessionTTL:       time.Minute,
+		RequestTimeout:   5 * time.Second,
+	}
+}
+
+func newProvider(t *testing.T) (*kisskhtest.Server, *scraping.KisskhProvider) {
+	t.Helper()
+
+	server := kisskhtest.NewServer()
+	t.Cleanup(server.Close)
+
+	provider := server.Provider()
+	provider.UpstreamConfig = fastUpstream()
+	return server, provider
+}
+
+func TestKisskhSearch(t *testing.T) {
+	tests := []struct {
+		keyword string
+		want    []int
+	}{
+		{keyword: "fixture", want: []int{1001, 1002}},
+		{keyword: "movie", want: []int{1002}},
+		{keyword: "nothing like this", want: []int{}},
+	}
+
+	_, provider := newProvider(t)
+	for _, tt := range tests {
+		t.Run(tt.keyword, func(t *testing.T) {
+			resp, err := provider.Search(tt.keyword)
+			if err != nil {
+				t.Fatalf("Search(%q) failed: %v", tt.keyword, err)
+			}
+
+			got := []int{}
+			for _, s := range resp.Series {
+				got = append(got, s.ID)
+				if s.Provider != "kisskh" {
+					t.Errorf("serie %d provider = %q, want kisskh", s.ID, s.Provider)
+				}
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Search(%q) ids = %v, want %v", tt.keyword, got, tt.want)
+			}
+		})
+	}
+
+	resp, err := provider.Search("movie")
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	movie := resp.Series[0]
+	if movie.Title != "Fixture Movie" || movie.EpisodesCount != 1 || movie.Label != "HD" ||
+		movie.Thumbnail != "https://fixtures.local/images/1002.png" {
+		t.Errorf("mapped serie = %+v", movie)
+	}
+}
+
+func TestKisskhViewDetail(t *testing.T) {
+	_, provider := newProvider(t)
+
+	resp, err := provider.ViewDetail("1001")
+	if err != nil {
+		t.Fatalf("ViewDetail failed: %v", err)
+	}
+	if len(resp.SeriesDetails) != 1 {
+		t.Fatalf("got %d series, want 1", len(resp.SeriesDetails))
+	}
+
+	detail := resp.SeriesDetails[0]
+	want := serie.SerieDetail{
+		ID:            1001,
+		Title:         "Fixture Drama",
+		Description:   "A recorded drama used to exercise the scraper offline.",
+		Country:       "South Korea",
+		Status:        "Ongoing",
+		Type:          "TVSeries",
+		EpisodesCount: 3,
+		Thumbnail:     "https://fixtures.local/images/1001.png",
+		Provider:      "kisskh",
+	}
+	if detail.ID != want.ID || detail.Title != want.Title || detail.Description != want.Description ||
+		detail.Country != want.Country || detail.Status != want.Status || detail.Type != want.Type ||
+		detail.EpisodesCount != want.EpisodesCount || detail.Thumbnail != want.Thumbnail || detail.Provider != want.Provider {
+		t.Errorf("ViewDetail mapped %+v, want %+v", detail, want)
+	}
+	if detail.Label != nil {
+		t.Errorf("label = %q, want nil for a null label", *detail.Label)
+	}
+
+	numbers := []float64{}
+	for _, ep := range detail.Episodes {
+		numbers = append(numbers, ep.Number)
+	}
+	if fmt.Sprint(numbers) != "[3 2 1]" {
+		t.Errorf("episode numbers = %v, want upstream order [3 2 1]", numbers)
+	}
+}
+
+func TestKisskhGetDeepDetail(t *testing.T) {
+	_, provider := newProvider(t)
+
+	var (
+		mu     sync.Mutex
+		events []scraping.ProgressEvent
+	)
+	resp, err := provider.GetDeepDetail("1001", func(event scraping.ProgressEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	})
+	if err != nil {
+		t.Fatalf("GetDeepDetail failed: %v", err)
+	}
+
+	detail := resp.SeriesDeepDetails[0]
+	if detail.ReleaseDate == nil || !detail.ReleaseDate.Equal(time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("release date = %v, want 2025-01-15", detail.ReleaseDate)
+	}
+	if detail.Genres != nil || detail.Tags != nil || detail.People != nil {
+		t.Errorf("kisskh exposes no metadata, got genres %v tags %v people %v", detail.Genres, detail.Tags, detail.People)
+	}
+
+	episodes := map[float64]serie.EpisodeDeep{}
+	for _, ep := range detail.Episodes {
+		episodes[ep.Number] = ep
+	}
+
+	tests := []struct {
+		number     float64
+		id         int
+		sub        int
+		resolved   bool
+		subtitles  []string
+		renditions []int
+	}{
+		// master playlist, two tracks and three variants
+		{number: 1, id: 50001, sub: 2, resolved: true, subtitles: []string{"en", "km"}, renditions: []int{360, 720, 1080}},
+		// media playlist, nothing to list as renditions
+		{number: 2, id: 50002, sub: 2, resolved: true, subtitles: []string{"en", "km"}, renditions: []int{}},
+		// no recorded video, the page never plays
+		{number: 3, id: 50003, sub: 0, resolved: false, subtitles: []string{}, renditions: []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("episode %g", tt.number), func(t *testing.T) {
+			ep, ok := episodes[tt.number]
+			if !ok {
+				t.Fatalf("episode %g missing", tt.number)
+			}
+			if ep.ID != tt.id || ep.SeriesID != 1001 || ep.Sub != tt.sub || ep.Provider != "kisskh" {
+				t.Errorf("mapped episode = %+v", ep)
+			}
+
+			if !tt.resolved {
+				if ep.Source != "" || ep.OriginSource != "" {
+					t.Errorf("failed episode kept src %q origin %q", ep.Source, ep.OriginSource)
+				}
+				return
+			}
+			if !strings.HasSuffix(ep.OriginSource, fmt.Sprintf("/hls/%d/index.m3u8", tt.id)) {
+				t.Errorf("origin src = %q", ep.OriginSource)
+			}
+			if !strings.Contains(ep.Source, "/m3u8/"+strings.TrimPrefix(ep.OriginSource, "http://")+"?") {
+				t.Errorf("src %q does not proxy origin %q", ep.Source, ep.OriginSource)
+			}
+			if !strings.Contains(ep.Source, "px_sig=") {
+				t.Errorf("src %q is not signed", ep.Source)
+			}
+
+			langs := []string{}
+			for _, sub := range ep.Subtitles {
+				langs = append(langs, sub.Lang)
+				if !strings.Contains(sub.Src, "/subtitle/fixtures.local/subs/") {
+					t.Errorf("subtitle src %q is not proxied", sub.Src)
+				}
+			}
+			if fmt.Sprint(langs) != fmt.Sprint(tt.subtitles) {
+				t.Errorf("subtitle langs = %v, want %v", langs, tt.subtitles)
+			}
+
+			heights := []int{}
+			for _, rendition := range ep.Renditions {
+				heights = append(heights, rendition.Height)
+			}
+			if fmt.Sprint(heights) != fmt.Sprint(tt.renditions) {
+				t.Errorf("rendition heights = %v, want %v", heights, tt.renditions)
+			}
+		})
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	resolved, failed := []float64{}, []float64{}
+	for _, event := range events {
+		switch event.Event {
+		case scraping.ProgressEpisodeResolved:
+			resolved = append(resolved, *event.Episode)
+		case scraping.ProgressEpisodeFailed:
+			failed = append(failed, *event.Episode)
+			if event.Error == "" {
+				t.Errorf("failed event for episode %g has no error", *event.Episode)
+			}
+		}
+	}
+	sort.Float64s(resolved)
+	if fmt.Sprint(resolved) != "[1 2]" || fmt.Sprint(failed) != "[3]" {
+		t.Errorf("progress resolved %v failed %v, want [1 2] and [3]", resolved, failed)
+	}
+}
+
+func TestKisskhResolveEpisode(t *testing.T) {
+	tests := []struct {
+		name   string
+		number float64
+		// want_src is empty when the episode should fail
+		want_src string
+	}{
+		{name: "hls", number: 1, want_src: "/m3u8/"},
+		{name: "not listed", number: 7},
+		{name: "no video", number: 3},
+	}
+
+	_, provider := newProvider(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := provider.ResolveEpisode(1001, tt.number)
+			if tt.want_src == "" {
+				if err == nil {
+					t.Fatalf("ResolveEpisode(%g) = %+v, want an error", tt.number, resp)
+				}
+				if err.MessageID != "scraping_failed" {
+					t.Errorf("message id = %q, want scraping_failed", err.MessageID)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolveEpisode(%g) failed: %v", tt.number, err)
+			}
+			if len(resp.Episodes) != 1 || !strings.Contains(resp.Episodes[0].Source, tt.want_src) {
+				t.Errorf("ResolveEpisode(%g) = %+v", tt.number, resp.Episodes)
+			}
+		})
+	}
+}
+
+func TestKisskhMovieResolvesToMp4Proxy(t *testing.T) {
+	_, provider := newProvider(t)
+
+	resp, err := provider.GetDeepDetail("1002", nil)
+	if err != nil {
+		t.Fatalf("GetDeepDetail failed: %v", err)
+	}
+	ep := resp.SeriesDeepDetails[0].Episodes[0]
+	if !strings.Contains(ep.Source, "/mp4?url=") {
+		t.Errorf("mp4 src = %q, want the mp4 proxy", ep.Source)
+	}
+	if len(ep.Renditions) != 0 {
+		t.Errorf("mp4 renditions = %v, want none", ep.Renditions)
+	}
+	if label := resp.SeriesDeepDetails[0].Label; label == nil || *label != "HD" {
+		t.Errorf("label = %v, want HD", label)
+	}
+}
+
+func TestKisskhUpstreamErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		key     string
+		want    string
+	}{
+		{
+			name: "missing drama",
+			key:  "9999",
+			want: "fetch_api_failed",
+		},
+		{
+			name: "server error",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/" {
+					http.SetCookie(w, &http.Cookie{Name: "kisskh_session", Value: "fixture"})
+					return
+				}
+				http.Error(w, "down", http.StatusBadGateway)
+			},
+			key:  "1001",
+			want: "fetch_api_failed",
+		},
+		{
+			name: "not json",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "<html>cloudflare</html>")
+			},
+			key:  "1001",
+			want: "parse_data_failed",
+		},
+		{
+			name: "unreachable",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+			},
+			key:  "1001",
+			want: "original_source_error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var provider *scraping.KisskhProvider
+			if tt.handler == nil {
+				_, provider = newProvider(t)
+			} else {
+				server := httptest.NewServer(tt.handler)
+				provider = scraping.NewKisskhProvider(nil)
+				provider.BaseURL = server.URL
+				provider.UpstreamConfig = fastUpstream()
+				if tt.want == "original_source_error" {
+					server.Close()
+				} else {
+					t.Cleanup(server.Close)
+				}
+			}
+
+			_, err := provider.ViewDetail(tt.key)
+			if err == nil {
+				t.Fatalf("ViewDetail(%s) succeeded, want %s", tt.key, tt.want)
+			}
+			if err.MessageID != "scraping_failed" || err.Err.Error() != tt.want {
+				t.Errorf("error = %s / %v, want scraping_failed / %s", err.MessageID, err.Err, tt.want)
+			}
+		})
+	}
+}
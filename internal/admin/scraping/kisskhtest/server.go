@@ -0,0 +1,174 @@
+// Package kisskhtest replays recorded kisskh responses from a local http server,
+// so the kisskh provider can be exercised without the live site or a browser.
+package kisskhtest
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"rerng_addicted_api/internal/admin/scraping"
+	"strings"
+	"sync"
+)
+
+//go:embed fixtures/*.json fixtures/*.m3u8
+var fixtures embed.FS
+
+// session cookie handed out by "/" and required by every api call, like upstream
+const session_cookie = "kisskh_session"
+
+// cdn host used by recorded video urls, Sniff points it at this server so playlists replay too
+const fixture_cdn = "https://cdn.fixtures.local"
+
+// SniffResult is what a real browser would have observed on an episode page
+type SniffResult struct {
+	Video string `json:"video"`
+	Sub   string `json:"sub"`
+}
+
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []string
+	episodes map[string]SniffResult
+}
+
+// NewServer starts a replay server, callers must Close it
+func NewServer() *Server {
+	s := &Server{
+		episodes: map[string]SniffResult{},
+	}
+	if raw, err := fixtures.ReadFile("fixtures/episodes.json"); err == nil {
+		_ = json.Unmarshal(raw, &s.episodes)
+	}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Provider returns a kisskh provider wired to this server, episode pages resolve from fixtures instead of a browser
+func (s *Server) Provider() *scraping.KisskhProvider {
+	provider := scraping.NewKisskhProvider(nil)
+	provider.BaseURL = s.URL
+	provider.Transport = s.Client().Transport
+	provider.Sniff = s.Sniff
+	return provider
+}
+
+// Sniff mimics the browser sniffer, episodes without a recorded result fail like a page that never played
+func (s *Server) Sniff(ep_url string, attempts int) (string, string, error) {
+	s.record("SNIFF " + ep_url)
+
+	parsed, err := url.Parse(ep_url)
+	if err != nil {
+		return "", "", err
+	}
+
+	result, ok := s.episodes[parsed.Query().Get("ep")]
+	if !ok {
+		return "", "", fmt.Errorf("no video url reported")
+	}
+	return strings.Replace(result.Video, fixture_cdn, s.URL, 1), result.Sub, nil
+}
+
+// Requests lists every request seen so far as "METHOD path?query"
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.requests...)
+}
+
+func (s *Server) record(request string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, request)
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.record(r.Method + " " + r.URL.RequestURI())
+
+	// playlists live on the cdn, which needs no session
+	if strings.HasPrefix(r.URL.Path, "/hls/") && strings.HasSuffix(r.URL.Path, "/index.m3u8") {
+		s.servePlaylist(w, r, path.Base(path.Dir(r.URL.Path)))
+		return
+	}
+
+	if r.URL.Path == "/" {
+		http.SetCookie(w, &http.Cookie{Name: session_cookie, Value: "fixture", Path: "/"})
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<html><body>kisskh fixture</body></html>")
+		return
+	}
+
+	if !strings.Contains(r.Header.Get("Cookie"), session_cookie+"=") {
+		http.Error(w, "missing session", http.StatusForbidden)
+		return
+	}
+
+	switch {
+	case r.URL.Path == "/api/DramaList/Search":
+		s.serveSearch(w, r.URL.Query().Get("q"))
+	case strings.HasPrefix(r.URL.Path, "/api/DramaList/Drama/"):
+		s.serveFixture(w, r, "drama_"+path.Base(r.URL.Path))
+	case strings.HasPrefix(r.URL.Path, "/api/Sub/"):
+		s.serveFixture(w, r, "sub_"+path.Base(r.URL.Path))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveSearch filters the recorded search results by title, like upstream's search
+func (s *Server) serveSearch(w http.ResponseWriter, keyword string) {
+	raw, err := fixtures.ReadFile("fixtures/search.json")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var items []map[string]interface{}
+	if err := json.Unmarshal(raw, &items); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	keyword = strings.ToLower(strings.TrimSpace(keyword))
+	matches := []map[string]interface{}{}
+	for _, item := range items {
+		title, _ := item["title"].(string)
+		if keyword == "" || strings.Contains(strings.ToLower(title), keyword) {
+			matches = append(matches, item)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(matches)
+}
+
+func (s *Server) serveFixture(w http.ResponseWriter, r *http.Request, name string) {
+	raw, err := fixtures.ReadFile("fixtures/" + name + ".json")
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(raw)
+}
+
+func (s *Server) servePlaylist(w http.ResponseWriter, r *http.Request, episode_id string) {
+	raw, err := fixtures.ReadFile("fixtures/hls_" + episode_id + ".m3u8")
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	_, _ = w.Write(raw)
+}
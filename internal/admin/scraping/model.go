@@ -17,3 +17,65 @@ type SeriesDetailsResponse struct {
 type EpisodesResponse struct {
 	Episodes []serie.EpisodeDeep `json:"episodes"`
 }
+
+type SubtitlesResponse struct {
+	Subtitles []serie.Subtitle `json:"subtitles"`
+}
+
+type RefreshResponse struct {
+	SerieID     int       `json:"serie_id"`
+	Checked     int       `json:"checked"`
+	NewEpisodes []float64 `json:"new_episodes"`
+	Failed      []float64 `json:"failed"`
+}
+
+type CachePurgeResponse struct {
+	Purged int64 `json:"purged"`
+}
+
+// seed outcome per series
+const (
+	SeedNew       = "new"
+	SeedUpdated   = "updated"
+	SeedUnchanged = "unchanged"
+	SeedSkipped   = "skipped"
+	SeedFailed    = "failed"
+)
+
+type SeedOptions struct {
+	Keys []int
+	// DryRun scrapes and diffs but writes nothing
+	DryRun bool
+	// OnlyMissing skips series that are already stored
+	OnlyMissing bool
+	Parallel    int
+}
+
+type SeedResult struct {
+	SerieID        int       `json:"serie_id"`
+	Title          string    `json:"title"`
+	State          string    `json:"state"`
+	Episodes       int       `json:"episodes"`
+	NewEpisodes    []float64 `json:"new_episodes"`
+	ChangedSources []float64 `json:"changed_sources"`
+	FailedEpisodes []float64 `json:"failed_episodes"`
+	Error          string    `json:"error,omitempty"`
+}
+
+type SeedReport struct {
+	DryRun         bool         `json:"dry_run"`
+	Results        []SeedResult `json:"results"`
+	New            int          `json:"new"`
+	Updated        int          `json:"updated"`
+	Unchanged      int          `json:"unchanged"`
+	Skipped        int          `json:"skipped"`
+	Failed         int          `json:"failed"`
+	NewEpisodes    int          `json:"new_episodes"`
+	ChangedSources int          `json:"changed_sources"`
+	FailedEpisodes int          `json:"failed_episodes"`
+}
+
+// HasFailures is true when a series or any of its episodes could not be scraped or stored
+func (r *SeedReport) HasFailures() bool {
+	return r.Failed > 0 || r.FailedEpisodes > 0
+}
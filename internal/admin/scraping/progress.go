@@ -0,0 +1,38 @@
+package scraping
+
+// progress event names streamed to the admin UI
+const (
+	ProgressSeriesFetched   = "series_fetched"
+	ProgressEpisodeResolved = "episode_resolved"
+	ProgressEpisodeFailed   = "episode_failed"
+	ProgressSubtitlesParsed = "subtitles_parsed"
+	ProgressUpsertDone      = "upsert_done"
+	ProgressCompleted       = "completed"
+)
+
+type ProgressEvent struct {
+	Event   string    `json:"event"`
+	Message string    `json:"message"`
+	SerieID int       `json:"serie_id,omitempty"`
+	Episode *float64  `json:"episode,omitempty"`
+	Current int       `json:"current"`
+	Total   int       `json:"total"`
+	Percent int       `json:"percent,omitempty"`
+	Count   int       `json:"count,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	Failed  []float64 `json:"failed,omitempty"`
+}
+
+// ProgressFunc receives scrape progress, it may be called from several goroutines at once
+type ProgressFunc func(event ProgressEvent)
+
+// Emit fills in the percentage and forwards the event, a nil func discards it
+func (p ProgressFunc) Emit(event ProgressEvent) {
+	if p == nil {
+		return
+	}
+	if event.Total > 0 && event.Current > 0 && event.Percent == 0 {
+		event.Percent = event.Current * 100 / event.Total
+	}
+	p(event)
+}
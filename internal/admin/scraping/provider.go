@@ -0,0 +1,114 @@
+package scraping
+
+import (
+	"fmt"
+	"net/http"
+	"rerng_addicted_api/internal/admin/serie"
+	"rerng_addicted_api/pkg/browser"
+	"rerng_addicted_api/pkg/netguard"
+	"rerng_addicted_api/pkg/responses"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// default provider used when the request does not specify one
+const DefaultProvider = serie.DefaultProvider
+
+// SourceProvider is implemented by every upstream catalog we can scrape from.
+// Each provider owns its own URLs, cookies and JSON shapes and maps them into the serie models.
+type SourceProvider interface {
+	// Name returns the registry key, also stored in tbl_series.provider / tbl_episodes.provider
+	Name() string
+	Search(keyword string) (*SeriesResponse, *responses.ErrorResponse)
+	ViewDetail(key string) (*SeriesDetailsResponse, *responses.ErrorResponse)
+	// GetDeepDetail resolves every episode, reporting each step to progress (which may be nil).
+	// Providers whose upstream exposes genres, tags or cast set them on the serie, the rest leave them nil.
+	GetDeepDetail(key string, progress ProgressFunc) (*SeriesDeepDetailsResponse, *responses.ErrorResponse)
+	// ResolveEpisode resolves the episode with the given number, fractional numbers like 10.5 included
+	ResolveEpisode(key int, number float64) (*EpisodesResponse, *responses.ErrorResponse)
+	// GetSubtitles fetches the subtitle tracks exposed at sub_path (as discovered while resolving an episode)
+	GetSubtitles(title string, sub_path string) (*SubtitlesResponse, *responses.ErrorResponse)
+}
+
+// ProviderDeps carries the process-wide services a provider may need
+type ProviderDeps struct {
+	Browser *browser.Pool
+	// Transport overrides the http transport of upstream calls, nil keeps the default
+	Transport http.RoundTripper
+	// Guard checks media urls found on pages before they are fetched, nil skips the check
+	Guard *netguard.Guard
+	// BaseURLs overrides a provider's upstream origin by provider name, e.g. to point at a local replay server
+	BaseURLs map[string]string
+}
+
+// BaseURL returns the configured origin for a provider, or fallback when none is set
+func (d ProviderDeps) BaseURL(name string, fallback string) string {
+	if base_url := strings.TrimRight(d.BaseURLs[name], "/"); base_url != "" {
+		return base_url
+	}
+	return fallback
+}
+
+type ProviderFactory func(deps ProviderDeps) SourceProvider
+
+var (
+	provider_mu        sync.RWMutex
+	provider_factories = map[string]ProviderFactory{}
+)
+
+// RegisterProvider makes a provider available by name, providers register themselves from init()
+func RegisterProvider(name string, factory ProviderFactory) {
+	provider_mu.Lock()
+	defer provider_mu.Unlock()
+
+	if _, exists := provider_factories[name]; exists {
+		panic(fmt.Sprintf("scraping: provider %q registered twice", name))
+	}
+	provider_factories[name] = factory
+}
+
+// ProviderNames lists every registered provider sorted by name
+func ProviderNames() []string {
+	provider_mu.RLock()
+	defer provider_mu.RUnlock()
+
+	names := make([]string, 0, len(provider_factories))
+	for name := range provider_factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Providers holds one instance of every registered provider, built once per process
+type Providers struct {
+	items map[string]SourceProvider
+}
+
+func NewProviders(deps ProviderDeps) *Providers {
+	provider_mu.RLock()
+	defer provider_mu.RUnlock()
+
+	items := make(map[string]SourceProvider, len(provider_factories))
+	for name, factory := range provider_factories {
+		items[name] = factory(deps)
+	}
+
+	return &Providers{
+		items: items,
+	}
+}
+
+// Get returns the provider registered under name, an empty name resolves to DefaultProvider
+func (p *Providers) Get(name string) (SourceProvider, *responses.ErrorResponse) {
+	if name == "" {
+		name = DefaultProvider
+	}
+
+	provider, ok := p.items[name]
+	if !ok {
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("scraping_failed", fmt.Errorf("unknown_provider"))
+	}
+	return provider, nil
+}
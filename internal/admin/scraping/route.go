@@ -1,6 +1,8 @@
 package scraping
 
 import (
+	"rerng_addicted_api/pkg/browser"
+	"rerng_addicted_api/pkg/imagestore"
 	"rerng_addicted_api/pkg/middlewares"
 
 	"github.com/gofiber/fiber/v2"
@@ -13,11 +15,11 @@ type ScrapingRoute struct {
 	ScrapingHandler *ScrapingHandler
 }
 
-func NewRoute(app *fiber.App, db_pool *sqlx.DB) *ScrapingRoute {
+func NewRoute(app *fiber.App, db_pool *sqlx.DB, providers *Providers, cache *ResponseCache, images *imagestore.Store, browser_pool *browser.Pool) *ScrapingRoute {
 	return &ScrapingRoute{
 		App:             app,
 		DBPool:          db_pool,
-		ScrapingHandler: NewScrapingHandler(db_pool),
+		ScrapingHandler: NewScrapingHandler(db_pool, providers, cache, images, browser_pool),
 	}
 }
 
@@ -28,6 +30,8 @@ func (sc *ScrapingRoute) RegisterScrapingRoute() *ScrapingRoute {
 	scraping.Get("/series/:key", middlewares.NewJwtMiddleware(sc.DBPool), sc.ScrapingHandler.ViewDetail)
 	scraping.Get("/series/:key/detail", sc.ScrapingHandler.GetDetail)
 	scraping.Get("/series/:key/episode/:ep", sc.ScrapingHandler.GetEpisode)
+	scraping.Get("/browser/health", middlewares.NewJwtMiddleware(sc.DBPool), sc.ScrapingHandler.BrowserHealth)
+	scraping.Delete("/cache", middlewares.NewJwtMiddleware(sc.DBPool), sc.ScrapingHandler.PurgeCache)
 
 	return sc
 }
@@ -2,69 +2,524 @@ package scraping
 
 import (
 	"fmt"
+	"rerng_addicted_api/internal/admin/history"
 	"rerng_addicted_api/internal/admin/serie"
+	"rerng_addicted_api/pkg/imagestore"
 	custom_log "rerng_addicted_api/pkg/logs"
 	types "rerng_addicted_api/pkg/model"
+	"rerng_addicted_api/pkg/proxyurl"
 	"rerng_addicted_api/pkg/responses"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
 
 	"github.com/jmoiron/sqlx"
 )
 
 type ScrapingServiceCreator interface {
-	Search(keyword string) (*SeriesResponse, *responses.ErrorResponse)
-	ViewDetail(key string) (*SeriesDetailsResponse, *responses.ErrorResponse)
-	GetDetail(key string) (*SeriesDeepDetailsResponse, *responses.ErrorResponse)
-	GetEpisodes(key int, ep_num int) (*EpisodesResponse, *responses.ErrorResponse)
-	Seed()
+	Search(provider string, keyword string) (*SeriesResponse, string, *responses.ErrorResponse)
+	ViewDetail(provider string, key string) (*SeriesDetailsResponse, string, *responses.ErrorResponse)
+	GetDetail(provider string, key string, progress ProgressFunc) (*SeriesDeepDetailsResponse, *responses.ErrorResponse)
+	GetEpisodes(provider string, key int, number float64) (*EpisodesResponse, *responses.ErrorResponse)
+	PurgeCache(kind string, provider string, key string) (*CachePurgeResponse, *responses.ErrorResponse)
+	Refresh(provider string, key int, progress ProgressFunc) (*RefreshResponse, *responses.ErrorResponse)
+	Seed(provider string, options SeedOptions) (*SeedReport, *responses.ErrorResponse)
 }
 
 type ScrapingService struct {
-	DBPool       *sqlx.DB
-	ScrapingRepo *ScrapingRepoImpl
-	UserContext  *types.UserContext
+	DBPool      *sqlx.DB
+	Providers   *Providers
+	UserContext *types.UserContext
+	// Cache is optional, without it every call goes to the provider
+	Cache *ResponseCache
+	// Trigger is recorded on the scrape runs this service writes, see the history package
+	Trigger string
+	// Images mirrors thumbnails before a series is stored, nil keeps only the upstream url
+	Images *imagestore.Store
 }
 
-func NewScrapingService(db_pool *sqlx.DB, user_context *types.UserContext) *ScrapingService {
+func NewScrapingService(db_pool *sqlx.DB, user_context *types.UserContext, providers *Providers, cache *ResponseCache) *ScrapingService {
 	return &ScrapingService{
-		DBPool:       db_pool,
-		ScrapingRepo: NewScrapingRepoImpl(db_pool, user_context),
+		DBPool:      db_pool,
+		Providers:   providers,
+		UserContext: user_context,
+		Cache:       cache,
+		Trigger:     history.TriggerAPI,
 	}
 }
 
-func (sc *ScrapingService) Search(keyword string) (*SeriesResponse, *responses.ErrorResponse) {
-	return sc.ScrapingRepo.Search(keyword)
+// repo builds a repository bound to the requested provider
+func (sc *ScrapingService) repo(provider string) (*ScrapingRepoImpl, *responses.ErrorResponse) {
+	source, err := sc.Providers.Get(provider)
+	if err != nil {
+		return nil, err
+	}
+	return NewScrapingRepoImpl(sc.DBPool, sc.UserContext, source), nil
+}
+
+// startRun opens a scrape history row and binds serie_repo to it so the upserts record their changes.
+// History is best effort, a failure here is logged and the scrape goes on unrecorded.
+func (sc *ScrapingService) startRun(serie_repo *serie.SerieRepoImpl, provider string, key int, ep_num float64) int {
+	run_id, err := history.NewHistoryRepoImpl(sc.DBPool, sc.UserContext).StartRun(sc.Trigger, provider, key, ep_num)
+	if err != nil {
+		custom_log.NewCustomLog("scrape_run_failed", err.Error(), "error")
+		return 0
+	}
+	serie_repo.RunID = &run_id
+	return run_id
+}
+
+func (sc *ScrapingService) finishRun(run_id int, counts history.RunCounts, scrape_err *responses.ErrorResponse) {
+	if run_id == 0 {
+		return
+	}
+	run_err := ""
+	if scrape_err != nil {
+		run_err = scrape_err.Err.Error()
+	}
+	if err := history.NewHistoryRepoImpl(sc.DBPool, sc.UserContext).FinishRun(run_id, counts, run_err); err != nil {
+		custom_log.NewCustomLog("scrape_run_failed", err.Error(), "error")
+	}
 }
 
-func (sc *ScrapingService) ViewDetail(key string) (*SeriesDetailsResponse, *responses.ErrorResponse) {
-	return sc.ScrapingRepo.ViewDetail(key)
+// mirrorThumbnail copies the series thumbnail to local storage, on failure the stored copy from an earlier scrape is kept
+func (sc *ScrapingService) mirrorThumbnail(serie_detail *serie.SerieDeepDetail) {
+	if sc.Images == nil || serie_detail.Thumbnail == "" {
+		return
+	}
+	thumbnail_path, err := sc.Images.Mirror(serie_detail.Thumbnail)
+	if err != nil {
+		custom_log.NewCustomLog("thumbnail_mirror_failed", err.Error(), "warn")
+		return
+	}
+	serie_detail.ThumbnailPath = &thumbnail_path
 }
 
-func (sc *ScrapingService) GetDetail(key string) (*SeriesDeepDetailsResponse, *responses.ErrorResponse) {
-	return sc.ScrapingRepo.GetDetail(key)
+// Search returns the provider's search results and whether they came from the cache
+func (sc *ScrapingService) Search(provider string, keyword string) (*SeriesResponse, string, *responses.ErrorResponse) {
+	repo, err := sc.repo(provider)
+	if err != nil {
+		return nil, "", err
+	}
+	return cached(sc.Cache, CacheKindSearch, repo.Provider.Name(), keyword, func() (*SeriesResponse, *responses.ErrorResponse) {
+		return repo.Search(keyword)
+	})
 }
 
-func (sc *ScrapingService) GetEpisodes(key int, ep_num int) (*EpisodesResponse, *responses.ErrorResponse) {
-	resp, err := sc.ScrapingRepo.GetEpisodes(key, ep_num)
+func (sc *ScrapingService) ViewDetail(provider string, key string) (*SeriesDetailsResponse, string, *responses.ErrorResponse) {
+	repo, err := sc.repo(provider)
+	if err != nil {
+		return nil, "", err
+	}
+	return cached(sc.Cache, CacheKindDetail, repo.Provider.Name(), key, func() (*SeriesDetailsResponse, *responses.ErrorResponse) {
+		return repo.ViewDetail(key)
+	})
+}
+
+// PurgeCache drops cached responses, see ResponseCache.Purge for how empty arguments widen the match
+func (sc *ScrapingService) PurgeCache(kind string, provider string, key string) (*CachePurgeResponse, *responses.ErrorResponse) {
+	if sc.Cache == nil {
+		return &CachePurgeResponse{}, nil
+	}
+
+	purged, err := sc.Cache.Purge(kind, provider, key)
+	if err != nil {
+		custom_log.NewCustomLog("cache_purge_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("cache_purge_failed", fmt.Errorf("cache_error"))
+	}
+	return &CachePurgeResponse{
+		Purged: purged,
+	}, nil
+}
+
+// GetDetail scrapes every episode of a series, stores the result and reports each step to progress
+func (sc *ScrapingService) GetDetail(provider string, key string, progress ProgressFunc) (*SeriesDeepDetailsResponse, *responses.ErrorResponse) {
+	repo, err := sc.repo(provider)
+	if err != nil {
+		return nil, err
+	}
+
+	serie_repo := serie.NewSerieRepoImpl(sc.DBPool, sc.UserContext)
+	serie_key, _ := strconv.Atoi(key)
+	run_id := sc.startRun(serie_repo, repo.Provider.Name(), serie_key, 0)
+	counts := history.RunCounts{}
+	defer func() {
+		sc.finishRun(run_id, counts, err)
+	}()
+
+	// remember failed episodes so the final event can list them
+	var (
+		failed_mu sync.Mutex
+		failed    []float64
+	)
+	resp, err := repo.GetDetail(key, func(event ProgressEvent) {
+		if event.Event == ProgressEpisodeFailed && event.Episode != nil {
+			failed_mu.Lock()
+			failed = append(failed, *event.Episode)
+			failed_mu.Unlock()
+		}
+		progress.Emit(event)
+	})
+	if err != nil {
+		return nil, err
+	}
+	counts.EpisodesFailed = len(failed)
+
+	total := 0
+	for i := range resp.SeriesDeepDetails {
+		serie_detail := &resp.SeriesDeepDetails[i]
+		total += len(serie_detail.Episodes)
+		sc.mirrorThumbnail(serie_detail)
+		upsert := *serie_detail
+		upsert.Episodes = withoutFailed(serie_detail.Episodes, failed)
 
-	if err == nil && len(resp.Episodes) > 0 {
-		serie_repo := serie.NewSerieRepoImpl(sc.DBPool, sc.UserContext)
-		if insert_err := serie_repo.InsertEpisode(sc.DBPool, key, resp.Episodes[0]); insert_err != nil {
-			custom_log.NewCustomLog("scraping_failed", insert_err.Error(), "error")
-			return nil, (&responses.ErrorResponse{}).NewErrorResponse("scraping_failed", fmt.Errorf("database_error"))
+		if _, err = serie_repo.Create(upsert); err != nil {
+			return nil, err
 		}
+		counts.SeriesUpserted++
+		counts.EpisodesUpserted += len(upsert.Episodes)
+
+		progress.Emit(ProgressEvent{
+			Event:   ProgressUpsertDone,
+			Message: serie_detail.Title,
+			SerieID: serie_detail.ID,
+			Current: len(upsert.Episodes),
+			Total:   len(serie_detail.Episodes),
+		})
+	}
+
+	sort.Float64s(failed)
+	progress.Emit(ProgressEvent{
+		Event:   ProgressCompleted,
+		Message: fmt.Sprintf("%d of %d episodes resolved", total-len(failed), total),
+		Current: total - len(failed),
+		Total:   total,
+		Failed:  failed,
+	})
+
+	return resp, nil
+}
+
+func (sc *ScrapingService) GetEpisodes(provider string, key int, number float64) (*EpisodesResponse, *responses.ErrorResponse) {
+	repo, err := sc.repo(provider)
+	if err != nil {
+		return nil, err
+	}
+
+	serie_repo := serie.NewSerieRepoImpl(sc.DBPool, sc.UserContext)
+	run_id := sc.startRun(serie_repo, repo.Provider.Name(), key, number)
+
+	resp, stored, err := sc.storeEpisode(repo, serie_repo, key, number)
+
+	counts := history.RunCounts{}
+	switch {
+	case err != nil:
+		counts.EpisodesFailed = 1
+	case stored:
+		counts.EpisodesUpserted = 1
 	}
+	sc.finishRun(run_id, counts, err)
 
 	return resp, err
 }
 
-func (sc *ScrapingService) Seed() {
-	series := sc.ScrapingRepo.Seed()
+// withoutFailed drops the episodes whose source could not be resolved, upserting them would blank the stored src
+func withoutFailed(episodes []serie.EpisodeDeep, failed []float64) []serie.EpisodeDeep {
+	if len(failed) == 0 {
+		return episodes
+	}
+	skip := make(map[float64]bool, len(failed))
+	for _, number := range failed {
+		skip[number] = true
+	}
+
+	kept := make([]serie.EpisodeDeep, 0, len(episodes))
+	for _, ep := range episodes {
+		if !skip[ep.Number] {
+			kept = append(kept, ep)
+		}
+	}
+	return kept
+}
+
+// storeEpisode resolves one episode and upserts it through serie_repo, stored is false when the provider returned no episode
+func (sc *ScrapingService) storeEpisode(repo *ScrapingRepoImpl, serie_repo *serie.SerieRepoImpl, key int, number float64) (*EpisodesResponse, bool, *responses.ErrorResponse) {
+	resp, err := repo.GetEpisodes(key, number)
+	if err != nil || len(resp.Episodes) == 0 {
+		return resp, false, err
+	}
+
+	serie_id, insert_err := serie_repo.SerieID(repo.Provider.Name(), key)
+	if insert_err == nil && serie_id == 0 {
+		// episodes hang off a stored series, a series scrape has to come first
+		return nil, false, (&responses.ErrorResponse{}).NewErrorResponse("scraping_failed", fmt.Errorf("serie_not_found"))
+	}
+	if insert_err == nil {
+		insert_err = serie_repo.InsertEpisode(sc.DBPool, serie_id, resp.Episodes[0])
+	}
+	if insert_err != nil {
+		custom_log.NewCustomLog("scraping_failed", insert_err.Error(), "error")
+		return nil, false, (&responses.ErrorResponse{}).NewErrorResponse("scraping_failed", fmt.Errorf("database_error"))
+	}
+
+	return resp, true, nil
+}
+
+// Refresh re-reads the series listing and resolves only the episodes that have no playable source yet
+func (sc *ScrapingService) Refresh(provider string, key int, progress ProgressFunc) (*RefreshResponse, *responses.ErrorResponse) {
+	repo, err := sc.repo(provider)
+	if err != nil {
+		return nil, err
+	}
+
 	serie_repo := serie.NewSerieRepoImpl(sc.DBPool, sc.UserContext)
-	for _, serie := range series {
-		_, err := serie_repo.Create(serie)
+	run_id := sc.startRun(serie_repo, repo.Provider.Name(), key, 0)
+	counts := history.RunCounts{}
+	defer func() {
+		sc.finishRun(run_id, counts, err)
+	}()
+
+	// always read the live listing, and let the cache benefit from it
+	detail, err := repo.ViewDetail(strconv.Itoa(key))
+	if err != nil {
+		return nil, err
+	}
+	sc.Cache.Store(CacheKindDetail, repo.Provider.Name(), strconv.Itoa(key), detail)
+
+	known, db_err := serie_repo.EpisodeNumbers(repo.Provider.Name(), key)
+	if db_err != nil {
+		custom_log.NewCustomLog("scraping_failed", db_err.Error(), "error")
+		err = (&responses.ErrorResponse{}).NewErrorResponse("scraping_failed", fmt.Errorf("database_error"))
+		return nil, err
+	}
+
+	have := make(map[float64]bool, len(known))
+	for _, number := range known {
+		have[number] = true
+	}
+
+	checked := 0
+	missing := []float64{}
+	for _, serie_detail := range detail.SeriesDetails {
+		// keep status and next episode date current so finished shows drop out of the refresh schedule
+		if err := serie_repo.UpdateListing(repo.Provider.Name(), serie_detail); err != nil {
+			custom_log.NewCustomLog("scraping_failed", err.Error(), "error")
+		}
+
+		for _, ep := range serie_detail.Episodes {
+			checked++
+			if !have[ep.Number] {
+				missing = append(missing, ep.Number)
+			}
+		}
+	}
+	sort.Float64s(missing)
+
+	progress.Emit(ProgressEvent{
+		Event:   ProgressSeriesFetched,
+		Message: fmt.Sprintf("%d new episodes", len(missing)),
+		SerieID: key,
+		Total:   len(missing),
+	})
+
+	resp := &RefreshResponse{
+		SerieID:     key,
+		Checked:     checked,
+		NewEpisodes: []float64{},
+		Failed:      []float64{},
+	}
+	for i := range missing {
+		number := missing[i]
+		_, stored, err := sc.storeEpisode(repo, serie_repo, key, number)
+		if err == nil && !stored {
+			err = (&responses.ErrorResponse{}).NewErrorResponse("scraping_failed", fmt.Errorf("episode_not_found"))
+		}
 		if err != nil {
-			fmt.Println("Error Inserting Data : ", err.Err)
+			resp.Failed = append(resp.Failed, number)
+			progress.Emit(ProgressEvent{
+				Event:   ProgressEpisodeFailed,
+				Message: fmt.Sprintf("episode %.0f failed", number),
+				SerieID: key,
+				Episode: &number,
+				Current: i + 1,
+				Total:   len(missing),
+				Error:   err.Err.Error(),
+			})
+			continue
 		}
+
+		resp.NewEpisodes = append(resp.NewEpisodes, number)
+		progress.Emit(ProgressEvent{
+			Event:   ProgressEpisodeResolved,
+			Message: fmt.Sprintf("episode %.0f resolved", number),
+			SerieID: key,
+			Episode: &number,
+			Current: i + 1,
+			Total:   len(missing),
+		})
 	}
 
+	progress.Emit(ProgressEvent{
+		Event:   ProgressCompleted,
+		Message: fmt.Sprintf("%d of %d new episodes resolved", len(resp.NewEpisodes), len(missing)),
+		SerieID: key,
+		Current: len(resp.NewEpisodes),
+		Total:   len(missing),
+		Failed:  resp.Failed,
+	})
+
+	counts.EpisodesUpserted = len(resp.NewEpisodes)
+	counts.EpisodesFailed = len(resp.Failed)
+	return resp, nil
+}
+
+// Seed scrapes every key in options and upserts the result, reporting what is new or changed against the database
+func (sc *ScrapingService) Seed(provider string, options SeedOptions) (*SeedReport, *responses.ErrorResponse) {
+	repo, err := sc.repo(provider)
+	if err != nil {
+		return nil, err
+	}
+
+	parallel := options.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	report := &SeedReport{
+		DryRun:  options.DryRun,
+		Results: make([]SeedResult, len(options.Keys)),
+	}
+
+	keys := make(chan int)
+	var wg sync.WaitGroup
+	for n := 0; n < parallel; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range keys {
+				report.Results[i] = sc.seedOne(repo, options.Keys[i], options)
+			}
+		}()
+	}
+	for i := range options.Keys {
+		keys <- i
+	}
+	close(keys)
+	wg.Wait()
+
+	for _, result := range report.Results {
+		switch result.State {
+		case SeedNew:
+			report.New++
+		case SeedUpdated:
+			report.Updated++
+		case SeedUnchanged:
+			report.Unchanged++
+		case SeedSkipped:
+			report.Skipped++
+		case SeedFailed:
+			report.Failed++
+		}
+		report.NewEpisodes += len(result.NewEpisodes)
+		report.ChangedSources += len(result.ChangedSources)
+		report.FailedEpisodes += len(result.FailedEpisodes)
+	}
+
+	return report, nil
+}
+
+func (sc *ScrapingService) seedOne(repo *ScrapingRepoImpl, key int, options SeedOptions) SeedResult {
+	result := SeedResult{
+		SerieID:        key,
+		NewEpisodes:    []float64{},
+		ChangedSources: []float64{},
+		FailedEpisodes: []float64{},
+	}
+
+	serie_repo := serie.NewSerieRepoImpl(sc.DBPool, sc.UserContext)
+	stored, exists, db_err := serie_repo.EpisodeSources(repo.Provider.Name(), key)
+	if db_err != nil {
+		result.State = SeedFailed
+		result.Error = db_err.Error()
+		return result
+	}
+	if exists && options.OnlyMissing {
+		result.State = SeedSkipped
+		return result
+	}
+
+	// a dry run writes nothing, so there is no history to record
+	run_id := 0
+	counts := history.RunCounts{}
+	var err *responses.ErrorResponse
+	if !options.DryRun {
+		run_id = sc.startRun(serie_repo, repo.Provider.Name(), key, 0)
+		defer func() {
+			sc.finishRun(run_id, counts, err)
+		}()
+	}
+
+	var failed_mu sync.Mutex
+	resp, err := repo.GetDetail(strconv.Itoa(key), func(event ProgressEvent) {
+		if event.Event == ProgressEpisodeFailed && event.Episode != nil {
+			failed_mu.Lock()
+			result.FailedEpisodes = append(result.FailedEpisodes, *event.Episode)
+			failed_mu.Unlock()
+		}
+	})
+	if err != nil {
+		result.State = SeedFailed
+		result.Error = err.Err.Error()
+		return result
+	}
+	sort.Float64s(result.FailedEpisodes)
+	counts.EpisodesFailed = len(result.FailedEpisodes)
+
+	for i := range resp.SeriesDeepDetails {
+		serie_detail := &resp.SeriesDeepDetails[i]
+		result.Title = serie_detail.Title
+		result.Episodes += len(serie_detail.Episodes)
+
+		for _, ep := range serie_detail.Episodes {
+			// an episode that failed to resolve is left out of the upsert, so it is neither new nor changed
+			if slices.Contains(result.FailedEpisodes, ep.Number) {
+				continue
+			}
+			old_src, ok := stored[ep.ID]
+			switch {
+			case !ok:
+				result.NewEpisodes = append(result.NewEpisodes, ep.Number)
+			// stored sources are kept unsigned
+			case ep.Source != "" && proxyurl.Strip(ep.Source) != old_src:
+				result.ChangedSources = append(result.ChangedSources, ep.Number)
+			}
+		}
+
+		if !options.DryRun {
+			sc.mirrorThumbnail(serie_detail)
+			upsert := *serie_detail
+			upsert.Episodes = withoutFailed(serie_detail.Episodes, result.FailedEpisodes)
+
+			if _, err = serie_repo.Create(upsert); err != nil {
+				result.State = SeedFailed
+				result.Error = err.Err.Error()
+				return result
+			}
+			counts.SeriesUpserted++
+			counts.EpisodesUpserted += len(upsert.Episodes)
+		}
+	}
+	sort.Float64s(result.NewEpisodes)
+	sort.Float64s(result.ChangedSources)
+
+	switch {
+	case !exists:
+		result.State = SeedNew
+	case len(result.NewEpisodes) > 0 || len(result.ChangedSources) > 0:
+		result.State = SeedUpdated
+	default:
+		result.State = SeedUnchanged
+	}
+	return result
 }
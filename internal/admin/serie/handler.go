@@ -1,13 +1,20 @@
 package serie
 
 import (
+	"fmt"
+	"net/http"
+	response "rerng_addicted_api/pkg/http/response"
+	custom_log "rerng_addicted_api/pkg/logs"
+	share "rerng_addicted_api/pkg/model"
+	"rerng_addicted_api/pkg/utils"
+	"strconv"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/jmoiron/sqlx"
-	share "rerng_addicted_api/pkg/model"
 )
 
 type SerieHandler struct {
-	DBPool          *sqlx.DB
+	DBPool       *sqlx.DB
 	SerieService func(c *fiber.Ctx) *SerieService
 }
 
@@ -16,11 +23,673 @@ func NewSerieHandler(db_pool *sqlx.DB) *SerieHandler {
 		DBPool: db_pool,
 		SerieService: func(c *fiber.Ctx) *SerieService {
 			var uCtx share.UserContext
+			// convert map to UserContext struct
 			uCtx, ok := c.Locals("UserContext").(share.UserContext)
 			if !ok {
+				custom_log.NewCustomLog("user_context_failed", "UserContext missing or invalid", "warn")
 				uCtx = share.UserContext{}
 			}
 			return NewSerieService(db_pool, &uCtx)
 		},
 	}
 }
+
+func (sh *SerieHandler) Show(c *fiber.Ctx) error {
+	var req SerieShowRequest
+
+	v := utils.NewValidator()
+	if err := req.bind(c, v); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("serie_show_failed", nil, c),
+				-2505,
+				err,
+			),
+		)
+	}
+
+	resp, err := sh.SerieService(c).Show(req)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate(err.MessageID, nil, c),
+				-2505,
+				fmt.Errorf("%s", utils.Translate(err.Err.Error(), nil, c)),
+			),
+		)
+	}
+
+	return c.Status(http.StatusOK).JSON(
+		response.NewResponseWithPaging(
+			utils.Translate("serie_show_success", nil, c),
+			2505,
+			resp,
+			req.PageOptions.Page,
+			req.PageOptions.Perpage,
+			resp.Total,
+		),
+	)
+}
+
+func (sh *SerieHandler) ShowOne(c *fiber.Ctx) error {
+	serie_id, err_con := strconv.Atoi(c.Params("id"))
+	if err_con != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("serie_show_failed", nil, c),
+				-2506,
+				fmt.Errorf("%s", utils.Translate("invalid_serie_id", nil, c)),
+			),
+		)
+	}
+
+	resp, err := sh.SerieService(c).ShowOne(serie_id)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate(err.MessageID, nil, c),
+				-2506,
+				fmt.Errorf("%s", utils.Translate(err.Err.Error(), nil, c)),
+			),
+		)
+	}
+
+	return c.Status(http.StatusOK).JSON(
+		response.NewResponse(
+			utils.Translate("serie_show_success", nil, c),
+			2506,
+			resp,
+		),
+	)
+}
+
+func (sh *SerieHandler) Create(c *fiber.Ctx) error {
+	var req NewSerieRequest
+
+	v := utils.NewValidator()
+	if err := req.bind(c, v); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("serie_create_failed", nil, c),
+				-2507,
+				err,
+			),
+		)
+	}
+
+	resp, err := sh.SerieService(c).Create(req)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate(err.MessageID, nil, c),
+				-2507,
+				fmt.Errorf("%s", utils.Translate(err.Err.Error(), nil, c)),
+			),
+		)
+	}
+
+	return c.Status(http.StatusCreated).JSON(
+		response.NewResponse(
+			utils.Translate("serie_create_success", nil, c),
+			2507,
+			resp,
+		),
+	)
+}
+
+func (sh *SerieHandler) Update(c *fiber.Ctx) error {
+	serie_id, err_con := strconv.Atoi(c.Params("id"))
+	if err_con != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("serie_update_failed", nil, c),
+				-2508,
+				fmt.Errorf("%s", utils.Translate("invalid_serie_id", nil, c)),
+			),
+		)
+	}
+
+	// the id comes from the path, a body without one still passes validation
+	req := NewSerieRequest{ID: serie_id}
+	v := utils.NewValidator()
+	if err := req.bind(c, v); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("serie_update_failed", nil, c),
+				-2508,
+				err,
+			),
+		)
+	}
+
+	resp, err := sh.SerieService(c).Update(serie_id, req)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate(err.MessageID, nil, c),
+				-2508,
+				fmt.Errorf("%s", utils.Translate(err.Err.Error(), nil, c)),
+			),
+		)
+	}
+
+	return c.Status(http.StatusOK).JSON(
+		response.NewResponse(
+			utils.Translate("serie_update_success", nil, c),
+			2508,
+			resp,
+		),
+	)
+}
+
+func (sh *SerieHandler) Delete(c *fiber.Ctx) error {
+	serie_id, err_con := strconv.Atoi(c.Params("id"))
+	if err_con != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("serie_delete_failed", nil, c),
+				-2509,
+				fmt.Errorf("%s", utils.Translate("invalid_serie_id", nil, c)),
+			),
+		)
+	}
+
+	if err := sh.SerieService(c).Delete(serie_id); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate(err.MessageID, nil, c),
+				-2509,
+				fmt.Errorf("%s", utils.Translate(err.Err.Error(), nil, c)),
+			),
+		)
+	}
+
+	return c.Status(http.StatusOK).JSON(
+		response.NewResponse(
+			utils.Translate("serie_delete_success", nil, c),
+			2509,
+			nil,
+		),
+	)
+}
+
+func (sh *SerieHandler) Restore(c *fiber.Ctx) error {
+	serie_id, err_con := strconv.Atoi(c.Params("id"))
+	if err_con != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("serie_restore_failed", nil, c),
+				-2510,
+				fmt.Errorf("%s", utils.Translate("invalid_serie_id", nil, c)),
+			),
+		)
+	}
+
+	resp, err := sh.SerieService(c).Restore(serie_id)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate(err.MessageID, nil, c),
+				-2510,
+				fmt.Errorf("%s", utils.Translate(err.Err.Error(), nil, c)),
+			),
+		)
+	}
+
+	return c.Status(http.StatusOK).JSON(
+		response.NewResponse(
+			utils.Translate("serie_restore_success", nil, c),
+			2510,
+			resp,
+		),
+	)
+}
+
+func (sh *SerieHandler) ShowMetadata(c *fiber.Ctx) error {
+	serie_id, err_con := strconv.Atoi(c.Params("id"))
+	if err_con != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("serie_metadata_show_failed", nil, c),
+				-2500,
+				fmt.Errorf("%s", utils.Translate("invalid_serie_id", nil, c)),
+			),
+		)
+	}
+
+	resp, err := sh.SerieService(c).ShowMetadata(serie_id)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate(err.MessageID, nil, c),
+				-2500,
+				fmt.Errorf("%s", utils.Translate(err.Err.Error(), nil, c)),
+			),
+		)
+	}
+
+	return c.Status(http.StatusOK).JSON(
+		response.NewResponse(
+			utils.Translate("serie_metadata_show_success", nil, c),
+			2500,
+			resp,
+		),
+	)
+}
+
+func (sh *SerieHandler) UpdateMetadata(c *fiber.Ctx) error {
+	serie_id, err_con := strconv.Atoi(c.Params("id"))
+	if err_con != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("serie_metadata_update_failed", nil, c),
+				-2501,
+				fmt.Errorf("%s", utils.Translate("invalid_serie_id", nil, c)),
+			),
+		)
+	}
+
+	var req SerieMetadataRequest
+	v := utils.NewValidator()
+	if err := req.bind(c, v); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("serie_metadata_update_failed", nil, c),
+				-2501,
+				err,
+			),
+		)
+	}
+
+	resp, err := sh.SerieService(c).UpdateMetadata(serie_id, req)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate(err.MessageID, nil, c),
+				-2501,
+				fmt.Errorf("%s", utils.Translate(err.Err.Error(), nil, c)),
+			),
+		)
+	}
+
+	return c.Status(http.StatusOK).JSON(
+		response.NewResponse(
+			utils.Translate("serie_metadata_update_success", nil, c),
+			2501,
+			resp,
+		),
+	)
+}
+
+func (sh *SerieHandler) ShowMetadataItems(c *fiber.Ctx) error {
+	var req MetadataShowRequest
+
+	v := utils.NewValidator()
+	if err := req.bind(c, v); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("metadata_show_failed", nil, c),
+				-2502,
+				err,
+			),
+		)
+	}
+
+	resp, err := sh.SerieService(c).ShowMetadataItems(c.Params("kind"), req)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate(err.MessageID, nil, c),
+				-2502,
+				fmt.Errorf("%s", utils.Translate(err.Err.Error(), nil, c)),
+			),
+		)
+	}
+
+	return c.Status(http.StatusOK).JSON(
+		response.NewResponseWithPaging(
+			utils.Translate("metadata_show_success", nil, c),
+			2502,
+			resp,
+			req.PageOptions.Page,
+			req.PageOptions.Perpage,
+			resp.Total,
+		),
+	)
+}
+
+func (sh *SerieHandler) UpdateMetadataItem(c *fiber.Ctx) error {
+	id, err_con := strconv.Atoi(c.Params("id"))
+	if err_con != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("metadata_update_failed", nil, c),
+				-2503,
+				fmt.Errorf("%s", utils.Translate("invalid_metadata_id", nil, c)),
+			),
+		)
+	}
+
+	var req MetadataItemRequest
+	v := utils.NewValidator()
+	if err := req.bind(c, v); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("metadata_update_failed", nil, c),
+				-2503,
+				err,
+			),
+		)
+	}
+
+	if err := sh.SerieService(c).UpdateMetadataItem(c.Params("kind"), id, req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate(err.MessageID, nil, c),
+				-2503,
+				fmt.Errorf("%s", utils.Translate(err.Err.Error(), nil, c)),
+			),
+		)
+	}
+
+	return c.Status(http.StatusOK).JSON(
+		response.NewResponse(
+			utils.Translate("metadata_update_success", nil, c),
+			2503,
+			nil,
+		),
+	)
+}
+
+func (sh *SerieHandler) DeleteMetadataItem(c *fiber.Ctx) error {
+	id, err_con := strconv.Atoi(c.Params("id"))
+	if err_con != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("metadata_delete_failed", nil, c),
+				-2504,
+				fmt.Errorf("%s", utils.Translate("invalid_metadata_id", nil, c)),
+			),
+		)
+	}
+
+	if err := sh.SerieService(c).DeleteMetadataItem(c.Params("kind"), id); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate(err.MessageID, nil, c),
+				-2504,
+				fmt.Errorf("%s", utils.Translate(err.Err.Error(), nil, c)),
+			),
+		)
+	}
+
+	return c.Status(http.StatusOK).JSON(
+		response.NewResponse(
+			utils.Translate("metadata_delete_success", nil, c),
+			2504,
+			nil,
+		),
+	)
+}
+
+// episodeParams reads the serie and episode ids from the path
+func episodeParams(c *fiber.Ctx) (int, int, error) {
+	serie_id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s", utils.Translate("invalid_serie_id", nil, c))
+	}
+	episode_id, err := strconv.Atoi(c.Params("episode_id"))
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s", utils.Translate("invalid_episode_id", nil, c))
+	}
+	return serie_id, episode_id, nil
+}
+
+func (sh *SerieHandler) ShowEpisodes(c *fiber.Ctx) error {
+	serie_id, err_con := strconv.Atoi(c.Params("id"))
+	if err_con != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("episode_show_failed", nil, c),
+				-2511,
+				fmt.Errorf("%s", utils.Translate("invalid_serie_id", nil, c)),
+			),
+		)
+	}
+
+	resp, err := sh.SerieService(c).ShowEpisodes(serie_id)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate(err.MessageID, nil, c),
+				-2511,
+				fmt.Errorf("%s", utils.Translate(err.Err.Error(), nil, c)),
+			),
+		)
+	}
+
+	return c.Status(http.StatusOK).JSON(
+		response.NewResponse(
+			utils.Translate("episode_show_success", nil, c),
+			2511,
+			resp,
+		),
+	)
+}
+
+func (sh *SerieHandler) UpdateEpisode(c *fiber.Ctx) error {
+	serie_id, episode_id, err_con := episodeParams(c)
+	if err_con != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("episode_update_failed", nil, c),
+				-2512,
+				err_con,
+			),
+		)
+	}
+
+	var req EpisodeRequest
+	v := utils.NewValidator()
+	if err := req.bind(c, v); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("episode_update_failed", nil, c),
+				-2512,
+				err,
+			),
+		)
+	}
+
+	resp, err := sh.SerieService(c).UpdateEpisode(serie_id, episode_id, req)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate(err.MessageID, nil, c),
+				-2512,
+				fmt.Errorf("%s", utils.Translate(err.Err.Error(), nil, c)),
+			),
+		)
+	}
+
+	return c.Status(http.StatusOK).JSON(
+		response.NewResponse(
+			utils.Translate("episode_update_success", nil, c),
+			2512,
+			resp,
+		),
+	)
+}
+
+func (sh *SerieHandler) AddSubtitle(c *fiber.Ctx) error {
+	serie_id, episode_id, err_con := episodeParams(c)
+	if err_con != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("subtitle_save_failed", nil, c),
+				-2513,
+				err_con,
+			),
+		)
+	}
+
+	var req SubtitleRequest
+	v := utils.NewValidator()
+	if err := req.bind(c, v); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("subtitle_save_failed", nil, c),
+				-2513,
+				err,
+			),
+		)
+	}
+
+	resp, err := sh.SerieService(c).AddSubtitle(serie_id, episode_id, req)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate(err.MessageID, nil, c),
+				-2513,
+				fmt.Errorf("%s", utils.Translate(err.Err.Error(), nil, c)),
+			),
+		)
+	}
+
+	return c.Status(http.StatusOK).JSON(
+		response.NewResponse(
+			utils.Translate("subtitle_save_success", nil, c),
+			2513,
+			resp,
+		),
+	)
+}
+
+func (sh *SerieHandler) ReplaceSubtitles(c *fiber.Ctx) error {
+	serie_id, episode_id, err_con := episodeParams(c)
+	if err_con != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("subtitle_save_failed", nil, c),
+				-2514,
+				err_con,
+			),
+		)
+	}
+
+	var req SubtitlesRequest
+	v := utils.NewValidator()
+	if err := req.bind(c, v); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("subtitle_save_failed", nil, c),
+				-2514,
+				err,
+			),
+		)
+	}
+
+	resp, err := sh.SerieService(c).ReplaceSubtitles(serie_id, episode_id, req)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate(err.MessageID, nil, c),
+				-2514,
+				fmt.Errorf("%s", utils.Translate(err.Err.Error(), nil, c)),
+			),
+		)
+	}
+
+	return c.Status(http.StatusOK).JSON(
+		response.NewResponse(
+			utils.Translate("subtitle_save_success", nil, c),
+			2514,
+			resp,
+		),
+	)
+}
+
+func (sh *SerieHandler) DeleteSubtitle(c *fiber.Ctx) error {
+	serie_id, episode_id, err_con := episodeParams(c)
+	if err_con != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("subtitle_delete_failed", nil, c),
+				-2515,
+				err_con,
+			),
+		)
+	}
+	subtitle_id, err_con := strconv.Atoi(c.Params("subtitle_id"))
+	if err_con != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("subtitle_delete_failed", nil, c),
+				-2515,
+				fmt.Errorf("%s", utils.Translate("invalid_subtitle_id", nil, c)),
+			),
+		)
+	}
+
+	if err := sh.SerieService(c).DeleteSubtitle(serie_id, episode_id, subtitle_id); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate(err.MessageID, nil, c),
+				-2515,
+				fmt.Errorf("%s", utils.Translate(err.Err.Error(), nil, c)),
+			),
+		)
+	}
+
+	return c.Status(http.StatusOK).JSON(
+		response.NewResponse(
+			utils.Translate("subtitle_delete_success", nil, c),
+			2515,
+			nil,
+		),
+	)
+}
+
+func (sh *SerieHandler) OrderSubtitles(c *fiber.Ctx) error {
+	serie_id, episode_id, err_con := episodeParams(c)
+	if err_con != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("subtitle_order_failed", nil, c),
+				-2516,
+				err_con,
+			),
+		)
+	}
+
+	var req SubtitleOrderRequest
+	v := utils.NewValidator()
+	if err := req.bind(c, v); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("subtitle_order_failed", nil, c),
+				-2516,
+				err,
+			),
+		)
+	}
+
+	resp, err := sh.SerieService(c).OrderSubtitles(serie_id, episode_id, req)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate(err.MessageID, nil, c),
+				-2516,
+				fmt.Errorf("%s", utils.Translate(err.Err.Error(), nil, c)),
+			),
+		)
+	}
+
+	return c.Status(http.StatusOK).JSON(
+		response.NewResponse(
+			utils.Translate("subtitle_order_success", nil, c),
+			2516,
+			resp,
+		),
+	)
+}
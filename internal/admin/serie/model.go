@@ -3,12 +3,63 @@ package serie
 import (
 	"fmt"
 	custom_log "rerng_addicted_api/pkg/logs"
+	share "rerng_addicted_api/pkg/model"
 	"rerng_addicted_api/pkg/utils"
+	"strconv"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
+// entities and actions recorded in tbl_catalog_changes
+const (
+	EntitySerie   = "serie"
+	EntityEpisode = "episode"
+
+	ActionInsert = "insert"
+	ActionUpdate = "update"
+)
+
+// columns whose changes are recorded, they are also the only columns a rollback writes
+var (
+	TrackedSerieFields = []string{
+		"title", "description", "release_date", "trailer", "country", "status", "type",
+		"next_ep_date_id", "episodes_count", "label", "favorite_id", "thumbnail", "thumbnail_path", "provider",
+	}
+	TrackedEpisodeFields = []string{
+		"series_id", "number", "sub", "src", "origin_src", "provider", "status_id", "max_height",
+	}
+)
+
+// provider recorded for series an admin created by hand
+const ManualProvider = "manual"
+
+// tbl_episodes.status_id values, InsertEpisode derives it from the src unless one is given
+const (
+	EpisodeStatusPlayable    = 1
+	EpisodeStatusUnavailable = 2
+)
+
+// where a genre, tag or person link came from, scrapes never replace links an admin set by hand
+const (
+	MetadataSourceScrape = "scrape"
+	MetadataSourceManual = "manual"
+)
+
+// metadata kinds, also the path segment of the admin metadata endpoints
+const (
+	MetadataGenres = "genres"
+	MetadataTags   = "tags"
+	MetadataPeople = "people"
+)
+
+// roles a person can have on a serie
+const (
+	RoleActor    = "actor"
+	RoleDirector = "director"
+	RoleWriter   = "writer"
+)
+
 type Serie struct {
 	ID            int    `db:"id" json:"id"`
 	Title         string `db:"title" json:"title"`
@@ -16,6 +67,7 @@ type Serie struct {
 	Label         string `db:"label" json:"label"`
 	FavoriteID    int    `db:"favorite_id" json:"favorite_id"`
 	Thumbnail     string `db:"thumbnail" json:"thumbnail"`
+	Provider      string `db:"provider" json:"provider"`
 }
 
 type Episode struct {
@@ -39,10 +91,14 @@ type SerieDetail struct {
 	Label         *string   `db:"label" json:"label"`
 	FavoriteID    int       `db:"favorite_id" json:"favorite_id"`
 	Thumbnail     string    `db:"thumbnail" json:"thumbnail"`
+	Provider      string    `db:"provider" json:"provider"`
 }
 
+// SerieDeepDetail carries the provider's id in ID when scraped, rows read back carry the internal id in ID
+// and the provider's in ExternalID
 type SerieDeepDetail struct {
 	ID            int           `db:"id" json:"id"`
+	ExternalID    int           `db:"external_id" json:"external_id,omitempty"`
 	Title         string        `db:"title" json:"title"`
 	Description   string        `db:"description" json:"description"`
 	ReleaseDate   *time.Time    `db:"release_date" json:"release_date"`
@@ -56,22 +112,134 @@ type SerieDeepDetail struct {
 	Label         *string       `db:"label" json:"label"`
 	FavoriteID    int           `db:"favorite_id" json:"favorite_id"`
 	Thumbnail     string        `db:"thumbnail" json:"thumbnail"`
+	Provider      string        `db:"provider" json:"provider"`
+	// ThumbnailPath is the mirrored copy of Thumbnail, served from /api/v1/images/
+	ThumbnailPath *string `db:"thumbnail_path" json:"thumbnail_path"`
+	// Genres, Tags and People are left nil by providers that do not expose them, nil keeps what is stored
+	Genres []Genre  `json:"genres,omitempty"`
+	Tags   []Tag    `json:"tags,omitempty"`
+	People []Credit `json:"people,omitempty"`
+	// DeletedAt is only loaded by the admin endpoints
+	DeletedAt *time.Time `db:"deleted_at" json:"deleted_at,omitempty"`
+}
+
+type Genre struct {
+	ID     int    `db:"id" json:"id"`
+	Name   string `db:"name" json:"name"`
+	Slug   string `db:"slug" json:"slug"`
+	Source string `db:"source" json:"source,omitempty"`
 }
 
+type Tag struct {
+	ID     int    `db:"id" json:"id"`
+	Name   string `db:"name" json:"name"`
+	Slug   string `db:"slug" json:"slug"`
+	Source string `db:"source" json:"source,omitempty"`
+}
+
+// Credit is a person's role on a serie
+type Credit struct {
+	PersonID  int     `db:"person_id" json:"person_id"`
+	Name      string  `db:"name" json:"name"`
+	Slug      string  `db:"slug" json:"slug"`
+	Thumbnail *string `db:"thumbnail" json:"thumbnail"`
+	Role      string  `db:"role" json:"role"`
+	Character *string `db:"character_name" json:"character"`
+	Source    string  `db:"source" json:"source,omitempty"`
+}
+
+// SerieSummary is one row of the admin serie list
+type SerieSummary struct {
+	ID            int        `db:"id" json:"id"`
+	Title         string     `db:"title" json:"title"`
+	Country       *string    `db:"country" json:"country"`
+	Status        *string    `db:"status" json:"status"`
+	Type          *string    `db:"type" json:"type"`
+	EpisodesCount int        `db:"episodes_count" json:"episodes_count"`
+	StoredCount   int        `db:"stored_count" json:"stored_count"`
+	Thumbnail     *string    `db:"thumbnail" json:"thumbnail"`
+	ThumbnailPath *string    `db:"thumbnail_path" json:"thumbnail_path"`
+	Provider      string     `db:"provider" json:"provider"`
+	CreatedAt     *time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt     *time.Time `db:"updated_at" json:"updated_at"`
+	DeletedAt     *time.Time `db:"deleted_at" json:"deleted_at"`
+}
+
+type SeriesResponse struct {
+	Series []SerieSummary `json:"series"`
+	Total  int            `json:"-"`
+}
+
+type EpisodesResponse struct {
+	Episodes []EpisodeDeep `json:"episodes"`
+}
+
+type SerieMetadata struct {
+	SerieID int      `json:"serie_id"`
+	Genres  []Genre  `json:"genres"`
+	Tags    []Tag    `json:"tags"`
+	People  []Credit `json:"people"`
+}
+
+type SerieMetadataResponse struct {
+	SerieMetadata []SerieMetadata `json:"serie_metadata"`
+}
+
+// MetadataItem is one row of tbl_genres, tbl_tags or tbl_people
+type MetadataItem struct {
+	ID          int     `db:"id" json:"id"`
+	Name        string  `db:"name" json:"name"`
+	Slug        string  `db:"slug" json:"slug"`
+	Thumbnail   *string `db:"thumbnail" json:"thumbnail,omitempty"`
+	SeriesCount int     `db:"series_count" json:"series_count"`
+}
+
+type MetadataItemsResponse struct {
+	Kind  string         `json:"kind"`
+	Items []MetadataItem `json:"items"`
+	Total int            `json:"-"`
+}
+
+// EpisodeDeep follows the same id convention as SerieDeepDetail
 type EpisodeDeep struct {
-	ID        int        `db:"id" json:"id"`
-	SeriesID  int        `db:"series_id" json:"series_id"`
-	Number    float64    `db:"number" json:"number"`
-	Sub       int        `db:"sub" json:"sub"`
-	Source    string     `db:"src" json:"src"`
-	Subtitles []Subtitle `json:"subtitles"`
+	ID         int        `db:"id" json:"id"`
+	ExternalID int        `db:"external_id" json:"external_id,omitempty"`
+	SeriesID   int        `db:"series_id" json:"series_id"`
+	Number     float64    `db:"number" json:"number"`
+	Sub        int        `db:"sub" json:"sub"`
+	Source     string     `db:"src" json:"src"`
+	Subtitles  []Subtitle `json:"subtitles"`
+	Provider   string     `db:"provider" json:"provider"`
+	// OriginSource is the upstream url behind the proxied src, used to probe whether it still plays
+	OriginSource string `db:"origin_src" json:"-"`
+	// Renditions are the quality variants of an hls master src, empty for single quality sources
+	Renditions []Rendition `json:"renditions"`
+	// StatusID overrides the status InsertEpisode would derive from Source, zero derives it
+	StatusID int `db:"status_id" json:"status_id,omitempty"`
+	// QualityUpgradedAt is when a re-resolve last found a taller rendition than the stored one
+	QualityUpgradedAt *time.Time `db:"quality_upgraded_at" json:"quality_upgraded_at,omitempty"`
+}
+
+type Rendition struct {
+	ID               int     `db:"id" json:"id"`
+	EpisodeID        int     `db:"episode_id" json:"episode_id"`
+	Bandwidth        int     `db:"bandwidth" json:"bandwidth"`
+	AverageBandwidth int     `db:"average_bandwidth" json:"average_bandwidth"`
+	Width            int     `db:"width" json:"width"`
+	Height           int     `db:"height" json:"height"`
+	Codecs           string  `db:"codecs" json:"codecs"`
+	FrameRate        float64 `db:"frame_rate" json:"frame_rate"`
+	Src              string  `db:"src" json:"src"`
+	OriginSource     string  `db:"origin_src" json:"-"`
 }
 
 type Subtitle struct {
+	ID      int    `db:"id" json:"id,omitempty"`
 	Src     string `db:"src" json:"src"`
 	Label   string `db:"label" json:"label"`
 	Lang    string `db:"lang" json:"lang"`
 	Default bool   `db:"is_default" json:"is_default"`
+	Order   int    `db:"order" json:"order,omitempty"`
 }
 
 type SeriesDeepDetailsResponse struct {
@@ -161,12 +329,210 @@ type NewSerieRequest struct {
 
 func (s *NewSerieRequest) bind(c *fiber.Ctx, v *utils.Validator) error {
 	if err := c.BodyParser(s); err != nil {
-		custom_log.NewCustomLog("login_failed", err.Error(), "error")
+		custom_log.NewCustomLog("serie_save_failed", err.Error(), "error")
+		return fmt.Errorf("%s", utils.Translate("invalid_body", nil, c))
+	}
+
+	if err := v.Validate(s, c); err != nil {
+		custom_log.NewCustomLog("serie_save_failed", err.Error(), "error")
+		return err
+	}
+
+	return nil
+}
+
+// releaseDate parses ReleaseDate, validation already checked the layout
+func (s *NewSerieRequest) releaseDate() *time.Time {
+	if s.ReleaseDate == "" {
+		return nil
+	}
+	release_date, err := time.Parse("2006-01-02", s.ReleaseDate)
+	if err != nil {
+		return nil
+	}
+	return &release_date
+}
+
+type SerieShowRequest struct {
+	PageOptions share.Paging   `json:"paging_options" query:"paging_options" validate:"required"`
+	Sorts       []share.Sort   `json:"sorts,omitempty" query:"sorts"`
+	Filters     []share.Filter `json:"filters,omitempty" query:"filters"`
+	// Trashed lists soft-deleted series instead of live ones
+	Trashed bool `json:"trashed" query:"trashed"`
+}
+
+func (r *SerieShowRequest) bind(c *fiber.Ctx, v *utils.Validator) error {
+	if err := c.QueryParser(r); err != nil {
+		return err
+	}
+
+	// fix `Filter.Value` nil when http query params failed parse to json type `interface{}`
+	for i := range r.Filters {
+		value := c.Query(fmt.Sprintf("filters[%d][value]", i))
+		if int_value, err := strconv.Atoi(value); err == nil {
+			r.Filters[i].Value = int_value
+		} else {
+			r.Filters[i].Value = value
+		}
+	}
+
+	if err := v.Validate(r, c); err != nil {
+		return err
+	}
+	return nil
+}
+
+// SerieMetadataRequest replaces the metadata of a serie by hand. A nil list leaves that kind as it
+// is, an empty one clears the manual links and lets the next scrape fill it again.
+type SerieMetadataRequest struct {
+	Genres []string        `json:"genres" validate:"omitempty,dive,min=1,max=100"`
+	Tags   []string        `json:"tags" validate:"omitempty,dive,min=1,max=100"`
+	People []CreditRequest `json:"people" validate:"omitempty,dive"`
+}
+
+type CreditRequest struct {
+	Name      string  `json:"name" validate:"required,min=1,max=255"`
+	Role      string  `json:"role" validate:"required,oneof=actor director writer"`
+	Character *string `json:"character" validate:"omitempty,max=255"`
+	Thumbnail *string `json:"thumbnail" validate:"omitempty,url"`
+}
+
+func (s *SerieMetadataRequest) bind(c *fiber.Ctx, v *utils.Validator) error {
+	if err := c.BodyParser(s); err != nil {
+		custom_log.NewCustomLog("serie_metadata_update_failed", err.Error(), "error")
+		return fmt.Errorf("%s", utils.Translate("invalid_body", nil, c))
+	}
+
+	if err := v.Validate(s, c); err != nil {
+		custom_log.NewCustomLog("serie_metadata_update_failed", err.Error(), "error")
+		return err
+	}
+
+	return nil
+}
+
+type MetadataItemRequest struct {
+	Name string `json:"name" validate:"required,min=1,max=255"`
+	// Thumbnail only applies to people
+	Thumbnail *string `json:"thumbnail" validate:"omitempty,url"`
+}
+
+func (s *MetadataItemRequest) bind(c *fiber.Ctx, v *utils.Validator) error {
+	if err := c.BodyParser(s); err != nil {
+		custom_log.NewCustomLog("metadata_update_failed", err.Error(), "error")
+		return fmt.Errorf("%s", utils.Translate("invalid_body", nil, c))
+	}
+
+	if err := v.Validate(s, c); err != nil {
+		custom_log.NewCustomLog("metadata_update_failed", err.Error(), "error")
+		return err
+	}
+
+	return nil
+}
+
+type MetadataShowRequest struct {
+	PageOptions share.Paging   `json:"paging_options" query:"paging_options" validate:"required"`
+	Sorts       []share.Sort   `json:"sorts,omitempty" query:"sorts"`
+	Filters     []share.Filter `json:"filters,omitempty" query:"filters"`
+}
+
+func (r *MetadataShowRequest) bind(c *fiber.Ctx, v *utils.Validator) error {
+	if err := c.QueryParser(r); err != nil {
+		return err
+	}
+
+	// fix `Filter.Value` nil when http query params failed parse to json type `interface{}`
+	for i := range r.Filters {
+		value := c.Query(fmt.Sprintf("filters[%d][value]", i))
+		if int_value, err := strconv.Atoi(value); err == nil {
+			r.Filters[i].Value = int_value
+		} else {
+			r.Filters[i].Value = value
+		}
+	}
+
+	if err := v.Validate(r, c); err != nil {
+		return err
+	}
+	return nil
+}
+
+// EpisodeRequest edits an episode, fields left out keep their stored value
+type EpisodeRequest struct {
+	Number   *float64 `json:"number" validate:"omitempty,gte=0"`
+	Sub      *int     `json:"sub" validate:"omitempty,gte=0"`
+	Source   *string  `json:"src" validate:"omitempty,url,max=500"`
+	StatusID *int     `json:"status_id" validate:"omitempty,oneof=1 2"`
+}
+
+func (s *EpisodeRequest) bind(c *fiber.Ctx, v *utils.Validator) error {
+	if err := c.BodyParser(s); err != nil {
+		custom_log.NewCustomLog("episode_update_failed", err.Error(), "error")
+		return fmt.Errorf("%s", utils.Translate("invalid_body", nil, c))
+	}
+
+	if err := v.Validate(s, c); err != nil {
+		custom_log.NewCustomLog("episode_update_failed", err.Error(), "error")
+		return err
+	}
+
+	return nil
+}
+
+type SubtitleRequest struct {
+	Src     string `json:"src" validate:"required,url,max=500"`
+	Label   string `json:"label" validate:"required,max=50"`
+	Lang    string `json:"lang" validate:"required,max=10"`
+	Default bool   `json:"is_default"`
+}
+
+func (s *SubtitleRequest) bind(c *fiber.Ctx, v *utils.Validator) error {
+	if err := c.BodyParser(s); err != nil {
+		custom_log.NewCustomLog("subtitle_save_failed", err.Error(), "error")
+		return fmt.Errorf("%s", utils.Translate("invalid_body", nil, c))
+	}
+
+	if err := v.Validate(s, c); err != nil {
+		custom_log.NewCustomLog("subtitle_save_failed", err.Error(), "error")
+		return err
+	}
+
+	return nil
+}
+
+// SubtitlesRequest replaces every subtitle track of an episode, in display order
+type SubtitlesRequest struct {
+	Subtitles []SubtitleRequest `json:"subtitles" validate:"dive"`
+}
+
+func (s *SubtitlesRequest) bind(c *fiber.Ctx, v *utils.Validator) error {
+	if err := c.BodyParser(s); err != nil {
+		custom_log.NewCustomLog("subtitle_save_failed", err.Error(), "error")
+		return fmt.Errorf("%s", utils.Translate("invalid_body", nil, c))
+	}
+
+	if err := v.Validate(s, c); err != nil {
+		custom_log.NewCustomLog("subtitle_save_failed", err.Error(), "error")
+		return err
+	}
+
+	return nil
+}
+
+// SubtitleOrderRequest lists subtitle ids in their new display order
+type SubtitleOrderRequest struct {
+	IDs []int `json:"ids" validate:"required,min=1,dive,gt=0"`
+}
+
+func (s *SubtitleOrderRequest) bind(c *fiber.Ctx, v *utils.Validator) error {
+	if err := c.BodyParser(s); err != nil {
+		custom_log.NewCustomLog("subtitle_order_failed", err.Error(), "error")
 		return fmt.Errorf("%s", utils.Translate("invalid_body", nil, c))
 	}
 
 	if err := v.Validate(s, c); err != nil {
-		custom_log.NewCustomLog("login_failed", err.Error(), "error")
+		custom_log.NewCustomLog("subtitle_order_failed", err.Error(), "error")
 		return err
 	}
 
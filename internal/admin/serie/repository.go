@@ -1,25 +1,39 @@
 package serie
 
 import (
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	custom_log "rerng_addicted_api/pkg/logs"
 	share "rerng_addicted_api/pkg/model"
+	postgres "rerng_addicted_api/pkg/postgres"
+	"rerng_addicted_api/pkg/proxyurl"
 	"rerng_addicted_api/pkg/responses"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/fatih/color"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
+// provider recorded for rows scraped before the provider column existed
+const DefaultProvider = "kisskh"
+
 type SerieRepo interface {
 	Create(serie_detail SerieDeepDetail) (*SeriesDeepDetailsResponse, *responses.ErrorResponse)
-	InsertEpisode(tx *sqlx.Tx, serie_id int, ep EpisodeDeep) error
-	InsertSubtitle(tx *sqlx.Tx, episode_id int, sub Subtitle) error
+	InsertEpisode(execer sqlx.Ext, serie_id int, ep EpisodeDeep) error
+	InsertSubtitle(execer sqlx.Ext, episode_id int, sub Subtitle) error
 }
 
 type SerieRepoImpl struct {
 	DBPool      *sqlx.DB
 	UserContext *share.UserContext
+	// RunID links recorded field changes to the scrape run that caused them, nil outside a run
+	RunID *int
 }
 
 func NewSerieRepoImpl(db_pool *sqlx.DB, userCtx *share.UserContext) *SerieRepoImpl {
@@ -30,6 +44,10 @@ func NewSerieRepoImpl(db_pool *sqlx.DB, userCtx *share.UserContext) *SerieRepoIm
 }
 
 func (sc *SerieRepoImpl) Create(serie_detail SerieDeepDetail) (*SeriesDeepDetailsResponse, *responses.ErrorResponse) {
+	if serie_detail.Provider == "" {
+		serie_detail.Provider = DefaultProvider
+	}
+
 	// begin transaction
 	tx, err := sc.DBPool.Beginx()
 	if err != nil {
@@ -40,15 +58,33 @@ func (sc *SerieRepoImpl) Create(serie_detail SerieDeepDetail) (*SeriesDeepDetail
 	}
 
 	color.Yellow("\n💾 Upserting series and related data...")
-	// upsert data
-	_, err = tx.NamedExec(`
+	// keep the previous values so the change history can show what this upsert replaced
+	serie_id, err := internalID(tx, "tbl_series", serie_detail.Provider, serie_detail.ID)
+	if err != nil {
+		color.Red("❌ Failed to read series %d: %v", serie_detail.ID, err)
+		tx.Rollback()
+		custom_log.NewCustomLog("insert_serie_failed", err.Error(), "error")
+		err_msg := &responses.ErrorResponse{}
+		return nil, err_msg.NewErrorResponse("insert_serie_failed", fmt.Errorf("database_error"))
+	}
+	before, err := snapshot(tx, "tbl_series", TrackedSerieFields, serie_id)
+	if err != nil {
+		color.Red("❌ Failed to read series %d: %v", serie_detail.ID, err)
+		tx.Rollback()
+		custom_log.NewCustomLog("insert_serie_failed", err.Error(), "error")
+		err_msg := &responses.ErrorResponse{}
+		return nil, err_msg.NewErrorResponse("insert_serie_failed", fmt.Errorf("database_error"))
+	}
+
+	// upsert data, the provider's id is only the lookup key, the stored row keeps its own id
+	query, args, err := tx.BindNamed(`
 		INSERT INTO tbl_series 
-			(id, title, description, release_date, trailer, country, status, type, next_ep_date_id,
-			episodes_count, label, favorite_id, thumbnail)
+			(external_id, title, description, release_date, trailer, country, status, type, next_ep_date_id,
+			episodes_count, label, favorite_id, thumbnail, thumbnail_path, provider)
 		VALUES 
 			(:id, :title, :description, :release_date, :trailer, :country, :status, :type, :next_ep_date_id,
-			:episodes_count, :label, :favorite_id, :thumbnail)
-		ON CONFLICT (id) DO UPDATE SET
+			:episodes_count, :label, :favorite_id, :thumbnail, :thumbnail_path, :provider)
+		ON CONFLICT (provider, external_id) DO UPDATE SET
 			title = EXCLUDED.title,
 			description = EXCLUDED.description,
 			release_date = EXCLUDED.release_date,
@@ -60,8 +96,14 @@ func (sc *SerieRepoImpl) Create(serie_detail SerieDeepDetail) (*SeriesDeepDetail
 			episodes_count = EXCLUDED.episodes_count,
 			label = EXCLUDED.label,
 			favorite_id = EXCLUDED.favorite_id,
-			thumbnail = EXCLUDED.thumbnail
+			thumbnail = EXCLUDED.thumbnail,
+			-- a failed mirror keeps the last good copy
+			thumbnail_path = COALESCE(EXCLUDED.thumbnail_path, tbl_series.thumbnail_path)
+		RETURNING id
 	`, serie_detail)
+	if err == nil {
+		err = tx.Get(&serie_id, query, args...)
+	}
 	if err != nil {
 		color.Red("❌ Failed to upsert series %d: %v", serie_detail.ID, err)
 		tx.Rollback()
@@ -70,9 +112,28 @@ func (sc *SerieRepoImpl) Create(serie_detail SerieDeepDetail) (*SeriesDeepDetail
 		return nil, err_msg.NewErrorResponse("insert_serie_failed", fmt.Errorf("database_error"))
 	}
 
+	if err := sc.recordChanges(tx, EntitySerie, serie_id, serie_id, "tbl_series", TrackedSerieFields, before); err != nil {
+		color.Red("❌ %v", err)
+		tx.Rollback()
+		custom_log.NewCustomLog("insert_serie_failed", err.Error(), "error")
+		err_msg := &responses.ErrorResponse{}
+		return nil, err_msg.NewErrorResponse("insert_serie_failed", fmt.Errorf("database_error"))
+	}
+
+	if err := sc.ReplaceMetadata(tx, serie_id, serie_detail.Genres, serie_detail.Tags, serie_detail.People, MetadataSourceScrape); err != nil {
+		color.Red("❌ %v", err)
+		tx.Rollback()
+		custom_log.NewCustomLog("insert_serie_failed", err.Error(), "error")
+		err_msg := &responses.ErrorResponse{}
+		return nil, err_msg.NewErrorResponse("insert_serie_failed", fmt.Errorf("database_error"))
+	}
+
 	// insert episodes
 	for _, ep := range serie_detail.Episodes {
-		if err := sc.InsertEpisode(tx, serie_detail.ID, ep); err != nil {
+		if ep.Provider == "" {
+			ep.Provider = serie_detail.Provider
+		}
+		if err := sc.InsertEpisode(tx, serie_id, ep); err != nil {
 			color.Red("❌ %v", err)
 			tx.Rollback()
 			custom_log.NewCustomLog("insert_episode_failed", err.Error(), "error")
@@ -95,58 +156,1249 @@ func (sc *SerieRepoImpl) Create(serie_detail SerieDeepDetail) (*SeriesDeepDetail
 	}, nil
 }
 
+// InsertEpisode upserts an episode of the stored serie serie_id by the provider's episode id in ep.ID
 func (sc *SerieRepoImpl) InsertEpisode(execer sqlx.Ext, serie_id int, ep EpisodeDeep) error {
-	// determine status_id based on source URL
-	status_id := 1
-	if !strings.Contains(ep.Source, ".m3u8") && !strings.Contains(ep.Source, ".mp4") {
-		status_id = 2
+	// determine status_id based on source URL, unless an admin set it
+	status_id := ep.StatusID
+	if status_id == 0 {
+		status_id = sourceStatus(ep.Source)
 	}
-	_, err := sqlx.NamedExec(execer, `
-		INSERT INTO tbl_episodes (id, series_id, number, sub, src, status_id)
-		VALUES (:id, :series_id, :number, :sub, :src, :status_id)
-		ON CONFLICT (id) DO UPDATE SET
+	provider := ep.Provider
+	if provider == "" {
+		provider = DefaultProvider
+	}
+	// a freshly resolved source is healthy until the next probe says otherwise
+	var origin_src *string
+	if ep.OriginSource != "" {
+		origin_src = &ep.OriginSource
+	}
+	var max_height *int
+	for _, rendition := range ep.Renditions {
+		if max_height == nil || rendition.Height > *max_height {
+			height := rendition.Height
+			max_height = &height
+		}
+	}
+
+	episode_id, err := internalID(execer, "tbl_episodes", provider, ep.ID)
+	if err != nil {
+		return fmt.Errorf("failed reading episode %d: %w", ep.ID, err)
+	}
+	before, err := snapshot(execer, "tbl_episodes", TrackedEpisodeFields, episode_id)
+	if err != nil {
+		return fmt.Errorf("failed reading episode %d: %w", ep.ID, err)
+	}
+
+	query, args, err := sqlx.Named(`
+		INSERT INTO tbl_episodes (external_id, series_id, number, sub, src, provider, status_id, origin_src, max_height,
+			is_stale, check_error, last_resolved_at, created_by)
+		VALUES (:id, :series_id, :number, :sub, :src, :provider, :status_id, :origin_src, :max_height,
+			FALSE, NULL, CASE WHEN :status_id = 1 THEN NOW() END, :user_id)
+		ON CONFLICT (provider, external_id) DO UPDATE SET
 			series_id = EXCLUDED.series_id,
 			number = EXCLUDED.number,
 			sub = EXCLUDED.sub,
 			src = EXCLUDED.src,
-			status_id = EXCLUDED.status_id
+			status_id = EXCLUDED.status_id,
+			origin_src = EXCLUDED.origin_src,
+			max_height = EXCLUDED.max_height,
+			quality_upgraded_at = CASE WHEN EXCLUDED.max_height > tbl_episodes.max_height
+				THEN NOW() ELSE tbl_episodes.quality_upgraded_at END,
+			is_stale = FALSE,
+			check_error = NULL,
+			last_resolved_at = COALESCE(EXCLUDED.last_resolved_at, tbl_episodes.last_resolved_at),
+			updated_at = NOW(),
+			updated_by = EXCLUDED.created_by
+		RETURNING id
 	`, map[string]interface{}{
-		"id":        ep.ID,
-		"series_id": serie_id,
-		"number":    ep.Number,
-		"sub":       ep.Sub,
-		"src":       ep.Source,
-		"status_id": status_id,
+		"id":         ep.ID,
+		"series_id":  serie_id,
+		"number":     ep.Number,
+		"sub":        ep.Sub,
+		"src":        proxyurl.Strip(ep.Source),
+		"provider":   provider,
+		"status_id":  status_id,
+		"origin_src": origin_src,
+		"max_height": max_height,
+		"user_id":    sc.UserContext.Id,
 	})
+	if err == nil {
+		err = sqlx.Get(execer, &episode_id, execer.Rebind(query), args...)
+	}
 	if err != nil {
 		return fmt.Errorf("failed upserting episode %d: %w", ep.ID, err)
 	}
 
+	if err := sc.recordChanges(execer, EntityEpisode, episode_id, serie_id, "tbl_episodes", TrackedEpisodeFields, before); err != nil {
+		return err
+	}
+	if before != nil && before["max_height"] != nil && max_height != nil {
+		if old_height, err := strconv.Atoi(*before["max_height"]); err == nil && *max_height > old_height {
+			color.Cyan("⬆️  Episode %d now available up to %dp (was %dp)", ep.ID, *max_height, old_height)
+		}
+	}
+
+	if err := sc.InsertRenditions(execer, episode_id, ep.Renditions); err != nil {
+		return err
+	}
+
 	for _, sub := range ep.Subtitles {
-		if err := sc.InsertSubtitle(execer, ep.ID, sub); err != nil {
+		if err := sc.InsertSubtitle(execer, episode_id, sub); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// sourceStatus is the status a src gets when nobody set one, only hls and mp4 sources play
+func sourceStatus(src string) int {
+	if !strings.Contains(src, ".m3u8") && !strings.Contains(src, ".mp4") {
+		return EpisodeStatusUnavailable
+	}
+	return EpisodeStatusPlayable
+}
+
+// InsertRenditions syncs the stored quality variants of an episode with the ones just resolved,
+// variants are matched by their upstream playlist and the ones no longer listed are dropped
+func (sc *SerieRepoImpl) InsertRenditions(execer sqlx.Ext, episode_id int, renditions []Rendition) error {
+	origins := make([]string, 0, len(renditions))
+	for _, rendition := range renditions {
+		_, err := execer.Exec(`
+			INSERT INTO tbl_episode_renditions
+				(episode_id, bandwidth, average_bandwidth, width, height, codecs, frame_rate, src, origin_src)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			ON CONFLICT (episode_id, origin_src) DO UPDATE SET
+				bandwidth = EXCLUDED.bandwidth,
+				average_bandwidth = EXCLUDED.average_bandwidth,
+				width = EXCLUDED.width,
+				height = EXCLUDED.height,
+				codecs = EXCLUDED.codecs,
+				frame_rate = EXCLUDED.frame_rate,
+				src = EXCLUDED.src,
+				updated_at = NOW()
+		`, episode_id, rendition.Bandwidth, rendition.AverageBandwidth, rendition.Width, rendition.Height,
+			rendition.Codecs, rendition.FrameRate, proxyurl.Strip(rendition.Src), rendition.OriginSource)
+		if err != nil {
+			return fmt.Errorf("failed upserting rendition of episode %d: %w", episode_id, err)
+		}
+		origins = append(origins, rendition.OriginSource)
+	}
+
+	_, err := execer.Exec(`
+		DELETE FROM tbl_episode_renditions
+		WHERE episode_id = $1 AND NOT (origin_src = ANY($2))
+	`, episode_id, pq.Array(origins))
+	if err != nil {
+		return fmt.Errorf("failed clearing renditions of episode %d: %w", episode_id, err)
+	}
+	return nil
+}
+
+// InsertSubtitle upserts a track by language, a zero Order keeps the stored position
 func (sc *SerieRepoImpl) InsertSubtitle(execer sqlx.Ext, episode_id int, sub Subtitle) error {
+	var order *int
+	if sub.Order > 0 {
+		order = &sub.Order
+	}
+
 	_, err := sqlx.NamedExec(execer, `
-		INSERT INTO tbl_subtitles (episode_id, src, label, lang, is_default)
-		VALUES (:episode_id, :src, :label, :lang, :is_default)
+		INSERT INTO tbl_subtitles (episode_id, src, label, lang, is_default, "order", created_by)
+		VALUES (:episode_id, :src, :label, :lang, :is_default, COALESCE(:order, 1), :user_id)
 		ON CONFLICT (episode_id, lang) DO UPDATE SET
 			src = EXCLUDED.src,
 			label = EXCLUDED.label,
-			is_default = EXCLUDED.is_default
+			is_default = EXCLUDED.is_default,
+			"order" = COALESCE(:order, tbl_subtitles."order"),
+			deleted_at = NULL,
+			deleted_by = NULL,
+			updated_at = NOW(),
+			updated_by = EXCLUDED.created_by
 	`, map[string]interface{}{
 		"episode_id": episode_id,
-		"src":        sub.Src,
+		"src":        proxyurl.Strip(sub.Src),
 		"label":      sub.Label,
 		"lang":       sub.Lang,
 		"is_default": sub.Default,
+		"order":      order,
+		"user_id":    sc.UserContext.Id,
 	})
 	if err != nil {
 		return fmt.Errorf("failed upserting subtitle for episode %d: %w", episode_id, err)
 	}
 	return nil
 }
+
+// EpisodeNumbers lists the episode numbers of a provider's series that already have a playable source
+func (sc *SerieRepoImpl) EpisodeNumbers(provider string, external_id int) ([]float64, error) {
+	numbers := []float64{}
+	err := sc.DBPool.Select(&numbers, `
+		SELECT e.number FROM tbl_episodes e
+		JOIN tbl_series s ON s.id = e.series_id
+		WHERE s.provider = $1 AND s.external_id = $2 AND e.status_id = 1 AND e.deleted_at IS NULL
+		ORDER BY e.number
+	`, provider, external_id)
+	if err != nil {
+		return nil, fmt.Errorf("failed selecting episodes of series %d: %w", external_id, err)
+	}
+	return numbers, nil
+}
+
+// UpdateListing refreshes the airing fields of a stored series from a fresh listing of provider,
+// recording the changes like Create does
+func (sc *SerieRepoImpl) UpdateListing(provider string, serie_detail SerieDetail) error {
+	tx, err := sc.DBPool.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed updating listing of series %d: %w", serie_detail.ID, err)
+	}
+	defer tx.Rollback()
+
+	serie_id, err := internalID(tx, "tbl_series", provider, serie_detail.ID)
+	if err != nil || serie_id == 0 {
+		return err
+	}
+	before, err := snapshot(tx, "tbl_series", TrackedSerieFields, serie_id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE tbl_series
+		SET status = $2, next_ep_date_id = $3, episodes_count = $4, updated_at = NOW(), updated_by = $5
+		WHERE id = $1
+	`, serie_id, serie_detail.Status, serie_detail.NextEpDateID, serie_detail.EpisodesCount, sc.UserContext.Id)
+	if err != nil {
+		return fmt.Errorf("failed updating listing of series %d: %w", serie_detail.ID, err)
+	}
+
+	if err := sc.recordChanges(tx, EntitySerie, serie_id, serie_id, "tbl_series", TrackedSerieFields, before); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Exists reports whether a serie is stored and not deleted
+func (sc *SerieRepoImpl) Exists(serie_id int) (bool, error) {
+	var exists bool
+	err := sc.DBPool.Get(&exists, `SELECT EXISTS (SELECT 1 FROM tbl_series WHERE id = $1 AND deleted_at IS NULL)`, serie_id)
+	if err != nil {
+		return false, fmt.Errorf("failed checking series %d: %w", serie_id, err)
+	}
+	return exists, nil
+}
+
+// EpisodeSources maps the provider's episode ids of a series to their stored src,
+// exists is false when the series is not stored yet
+func (sc *SerieRepoImpl) EpisodeSources(provider string, external_id int) (map[int]string, bool, error) {
+	serie_id, err := internalID(sc.DBPool, "tbl_series", provider, external_id)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed checking series %d: %w", external_id, err)
+	}
+
+	sources := map[int]string{}
+	if serie_id == 0 {
+		return sources, false, nil
+	}
+
+	rows := []struct {
+		ID  int    `db:"id"`
+		Src string `db:"src"`
+	}{}
+	if err := sc.DBPool.Select(&rows, `SELECT external_id AS id, src FROM tbl_episodes WHERE series_id = $1`, serie_id); err != nil {
+		return nil, true, fmt.Errorf("failed selecting episodes of series %d: %w", serie_id, err)
+	}
+	for _, row := range rows {
+		sources[row.ID] = row.Src
+	}
+	return sources, true, nil
+}
+
+// SerieID resolves the provider's id of a series to the id it is stored under, zero when it is not stored
+func (sc *SerieRepoImpl) SerieID(provider string, external_id int) (int, error) {
+	serie_id, err := internalID(sc.DBPool, "tbl_series", provider, external_id)
+	if err != nil {
+		return 0, fmt.Errorf("failed checking series %d: %w", external_id, err)
+	}
+	return serie_id, nil
+}
+
+// internalID looks up the row a provider's id is stored under, zero when there is none
+func internalID(queryer sqlx.Queryer, table string, provider string, external_id int) (int, error) {
+	var id int
+	err := sqlx.Get(queryer, &id, fmt.Sprintf(`SELECT id FROM %s WHERE provider = $1 AND external_id = $2`, table), provider, external_id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return id, err
+}
+
+// snapshot reads the given columns of one row as text, it returns nil when the row does not exist
+func snapshot(queryer sqlx.Queryer, table string, fields []string, id int) (map[string]*string, error) {
+	columns := make([]string, len(fields))
+	for i, field := range fields {
+		columns[i] = fmt.Sprintf("%s::text AS %s", field, field)
+	}
+
+	var raw []byte
+	err := sqlx.Get(queryer, &raw, fmt.Sprintf(
+		`SELECT to_jsonb(t) FROM (SELECT %s FROM %s WHERE id = $1) t`,
+		strings.Join(columns, ", "), table,
+	), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed reading %s %d: %w", table, id, err)
+	}
+
+	values := map[string]*string{}
+	if err := json.Unmarshal(raw, &values); err != nil {
+		return nil, fmt.Errorf("failed decoding %s %d: %w", table, id, err)
+	}
+	return values, nil
+}
+
+// recordChanges compares the row against its snapshot from before the upsert and stores one
+// change per field that differs, or a single insert change when the row is new
+func (sc *SerieRepoImpl) recordChanges(execer sqlx.Ext, entity string, entity_id int, serie_id int, table string, fields []string, before map[string]*string) error {
+	insert := func(action string, field *string, old_value *string, new_value *string) error {
+		_, err := execer.Exec(`
+			INSERT INTO tbl_catalog_changes (run_id, entity, entity_id, series_id, action, field, old_value, new_value, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		`, sc.RunID, entity, entity_id, serie_id, action, field, old_value, new_value, sc.UserContext.Id)
+		if err != nil {
+			return fmt.Errorf("failed recording %s change of %s %d: %w", action, entity, entity_id, err)
+		}
+		return nil
+	}
+
+	if before == nil {
+		return insert(ActionInsert, nil, nil, nil)
+	}
+
+	after, err := snapshot(execer, table, fields, entity_id)
+	if err != nil {
+		return err
+	}
+	for _, field := range fields {
+		old_value, new_value := before[field], after[field]
+		if old_value == nil && new_value == nil {
+			continue
+		}
+		if old_value != nil && new_value != nil && *old_value == *new_value {
+			continue
+		}
+		field := field
+		if err := insert(ActionUpdate, &field, old_value, new_value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tables behind each metadata kind
+var metadata_tables = map[string]struct {
+	table       string
+	link_table  string
+	link_column string
+}{
+	MetadataGenres: {"tbl_genres", "tbl_serie_genres", "genre_id"},
+	MetadataTags:   {"tbl_tags", "tbl_serie_tags", "tag_id"},
+	MetadataPeople: {"tbl_people", "tbl_serie_people", "person_id"},
+}
+
+// columns admins may filter and sort metadata lists by
+var metadata_list_columns = map[string]bool{
+	"t.id":         true,
+	"t.name":       true,
+	"t.slug":       true,
+	"t.created_at": true,
+}
+
+// Slugify lowercases name and joins its words with dashes, names in any script keep their letters
+func Slugify(name string) string {
+	var slug strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && slug.Len() > 0 {
+				slug.WriteByte('-')
+			}
+			slug.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	return slug.String()
+}
+
+// Metadata loads the genres, tags and people linked to a serie
+func (sc *SerieRepoImpl) Metadata(serie_id int) (*SerieMetadata, error) {
+	metadata := &SerieMetadata{
+		SerieID: serie_id,
+		Genres:  []Genre{},
+		Tags:    []Tag{},
+		People:  []Credit{},
+	}
+
+	err := sc.DBPool.Select(&metadata.Genres, `
+		SELECT g.id, g.name, g.slug, sg.source
+		FROM tbl_serie_genres sg
+		INNER JOIN tbl_genres g ON g.id = sg.genre_id
+		WHERE sg.series_id = $1
+		ORDER BY sg."order", g.name
+	`, serie_id)
+	if err != nil {
+		return nil, fmt.Errorf("failed selecting genres of series %d: %w", serie_id, err)
+	}
+
+	err = sc.DBPool.Select(&metadata.Tags, `
+		SELECT t.id, t.name, t.slug, st.source
+		FROM tbl_serie_tags st
+		INNER JOIN tbl_tags t ON t.id = st.tag_id
+		WHERE st.series_id = $1
+		ORDER BY st."order", t.name
+	`, serie_id)
+	if err != nil {
+		return nil, fmt.Errorf("failed selecting tags of series %d: %w", serie_id, err)
+	}
+
+	err = sc.DBPool.Select(&metadata.People, `
+		SELECT p.id AS person_id, p.name, p.slug, p.thumbnail, sp.role, sp.character_name, sp.source
+		FROM tbl_serie_people sp
+		INNER JOIN tbl_people p ON p.id = sp.person_id
+		WHERE sp.series_id = $1
+		ORDER BY sp."order", p.name
+	`, serie_id)
+	if err != nil {
+		return nil, fmt.Errorf("failed selecting people of series %d: %w", serie_id, err)
+	}
+
+	return metadata, nil
+}
+
+// ReplaceMetadata links the given genres, tags and people to a serie, a nil list leaves that kind alone.
+// Scraped links replace earlier scraped ones unless an admin already set that kind by hand,
+// manual links replace everything of their kind.
+func (sc *SerieRepoImpl) ReplaceMetadata(execer sqlx.Ext, serie_id int, genres []Genre, tags []Tag, people []Credit, source string) error {
+	if genres != nil {
+		names := make([]string, len(genres))
+		for i, genre := range genres {
+			names[i] = genre.Name
+		}
+		if err := sc.replaceTerms(execer, MetadataGenres, serie_id, names, source); err != nil {
+			return err
+		}
+	}
+
+	if tags != nil {
+		names := make([]string, len(tags))
+		for i, tag := range tags {
+			names[i] = tag.Name
+		}
+		if err := sc.replaceTerms(execer, MetadataTags, serie_id, names, source); err != nil {
+			return err
+		}
+	}
+
+	if people != nil {
+		if err := sc.replaceCredits(execer, serie_id, people, source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clearLinks deletes the links of one kind that source may replace, skip is true when a scrape
+// has to leave the kind alone because an admin set it by hand
+func clearLinks(execer sqlx.Ext, kind string, serie_id int, source string) (bool, error) {
+	tables := metadata_tables[kind]
+
+	if source == MetadataSourceScrape {
+		var manual bool
+		err := sqlx.Get(execer, &manual, fmt.Sprintf(
+			`SELECT EXISTS (SELECT 1 FROM %s WHERE series_id = $1 AND source = $2)`, tables.link_table,
+		), serie_id, MetadataSourceManual)
+		if err != nil {
+			return false, fmt.Errorf("failed checking %s of series %d: %w", kind, serie_id, err)
+		}
+		if manual {
+			return true, nil
+		}
+	}
+
+	if _, err := execer.Exec(fmt.Sprintf(`DELETE FROM %s WHERE series_id = $1`, tables.link_table), serie_id); err != nil {
+		return false, fmt.Errorf("failed clearing %s of series %d: %w", kind, serie_id, err)
+	}
+	return false, nil
+}
+
+func (sc *SerieRepoImpl) replaceTerms(execer sqlx.Ext, kind string, serie_id int, names []string, source string) error {
+	skip, err := clearLinks(execer, kind, serie_id, source)
+	if err != nil || skip {
+		return err
+	}
+
+	tables := metadata_tables[kind]
+	seen := map[string]bool{}
+	for i, name := range names {
+		name = strings.TrimSpace(name)
+		slug := Slugify(name)
+		if slug == "" || seen[slug] {
+			continue
+		}
+		seen[slug] = true
+
+		// the no-op update makes RETURNING give back the id of an existing row too
+		var term_id int
+		err := sqlx.Get(execer, &term_id, fmt.Sprintf(`
+			INSERT INTO %s (name, slug, created_by) VALUES ($1, $2, $3)
+			ON CONFLICT (slug) DO UPDATE SET slug = EXCLUDED.slug
+			RETURNING id
+		`, tables.table), name, slug, sc.UserContext.Id)
+		if err != nil {
+			return fmt.Errorf("failed upserting %s %q: %w", kind, name, err)
+		}
+
+		_, err = execer.Exec(fmt.Sprintf(`
+			INSERT INTO %s (series_id, %s, source, "order", created_by) VALUES ($1, $2, $3, $4, $5)
+		`, tables.link_table, tables.link_column), serie_id, term_id, source, i+1, sc.UserContext.Id)
+		if err != nil {
+			return fmt.Errorf("failed linking %s %q to series %d: %w", kind, name, serie_id, err)
+		}
+	}
+	return nil
+}
+
+func (sc *SerieRepoImpl) replaceCredits(execer sqlx.Ext, serie_id int, people []Credit, source string) error {
+	skip, err := clearLinks(execer, MetadataPeople, serie_id, source)
+	if err != nil || skip {
+		return err
+	}
+
+	for i, credit := range people {
+		name := strings.TrimSpace(credit.Name)
+		slug := Slugify(name)
+		if slug == "" {
+			continue
+		}
+
+		var person_id int
+		err := sqlx.Get(execer, &person_id, `
+			INSERT INTO tbl_people (name, slug, thumbnail, created_by) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (slug) DO UPDATE SET thumbnail = COALESCE(EXCLUDED.thumbnail, tbl_people.thumbnail)
+			RETURNING id
+		`, name, slug, credit.Thumbnail, sc.UserContext.Id)
+		if err != nil {
+			return fmt.Errorf("failed upserting person %q: %w", name, err)
+		}
+
+		_, err = execer.Exec(`
+			INSERT INTO tbl_serie_people (series_id, person_id, role, character_name, source, "order", created_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (series_id, person_id, role) DO NOTHING
+		`, serie_id, person_id, credit.Role, credit.Character, source, i+1, sc.UserContext.Id)
+		if err != nil {
+			return fmt.Errorf("failed linking person %q to series %d: %w", name, serie_id, err)
+		}
+	}
+	return nil
+}
+
+func (sc *SerieRepoImpl) ShowMetadataItems(kind string, req MetadataShowRequest) (*MetadataItemsResponse, *responses.ErrorResponse) {
+	tables, ok := metadata_tables[kind]
+	if !ok {
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("metadata_show_failed", fmt.Errorf("invalid_metadata_kind"))
+	}
+
+	filters := []share.Filter{}
+	for _, f := range req.Filters {
+		if metadata_list_columns[f.Property] {
+			filters = append(filters, f)
+		}
+	}
+	sorts := []share.Sort{}
+	for _, s := range req.Sorts {
+		if metadata_list_columns[s.Property] {
+			sorts = append(sorts, s)
+		}
+	}
+	if len(sorts) == 0 {
+		sorts = append(sorts, share.Sort{Property: "t.name", Direction: "asc"})
+	}
+
+	sql_limit := postgres.BuildPaging(req.PageOptions.Page, req.PageOptions.Perpage)
+	sql_order_by := postgres.BuildSQLSort(sorts)
+	sql_filters, args_filters := postgres.BuildSQLFilter(filters)
+
+	where_clause := ""
+	if sql_filters != "" {
+		where_clause = "WHERE " + sql_filters
+	}
+
+	thumbnail := "NULL::text AS thumbnail"
+	if kind == MetadataPeople {
+		thumbnail = "t.thumbnail"
+	}
+
+	items := []MetadataItem{}
+	query := fmt.Sprintf(`
+		SELECT t.id, t.name, t.slug, %s,
+			(SELECT COUNT(DISTINCT l.series_id) FROM %s l WHERE l.%s = t.id) AS series_count
+		FROM %s t %s %s %s
+	`, thumbnail, tables.link_table, tables.link_column, tables.table, where_clause, sql_order_by, sql_limit)
+	if err := sc.DBPool.Select(&items, query, args_filters...); err != nil {
+		custom_log.NewCustomLog("metadata_show_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("metadata_show_failed", fmt.Errorf("database_error"))
+	}
+
+	var total int
+	count_query := fmt.Sprintf(`SELECT COUNT(*) FROM %s t %s`, tables.table, where_clause)
+	if err := sc.DBPool.Get(&total, count_query, args_filters...); err != nil {
+		custom_log.NewCustomLog("metadata_show_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("metadata_show_failed", fmt.Errorf("database_error"))
+	}
+
+	return &MetadataItemsResponse{
+		Kind:  kind,
+		Items: items,
+		Total: total,
+	}, nil
+}
+
+// UpdateMetadataItem renames a genre, tag or person everywhere it is linked
+func (sc *SerieRepoImpl) UpdateMetadataItem(kind string, id int, req MetadataItemRequest) *responses.ErrorResponse {
+	tables, ok := metadata_tables[kind]
+	if !ok {
+		return (&responses.ErrorResponse{}).NewErrorResponse("metadata_update_failed", fmt.Errorf("invalid_metadata_kind"))
+	}
+
+	name := strings.TrimSpace(req.Name)
+	slug := Slugify(name)
+	if slug == "" {
+		return (&responses.ErrorResponse{}).NewErrorResponse("metadata_update_failed", fmt.Errorf("invalid_metadata_name"))
+	}
+
+	var exists bool
+	err := sc.DBPool.Get(&exists, fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s WHERE slug = $1 AND id <> $2)`, tables.table), slug, id)
+	if err != nil {
+		custom_log.NewCustomLog("metadata_update_failed", err.Error(), "error")
+		return (&responses.ErrorResponse{}).NewErrorResponse("metadata_update_failed", fmt.Errorf("database_error"))
+	}
+	if exists {
+		return (&responses.ErrorResponse{}).NewErrorResponse("metadata_update_failed", fmt.Errorf("metadata_exists"))
+	}
+
+	set_thumbnail := ""
+	args := []interface{}{id, name, slug, sc.UserContext.Id}
+	if kind == MetadataPeople {
+		set_thumbnail = ", thumbnail = $5"
+		args = append(args, req.Thumbnail)
+	}
+
+	result, err := sc.DBPool.Exec(fmt.Sprintf(`
+		UPDATE %s SET name = $2, slug = $3, updated_at = NOW(), updated_by = $4%s
+		WHERE id = $1
+	`, tables.table, set_thumbnail), args...)
+	if err != nil {
+		custom_log.NewCustomLog("metadata_update_failed", err.Error(), "error")
+		return (&responses.ErrorResponse{}).NewErrorResponse("metadata_update_failed", fmt.Errorf("database_error"))
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return (&responses.ErrorResponse{}).NewErrorResponse("metadata_update_failed", fmt.Errorf("metadata_not_found"))
+	}
+	return nil
+}
+
+// DeleteMetadataItem removes a genre, tag or person along with its links
+func (sc *SerieRepoImpl) DeleteMetadataItem(kind string, id int) *responses.ErrorResponse {
+	tables, ok := metadata_tables[kind]
+	if !ok {
+		return (&responses.ErrorResponse{}).NewErrorResponse("metadata_delete_failed", fmt.Errorf("invalid_metadata_kind"))
+	}
+
+	result, err := sc.DBPool.Exec(fmt.Sprintf(`DELETE FROM %s WHERE id = $1`, tables.table), id)
+	if err != nil {
+		custom_log.NewCustomLog("metadata_delete_failed", err.Error(), "error")
+		return (&responses.ErrorResponse{}).NewErrorResponse("metadata_delete_failed", fmt.Errorf("database_error"))
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return (&responses.ErrorResponse{}).NewErrorResponse("metadata_delete_failed", fmt.Errorf("metadata_not_found"))
+	}
+	return nil
+}
+
+// columns admins may filter and sort the serie list by
+var serie_list_columns = map[string]bool{
+	"s.id":             true,
+	"s.title":          true,
+	"s.country":        true,
+	"s.status":         true,
+	"s.type":           true,
+	"s.episodes_count": true,
+	"s.provider":       true,
+	"s.created_at":     true,
+	"s.updated_at":     true,
+	"s.deleted_at":     true,
+}
+
+func (sc *SerieRepoImpl) Show(req SerieShowRequest) (*SeriesResponse, *responses.ErrorResponse) {
+	filters := []share.Filter{}
+	for _, f := range req.Filters {
+		if serie_list_columns[f.Property] {
+			filters = append(filters, f)
+		}
+	}
+	sorts := []share.Sort{}
+	for _, s := range req.Sorts {
+		if serie_list_columns[s.Property] {
+			sorts = append(sorts, s)
+		}
+	}
+	if len(sorts) == 0 {
+		sorts = append(sorts, share.Sort{Property: "s.id", Direction: "desc"})
+	}
+
+	sql_limit := postgres.BuildPaging(req.PageOptions.Page, req.PageOptions.Perpage)
+	sql_order_by := postgres.BuildSQLSort(sorts)
+	sql_filters, args_filters := postgres.BuildSQLFilter(filters)
+
+	where_clause := "WHERE s.deleted_at IS NULL"
+	if req.Trashed {
+		where_clause = "WHERE s.deleted_at IS NOT NULL"
+	}
+	if sql_filters != "" {
+		where_clause += " AND " + sql_filters
+	}
+
+	series := []SerieSummary{}
+	query := fmt.Sprintf(`
+		SELECT s.id, s.title, s.country, s.status, s.type, s.episodes_count, s.thumbnail, s.thumbnail_path,
+			s.provider, s.created_at, s.updated_at, s.deleted_at,
+			(SELECT COUNT(*) FROM tbl_episodes e WHERE e.series_id = s.id AND e.status_id = 1 AND e.deleted_at IS NULL) AS stored_count
+		FROM tbl_series s %s %s %s
+	`, where_clause, sql_order_by, sql_limit)
+	if err := sc.DBPool.Select(&series, query, args_filters...); err != nil {
+		custom_log.NewCustomLog("serie_show_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("serie_show_failed", fmt.Errorf("database_error"))
+	}
+
+	var total int
+	count_query := fmt.Sprintf(`SELECT COUNT(*) FROM tbl_series s %s`, where_clause)
+	if err := sc.DBPool.Get(&total, count_query, args_filters...); err != nil {
+		custom_log.NewCustomLog("serie_show_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("serie_show_failed", fmt.Errorf("database_error"))
+	}
+
+	return &SeriesResponse{
+		Series: series,
+		Total:  total,
+	}, nil
+}
+
+// ShowOne loads a serie with its episodes, subtitles, renditions and metadata, deleted series included
+func (sc *SerieRepoImpl) ShowOne(serie_id int) (*SeriesDeepDetailsResponse, *responses.ErrorResponse) {
+	var detail SerieDeepDetail
+	err := sc.DBPool.Get(&detail, `
+		SELECT id, external_id, title, COALESCE(description, '') AS description, release_date, COALESCE(trailer, '') AS trailer,
+			COALESCE(country, '') AS country, COALESCE(status, '') AS status, COALESCE(type, '') AS type,
+			COALESCE(next_ep_date_id, 0) AS next_ep_date_id, COALESCE(episodes_count, 0) AS episodes_count,
+			label, COALESCE(favorite_id, 0) AS favorite_id, COALESCE(thumbnail, '') AS thumbnail,
+			thumbnail_path, provider, deleted_at
+		FROM tbl_series
+		WHERE id = $1
+	`, serie_id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("serie_show_failed", fmt.Errorf("serie_not_found"))
+	}
+	if err != nil {
+		custom_log.NewCustomLog("serie_show_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("serie_show_failed", fmt.Errorf("database_error"))
+	}
+
+	detail.Episodes, err = sc.episodes(serie_id)
+	if err != nil {
+		custom_log.NewCustomLog("serie_show_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("serie_show_failed", fmt.Errorf("database_error"))
+	}
+	sc.signEpisodes(detail.Episodes)
+
+	metadata, err := sc.Metadata(serie_id)
+	if err != nil {
+		custom_log.NewCustomLog("serie_show_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("serie_show_failed", fmt.Errorf("database_error"))
+	}
+	detail.Genres, detail.Tags, detail.People = metadata.Genres, metadata.Tags, metadata.People
+
+	return &SeriesDeepDetailsResponse{
+		SeriesDeepDetails: []SerieDeepDetail{detail},
+	}, nil
+}
+
+// episodes loads the live episodes of a serie with their subtitles and renditions
+func (sc *SerieRepoImpl) episodes(serie_id int) ([]EpisodeDeep, error) {
+	episodes := []EpisodeDeep{}
+	err := sc.DBPool.Select(&episodes, `
+		SELECT id, external_id, series_id, number, COALESCE(sub, 0) AS sub, src, provider,
+			COALESCE(origin_src, '') AS origin_src, status_id, quality_upgraded_at
+		FROM tbl_episodes
+		WHERE series_id = $1 AND deleted_at IS NULL
+		ORDER BY number
+	`, serie_id)
+	if err != nil {
+		return nil, fmt.Errorf("failed selecting episodes of series %d: %w", serie_id, err)
+	}
+
+	ids := make([]int64, len(episodes))
+	index := make(map[int]int, len(episodes))
+	for i := range episodes {
+		ids[i] = int64(episodes[i].ID)
+		index[episodes[i].ID] = i
+		episodes[i].Subtitles = []Subtitle{}
+		episodes[i].Renditions = []Rendition{}
+	}
+	if len(ids) == 0 {
+		return episodes, nil
+	}
+
+	subtitles := []struct {
+		EpisodeID int `db:"episode_id"`
+		Subtitle
+	}{}
+	err = sc.DBPool.Select(&subtitles, `
+		SELECT id, episode_id, src, COALESCE(label, '') AS label, COALESCE(lang, '') AS lang,
+			COALESCE(is_default, FALSE) AS is_default, COALESCE("order", 1) AS "order"
+		FROM tbl_subtitles
+		WHERE episode_id = ANY($1) AND deleted_at IS NULL
+		ORDER BY episode_id, "order", id
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed selecting subtitles of series %d: %w", serie_id, err)
+	}
+	for _, sub := range subtitles {
+		i := index[sub.EpisodeID]
+		episodes[i].Subtitles = append(episodes[i].Subtitles, sub.Subtitle)
+	}
+
+	renditions := []Rendition{}
+	err = sc.DBPool.Select(&renditions, `
+		SELECT id, episode_id, bandwidth, average_bandwidth, width, height, codecs, frame_rate, src, origin_src
+		FROM tbl_episode_renditions
+		WHERE episode_id = ANY($1)
+		ORDER BY episode_id, height DESC, bandwidth DESC
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed selecting renditions of series %d: %w", serie_id, err)
+	}
+	for _, rendition := range renditions {
+		i := index[rendition.EpisodeID]
+		episodes[i].Renditions = append(episodes[i].Renditions, rendition)
+	}
+
+	return episodes, nil
+}
+
+// Add stores a serie entered by hand under req.ID as its external id and returns its internal id,
+// unlike Create it refuses ids that are already taken
+func (sc *SerieRepoImpl) Add(req NewSerieRequest) (int, *responses.ErrorResponse) {
+	tx, err := sc.DBPool.Beginx()
+	if err != nil {
+		custom_log.NewCustomLog("serie_create_failed", err.Error(), "error")
+		return 0, (&responses.ErrorResponse{}).NewErrorResponse("serie_create_failed", fmt.Errorf("technical_error"))
+	}
+	defer tx.Rollback()
+
+	var deleted_at *time.Time
+	err = tx.Get(&deleted_at, `SELECT deleted_at FROM tbl_series WHERE provider = $1 AND external_id = $2`, ManualProvider, req.ID)
+	switch {
+	case err == nil && deleted_at != nil:
+		return 0, (&responses.ErrorResponse{}).NewErrorResponse("serie_create_failed", fmt.Errorf("serie_deleted"))
+	case err == nil:
+		return 0, (&responses.ErrorResponse{}).NewErrorResponse("serie_create_failed", fmt.Errorf("serie_exists"))
+	case !errors.Is(err, sql.ErrNoRows):
+		custom_log.NewCustomLog("serie_create_failed", err.Error(), "error")
+		return 0, (&responses.ErrorResponse{}).NewErrorResponse("serie_create_failed", fmt.Errorf("database_error"))
+	}
+
+	var serie_id int
+	err = tx.Get(&serie_id, `
+		INSERT INTO tbl_series
+			(external_id, title, description, release_date, trailer, country, status, type,
+			episodes_count, label, favorite_id, thumbnail, provider, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		RETURNING id
+	`, req.ID, req.Title, req.Description, req.releaseDate(), req.Trailer, req.Country, req.Status, req.Type,
+		req.EpisodesCount, req.Label, req.FavoriteID, req.Thumbnail, ManualProvider, sc.UserContext.Id)
+	if err != nil {
+		custom_log.NewCustomLog("serie_create_failed", err.Error(), "error")
+		return 0, (&responses.ErrorResponse{}).NewErrorResponse("serie_create_failed", fmt.Errorf("database_error"))
+	}
+
+	if err := sc.recordChanges(tx, EntitySerie, serie_id, serie_id, "tbl_series", TrackedSerieFields, nil); err != nil {
+		custom_log.NewCustomLog("serie_create_failed", err.Error(), "error")
+		return 0, (&responses.ErrorResponse{}).NewErrorResponse("serie_create_failed", fmt.Errorf("database_error"))
+	}
+
+	// episodes entered here have no source yet, a scrape or a manual edit fills it in
+	for _, ep := range req.Episodes {
+		episode := EpisodeDeep{ID: ep.ID, Number: ep.Number, Sub: ep.Sub, Provider: ManualProvider}
+		if err := sc.InsertEpisode(tx, serie_id, episode); err != nil {
+			custom_log.NewCustomLog("serie_create_failed", err.Error(), "error")
+			return 0, (&responses.ErrorResponse{}).NewErrorResponse("serie_create_failed", fmt.Errorf("database_error"))
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		custom_log.NewCustomLog("serie_create_failed", err.Error(), "error")
+		return 0, (&responses.ErrorResponse{}).NewErrorResponse("serie_create_failed", fmt.Errorf("database_error"))
+	}
+	return serie_id, nil
+}
+
+// Update overwrites the editable fields of a live serie, the provider and airing schedule stay as scraped
+func (sc *SerieRepoImpl) Update(serie_id int, req NewSerieRequest) *responses.ErrorResponse {
+	tx, err := sc.DBPool.Beginx()
+	if err != nil {
+		custom_log.NewCustomLog("serie_update_failed", err.Error(), "error")
+		return (&responses.ErrorResponse{}).NewErrorResponse("serie_update_failed", fmt.Errorf("technical_error"))
+	}
+	defer tx.Rollback()
+
+	before, err := snapshot(tx, "tbl_series", TrackedSerieFields, serie_id)
+	if err != nil {
+		custom_log.NewCustomLog("serie_update_failed", err.Error(), "error")
+		return (&responses.ErrorResponse{}).NewErrorResponse("serie_update_failed", fmt.Errorf("database_error"))
+	}
+
+	res, err := tx.Exec(`
+		UPDATE tbl_series
+		SET title = $2, description = $3, release_date = $4, trailer = $5, country = $6, status = $7, type = $8,
+			episodes_count = $9, label = $10, favorite_id = $11, thumbnail = $12,
+			updated_at = NOW(), updated_by = $13
+		WHERE id = $1 AND deleted_at IS NULL
+	`, serie_id, req.Title, req.Description, req.releaseDate(), req.Trailer, req.Country, req.Status, req.Type,
+		req.EpisodesCount, req.Label, req.FavoriteID, req.Thumbnail, sc.UserContext.Id)
+	if err != nil {
+		custom_log.NewCustomLog("serie_update_failed", err.Error(), "error")
+		return (&responses.ErrorResponse{}).NewErrorResponse("serie_update_failed", fmt.Errorf("database_error"))
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return (&responses.ErrorResponse{}).NewErrorResponse("serie_update_failed", fmt.Errorf("serie_not_found"))
+	}
+
+	if err := sc.recordChanges(tx, EntitySerie, serie_id, serie_id, "tbl_series", TrackedSerieFields, before); err != nil {
+		custom_log.NewCustomLog("serie_update_failed", err.Error(), "error")
+		return (&responses.ErrorResponse{}).NewErrorResponse("serie_update_failed", fmt.Errorf("database_error"))
+	}
+
+	if err := tx.Commit(); err != nil {
+		custom_log.NewCustomLog("serie_update_failed", err.Error(), "error")
+		return (&responses.ErrorResponse{}).NewErrorResponse("serie_update_failed", fmt.Errorf("database_error"))
+	}
+	return nil
+}
+
+// Delete soft-deletes a serie, its episodes stay untouched so a restore brings everything back
+func (sc *SerieRepoImpl) Delete(serie_id int) *responses.ErrorResponse {
+	res, err := sc.DBPool.Exec(`
+		UPDATE tbl_series SET deleted_at = NOW(), deleted_by = $2
+		WHERE id = $1 AND deleted_at IS NULL
+	`, serie_id, sc.UserContext.Id)
+	if err != nil {
+		custom_log.NewCustomLog("serie_delete_failed", err.Error(), "error")
+		return (&responses.ErrorResponse{}).NewErrorResponse("serie_delete_failed", fmt.Errorf("database_error"))
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return (&responses.ErrorResponse{}).NewErrorResponse("serie_delete_failed", fmt.Errorf("serie_not_found"))
+	}
+	return nil
+}
+
+func (sc *SerieRepoImpl) Restore(serie_id int) *responses.ErrorResponse {
+	res, err := sc.DBPool.Exec(`
+		UPDATE tbl_series SET deleted_at = NULL, deleted_by = NULL, updated_at = NOW(), updated_by = $2
+		WHERE id = $1 AND deleted_at IS NOT NULL
+	`, serie_id, sc.UserContext.Id)
+	if err != nil {
+		custom_log.NewCustomLog("serie_restore_failed", err.Error(), "error")
+		return (&responses.ErrorResponse{}).NewErrorResponse("serie_restore_failed", fmt.Errorf("database_error"))
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return (&responses.ErrorResponse{}).NewErrorResponse("serie_restore_failed", fmt.Errorf("serie_not_deleted"))
+	}
+	return nil
+}
+
+// episode loads one live episode of a serie, nil when it does not exist
+func (sc *SerieRepoImpl) episode(serie_id int, episode_id int) (*EpisodeDeep, error) {
+	episodes, err := sc.episodes(serie_id)
+	if err != nil {
+		return nil, err
+	}
+	for i := range episodes {
+		if episodes[i].ID == episode_id {
+			return &episodes[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (sc *SerieRepoImpl) ShowEpisodes(serie_id int) (*EpisodesResponse, *responses.ErrorResponse) {
+	exists, err := sc.Exists(serie_id)
+	if err != nil {
+		custom_log.NewCustomLog("episode_show_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("episode_show_failed", fmt.Errorf("database_error"))
+	}
+	if !exists {
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("episode_show_failed", fmt.Errorf("serie_not_found"))
+	}
+
+	episodes, err := sc.episodes(serie_id)
+	if err != nil {
+		custom_log.NewCustomLog("episode_show_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("episode_show_failed", fmt.Errorf("database_error"))
+	}
+	sc.signEpisodes(episodes)
+	return &EpisodesResponse{
+		Episodes: episodes,
+	}, nil
+}
+
+// UpdateEpisode writes only the columns the request sets, the probe state of the episode stays as it was.
+// A new src drops the renditions and origin of the old one, the next probe checks it from scratch.
+func (sc *SerieRepoImpl) UpdateEpisode(serie_id int, episode_id int, req EpisodeRequest) (*EpisodesResponse, *responses.ErrorResponse) {
+	ep, err := sc.episode(serie_id, episode_id)
+	if err != nil {
+		custom_log.NewCustomLog("episode_update_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("episode_update_failed", fmt.Errorf("database_error"))
+	}
+	if ep == nil {
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("episode_update_failed", fmt.Errorf("episode_not_found"))
+	}
+
+	sets := []string{}
+	args := []interface{}{episode_id}
+	set := func(column string, value interface{}) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	if req.Number != nil {
+		set("number", *req.Number)
+	}
+	if req.Sub != nil {
+		set("sub", *req.Sub)
+	}
+	src_changed := false
+	if req.Source != nil && proxyurl.Strip(*req.Source) != proxyurl.Strip(ep.Source) {
+		src_changed = true
+		set("src", proxyurl.Strip(*req.Source))
+		sets = append(sets, "origin_src = NULL", "max_height = NULL")
+		// a new src gets its status derived again unless the request sets one
+		if req.StatusID == nil {
+			set("status_id", sourceStatus(*req.Source))
+		}
+	}
+	if req.StatusID != nil {
+		set("status_id", *req.StatusID)
+	}
+	if len(sets) == 0 {
+		return sc.showEpisode(serie_id, episode_id, "episode_update_failed")
+	}
+	set("updated_by", sc.UserContext.Id)
+	sets = append(sets, "updated_at = NOW()")
+
+	tx, err := sc.DBPool.Beginx()
+	if err != nil {
+		custom_log.NewCustomLog("episode_update_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("episode_update_failed", fmt.Errorf("technical_error"))
+	}
+	defer tx.Rollback()
+
+	before, err := snapshot(tx, "tbl_episodes", TrackedEpisodeFields, episode_id)
+	if err != nil {
+		custom_log.NewCustomLog("episode_update_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("episode_update_failed", fmt.Errorf("database_error"))
+	}
+
+	_, err = tx.Exec(`UPDATE tbl_episodes SET `+strings.Join(sets, ", ")+` WHERE id = $1 AND deleted_at IS NULL`, args...)
+	if err == nil && src_changed {
+		_, err = tx.Exec(`DELETE FROM tbl_episode_renditions WHERE episode_id = $1`, episode_id)
+	}
+	if err == nil {
+		err = sc.recordChanges(tx, EntityEpisode, episode_id, serie_id, "tbl_episodes", TrackedEpisodeFields, before)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		custom_log.NewCustomLog("episode_update_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("episode_update_failed", fmt.Errorf("database_error"))
+	}
+
+	return sc.showEpisode(serie_id, episode_id, "episode_update_failed")
+}
+
+// SaveSubtitles upserts tracks by language, replace soft-deletes the tracks not listed first
+func (sc *SerieRepoImpl) SaveSubtitles(serie_id int, episode_id int, subtitles []Subtitle, replace bool) (*EpisodesResponse, *responses.ErrorResponse) {
+	ep, err := sc.episode(serie_id, episode_id)
+	if err != nil {
+		custom_log.NewCustomLog("subtitle_save_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("subtitle_save_failed", fmt.Errorf("database_error"))
+	}
+	if ep == nil {
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("subtitle_save_failed", fmt.Errorf("episode_not_found"))
+	}
+
+	tx, err := sc.DBPool.Beginx()
+	if err != nil {
+		custom_log.NewCustomLog("subtitle_save_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("subtitle_save_failed", fmt.Errorf("technical_error"))
+	}
+	defer tx.Rollback()
+
+	if replace {
+		_, err := tx.Exec(`
+			UPDATE tbl_subtitles SET deleted_at = NOW(), deleted_by = $2
+			WHERE episode_id = $1 AND deleted_at IS NULL
+		`, episode_id, sc.UserContext.Id)
+		if err != nil {
+			custom_log.NewCustomLog("subtitle_save_failed", err.Error(), "error")
+			return nil, (&responses.ErrorResponse{}).NewErrorResponse("subtitle_save_failed", fmt.Errorf("database_error"))
+		}
+	}
+
+	// a new language goes after the existing tracks
+	last_order := 0
+	langs := map[string]bool{}
+	for _, sub := range ep.Subtitles {
+		last_order = max(last_order, sub.Order)
+		langs[sub.Lang] = true
+	}
+
+	for _, sub := range subtitles {
+		if !replace && sub.Order == 0 && !langs[sub.Lang] {
+			last_order++
+			sub.Order = last_order
+		}
+		// only one track can be picked by default
+		if sub.Default {
+			_, err := tx.Exec(`
+				UPDATE tbl_subtitles SET is_default = FALSE, updated_at = NOW(), updated_by = $3
+				WHERE episode_id = $1 AND lang <> $2 AND is_default
+			`, episode_id, sub.Lang, sc.UserContext.Id)
+			if err != nil {
+				custom_log.NewCustomLog("subtitle_save_failed", err.Error(), "error")
+				return nil, (&responses.ErrorResponse{}).NewErrorResponse("subtitle_save_failed", fmt.Errorf("database_error"))
+			}
+		}
+		if err := sc.InsertSubtitle(tx, episode_id, sub); err != nil {
+			custom_log.NewCustomLog("subtitle_save_failed", err.Error(), "error")
+			return nil, (&responses.ErrorResponse{}).NewErrorResponse("subtitle_save_failed", fmt.Errorf("database_error"))
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		custom_log.NewCustomLog("subtitle_save_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("subtitle_save_failed", fmt.Errorf("database_error"))
+	}
+
+	return sc.showEpisode(serie_id, episode_id, "subtitle_save_failed")
+}
+
+func (sc *SerieRepoImpl) DeleteSubtitle(serie_id int, episode_id int, subtitle_id int) *responses.ErrorResponse {
+	res, err := sc.DBPool.Exec(`
+		UPDATE tbl_subtitles st SET deleted_at = NOW(), deleted_by = $4
+		FROM tbl_episodes e
+		WHERE st.id = $3 AND st.episode_id = $2 AND st.deleted_at IS NULL
+			AND e.id = st.episode_id AND e.series_id = $1
+	`, serie_id, episode_id, subtitle_id, sc.UserContext.Id)
+	if err != nil {
+		custom_log.NewCustomLog("subtitle_delete_failed", err.Error(), "error")
+		return (&responses.ErrorResponse{}).NewErrorResponse("subtitle_delete_failed", fmt.Errorf("database_error"))
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return (&responses.ErrorResponse{}).NewErrorResponse("subtitle_delete_failed", fmt.Errorf("subtitle_not_found"))
+	}
+	return nil
+}
+
+// OrderSubtitles numbers the tracks of an episode in the given order, every live track has to be listed once
+func (sc *SerieRepoImpl) OrderSubtitles(serie_id int, episode_id int, ids []int) (*EpisodesResponse, *responses.ErrorResponse) {
+	ep, err := sc.episode(serie_id, episode_id)
+	if err != nil {
+		custom_log.NewCustomLog("subtitle_order_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("subtitle_order_failed", fmt.Errorf("database_error"))
+	}
+	if ep == nil {
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("subtitle_order_failed", fmt.Errorf("episode_not_found"))
+	}
+
+	listed := map[int]bool{}
+	for _, id := range ids {
+		listed[id] = true
+	}
+	if len(listed) != len(ids) || len(ids) != len(ep.Subtitles) {
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("subtitle_order_failed", fmt.Errorf("invalid_subtitle_order"))
+	}
+	for _, sub := range ep.Subtitles {
+		if !listed[sub.ID] {
+			return nil, (&responses.ErrorResponse{}).NewErrorResponse("subtitle_order_failed", fmt.Errorf("invalid_subtitle_order"))
+		}
+	}
+
+	tx, err := sc.DBPool.Beginx()
+	if err != nil {
+		custom_log.NewCustomLog("subtitle_order_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("subtitle_order_failed", fmt.Errorf("technical_error"))
+	}
+	defer tx.Rollback()
+
+	for i, id := range ids {
+		_, err := tx.Exec(`
+			UPDATE tbl_subtitles SET "order" = $2, updated_at = NOW(), updated_by = $3
+			WHERE id = $1
+		`, id, i+1, sc.UserContext.Id)
+		if err != nil {
+			custom_log.NewCustomLog("subtitle_order_failed", err.Error(), "error")
+			return nil, (&responses.ErrorResponse{}).NewErrorResponse("subtitle_order_failed", fmt.Errorf("database_error"))
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		custom_log.NewCustomLog("subtitle_order_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("subtitle_order_failed", fmt.Errorf("database_error"))
+	}
+
+	return sc.showEpisode(serie_id, episode_id, "subtitle_order_failed")
+}
+
+// showEpisode answers an episode edit with the stored result
+func (sc *SerieRepoImpl) showEpisode(serie_id int, episode_id int, message_id string) (*EpisodesResponse, *responses.ErrorResponse) {
+	ep, err := sc.episode(serie_id, episode_id)
+	if err != nil {
+		custom_log.NewCustomLog(message_id, err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse(message_id, fmt.Errorf("database_error"))
+	}
+	if ep == nil {
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse(message_id, fmt.Errorf("episode_not_found"))
+	}
+	episodes := []EpisodeDeep{*ep}
+	sc.signEpisodes(episodes)
+	return &EpisodesResponse{
+		Episodes: episodes,
+	}, nil
+}
+
+// signEpisodes gives the stored proxy urls a signature bound to the acting admin, like the catalog does for viewers
+func (sc *SerieRepoImpl) signEpisodes(episodes []EpisodeDeep) {
+	signer := proxyurl.Default()
+	for i := range episodes {
+		episodes[i].Source = signer.Resign(episodes[i].Source, sc.UserContext.UserUuid)
+		for j := range episodes[i].Subtitles {
+			episodes[i].Subtitles[j].Src = signer.Resign(episodes[i].Subtitles[j].Src, sc.UserContext.UserUuid)
+		}
+		for j := range episodes[i].Renditions {
+			episodes[i].Renditions[j].Src = signer.Resign(episodes[i].Renditions[j].Src, sc.UserContext.UserUuid)
+		}
+	}
+}
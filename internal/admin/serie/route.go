@@ -1,29 +1,50 @@
 package serie
 
 import (
+	"rerng_addicted_api/pkg/middlewares"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/jmoiron/sqlx"
 )
 
 type SerieRoute struct {
-	App             *fiber.App
-	DBPool          *sqlx.DB
+	App          *fiber.App
+	DBPool       *sqlx.DB
 	SerieHandler *SerieHandler
 }
 
 func NewRoute(app *fiber.App, db_pool *sqlx.DB) *SerieRoute {
 	return &SerieRoute{
-		App:             app,
-		DBPool:          db_pool,
+		App:          app,
+		DBPool:       db_pool,
 		SerieHandler: NewSerieHandler(db_pool),
 	}
 }
 
 func (r *SerieRoute) RegisterSerieRoute() *SerieRoute {
-	//group := r.App.Group("/api/v1/admin/serie")
+	series := r.App.Group("/api/v1/admin/series", middlewares.NewJwtMiddleware(r.DBPool))
+
+	series.Get("/", r.SerieHandler.Show)
+	series.Post("/", r.SerieHandler.Create)
+	series.Get("/:id", r.SerieHandler.ShowOne)
+	series.Put("/:id", r.SerieHandler.Update)
+	series.Delete("/:id", r.SerieHandler.Delete)
+	series.Post("/:id/restore", r.SerieHandler.Restore)
+	series.Get("/:id/episodes", r.SerieHandler.ShowEpisodes)
+	series.Put("/:id/episodes/:episode_id", r.SerieHandler.UpdateEpisode)
+	series.Post("/:id/episodes/:episode_id/subtitles", r.SerieHandler.AddSubtitle)
+	series.Put("/:id/episodes/:episode_id/subtitles", r.SerieHandler.ReplaceSubtitles)
+	series.Put("/:id/episodes/:episode_id/subtitles/order", r.SerieHandler.OrderSubtitles)
+	series.Delete("/:id/episodes/:episode_id/subtitles/:subtitle_id", r.SerieHandler.DeleteSubtitle)
+	series.Get("/:id/metadata", r.SerieHandler.ShowMetadata)
+	series.Put("/:id/metadata", r.SerieHandler.UpdateMetadata)
+
+	// genres, tags and people shared by every serie
+	metadata := r.App.Group("/api/v1/admin/metadata", middlewares.NewJwtMiddleware(r.DBPool))
 
-	// TODO: add your routes here
-	// group.Get("/search", middlewares.NewJwtMiddleware(r.DBPool), r.SerieHandler.Search)
+	metadata.Get("/:kind", r.SerieHandler.ShowMetadataItems)
+	metadata.Put("/:kind/:id", r.SerieHandler.UpdateMetadataItem)
+	metadata.Delete("/:kind/:id", r.SerieHandler.DeleteMetadataItem)
 
 	return r
 }
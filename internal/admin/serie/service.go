@@ -1,22 +1,215 @@
 package serie
 
 import (
+	"fmt"
+	custom_log "rerng_addicted_api/pkg/logs"
 	share "rerng_addicted_api/pkg/model"
+	"rerng_addicted_api/pkg/responses"
+
 	"github.com/jmoiron/sqlx"
 )
 
+type SerieServiceCreator interface {
+	Show(req SerieShowRequest) (*SeriesResponse, *responses.ErrorResponse)
+	ShowOne(serie_id int) (*SeriesDeepDetailsResponse, *responses.ErrorResponse)
+	Create(req NewSerieRequest) (*SeriesDeepDetailsResponse, *responses.ErrorResponse)
+	Update(serie_id int, req NewSerieRequest) (*SeriesDeepDetailsResponse, *responses.ErrorResponse)
+	Delete(serie_id int) *responses.ErrorResponse
+	Restore(serie_id int) (*SeriesDeepDetailsResponse, *responses.ErrorResponse)
+	ShowEpisodes(serie_id int) (*EpisodesResponse, *responses.ErrorResponse)
+	UpdateEpisode(serie_id int, episode_id int, req EpisodeRequest) (*EpisodesResponse, *responses.ErrorResponse)
+	AddSubtitle(serie_id int, episode_id int, req SubtitleRequest) (*EpisodesResponse, *responses.ErrorResponse)
+	ReplaceSubtitles(serie_id int, episode_id int, req SubtitlesRequest) (*EpisodesResponse, *responses.ErrorResponse)
+	DeleteSubtitle(serie_id int, episode_id int, subtitle_id int) *responses.ErrorResponse
+	OrderSubtitles(serie_id int, episode_id int, req SubtitleOrderRequest) (*EpisodesResponse, *responses.ErrorResponse)
+	ShowMetadata(serie_id int) (*SerieMetadataResponse, *responses.ErrorResponse)
+	UpdateMetadata(serie_id int, req SerieMetadataRequest) (*SerieMetadataResponse, *responses.ErrorResponse)
+	ShowMetadataItems(kind string, req MetadataShowRequest) (*MetadataItemsResponse, *responses.ErrorResponse)
+	UpdateMetadataItem(kind string, id int, req MetadataItemRequest) *responses.ErrorResponse
+	DeleteMetadataItem(kind string, id int) *responses.ErrorResponse
+}
+
 type SerieService struct {
-	DBPool       *sqlx.DB
-	SerieRepo *SerieRepoImpl
-	UserContext  *share.UserContext
+	DBPool      *sqlx.DB
+	SerieRepo   *SerieRepoImpl
+	UserContext *share.UserContext
 }
 
 func NewSerieService(db_pool *sqlx.DB, userCtx *share.UserContext) *SerieService {
 	return &SerieService{
-		DBPool: db_pool,
+		DBPool:      db_pool,
 		UserContext: userCtx,
-		SerieRepo: NewSerieRepoImpl(db_pool, userCtx),
+		SerieRepo:   NewSerieRepoImpl(db_pool, userCtx),
+	}
+}
+
+func (s *SerieService) Show(req SerieShowRequest) (*SeriesResponse, *responses.ErrorResponse) {
+	return s.SerieRepo.Show(req)
+}
+
+func (s *SerieService) ShowOne(serie_id int) (*SeriesDeepDetailsResponse, *responses.ErrorResponse) {
+	return s.SerieRepo.ShowOne(serie_id)
+}
+
+func (s *SerieService) Create(req NewSerieRequest) (*SeriesDeepDetailsResponse, *responses.ErrorResponse) {
+	serie_id, err := s.SerieRepo.Add(req)
+	if err != nil {
+		return nil, err
+	}
+	return s.SerieRepo.ShowOne(serie_id)
+}
+
+func (s *SerieService) Update(serie_id int, req NewSerieRequest) (*SeriesDeepDetailsResponse, *responses.ErrorResponse) {
+	if err := s.SerieRepo.Update(serie_id, req); err != nil {
+		return nil, err
+	}
+	return s.SerieRepo.ShowOne(serie_id)
+}
+
+func (s *SerieService) Delete(serie_id int) *responses.ErrorResponse {
+	return s.SerieRepo.Delete(serie_id)
+}
+
+func (s *SerieService) Restore(serie_id int) (*SeriesDeepDetailsResponse, *responses.ErrorResponse) {
+	if err := s.SerieRepo.Restore(serie_id); err != nil {
+		return nil, err
+	}
+	return s.SerieRepo.ShowOne(serie_id)
+}
+
+func (s *SerieService) ShowEpisodes(serie_id int) (*EpisodesResponse, *responses.ErrorResponse) {
+	return s.SerieRepo.ShowEpisodes(serie_id)
+}
+
+func (s *SerieService) UpdateEpisode(serie_id int, episode_id int, req EpisodeRequest) (*EpisodesResponse, *responses.ErrorResponse) {
+	return s.SerieRepo.UpdateEpisode(serie_id, episode_id, req)
+}
+
+func (s *SerieService) AddSubtitle(serie_id int, episode_id int, req SubtitleRequest) (*EpisodesResponse, *responses.ErrorResponse) {
+	subtitle := Subtitle{
+		Src:     req.Src,
+		Label:   req.Label,
+		Lang:    req.Lang,
+		Default: req.Default,
+	}
+	return s.SerieRepo.SaveSubtitles(serie_id, episode_id, []Subtitle{subtitle}, false)
+}
+
+func (s *SerieService) ReplaceSubtitles(serie_id int, episode_id int, req SubtitlesRequest) (*EpisodesResponse, *responses.ErrorResponse) {
+	langs := map[string]bool{}
+	subtitles := make([]Subtitle, len(req.Subtitles))
+	for i, sub := range req.Subtitles {
+		if langs[sub.Lang] {
+			return nil, (&responses.ErrorResponse{}).NewErrorResponse("subtitle_save_failed", fmt.Errorf("duplicate_subtitle_lang"))
+		}
+		langs[sub.Lang] = true
+
+		subtitles[i] = Subtitle{
+			Src:     sub.Src,
+			Label:   sub.Label,
+			Lang:    sub.Lang,
+			Default: sub.Default,
+			Order:   i + 1,
+		}
+	}
+	return s.SerieRepo.SaveSubtitles(serie_id, episode_id, subtitles, true)
+}
+
+func (s *SerieService) DeleteSubtitle(serie_id int, episode_id int, subtitle_id int) *responses.ErrorResponse {
+	return s.SerieRepo.DeleteSubtitle(serie_id, episode_id, subtitle_id)
+}
+
+func (s *SerieService) OrderSubtitles(serie_id int, episode_id int, req SubtitleOrderRequest) (*EpisodesResponse, *responses.ErrorResponse) {
+	return s.SerieRepo.OrderSubtitles(serie_id, episode_id, req.IDs)
+}
+
+func (s *SerieService) ShowMetadata(serie_id int) (*SerieMetadataResponse, *responses.ErrorResponse) {
+	if err := s.exists(serie_id, "serie_metadata_show_failed"); err != nil {
+		return nil, err
+	}
+
+	metadata, err := s.SerieRepo.Metadata(serie_id)
+	if err != nil {
+		custom_log.NewCustomLog("serie_metadata_show_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("serie_metadata_show_failed", fmt.Errorf("database_error"))
+	}
+
+	return &SerieMetadataResponse{
+		SerieMetadata: []SerieMetadata{*metadata},
+	}, nil
+}
+
+func (s *SerieService) UpdateMetadata(serie_id int, req SerieMetadataRequest) (*SerieMetadataResponse, *responses.ErrorResponse) {
+	if err := s.exists(serie_id, "serie_metadata_update_failed"); err != nil {
+		return nil, err
+	}
+
+	var genres []Genre
+	if req.Genres != nil {
+		genres = make([]Genre, len(req.Genres))
+		for i, name := range req.Genres {
+			genres[i] = Genre{Name: name}
+		}
+	}
+	var tags []Tag
+	if req.Tags != nil {
+		tags = make([]Tag, len(req.Tags))
+		for i, name := range req.Tags {
+			tags[i] = Tag{Name: name}
+		}
 	}
+	var people []Credit
+	if req.People != nil {
+		people = make([]Credit, len(req.People))
+		for i, person := range req.People {
+			people[i] = Credit{
+				Name:      person.Name,
+				Role:      person.Role,
+				Character: person.Character,
+				Thumbnail: person.Thumbnail,
+			}
+		}
+	}
+
+	tx, err := s.DBPool.Beginx()
+	if err != nil {
+		custom_log.NewCustomLog("serie_metadata_update_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("serie_metadata_update_failed", fmt.Errorf("technical_error"))
+	}
+	if err := s.SerieRepo.ReplaceMetadata(tx, serie_id, genres, tags, people, MetadataSourceManual); err != nil {
+		tx.Rollback()
+		custom_log.NewCustomLog("serie_metadata_update_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("serie_metadata_update_failed", fmt.Errorf("database_error"))
+	}
+	if err := tx.Commit(); err != nil {
+		custom_log.NewCustomLog("serie_metadata_update_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("serie_metadata_update_failed", fmt.Errorf("database_error"))
+	}
+
+	return s.ShowMetadata(serie_id)
+}
+
+func (s *SerieService) ShowMetadataItems(kind string, req MetadataShowRequest) (*MetadataItemsResponse, *responses.ErrorResponse) {
+	return s.SerieRepo.ShowMetadataItems(kind, req)
 }
 
-// TODO: add your service methods here
+func (s *SerieService) UpdateMetadataItem(kind string, id int, req MetadataItemRequest) *responses.ErrorResponse {
+	return s.SerieRepo.UpdateMetadataItem(kind, id, req)
+}
+
+func (s *SerieService) DeleteMetadataItem(kind string, id int) *responses.ErrorResponse {
+	return s.SerieRepo.DeleteMetadataItem(kind, id)
+}
+
+// exists reports serie_not_found under message_id when the serie is not stored
+func (s *SerieService) exists(serie_id int, message_id string) *responses.ErrorResponse {
+	found, err := s.SerieRepo.Exists(serie_id)
+	if err != nil {
+		custom_log.NewCustomLog(message_id, err.Error(), "error")
+		return (&responses.ErrorResponse{}).NewErrorResponse(message_id, fmt.Errorf("database_error"))
+	}
+	if !found {
+		return (&responses.ErrorResponse{}).NewErrorResponse(message_id, fmt.Errorf("serie_not_found"))
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package source
+
+import (
+	"fmt"
+	"net/http"
+	response "rerng_addicted_api/pkg/http/response"
+	custom_log "rerng_addicted_api/pkg/logs"
+	types "rerng_addicted_api/pkg/model"
+	"rerng_addicted_api/pkg/utils"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jmoiron/sqlx"
+)
+
+type SourceHandler struct {
+	DBPool        *sqlx.DB
+	SourceService func(c *fiber.Ctx) *SourceService
+}
+
+func NewSourceHandler(db_pool *sqlx.DB, monitor *Monitor) *SourceHandler {
+	return &SourceHandler{
+		DBPool: db_pool,
+		SourceService: func(c *fiber.Ctx) *SourceService {
+			var uCtx types.UserContext
+			// convert map to UserContext struct
+			uCtx, ok := c.Locals("UserContext").(types.UserContext)
+			if !ok {
+				custom_log.NewCustomLog("user_context_failed", "UserContext missing or invalid", "warn")
+				uCtx = types.UserContext{}
+			}
+
+			return NewSourceService(db_pool, &uCtx, monitor)
+		},
+	}
+}
+
+func (sh *SourceHandler) Show(c *fiber.Ctx) error {
+	var req SourceShowRequest
+
+	v := utils.NewValidator()
+	if err := req.bind(c, v); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("source_show_failed", nil, c),
+				-2200,
+				err,
+			),
+		)
+	}
+
+	resp, err := sh.SourceService(c).Show(req)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate(err.MessageID, nil, c),
+				-2200,
+				fmt.Errorf("%s", utils.Translate(err.Err.Error(), nil, c)),
+			),
+		)
+	}
+
+	return c.Status(http.StatusOK).JSON(
+		response.NewResponseWithPaging(
+			utils.Translate("source_show_success", nil, c),
+			2200,
+			resp,
+			req.PageOptions.Page,
+			req.PageOptions.Perpage,
+			resp.Total,
+		),
+	)
+}
+
+func (sh *SourceHandler) ShowOne(c *fiber.Ctx) error {
+	id, err_con := strconv.Atoi(c.Params("id"))
+	if err_con != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("source_show_failed", nil, c),
+				-2201,
+				fmt.Errorf("%s", utils.Translate("invalid_episode_id", nil, c)),
+			),
+		)
+	}
+
+	resp, err := sh.SourceService(c).ShowOne(id)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate(err.MessageID, nil, c),
+				-2201,
+				fmt.Errorf("%s", utils.Translate(err.Err.Error(), nil, c)),
+			),
+		)
+	}
+
+	return c.Status(http.StatusOK).JSON(
+		response.NewResponse(
+			utils.Translate("source_show_success", nil, c),
+			2201,
+			resp,
+		),
+	)
+}
+
+func (sh *SourceHandler) Check(c *fiber.Ctx) error {
+	resp, err := sh.SourceService(c).Check()
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(
+			response.NewResponseError(
+				utils.Translate(err.MessageID, nil, c),
+				-2202,
+				fmt.Errorf("%s", utils.Translate(err.Err.Error(), nil, c)),
+			),
+		)
+	}
+
+	return c.Status(http.StatusOK).JSON(
+		response.NewResponse(
+			utils.Translate("source_check_success", nil, c),
+			2202,
+			resp,
+		),
+	)
+}
+
+func (sh *SourceHandler) Resolve(c *fiber.Ctx) error {
+	id, err_con := strconv.Atoi(c.Params("id"))
+	if err_con != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("source_resolve_failed", nil, c),
+				-2203,
+				fmt.Errorf("%s", utils.Translate("invalid_episode_id", nil, c)),
+			),
+		)
+	}
+
+	resp, err := sh.SourceService(c).Resolve(id)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate(err.MessageID, nil, c),
+				-2203,
+				fmt.Errorf("%s", utils.Translate(err.Err.Error(), nil, c)),
+			),
+		)
+	}
+
+	return c.Status(http.StatusOK).JSON(
+		response.NewResponse(
+			utils.Translate("source_resolve_success", nil, c),
+			2203,
+			resp,
+		),
+	)
+}
+
+// Playable is the viewer endpoint, a stale source is re-resolved before it is returned
+func (sh *SourceHandler) Playable(c *fiber.Ctx) error {
+	id, err_con := strconv.Atoi(c.Params("id"))
+	if err_con != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("source_show_failed", nil, c),
+				-2204,
+				fmt.Errorf("%s", utils.Translate("invalid_episode_id", nil, c)),
+			),
+		)
+	}
+
+	resp, err := sh.SourceService(c).Playable(id)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate(err.MessageID, nil, c),
+				-2204,
+				fmt.Errorf("%s", utils.Translate(err.Err.Error(), nil, c)),
+			),
+		)
+	}
+
+	return c.Status(http.StatusOK).JSON(
+		response.NewResponse(
+			utils.Translate("source_show_success", nil, c),
+			2204,
+			resp,
+		),
+	)
+}
@@ -0,0 +1,83 @@
+package source
+
+import (
+	"fmt"
+	types "rerng_addicted_api/pkg/model"
+	"rerng_addicted_api/pkg/utils"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// EpisodeSource is the stored playback source of one episode and its last probe result
+type EpisodeSource struct {
+	ID       int `db:"id" json:"id"`
+	SeriesID int `db:"series_id" json:"series_id"`
+	// SeriesKey is the provider's id of the series, what a re-scrape looks it up by
+	SeriesKey      int        `db:"series_key" json:"series_key"`
+	Number         float64    `db:"number" json:"number"`
+	Provider       string     `db:"provider" json:"provider"`
+	Src            string     `db:"src" json:"src"`
+	OriginSrc      *string    `db:"origin_src" json:"origin_src"`
+	StatusID       int        `db:"status_id" json:"status_id"`
+	IsStale        bool       `db:"is_stale" json:"is_stale"`
+	CheckError     *string    `db:"check_error" json:"check_error"`
+	LastCheckedAt  *time.Time `db:"last_checked_at" json:"last_checked_at"`
+	LastResolvedAt *time.Time `db:"last_resolved_at" json:"last_resolved_at"`
+	// MaxHeight is the tallest stored rendition, QualityUpgradedAt when it last grew
+	MaxHeight         *int       `db:"max_height" json:"max_height"`
+	QualityUpgradedAt *time.Time `db:"quality_upgraded_at" json:"quality_upgraded_at"`
+}
+
+// Playable is false when the viewer would get a dead or missing source
+func (e *EpisodeSource) Playable() bool {
+	return e.StatusID == 1 && !e.IsStale
+}
+
+type EpisodeSourcesResponse struct {
+	EpisodeSources []EpisodeSource `json:"episode_sources"`
+	Total          int             `json:"-"`
+}
+
+// ProbeResult is the outcome of one upstream probe, Stale is only set on a definitive answer
+type ProbeResult struct {
+	Stale  bool
+	Status int
+	Error  string
+}
+
+type CheckResponse struct {
+	Checked int `json:"checked"`
+	Healthy int `json:"healthy"`
+	Stale   int `json:"stale"`
+	// Errored counts probes that failed without a definitive answer, those sources stay playable
+	Errored int `json:"errored"`
+}
+
+type SourceShowRequest struct {
+	PageOptions types.Paging   `json:"paging_options" query:"paging_options" validate:"required"`
+	Sorts       []types.Sort   `json:"sorts,omitempty" query:"sorts"`
+	Filters     []types.Filter `json:"filters,omitempty" query:"filters"`
+}
+
+func (r *SourceShowRequest) bind(c *fiber.Ctx, v *utils.Validator) error {
+	if err := c.QueryParser(r); err != nil {
+		return err
+	}
+
+	// fix `Filter.Value` nil when http query params failed parse to json type `interface{}`
+	for i := range r.Filters {
+		value := c.Query(fmt.Sprintf("filters[%d][value]", i))
+		if int_value, err := strconv.Atoi(value); err == nil {
+			r.Filters[i].Value = int_value
+		} else {
+			r.Filters[i].Value = value
+		}
+	}
+
+	if err := v.Validate(r, c); err != nil {
+		return err
+	}
+	return nil
+}
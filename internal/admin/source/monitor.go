@@ -0,0 +1,175 @@
+package source
+
+import (
+	"fmt"
+	"rerng_addicted_api/configs"
+	"rerng_addicted_api/internal/admin/history"
+	"rerng_addicted_api/internal/admin/scraping"
+	custom_log "rerng_addicted_api/pkg/logs"
+	types "rerng_addicted_api/pkg/model"
+	"rerng_addicted_api/pkg/responses"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Monitor probes stored episode sources in the background and re-resolves stale ones on demand.
+// Probing only flags sources, the browser flow runs when somebody actually needs the episode.
+type Monitor struct {
+	DBPool    *sqlx.DB
+	Providers *scraping.Providers
+	Config    *configs.SourceConfig
+	Prober    *Prober
+
+	// episodes being re-resolved right now, concurrent viewers wait on the same call
+	resolving_mu sync.Mutex
+	resolving    map[int]*resolveCall
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+type resolveCall struct {
+	done chan struct{}
+	err  *responses.ErrorResponse
+}
+
+func NewMonitor(db_pool *sqlx.DB, providers *scraping.Providers, config *configs.SourceConfig) *Monitor {
+	return &Monitor{
+		DBPool:    db_pool,
+		Providers: providers,
+		Config:    config,
+		Prober:    NewProber(config.ProbeTimeout),
+		resolving: map[int]*resolveCall{},
+		stop:      make(chan struct{}),
+	}
+}
+
+func (m *Monitor) Start() *Monitor {
+	if !m.Config.Enabled {
+		fmt.Println("⏸️  Source health checks disabled")
+		return m
+	}
+
+	m.wg.Add(1)
+	go m.loop()
+	return m
+}
+
+func (m *Monitor) Stop() {
+	m.once.Do(func() {
+		close(m.stop)
+	})
+	m.wg.Wait()
+}
+
+func (m *Monitor) loop() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.Config.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		// keep draining while full batches come back, the backlog after a long downtime can be large
+		for {
+			resp, err := m.Check()
+			if err != nil {
+				custom_log.NewCustomLog("source_check_failed", err.Error(), "error")
+				break
+			}
+			if resp.Checked > 0 {
+				fmt.Printf("🩺 Checked %d sources: %d healthy, %d stale, %d errored\n", resp.Checked, resp.Healthy, resp.Stale, resp.Errored)
+			}
+			if resp.Checked < m.Config.BatchSize {
+				break
+			}
+
+			select {
+			case <-m.stop:
+				return
+			default:
+			}
+		}
+
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check probes one batch of sources that are due and records the results
+func (m *Monitor) Check() (*CheckResponse, error) {
+	repo := NewSourceRepoImpl(m.DBPool, &types.UserContext{})
+
+	sources, err := repo.ClaimDue(m.Config.RecheckAfter, m.Config.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &CheckResponse{Checked: len(sources)}
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		limit = make(chan struct{}, m.Config.Concurrency)
+	)
+	for _, source := range sources {
+		wg.Add(1)
+		go func(source EpisodeSource) {
+			defer wg.Done()
+			limit <- struct{}{}
+			defer func() { <-limit }()
+
+			result := m.Prober.Probe(originOf(source))
+			if err := repo.RecordProbe(source.ID, result); err != nil {
+				custom_log.NewCustomLog("source_check_failed", err.Error(), "error")
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case result.Stale:
+				resp.Stale++
+			case result.Error != "":
+				resp.Errored++
+			default:
+				resp.Healthy++
+			}
+		}(source)
+	}
+	wg.Wait()
+
+	return resp, nil
+}
+
+// Resolve runs the provider's episode flow for a stored episode, callers asking for the same
+// episode while it runs share the result instead of opening another browser page
+func (m *Monitor) Resolve(user_context *types.UserContext, source EpisodeSource) *responses.ErrorResponse {
+	m.resolving_mu.Lock()
+	if call, ok := m.resolving[source.ID]; ok {
+		m.resolving_mu.Unlock()
+		<-call.done
+		return call.err
+	}
+	call := &resolveCall{done: make(chan struct{})}
+	m.resolving[source.ID] = call
+	m.resolving_mu.Unlock()
+
+	defer func() {
+		m.resolving_mu.Lock()
+		delete(m.resolving, source.ID)
+		m.resolving_mu.Unlock()
+		close(call.done)
+	}()
+
+	fmt.Printf("♻️  Re-resolving episode %g of series %d\n", source.Number, source.SeriesKey)
+	scraping_service := scraping.NewScrapingService(m.DBPool, user_context, m.Providers, nil)
+	scraping_service.Trigger = history.TriggerSource
+	if _, err := scraping_service.GetEpisodes(source.Provider, source.SeriesKey, source.Number); err != nil {
+		call.err = err
+	}
+	return call.err
+}
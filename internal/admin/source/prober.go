@@ -0,0 +1,114 @@
+package source
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"rerng_addicted_api/pkg/proxyurl"
+	"strings"
+	"time"
+)
+
+// how much of a playlist is read to confirm it is still a playlist
+const probe_read_limit = 2048
+
+// Prober checks whether an upstream media url still serves content
+type Prober struct {
+	Client *http.Client
+}
+
+func NewProber(timeout time.Duration) *Prober {
+	return &Prober{
+		Client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Probe reads the head of a playlist, or sends a HEAD for other media and falls back to a ranged GET
+// when the origin refuses HEAD. Network errors and 5xx are reported without marking the source stale.
+func (p *Prober) Probe(target string) ProbeResult {
+	is_playlist := strings.Contains(target, ".m3u8")
+
+	if !is_playlist {
+		result, refused := p.do(http.MethodHead, target, false)
+		if !refused {
+			return result
+		}
+	}
+
+	result, _ := p.do(http.MethodGet, target, is_playlist)
+	return result
+}
+
+// do sends one probe request, refused is true when the origin rejected the method rather than the url
+func (p *Prober) do(method string, target string, is_playlist bool) (ProbeResult, bool) {
+	req, err := http.NewRequest(method, target, nil)
+	if err != nil {
+		return ProbeResult{Stale: true, Error: fmt.Sprintf("invalid source url: %v", err)}, false
+	}
+	// same headers the proxy sends, some CDNs only answer their own site
+	if parsed, err := url.Parse(target); err == nil && parsed.Host != "" {
+		req.Header.Set("Referer", "https://"+parsed.Host)
+		req.Header.Set("Origin", "https://"+parsed.Host)
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0")
+	req.Header.Set("Accept", "*/*")
+	if method == http.MethodGet {
+		req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", probe_read_limit-1))
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return ProbeResult{Error: err.Error()}, false
+	}
+	defer resp.Body.Close()
+
+	result := ProbeResult{Status: resp.StatusCode}
+	switch {
+	case method == http.MethodHead && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented):
+		return result, true
+	case resp.StatusCode >= 500:
+		result.Error = fmt.Sprintf("upstream returned %d", resp.StatusCode)
+		return result, false
+	case resp.StatusCode >= 400:
+		result.Stale = true
+		result.Error = fmt.Sprintf("upstream returned %d", resp.StatusCode)
+		return result, false
+	}
+
+	if is_playlist {
+		head, err := io.ReadAll(io.LimitReader(resp.Body, probe_read_limit))
+		if err != nil {
+			result.Error = err.Error()
+			return result, false
+		}
+		// expired signed urls often answer 200 with an html error page
+		if !bytes.HasPrefix(bytes.TrimSpace(bytes.TrimPrefix(head, []byte("\xef\xbb\xbf"))), []byte("#EXTM3U")) {
+			result.Stale = true
+			result.Error = "response is not an m3u8 playlist"
+		}
+	}
+	return result, false
+}
+
+// originOf returns the upstream url to probe, rows stored before origin_src existed are derived from the proxied src
+func originOf(source EpisodeSource) string {
+	if source.OriginSrc != nil && *source.OriginSrc != "" {
+		return *source.OriginSrc
+	}
+
+	if i := strings.Index(source.Src, "/m3u8/"); i >= 0 {
+		path, query, _ := strings.Cut(source.Src[i+len("/m3u8/"):], "?")
+		if query = proxyurl.UpstreamQuery(query); query != "" {
+			return "https://" + path + "?" + query
+		}
+		return "https://" + path
+	}
+	if parsed, err := url.Parse(source.Src); err == nil && strings.HasSuffix(parsed.Path, "/mp4") {
+		if target := parsed.Query().Get("url"); target != "" {
+			return target
+		}
+	}
+	return source.Src
+}
@@ -0,0 +1,121 @@
+package source
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"rerng_addicted_api/pkg/proxyurl"
+	"testing"
+	"time"
+)
+
+func TestProbe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/live.m3u8":
+			w.Write([]byte("#EXTM3U\n#EXT-X-VERSION:3\n"))
+		case "/bom.m3u8":
+			w.Write([]byte("\xef\xbb\xbf\n#EXTM3U\n"))
+		case "/expired.m3u8":
+			w.Write([]byte("<html><body>token expired</body></html>"))
+		case "/video.mp4":
+			w.WriteHeader(http.StatusOK)
+		case "/no-head.mp4":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			w.WriteHeader(http.StatusPartialContent)
+		case "/down.m3u8", "/down.mp4":
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	prober := NewProber(5 * time.Second)
+
+	tests := []struct {
+		name   string
+		target string
+		stale  bool
+		status int
+		errors bool
+	}{
+		{"live playlist", server.URL + "/live.m3u8", false, http.StatusOK, false},
+		{"playlist with a byte order mark", server.URL + "/bom.m3u8", false, http.StatusOK, false},
+		{"playlist answered with an html page", server.URL + "/expired.m3u8", true, http.StatusOK, true},
+		{"playlist gone", server.URL + "/gone.m3u8", true, http.StatusNotFound, true},
+		{"playlist origin down", server.URL + "/down.m3u8", false, http.StatusBadGateway, true},
+		{"video", server.URL + "/video.mp4", false, http.StatusOK, false},
+		{"video origin refusing head", server.URL + "/no-head.mp4", false, http.StatusPartialContent, false},
+		{"video gone", server.URL + "/gone.mp4", true, http.StatusNotFound, true},
+		{"video origin down", server.URL + "/down.mp4", false, http.StatusBadGateway, true},
+		{"origin unreachable", closed.URL + "/live.m3u8", false, 0, true},
+		{"invalid url", "http://[::1/live.m3u8", true, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := prober.Probe(tt.target)
+			if result.Stale != tt.stale || result.Status != tt.status || (result.Error != "") != tt.errors {
+				t.Errorf("Probe(%s) = %+v, want stale %v, status %d, error %v", tt.target, result, tt.stale, tt.status, tt.errors)
+			}
+		})
+	}
+}
+
+func TestOriginOf(t *testing.T) {
+	origin := "https://cdn.example.org/show/index.m3u8"
+	empty := ""
+	base := "http://api.example.org:8585" + proxyurl.Mount
+
+	tests := []struct {
+		name   string
+		source EpisodeSource
+		want   string
+	}{
+		{"stored origin", EpisodeSource{Src: base + "/m3u8/other.example.org/a.m3u8", OriginSrc: &origin}, origin},
+		{"empty stored origin", EpisodeSource{Src: "https://cdn.example.org/a.m3u8", OriginSrc: &empty}, "https://cdn.example.org/a.m3u8"},
+		{"proxied playlist", EpisodeSource{Src: base + "/m3u8/cdn.example.org/show/index.m3u8"}, origin},
+		{
+			name:   "proxied playlist keeps only the upstream query",
+			source: EpisodeSource{Src: base + "/m3u8/cdn.example.org/show/index.m3u8?token=1&" + proxyurl.ParamExpires + "=9&" + proxyurl.ParamProvider + "=kisskh&" + proxyurl.ParamSignature + "=s"},
+			want:   origin + "?token=1",
+		},
+		{"proxied mp4", EpisodeSource{Src: base + "/mp4?url=https%3A%2F%2Fkisskh.co%2FDrama%2FShow%2FEpisode-1"}, "https://kisskh.co/Drama/Show/Episode-1"},
+		{"proxied mp4 without a url", EpisodeSource{Src: base + "/mp4"}, base + "/mp4"},
+		{"upstream src", EpisodeSource{Src: "https://cdn.example.org/video.mp4"}, "https://cdn.example.org/video.mp4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := originOf(tt.source); got != tt.want {
+				t.Errorf("originOf(%q) = %q, want %q", tt.source.Src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlayable(t *testing.T) {
+	tests := []struct {
+		status_id int
+		is_stale  bool
+		want      bool
+	}{
+		{1, false, true},
+		{1, true, false},
+		{2, false, false},
+		{0, false, false},
+	}
+
+	for _, tt := range tests {
+		source := EpisodeSource{StatusID: tt.status_id, IsStale: tt.is_stale}
+		if got := source.Playable(); got != tt.want {
+			t.Errorf("Playable with status %d and stale %v = %v, want %v", tt.status_id, tt.is_stale, got, tt.want)
+		}
+	}
+}
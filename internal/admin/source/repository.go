@@ -0,0 +1,153 @@
+package source
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	custom_log "rerng_addicted_api/pkg/logs"
+	types "rerng_addicted_api/pkg/model"
+	postgres "rerng_addicted_api/pkg/postgres"
+	"rerng_addicted_api/pkg/responses"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const source_columns = `
+	e.id, e.series_id, (SELECT s.external_id FROM tbl_series s WHERE s.id = e.series_id) AS series_key,
+	e.number, e.provider, e.src, e.origin_src, e.status_id,
+	e.is_stale, e.check_error, e.last_checked_at, e.last_resolved_at, e.max_height, e.quality_upgraded_at`
+
+// columns admins may filter and sort the source list by
+var source_list_columns = map[string]bool{
+	"e.id":               true,
+	"e.series_id":        true,
+	"e.provider":         true,
+	"e.status_id":        true,
+	"e.is_stale":         true,
+	"e.last_checked_at":  true,
+	"e.last_resolved_at": true,
+	"e.max_height":       true,
+	// episodes whose upstream started offering a better quality
+	"e.quality_upgraded_at": true,
+}
+
+type SourceRepo interface {
+	Show(req SourceShowRequest) (*EpisodeSourcesResponse, *responses.ErrorResponse)
+	ShowOne(id int) (*EpisodeSourcesResponse, *responses.ErrorResponse)
+}
+
+type SourceRepoImpl struct {
+	DBPool      *sqlx.DB
+	UserContext *types.UserContext
+}
+
+func NewSourceRepoImpl(db_pool *sqlx.DB, user_context *types.UserContext) *SourceRepoImpl {
+	return &SourceRepoImpl{
+		DBPool:      db_pool,
+		UserContext: user_context,
+	}
+}
+
+func (s *SourceRepoImpl) Show(req SourceShowRequest) (*EpisodeSourcesResponse, *responses.ErrorResponse) {
+	filters := []types.Filter{}
+	for _, f := range req.Filters {
+		if source_list_columns[f.Property] {
+			filters = append(filters, f)
+		}
+	}
+	sorts := []types.Sort{}
+	for _, s := range req.Sorts {
+		if source_list_columns[s.Property] {
+			sorts = append(sorts, s)
+		}
+	}
+	if len(sorts) == 0 {
+		sorts = append(sorts, types.Sort{Property: "e.last_checked_at", Direction: "desc"})
+	}
+
+	sql_limit := postgres.BuildPaging(req.PageOptions.Page, req.PageOptions.Perpage)
+	sql_order_by := postgres.BuildSQLSort(sorts)
+	sql_filters, args_filters := postgres.BuildSQLFilter(filters)
+
+	where_clause := "WHERE e.deleted_at IS NULL"
+	if sql_filters != "" {
+		where_clause += " AND " + sql_filters
+	}
+
+	sources := []EpisodeSource{}
+	query := fmt.Sprintf(`SELECT %s FROM tbl_episodes e %s %s %s`, source_columns, where_clause, sql_order_by, sql_limit)
+	if err := s.DBPool.Select(&sources, query, args_filters...); err != nil {
+		custom_log.NewCustomLog("source_show_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("source_show_failed", fmt.Errorf("database_error"))
+	}
+
+	var total int
+	count_query := fmt.Sprintf(`SELECT COUNT(*) FROM tbl_episodes e %s`, where_clause)
+	if err := s.DBPool.Get(&total, count_query, args_filters...); err != nil {
+		custom_log.NewCustomLog("source_show_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("source_show_failed", fmt.Errorf("database_error"))
+	}
+
+	return &EpisodeSourcesResponse{
+		EpisodeSources: sources,
+		Total:          total,
+	}, nil
+}
+
+func (s *SourceRepoImpl) ShowOne(id int) (*EpisodeSourcesResponse, *responses.ErrorResponse) {
+	var source EpisodeSource
+	err := s.DBPool.Get(&source, fmt.Sprintf(`SELECT %s FROM tbl_episodes e WHERE e.id = $1 AND e.deleted_at IS NULL`, source_columns), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("source_show_failed", fmt.Errorf("source_not_found"))
+	}
+	if err != nil {
+		custom_log.NewCustomLog("source_show_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("source_show_failed", fmt.Errorf("database_error"))
+	}
+
+	return &EpisodeSourcesResponse{
+		EpisodeSources: []EpisodeSource{source},
+	}, nil
+}
+
+// ClaimDue stamps up to limit playable sources whose last probe is older than recheck_after and returns them,
+// stamping first keeps two processes from probing the same rows
+func (s *SourceRepoImpl) ClaimDue(recheck_after time.Duration, limit int) ([]EpisodeSource, error) {
+	sources := []EpisodeSource{}
+	err := s.DBPool.Select(&sources, fmt.Sprintf(`
+		UPDATE tbl_episodes e
+		SET last_checked_at = NOW()
+		WHERE e.id IN (
+			SELECT id FROM tbl_episodes
+			WHERE status_id = 1 AND NOT is_stale AND deleted_at IS NULL
+				AND (last_checked_at IS NULL OR last_checked_at < NOW() - make_interval(secs => $1))
+			ORDER BY last_checked_at NULLS FIRST, id
+			FOR UPDATE SKIP LOCKED
+			LIMIT $2
+		)
+		RETURNING %s
+	`, source_columns), recheck_after.Seconds(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed claiming sources to check: %w", err)
+	}
+	return sources, nil
+}
+
+// RecordProbe stores the probe outcome, only a definitive failure marks the source stale
+func (s *SourceRepoImpl) RecordProbe(id int, result ProbeResult) error {
+	var err_value *string
+	if result.Error != "" {
+		err_value = &result.Error
+	}
+
+	_, err := s.DBPool.Exec(`
+		UPDATE tbl_episodes
+		SET is_stale = $2, check_error = $3, last_checked_at = NOW()
+		WHERE id = $1
+	`, id, result.Stale, err_value)
+	if err != nil {
+		return fmt.Errorf("failed recording probe of episode %d: %w", id, err)
+	}
+	return nil
+}
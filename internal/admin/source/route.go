@@ -0,0 +1,39 @@
+package source
+
+import (
+	"rerng_addicted_api/pkg/middlewares"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jmoiron/sqlx"
+)
+
+type SourceRoute struct {
+	App           *fiber.App
+	DBPool        *sqlx.DB
+	Monitor       *Monitor
+	SourceHandler *SourceHandler
+}
+
+func NewRoute(app *fiber.App, db_pool *sqlx.DB, monitor *Monitor) *SourceRoute {
+	return &SourceRoute{
+		App:           app,
+		DBPool:        db_pool,
+		Monitor:       monitor,
+		SourceHandler: NewSourceHandler(db_pool, monitor),
+	}
+}
+
+func (sr *SourceRoute) RegisterSourceRoute() *SourceRoute {
+	sources := sr.App.Group("/api/v1/admin/sources", middlewares.NewJwtMiddleware(sr.DBPool))
+
+	sources.Post("/check", sr.SourceHandler.Check)
+	sources.Get("/", sr.SourceHandler.Show)
+	sources.Get("/:id", sr.SourceHandler.ShowOne)
+	sources.Post("/:id/resolve", sr.SourceHandler.Resolve)
+
+	// viewers ask for a playable source before starting an episode
+	episodes := sr.App.Group("/api/v1/episodes")
+	episodes.Get("/:id/source", sr.SourceHandler.Playable)
+
+	return sr
+}
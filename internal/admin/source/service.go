@@ -0,0 +1,88 @@
+package source
+
+import (
+	"fmt"
+	custom_log "rerng_addicted_api/pkg/logs"
+	types "rerng_addicted_api/pkg/model"
+	"rerng_addicted_api/pkg/proxyurl"
+	"rerng_addicted_api/pkg/responses"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type SourceServiceCreator interface {
+	Show(req SourceShowRequest) (*EpisodeSourcesResponse, *responses.ErrorResponse)
+	ShowOne(id int) (*EpisodeSourcesResponse, *responses.ErrorResponse)
+	Check() (*CheckResponse, *responses.ErrorResponse)
+	Resolve(id int) (*EpisodeSourcesResponse, *responses.ErrorResponse)
+	Playable(id int) (*EpisodeSourcesResponse, *responses.ErrorResponse)
+}
+
+type SourceService struct {
+	DBPool      *sqlx.DB
+	UserContext *types.UserContext
+	Monitor     *Monitor
+}
+
+func NewSourceService(db_pool *sqlx.DB, user_context *types.UserContext, monitor *Monitor) *SourceService {
+	return &SourceService{
+		DBPool:      db_pool,
+		UserContext: user_context,
+		Monitor:     monitor,
+	}
+}
+
+func (s *SourceService) Show(req SourceShowRequest) (*EpisodeSourcesResponse, *responses.ErrorResponse) {
+	return NewSourceRepoImpl(s.DBPool, s.UserContext).Show(req)
+}
+
+func (s *SourceService) ShowOne(id int) (*EpisodeSourcesResponse, *responses.ErrorResponse) {
+	return NewSourceRepoImpl(s.DBPool, s.UserContext).ShowOne(id)
+}
+
+// Check probes the next batch of due sources right away instead of waiting for the monitor tick
+func (s *SourceService) Check() (*CheckResponse, *responses.ErrorResponse) {
+	resp, err := s.Monitor.Check()
+	if err != nil {
+		custom_log.NewCustomLog("source_check_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("source_check_failed", fmt.Errorf("database_error"))
+	}
+	return resp, nil
+}
+
+// Resolve re-runs the browser flow for an episode whether or not its source is stale
+func (s *SourceService) Resolve(id int) (*EpisodeSourcesResponse, *responses.ErrorResponse) {
+	repo := NewSourceRepoImpl(s.DBPool, s.UserContext)
+	resp, err := repo.ShowOne(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.Monitor.Resolve(s.UserContext, resp.EpisodeSources[0]); err != nil {
+		return nil, err
+	}
+	return repo.ShowOne(id)
+}
+
+// Playable returns the stored source for a viewer, re-resolving it first when it is stale or was never resolved
+func (s *SourceService) Playable(id int) (*EpisodeSourcesResponse, *responses.ErrorResponse) {
+	repo := NewSourceRepoImpl(s.DBPool, s.UserContext)
+	resp, err := repo.ShowOne(id)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.EpisodeSources[0].Playable() {
+		if err := s.Monitor.Resolve(s.UserContext, resp.EpisodeSources[0]); err != nil {
+			return nil, err
+		}
+		if resp, err = repo.ShowOne(id); err != nil {
+			return nil, err
+		}
+	}
+
+	// sources are stored unsigned, the viewer gets a fresh signature
+	for i := range resp.EpisodeSources {
+		resp.EpisodeSources[i].Src = proxyurl.Default().Resign(resp.EpisodeSources[i].Src, s.UserContext.UserUuid)
+	}
+	return resp, nil
+}
@@ -0,0 +1,233 @@
+package serie
+
+import (
+	"fmt"
+	"net/http"
+	"rerng_addicted_api/configs"
+	response "rerng_addicted_api/pkg/http/response"
+	custom_log "rerng_addicted_api/pkg/logs"
+	types "rerng_addicted_api/pkg/model"
+	"rerng_addicted_api/pkg/utils"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jmoiron/sqlx"
+)
+
+type SerieHandler struct {
+	DBPool       *sqlx.DB
+	SerieService func(c *fiber.Ctx) *SerieService
+}
+
+func NewSerieHandler(db_pool *sqlx.DB, popular *PopularQueries, config *configs.CatalogConfig) *SerieHandler {
+	return &SerieHandler{
+		DBPool: db_pool,
+		SerieService: func(c *fiber.Ctx) *SerieService {
+			var uCtx types.UserContext
+			// the catalog is public, a missing UserContext is expected
+			uCtx, ok := c.Locals("UserContext").(types.UserContext)
+			if !ok {
+				uCtx = types.UserContext{}
+			}
+
+			service := NewSerieService(db_pool, &uCtx)
+			service.Popular = popular
+			service.Config = config
+			return service
+		},
+	}
+}
+
+func (sh *SerieHandler) Show(c *fiber.Ctx) error {
+	var req SerieShowRequest
+
+	v := utils.NewValidator()
+	if err := req.bind(c, v); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("catalog_show_failed", nil, c),
+				-3000,
+				err,
+			),
+		)
+	}
+
+	resp, err := sh.SerieService(c).Show(req)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate(err.MessageID, nil, c),
+				-3000,
+				fmt.Errorf("%s", utils.Translate(err.Err.Error(), nil, c)),
+			),
+		)
+	}
+
+	return c.Status(http.StatusOK).JSON(
+		response.NewResponseWithPaging(
+			utils.Translate("catalog_show_success", nil, c),
+			3000,
+			resp,
+			req.PageOptions.Page,
+			req.PageOptions.Perpage,
+			resp.Total,
+		),
+	)
+}
+
+func (sh *SerieHandler) Search(c *fiber.Ctx) error {
+	var req SerieSearchRequest
+
+	v := utils.NewValidator()
+	if err := req.bind(c, v); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("catalog_search_failed", nil, c),
+				-3003,
+				err,
+			),
+		)
+	}
+
+	resp, err := sh.SerieService(c).Search(req)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate(err.MessageID, nil, c),
+				-3003,
+				fmt.Errorf("%s", utils.Translate(err.Err.Error(), nil, c)),
+			),
+		)
+	}
+
+	return c.Status(http.StatusOK).JSON(
+		response.NewResponseWithPaging(
+			utils.Translate("catalog_search_success", nil, c),
+			3003,
+			resp,
+			req.PageOptions.Page,
+			req.PageOptions.Perpage,
+			resp.Total,
+		),
+	)
+}
+
+func (sh *SerieHandler) Suggest(c *fiber.Ctx) error {
+	var req SerieSuggestRequest
+
+	v := utils.NewValidator()
+	if err := req.bind(c, v); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("catalog_suggest_failed", nil, c),
+				-3004,
+				err,
+			),
+		)
+	}
+
+	resp, err := sh.SerieService(c).Suggest(req)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate(err.MessageID, nil, c),
+				-3004,
+				fmt.Errorf("%s", utils.Translate(err.Err.Error(), nil, c)),
+			),
+		)
+	}
+
+	// suggestions change slowly, a short browser cache absorbs repeated keystrokes
+	c.Set("Cache-Control", "public, max-age=30")
+	return c.Status(http.StatusOK).JSON(
+		response.NewResponse(
+			utils.Translate("catalog_suggest_success", nil, c),
+			3004,
+			resp,
+		),
+	)
+}
+
+func (sh *SerieHandler) ShowOne(c *fiber.Ctx) error {
+	serie_id, err_con := strconv.Atoi(c.Params("id"))
+	if err_con != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("catalog_show_failed", nil, c),
+				-3001,
+				fmt.Errorf("%s", utils.Translate("invalid_serie_id", nil, c)),
+			),
+		)
+	}
+
+	resp, err := sh.SerieService(c).ShowOne(serie_id)
+	if err != nil {
+		custom_log.NewCustomLog("catalog_show_failed", err.Err.Error(), "warn")
+		// only a missing serie is a 404, a failing database is reported like the other catalog errors
+		status := http.StatusBadRequest
+		if err.Err.Error() == "serie_not_found" {
+			status = http.StatusNotFound
+		}
+		return c.Status(status).JSON(
+			response.NewResponseError(
+				utils.Translate(err.MessageID, nil, c),
+				-3001,
+				fmt.Errorf("%s", utils.Translate(err.Err.Error(), nil, c)),
+			),
+		)
+	}
+
+	return c.Status(http.StatusOK).JSON(
+		response.NewResponse(
+			utils.Translate("catalog_show_success", nil, c),
+			3001,
+			resp,
+		),
+	)
+}
+
+func (sh *SerieHandler) ShowEpisode(c *fiber.Ctx) error {
+	serie_id, err_con := strconv.Atoi(c.Params("id"))
+	if err_con != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("catalog_episode_failed", nil, c),
+				-3002,
+				fmt.Errorf("%s", utils.Translate("invalid_serie_id", nil, c)),
+			),
+		)
+	}
+	number, err_con := strconv.ParseFloat(c.Params("number"), 64)
+	if err_con != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("catalog_episode_failed", nil, c),
+				-3002,
+				fmt.Errorf("%s", utils.Translate("invalid_episode_number", nil, c)),
+			),
+		)
+	}
+
+	resp, err := sh.SerieService(c).ShowEpisode(serie_id, number)
+	if err != nil {
+		status := http.StatusBadRequest
+		if err.Err.Error() == "serie_not_found" || err.Err.Error() == "episode_not_found" {
+			status = http.StatusNotFound
+		}
+		return c.Status(status).JSON(
+			response.NewResponseError(
+				utils.Translate(err.MessageID, nil, c),
+				-3002,
+				fmt.Errorf("%s", utils.Translate(err.Err.Error(), nil, c)),
+			),
+		)
+	}
+
+	return c.Status(http.StatusOK).JSON(
+		response.NewResponse(
+			utils.Translate("catalog_episode_success", nil, c),
+			3002,
+			resp,
+		),
+	)
+}
@@ -0,0 +1,232 @@
+package serie
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"rerng_addicted_api/configs"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+)
+
+type testError struct {
+	Success    bool   `json:"success"`
+	Message    string `json:"message"`
+	StatusCode int    `json:"status_code"`
+	Data       struct {
+		Error string `json:"error"`
+	} `json:"data"`
+}
+
+// testApp serves the catalog routes against a database that refuses every connection,
+// so a request that passes validation comes back as a database error
+func testApp(t *testing.T) *fiber.App {
+	t.Helper()
+	db_pool, err := sqlx.Open("postgres", "postgres://catalog@127.0.0.1:1/catalog?sslmode=disable&connect_timeout=1")
+	if err != nil {
+		t.Fatalf("sqlx.Open failed: %v", err)
+	}
+	t.Cleanup(func() { db_pool.Close() })
+
+	app := fiber.New()
+	NewRoute(app, db_pool, nil, &configs.CatalogConfig{SuggestLimit: 8, SuggestMaxLimit: 20}).RegisterSerieRoute()
+	return app
+}
+
+func testRequest(t *testing.T, app *fiber.App, target string) (*http.Response, testError) {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, target, nil), -1)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", target, err)
+	}
+	defer resp.Body.Close()
+
+	var body testError
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("GET %s returned a body that is not json: %v", target, err)
+	}
+	return resp, body
+}
+
+func TestBrowse(t *testing.T) {
+	app := testApp(t)
+
+	tests := []struct {
+		name    string
+		target  string
+		status  int
+		code    int
+		message string
+		err     string
+	}{
+		{"list without paging", "/api/v1/front/series/", http.StatusBadRequest, -3000, "catalog_show_failed", ""},
+		{"list with a zero page", "/api/v1/front/series/?paging_options.page=0&paging_options.per_page=10", http.StatusBadRequest, -3000, "catalog_show_failed", ""},
+		{"list while the database is down", "/api/v1/front/series/?paging_options.page=1&paging_options.per_page=10", http.StatusBadRequest, -3000, "catalog_show_failed", "database_error"},
+		{"serie id that is not a number", "/api/v1/front/series/abc", http.StatusBadRequest, -3001, "catalog_show_failed", "invalid_serie_id"},
+		{"serie while the database is down", "/api/v1/front/series/12", http.StatusBadRequest, -3001, "catalog_show_failed", "database_error"},
+		{"episode of a serie id that is not a number", "/api/v1/front/series/abc/episodes/1", http.StatusBadRequest, -3002, "catalog_episode_failed", "invalid_serie_id"},
+		{"episode number that is not a number", "/api/v1/front/series/12/episodes/first", http.StatusBadRequest, -3002, "catalog_episode_failed", ""},
+		{"episode while the database is down", "/api/v1/front/series/12/episodes/1.5", http.StatusBadRequest, -3002, "catalog_episode_failed", "database_error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := testRequest(t, app, tt.target)
+			if resp.StatusCode != tt.status || body.StatusCode != tt.code || body.Success {
+				t.Fatalf("GET %s = %d with code %d, want %d with code %d", tt.target, resp.StatusCode, body.StatusCode, tt.status, tt.code)
+			}
+			if tt.message != "" && body.Message != tt.message {
+				t.Errorf("message = %q, want %q", body.Message, tt.message)
+			}
+			if tt.err != "" && body.Data.Error != tt.err {
+				t.Errorf("error = %q, want %q", body.Data.Error, tt.err)
+			}
+		})
+	}
+}
+
+func TestSearch(t *testing.T) {
+	app := testApp(t)
+	paging := "paging_options.page=1&paging_options.per_page=10"
+
+	tests := []struct {
+		name   string
+		target string
+		err    string
+	}{
+		{"no keyword", "/api/v1/front/series/search?" + paging, ""},
+		{"blank keyword", "/api/v1/front/series/search?keyword=%20%20&" + paging, ""},
+		{"keyword too long", "/api/v1/front/series/search?keyword=" + strings.Repeat("a", 101) + "&" + paging, ""},
+		{"no paging", "/api/v1/front/series/search?keyword=love", ""},
+		{"zero per page", "/api/v1/front/series/search?keyword=love&paging_options.page=1&paging_options.per_page=0", ""},
+		{"valid search", "/api/v1/front/series/search?keyword=love&" + paging, "database_error"},
+		{"longest keyword", "/api/v1/front/series/search?keyword=" + strings.Repeat("a", 100) + "&" + paging, "database_error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := testRequest(t, app, tt.target)
+			if resp.StatusCode != http.StatusBadRequest || body.StatusCode != -3003 || body.Message != "catalog_search_failed" {
+				t.Fatalf("GET %s = %d with code %d (%s), want %d with code -3003", tt.target, resp.StatusCode, body.StatusCode, body.Message, http.StatusBadRequest)
+			}
+			// a request that fails validation never reaches the database
+			if tt.err == "" && body.Data.Error == "database_error" {
+				t.Errorf("GET %s reached the database", tt.target)
+			}
+			if tt.err != "" && body.Data.Error != tt.err {
+				t.Errorf("error = %q, want %q", body.Data.Error, tt.err)
+			}
+		})
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		headline string
+		want     string
+	}{
+		{"Love Next Door", "Love Next Door"},
+		{highlight_start + "Love" + highlight_stop + " Next Door", "<mark>Love</mark> Next Door"},
+		{"<script>" + highlight_start + "Love" + highlight_stop + "</script>", "&lt;script&gt;<mark>Love</mark>&lt;/script&gt;"},
+		{"Tom & " + highlight_start + "Jerry" + highlight_stop, "Tom &amp; <mark>Jerry</mark>"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := highlight(tt.headline); got != tt.want {
+			t.Errorf("highlight(%q) = %q, want %q", tt.headline, got, tt.want)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	app := testApp(t)
+
+	tests := []struct {
+		name   string
+		target string
+		err    string
+	}{
+		{"no keyword", "/api/v1/front/series/suggest", ""},
+		{"blank keyword", "/api/v1/front/series/suggest?keyword=%20", ""},
+		{"keyword too long", "/api/v1/front/series/suggest?keyword=" + strings.Repeat("a", 101), ""},
+		{"negative limit", "/api/v1/front/series/suggest?keyword=lo&limit=-1", ""},
+		{"limit that is not a number", "/api/v1/front/series/suggest?keyword=lo&limit=many", ""},
+		{"popular that is not a bool", "/api/v1/front/series/suggest?keyword=lo&popular=maybe", ""},
+		{"default limit", "/api/v1/front/series/suggest?keyword=lo", "database_error"},
+		{"limit over the max", "/api/v1/front/series/suggest?keyword=lo&limit=500", "database_error"},
+		{"without popular queries", "/api/v1/front/series/suggest?keyword=lo&popular=false", "database_error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := testRequest(t, app, tt.target)
+			if resp.StatusCode != http.StatusBadRequest || body.StatusCode != -3004 || body.Message != "catalog_suggest_failed" {
+				t.Fatalf("GET %s = %d with code %d (%s), want %d with code -3004", tt.target, resp.StatusCode, body.StatusCode, body.Message, http.StatusBadRequest)
+			}
+			if tt.err == "" && body.Data.Error == "database_error" {
+				t.Errorf("GET %s reached the database", tt.target)
+			}
+			if tt.err != "" && body.Data.Error != tt.err {
+				t.Errorf("error = %q, want %q", body.Data.Error, tt.err)
+			}
+			// only suggestions that were found may be cached by the browser
+			if cache_control := resp.Header.Get("Cache-Control"); cache_control != "" {
+				t.Errorf("a failed suggest set Cache-Control %q", cache_control)
+			}
+		})
+	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"love", "love"},
+		{"100%", `100\%`},
+		{"my_love", `my\_love`},
+		{`back\slash`, `back\\slash`},
+		{`%_\`, `\%\_\\`},
+	}
+
+	for _, tt := range tests {
+		if got := escapeLike(tt.value); got != tt.want {
+			t.Errorf("escapeLike(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"Love", "love"},
+		{"  Love   Next\tDoor ", "love next door"},
+		{"   ", ""},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeQuery(tt.query); got != tt.want {
+			t.Errorf("normalizeQuery(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestPopularWithoutRedis(t *testing.T) {
+	popular := NewPopularQueries(nil, &configs.CatalogConfig{PopularDays: 7, PopularSize: 100})
+	if popular != nil {
+		t.Fatal("NewPopularQueries without a client is not nil")
+	}
+
+	// suggestions still work, they just come from the catalog alone
+	popular.Record("love")
+	if matches := popular.Match("lo", 5); matches == nil || len(matches) != 0 {
+		t.Errorf("Match without redis = %#v, want an empty list", matches)
+	}
+}
@@ -0,0 +1,166 @@
+package serie
+
+import (
+	"fmt"
+	"rerng_addicted_api/internal/admin/serie"
+	types "rerng_addicted_api/pkg/model"
+	"rerng_addicted_api/pkg/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SerieSummary is one card of the catalog grid
+type SerieSummary struct {
+	ID             int        `db:"id" json:"id"`
+	Title          string     `db:"title" json:"title"`
+	Country        *string    `db:"country" json:"country"`
+	Status         *string    `db:"status" json:"status"`
+	Type           *string    `db:"type" json:"type"`
+	ReleaseDate    *time.Time `db:"release_date" json:"release_date"`
+	EpisodesCount  int        `db:"episodes_count" json:"episodes_count"`
+	AvailableCount int        `db:"available_count" json:"available_count"`
+	Label          *string    `db:"label" json:"label"`
+	Thumbnail      *string    `db:"thumbnail" json:"thumbnail"`
+	// ImageURL serves the thumbnail resized, append ?w=&h= for the size a grid cell needs
+	ImageURL string `json:"image_url"`
+}
+
+type SerieDetail struct {
+	SerieSummary
+	Description *string          `db:"description" json:"description"`
+	Trailer     *string          `db:"trailer" json:"trailer"`
+	Genres      []serie.Genre    `json:"genres"`
+	Tags        []serie.Tag      `json:"tags"`
+	People      []serie.Credit   `json:"people"`
+	Episodes    []EpisodeSummary `json:"episodes"`
+}
+
+type EpisodeSummary struct {
+	ID        int     `db:"id" json:"id"`
+	Number    float64 `db:"number" json:"number"`
+	Sub       int     `db:"sub" json:"sub"`
+	Available bool    `db:"available" json:"available"`
+}
+
+type EpisodeDetail struct {
+	ID        int     `db:"id" json:"id"`
+	SeriesID  int     `db:"series_id" json:"series_id"`
+	Number    float64 `db:"number" json:"number"`
+	Sub       int     `db:"sub" json:"sub"`
+	Source    string  `db:"src" json:"src"`
+	Available bool    `db:"available" json:"available"`
+	// Stale sources should be fetched again through SourceURL, which re-resolves them first
+	Stale      bool              `db:"is_stale" json:"is_stale"`
+	SourceURL  string            `json:"source_url"`
+	Subtitles  []serie.Subtitle  `json:"subtitles"`
+	Renditions []serie.Rendition `json:"renditions"`
+}
+
+// SerieSearchResult is a catalog card with how and where it matched the keyword
+type SerieSearchResult struct {
+	SerieSummary
+	Rank       float64 `db:"rank" json:"rank"`
+	Similarity float64 `db:"similarity" json:"similarity"`
+	// the highlights are html escaped with matched terms wrapped in <mark>
+	TitleHighlight       string `db:"title_highlight" json:"title_highlight"`
+	DescriptionHighlight string `db:"description_highlight" json:"description_highlight"`
+}
+
+type SeriesSearchResponse struct {
+	Series []SerieSearchResult `json:"series"`
+	Total  int                 `json:"-"`
+}
+
+type SerieSuggestion struct {
+	ID       int    `db:"id" json:"id"`
+	Title    string `db:"title" json:"title"`
+	ImageURL string `db:"-" json:"image_url"`
+}
+
+// SuggestionsResponse lists matching titles, and popular recent searches when asked for
+type SuggestionsResponse struct {
+	Series  []SerieSuggestion `json:"series"`
+	Queries []string          `json:"queries"`
+}
+
+type SeriesResponse struct {
+	Series []SerieSummary `json:"series"`
+	Total  int            `json:"-"`
+}
+
+type SerieDetailResponse struct {
+	Series []SerieDetail `json:"series"`
+}
+
+type EpisodeDetailResponse struct {
+	Episodes []EpisodeDetail `json:"episodes"`
+}
+
+type SerieShowRequest struct {
+	PageOptions types.Paging   `json:"paging_options" query:"paging_options" validate:"required"`
+	Sorts       []types.Sort   `json:"sorts,omitempty" query:"sorts"`
+	Filters     []types.Filter `json:"filters,omitempty" query:"filters"`
+	// Genre and Tag narrow the list to series linked to that slug
+	Genre string `json:"genre" query:"genre"`
+	Tag   string `json:"tag" query:"tag"`
+}
+
+func (r *SerieShowRequest) bind(c *fiber.Ctx, v *utils.Validator) error {
+	if err := c.QueryParser(r); err != nil {
+		return err
+	}
+
+	// fix `Filter.Value` nil when http query params failed parse to json type `interface{}`
+	for i := range r.Filters {
+		value := c.Query(fmt.Sprintf("filters[%d][value]", i))
+		if int_value, err := strconv.Atoi(value); err == nil {
+			r.Filters[i].Value = int_value
+		} else {
+			r.Filters[i].Value = value
+		}
+	}
+
+	if err := v.Validate(r, c); err != nil {
+		return err
+	}
+	return nil
+}
+
+type SerieSearchRequest struct {
+	PageOptions types.Paging `json:"paging_options" query:"paging_options" validate:"required"`
+	Keyword     string       `json:"keyword" query:"keyword" validate:"required,min=1,max=100"`
+}
+
+func (r *SerieSearchRequest) bind(c *fiber.Ctx, v *utils.Validator) error {
+	if err := c.QueryParser(r); err != nil {
+		return err
+	}
+	r.Keyword = strings.TrimSpace(r.Keyword)
+
+	if err := v.Validate(r, c); err != nil {
+		return err
+	}
+	return nil
+}
+
+type SerieSuggestRequest struct {
+	Keyword string `json:"keyword" query:"keyword" validate:"required,min=1,max=100"`
+	Limit   int    `json:"limit" query:"limit" validate:"omitempty,min=1"`
+	// Popular merges popular recent searches, on unless set to false
+	Popular *bool `json:"popular" query:"popular"`
+}
+
+func (r *SerieSuggestRequest) bind(c *fiber.Ctx, v *utils.Validator) error {
+	if err := c.QueryParser(r); err != nil {
+		return err
+	}
+	r.Keyword = strings.TrimSpace(r.Keyword)
+
+	if err := v.Validate(r, c); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package serie
+
+import (
+	"context"
+	"fmt"
+	"rerng_addicted_api/configs"
+	custom_log "rerng_addicted_api/pkg/logs"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	popular_prefix = "catalog:queries"
+	// the merged window is kept briefly so keystrokes do not union every day bucket
+	popular_merged_ttl = time.Minute
+	// how many of the top queries are scanned for a prefix match
+	popular_scan = 200
+	// suggestions must stay fast, a slow redis just means no popular queries
+	popular_timeout = 50 * time.Millisecond
+)
+
+// PopularQueries counts catalog searches that found something, one redis sorted set per day
+type PopularQueries struct {
+	Redis  *redis.Client
+	Config *configs.CatalogConfig
+}
+
+// NewPopularQueries is nil without a redis client, suggestions then only come from the catalog
+func NewPopularQueries(client *redis.Client, config *configs.CatalogConfig) *PopularQueries {
+	if client == nil {
+		return nil
+	}
+	return &PopularQueries{
+		Redis:  client,
+		Config: config,
+	}
+}
+
+func popularDayKey(day time.Time) string {
+	return fmt.Sprintf("%s:%s", popular_prefix, day.UTC().Format("20060102"))
+}
+
+func normalizeQuery(query string) string {
+	return strings.ToLower(strings.Join(strings.Fields(query), " "))
+}
+
+// Record bumps the query in today's bucket, errors are only logged since counting is best effort
+func (pq *PopularQueries) Record(query string) {
+	if pq == nil {
+		return
+	}
+	query = normalizeQuery(query)
+	if query == "" {
+		return
+	}
+
+	ctx := context.Background()
+	key := popularDayKey(time.Now())
+	pipe := pq.Redis.TxPipeline()
+	pipe.ZIncrBy(ctx, key, 1, query)
+	pipe.ZRemRangeByRank(ctx, key, 0, int64(-pq.Config.PopularSize-1))
+	pipe.Expire(ctx, key, time.Duration(pq.Config.PopularDays+1)*24*time.Hour)
+	if _, err := pipe.Exec(ctx); err != nil {
+		custom_log.NewCustomLog("popular_record_failed", err.Error(), "error")
+	}
+}
+
+// Match returns up to limit of the most searched queries over the window that start with the prefix
+func (pq *PopularQueries) Match(prefix string, limit int) []string {
+	matches := []string{}
+	if pq == nil || pq.Config.PopularDays < 1 {
+		return matches
+	}
+	prefix = normalizeQuery(prefix)
+	if prefix == "" {
+		return matches
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), popular_timeout)
+	defer cancel()
+
+	merged := popular_prefix + ":merged"
+	exists, err := pq.Redis.Exists(ctx, merged).Result()
+	if err != nil {
+		custom_log.NewCustomLog("popular_match_failed", err.Error(), "warn")
+		return matches
+	}
+	if exists == 0 {
+		now := time.Now()
+		keys := make([]string, pq.Config.PopularDays)
+		for i := range keys {
+			keys[i] = popularDayKey(now.AddDate(0, 0, -i))
+		}
+		pipe := pq.Redis.TxPipeline()
+		pipe.ZUnionStore(ctx, merged, &redis.ZStore{Keys: keys})
+		pipe.Expire(ctx, merged, popular_merged_ttl)
+		if _, err := pipe.Exec(ctx); err != nil {
+			custom_log.NewCustomLog("popular_match_failed", err.Error(), "warn")
+			return matches
+		}
+	}
+
+	members, err := pq.Redis.ZRevRange(ctx, merged, 0, popular_scan-1).Result()
+	if err != nil {
+		custom_log.NewCustomLog("popular_match_failed", err.Error(), "warn")
+		return matches
+	}
+	for _, member := range members {
+		if !strings.HasPrefix(member, prefix) && !strings.Contains(member, " "+prefix) {
+			continue
+		}
+		matches = append(matches, member)
+		if len(matches) == limit {
+			break
+		}
+	}
+	return matches
+}
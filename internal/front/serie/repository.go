@@ -0,0 +1,317 @@
+package serie
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"rerng_addicted_api/internal/admin/serie"
+	custom_log "rerng_addicted_api/pkg/logs"
+	types "rerng_addicted_api/pkg/model"
+	postgres "rerng_addicted_api/pkg/postgres"
+	"rerng_addicted_api/pkg/proxyurl"
+	"rerng_addicted_api/pkg/responses"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const summary_columns = `
+	s.id, s.title, s.country, s.status, s.type, s.release_date, COALESCE(s.episodes_count, 0) AS episodes_count,
+	(SELECT COUNT(*) FROM tbl_episodes e WHERE e.series_id = s.id AND e.status_id = 1 AND e.deleted_at IS NULL) AS available_count,
+	s.label, s.thumbnail`
+
+// columns viewers may filter and sort the catalog by
+var serie_list_columns = map[string]bool{
+	"s.id":             true,
+	"s.title":          true,
+	"s.country":        true,
+	"s.status":         true,
+	"s.type":           true,
+	"s.release_date":   true,
+	"s.episodes_count": true,
+	"s.created_at":     true,
+	"s.updated_at":     true,
+}
+
+// ts_headline markers, swapped for <mark> once the rest of the text is escaped
+const (
+	highlight_start = "\x02"
+	highlight_stop  = "\x03"
+)
+
+type SerieRepo interface {
+	Show(req SerieShowRequest) (*SeriesResponse, *responses.ErrorResponse)
+	Search(req SerieSearchRequest) (*SeriesSearchResponse, *responses.ErrorResponse)
+	Suggest(keyword string, limit int) ([]SerieSuggestion, *responses.ErrorResponse)
+	ShowOne(serie_id int) (*SerieDetailResponse, *responses.ErrorResponse)
+	ShowEpisode(serie_id int, number float64) (*EpisodeDetailResponse, *responses.ErrorResponse)
+}
+
+type SerieRepoImpl struct {
+	DBPool      *sqlx.DB
+	UserContext *types.UserContext
+}
+
+func NewSerieRepoImpl(db_pool *sqlx.DB, user_context *types.UserContext) *SerieRepoImpl {
+	return &SerieRepoImpl{
+		DBPool:      db_pool,
+		UserContext: user_context,
+	}
+}
+
+func imageURL(serie_id int) string {
+	return fmt.Sprintf("/api/v1/images/series/%d", serie_id)
+}
+
+func (sr *SerieRepoImpl) Show(req SerieShowRequest) (*SeriesResponse, *responses.ErrorResponse) {
+	filters := []types.Filter{}
+	for _, f := range req.Filters {
+		if serie_list_columns[f.Property] {
+			filters = append(filters, f)
+		}
+	}
+	sorts := []types.Sort{}
+	for _, s := range req.Sorts {
+		if serie_list_columns[s.Property] {
+			sorts = append(sorts, s)
+		}
+	}
+	if len(sorts) == 0 {
+		sorts = append(sorts, types.Sort{Property: "s.updated_at", Direction: "desc"}, types.Sort{Property: "s.id", Direction: "desc"})
+	}
+
+	sql_limit := postgres.BuildPaging(req.PageOptions.Page, req.PageOptions.Perpage)
+	sql_order_by := postgres.BuildSQLSort(sorts)
+	sql_filters, args_filters := postgres.BuildSQLFilter(filters)
+
+	clauses := []string{"s.deleted_at IS NULL"}
+	if sql_filters != "" {
+		clauses = append(clauses, sql_filters)
+	}
+	// the filter placeholders come first, the slug ones continue the numbering
+	if req.Genre != "" {
+		args_filters = append(args_filters, req.Genre)
+		clauses = append(clauses, fmt.Sprintf(`EXISTS (
+			SELECT 1 FROM tbl_serie_genres sg INNER JOIN tbl_genres g ON g.id = sg.genre_id
+			WHERE sg.series_id = s.id AND g.slug = $%d)`, len(args_filters)))
+	}
+	if req.Tag != "" {
+		args_filters = append(args_filters, req.Tag)
+		clauses = append(clauses, fmt.Sprintf(`EXISTS (
+			SELECT 1 FROM tbl_serie_tags st INNER JOIN tbl_tags t ON t.id = st.tag_id
+			WHERE st.series_id = s.id AND t.slug = $%d)`, len(args_filters)))
+	}
+	where_clause := "WHERE " + strings.Join(clauses, " AND ")
+
+	series := []SerieSummary{}
+	query := fmt.Sprintf(`SELECT %s FROM tbl_series s %s %s %s`, summary_columns, where_clause, sql_order_by, sql_limit)
+	if err := sr.DBPool.Select(&series, query, args_filters...); err != nil {
+		custom_log.NewCustomLog("catalog_show_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("catalog_show_failed", fmt.Errorf("database_error"))
+	}
+	for i := range series {
+		series[i].ImageURL = imageURL(series[i].ID)
+	}
+
+	var total int
+	count_query := fmt.Sprintf(`SELECT COUNT(*) FROM tbl_series s %s`, where_clause)
+	if err := sr.DBPool.Get(&total, count_query, args_filters...); err != nil {
+		custom_log.NewCustomLog("catalog_show_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("catalog_show_failed", fmt.Errorf("database_error"))
+	}
+
+	return &SeriesResponse{
+		Series: series,
+		Total:  total,
+	}, nil
+}
+
+// Search matches the keyword against the search vector and, for typos, title trigrams,
+// ranking full-text hits and close titles together
+func (sr *SerieRepoImpl) Search(req SerieSearchRequest) (*SeriesSearchResponse, *responses.ErrorResponse) {
+	sql_limit := postgres.BuildPaging(req.PageOptions.Page, req.PageOptions.Perpage)
+
+	where_clause := `
+		WHERE s.deleted_at IS NULL
+			AND (s.search_vector @@ websearch_to_tsquery('simple', $1) OR s.title % $1 OR $1 <% s.title)`
+
+	headline_options := fmt.Sprintf("StartSel=%s, StopSel=%s", highlight_start, highlight_stop)
+	results := []SerieSearchResult{}
+	query := fmt.Sprintf(`
+		SELECT %s,
+			ts_rank_cd(s.search_vector, websearch_to_tsquery('simple', $1)) AS rank,
+			GREATEST(similarity(s.title, $1), word_similarity($1, s.title)) AS similarity,
+			ts_headline('simple', s.title, websearch_to_tsquery('simple', $1), $2 || ', HighlightAll=true') AS title_highlight,
+			ts_headline('simple', COALESCE(s.description, ''), websearch_to_tsquery('simple', $1), $2 || ', MaxWords=35, MinWords=15') AS description_highlight
+		FROM tbl_series s
+		%s
+		ORDER BY ts_rank_cd(s.search_vector, websearch_to_tsquery('simple', $1))
+			+ GREATEST(similarity(s.title, $1), word_similarity($1, s.title)) DESC, s.id DESC
+		%s
+	`, summary_columns, where_clause, sql_limit)
+	if err := sr.DBPool.Select(&results, query, req.Keyword, headline_options); err != nil {
+		custom_log.NewCustomLog("catalog_search_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("catalog_search_failed", fmt.Errorf("database_error"))
+	}
+	for i := range results {
+		results[i].ImageURL = imageURL(results[i].ID)
+		results[i].TitleHighlight = highlight(results[i].TitleHighlight)
+		results[i].DescriptionHighlight = highlight(results[i].DescriptionHighlight)
+	}
+
+	var total int
+	count_query := fmt.Sprintf(`SELECT COUNT(*) FROM tbl_series s %s`, where_clause)
+	if err := sr.DBPool.Get(&total, count_query, req.Keyword); err != nil {
+		custom_log.NewCustomLog("catalog_search_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("catalog_search_failed", fmt.Errorf("database_error"))
+	}
+
+	return &SeriesSearchResponse{
+		Series: results,
+		Total:  total,
+	}, nil
+}
+
+// Suggest lists titles starting with the keyword first, then close fuzzy matches, both served by the title trigram index
+func (sr *SerieRepoImpl) Suggest(keyword string, limit int) ([]SerieSuggestion, *responses.ErrorResponse) {
+	suggestions := []SerieSuggestion{}
+	query := `
+		SELECT s.id, s.title
+		FROM tbl_series s
+		WHERE s.deleted_at IS NULL
+			AND (s.title ILIKE $1 OR $2 <% s.title)
+		ORDER BY s.title ILIKE $1 DESC, word_similarity($2, s.title) DESC, s.id DESC
+		LIMIT $3
+	`
+	if err := sr.DBPool.Select(&suggestions, query, escapeLike(keyword)+"%", keyword, limit); err != nil {
+		custom_log.NewCustomLog("catalog_suggest_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("catalog_suggest_failed", fmt.Errorf("database_error"))
+	}
+	for i := range suggestions {
+		suggestions[i].ImageURL = imageURL(suggestions[i].ID)
+	}
+
+	return suggestions, nil
+}
+
+// escapeLike keeps user input literal inside a LIKE pattern
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+// highlight escapes scraped text for html and turns the headline markers into <mark> tags
+func highlight(headline string) string {
+	escaped := html.EscapeString(headline)
+	escaped = strings.ReplaceAll(escaped, highlight_start, "<mark>")
+	return strings.ReplaceAll(escaped, highlight_stop, "</mark>")
+}
+
+func (sr *SerieRepoImpl) ShowOne(serie_id int) (*SerieDetailResponse, *responses.ErrorResponse) {
+	var detail SerieDetail
+	err := sr.DBPool.Get(&detail, fmt.Sprintf(`
+		SELECT %s, s.description, s.trailer
+		FROM tbl_series s
+		WHERE s.id = $1 AND s.deleted_at IS NULL
+	`, summary_columns), serie_id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("catalog_show_failed", fmt.Errorf("serie_not_found"))
+	}
+	if err != nil {
+		custom_log.NewCustomLog("catalog_show_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("catalog_show_failed", fmt.Errorf("database_error"))
+	}
+	detail.ImageURL = imageURL(detail.ID)
+
+	detail.Episodes = []EpisodeSummary{}
+	err = sr.DBPool.Select(&detail.Episodes, `
+		SELECT id, number, COALESCE(sub, 0) AS sub, status_id = 1 AS available
+		FROM tbl_episodes
+		WHERE series_id = $1 AND deleted_at IS NULL
+		ORDER BY number
+	`, serie_id)
+	if err != nil {
+		custom_log.NewCustomLog("catalog_show_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("catalog_show_failed", fmt.Errorf("database_error"))
+	}
+
+	metadata, err := serie.NewSerieRepoImpl(sr.DBPool, sr.UserContext).Metadata(serie_id)
+	if err != nil {
+		custom_log.NewCustomLog("catalog_show_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("catalog_show_failed", fmt.Errorf("database_error"))
+	}
+	// whether a link was scraped or set by hand is admin business
+	for i := range metadata.Genres {
+		metadata.Genres[i].Source = ""
+	}
+	for i := range metadata.Tags {
+		metadata.Tags[i].Source = ""
+	}
+	for i := range metadata.People {
+		metadata.People[i].Source = ""
+	}
+	detail.Genres, detail.Tags, detail.People = metadata.Genres, metadata.Tags, metadata.People
+
+	return &SerieDetailResponse{
+		Series: []SerieDetail{detail},
+	}, nil
+}
+
+func (sr *SerieRepoImpl) ShowEpisode(serie_id int, number float64) (*EpisodeDetailResponse, *responses.ErrorResponse) {
+	var episode EpisodeDetail
+	err := sr.DBPool.Get(&episode, `
+		SELECT e.id, e.series_id, e.number, COALESCE(e.sub, 0) AS sub, e.src, e.status_id = 1 AS available, e.is_stale
+		FROM tbl_episodes e
+		INNER JOIN tbl_series s ON s.id = e.series_id AND s.deleted_at IS NULL
+		WHERE e.series_id = $1 AND e.number = $2 AND e.deleted_at IS NULL
+		ORDER BY e.status_id, e.id
+		LIMIT 1
+	`, serie_id, number)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("catalog_episode_failed", fmt.Errorf("episode_not_found"))
+	}
+	if err != nil {
+		custom_log.NewCustomLog("catalog_episode_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("catalog_episode_failed", fmt.Errorf("database_error"))
+	}
+	episode.SourceURL = fmt.Sprintf("/api/v1/episodes/%d/source", episode.ID)
+
+	episode.Subtitles = []serie.Subtitle{}
+	err = sr.DBPool.Select(&episode.Subtitles, `
+		SELECT id, src, COALESCE(label, '') AS label, COALESCE(lang, '') AS lang,
+			COALESCE(is_default, FALSE) AS is_default, COALESCE("order", 1) AS "order"
+		FROM tbl_subtitles
+		WHERE episode_id = $1 AND deleted_at IS NULL
+		ORDER BY "order", id
+	`, episode.ID)
+	if err != nil {
+		custom_log.NewCustomLog("catalog_episode_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("catalog_episode_failed", fmt.Errorf("database_error"))
+	}
+
+	episode.Renditions = []serie.Rendition{}
+	err = sr.DBPool.Select(&episode.Renditions, `
+		SELECT id, episode_id, bandwidth, average_bandwidth, width, height, codecs, frame_rate, src, origin_src
+		FROM tbl_episode_renditions
+		WHERE episode_id = $1
+		ORDER BY height DESC, bandwidth DESC
+	`, episode.ID)
+	if err != nil {
+		custom_log.NewCustomLog("catalog_episode_failed", err.Error(), "error")
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("catalog_episode_failed", fmt.Errorf("database_error"))
+	}
+
+	// proxy urls are stored unsigned, each viewer gets a fresh signature
+	signer := proxyurl.Default()
+	episode.Source = signer.Resign(episode.Source, sr.UserContext.UserUuid)
+	for i := range episode.Subtitles {
+		episode.Subtitles[i].Src = signer.Resign(episode.Subtitles[i].Src, sr.UserContext.UserUuid)
+	}
+	for i := range episode.Renditions {
+		episode.Renditions[i].Src = signer.Resign(episode.Renditions[i].Src, sr.UserContext.UserUuid)
+	}
+
+	return &EpisodeDetailResponse{
+		Episodes: []EpisodeDetail{episode},
+	}, nil
+}
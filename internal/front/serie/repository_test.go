@@ -0,0 +1,241 @@
+package serie
+
+import (
+	"fmt"
+	"os"
+	types "rerng_addicted_api/pkg/model"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+)
+
+type testSerie struct {
+	name        string
+	title       string
+	description string
+	deleted     bool
+}
+
+// testDB connects to TEST_DATABASE_URL, a database migrated with goose, the test is skipped when it is not set
+func testDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db_pool, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		t.Fatalf("failed connecting to TEST_DATABASE_URL: %v", err)
+	}
+	t.Cleanup(func() { db_pool.Close() })
+	return db_pool
+}
+
+// seedSeries stores the series under a provider of their own, removed again when the test ends,
+// and returns their names by id so results from other rows in the database can be told apart
+func seedSeries(t *testing.T, db_pool *sqlx.DB, series []testSerie) map[int]string {
+	t.Helper()
+	provider := fmt.Sprintf("test-%d", time.Now().UnixNano())
+	t.Cleanup(func() {
+		db_pool.Exec(`DELETE FROM tbl_series WHERE provider = $1`, provider)
+	})
+
+	names := map[int]string{}
+	for i, serie := range series {
+		var deleted_at *time.Time
+		if serie.deleted {
+			now := time.Now()
+			deleted_at = &now
+		}
+		var id int
+		err := db_pool.Get(&id, `
+			INSERT INTO tbl_series (title, description, provider, external_id, deleted_at)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id
+		`, serie.title, serie.description, provider, i+1, deleted_at)
+		if err != nil {
+			t.Fatalf("failed seeding %s: %v", serie.name, err)
+		}
+		names[id] = serie.name
+	}
+	return names
+}
+
+// seeded keeps the names of the seeded series among ids, in order
+func seeded(names map[int]string, ids []int) []string {
+	found := []string{}
+	for _, id := range ids {
+		if name, ok := names[id]; ok {
+			found = append(found, name)
+		}
+	}
+	return found
+}
+
+var search_series = []testSerie{
+	{name: "moonlight", title: "Moonlight Garden", description: "Two rivals inherit the same garden."},
+	{name: "harbor", title: "Moonlit Harbor", description: "A keeper of the lighthouse waits for a ship that never comes."},
+	{name: "rain", title: "Garden of Quiet Rain", description: "A florist and a gardener."},
+	{name: "deleted", title: "Moonlight Garden Returns", description: "Removed from the catalog.", deleted: true},
+}
+
+func TestSearchDatabase(t *testing.T) {
+	db_pool := testDB(t)
+	names := seedSeries(t, db_pool, search_series)
+	repo := NewSerieRepoImpl(db_pool, nil)
+
+	tests := []struct {
+		name    string
+		keyword string
+		// first is the seeded serie expected before the other seeded matches
+		first string
+		want  []string
+	}{
+		{"full text match on the title ranks first", "moonlight garden", "moonlight", []string{"moonlight"}},
+		{"one word matches several titles", "garden", "", []string{"moonlight", "rain"}},
+		{"typo falls back to trigram matching", "moonlght", "moonlight", []string{"moonlight"}},
+		{"description is searched too", "lighthouse", "harbor", []string{"harbor"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := repo.Search(SerieSearchRequest{Keyword: tt.keyword, PageOptions: types.Paging{Page: 1, Perpage: 100}})
+			if err != nil {
+				t.Fatalf("Search(%q) failed: %s", tt.keyword, err.ErrorString())
+			}
+			ids := []int{}
+			for _, serie := range resp.Series {
+				ids = append(ids, serie.ID)
+			}
+			found := seeded(names, ids)
+
+			for _, name := range tt.want {
+				if !slices.Contains(found, name) {
+					t.Errorf("Search(%q) found %v, want %s among them", tt.keyword, found, name)
+				}
+			}
+			if slices.Contains(found, "deleted") {
+				t.Errorf("Search(%q) found a deleted serie", tt.keyword)
+			}
+			if tt.first != "" && (len(found) == 0 || found[0] != tt.first) {
+				t.Errorf("Search(%q) ranked %v, want %s first", tt.keyword, found, tt.first)
+			}
+		})
+	}
+}
+
+func TestSearchHighlightDatabase(t *testing.T) {
+	db_pool := testDB(t)
+	names := seedSeries(t, db_pool, []testSerie{
+		{name: "escaped", title: "Tom & Jerry", description: "The cat chases the mouse across the house."},
+	})
+	repo := NewSerieRepoImpl(db_pool, nil)
+
+	resp, err := repo.Search(SerieSearchRequest{Keyword: "jerry mouse", PageOptions: types.Paging{Page: 1, Perpage: 100}})
+	if err != nil {
+		t.Fatalf("Search failed: %s", err.ErrorString())
+	}
+	for _, serie := range resp.Series {
+		if names[serie.ID] != "escaped" {
+			continue
+		}
+		if want := "Tom &amp; <mark>Jerry</mark>"; serie.TitleHighlight != want {
+			t.Errorf("title highlight = %q, want %q", serie.TitleHighlight, want)
+		}
+		if !strings.Contains(serie.DescriptionHighlight, "<mark>mouse</mark>") {
+			t.Errorf("description highlight = %q, want mouse marked", serie.DescriptionHighlight)
+		}
+		return
+	}
+	t.Fatal("Search did not find the seeded serie")
+}
+
+func TestSearchVectorFollowsUpdates(t *testing.T) {
+	db_pool := testDB(t)
+	names := seedSeries(t, db_pool, []testSerie{{name: "renamed", title: "Provisional Title"}})
+	repo := NewSerieRepoImpl(db_pool, nil)
+
+	var id int
+	for seeded_id := range names {
+		id = seeded_id
+	}
+	// search_vector is generated, a rename is searchable without touching it
+	if _, err := db_pool.Exec(`UPDATE tbl_series SET title = 'Starfall Chronicles' WHERE id = $1`, id); err != nil {
+		t.Fatalf("rename failed: %v", err)
+	}
+
+	resp, err := repo.Search(SerieSearchRequest{Keyword: "starfall", PageOptions: types.Paging{Page: 1, Perpage: 100}})
+	if err != nil {
+		t.Fatalf("Search failed: %s", err.ErrorString())
+	}
+	ids := []int{}
+	for _, serie := range resp.Series {
+		ids = append(ids, serie.ID)
+	}
+	if !slices.Contains(ids, id) {
+		t.Error("Search did not find the renamed serie by its new title")
+	}
+}
+
+func TestSuggestDatabase(t *testing.T) {
+	db_pool := testDB(t)
+	names := seedSeries(t, db_pool, []testSerie{
+		{name: "moonlight", title: "Moonlight Garden"},
+		{name: "moonlit", title: "Moonlit Harbor"},
+		{name: "garden", title: "Garden of Quiet Rain"},
+		{name: "percent", title: "100% Love"},
+		{name: "thousand", title: "1000 Loves"},
+		{name: "deleted", title: "Moonlight Garden Returns", deleted: true},
+	})
+	repo := NewSerieRepoImpl(db_pool, nil)
+
+	tests := []struct {
+		name    string
+		keyword string
+		// want lists seeded series that must be suggested, in the order they must come in
+		want []string
+	}{
+		{"prefix matches", "moonl", []string{"moonlight", "moonlit"}},
+		{"prefix before a word inside the title", "garden", []string{"garden", "moonlight"}},
+		{"like wildcards are literal", "100%", []string{"percent", "thousand"}},
+		{"case insensitive", "MOONLIT", []string{"moonlit"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			suggestions, err := repo.Suggest(tt.keyword, 20)
+			if err != nil {
+				t.Fatalf("Suggest(%q) failed: %s", tt.keyword, err.ErrorString())
+			}
+			ids := []int{}
+			for _, suggestion := range suggestions {
+				ids = append(ids, suggestion.ID)
+			}
+			found := seeded(names, ids)
+
+			if slices.Contains(found, "deleted") {
+				t.Errorf("Suggest(%q) found a deleted serie", tt.keyword)
+			}
+			last := -1
+			for _, name := range tt.want {
+				at := slices.Index(found, name)
+				if at < 0 {
+					t.Errorf("Suggest(%q) = %v, want %s among them", tt.keyword, found, name)
+					continue
+				}
+				if at < last {
+					t.Errorf("Suggest(%q) = %v, want the order %v", tt.keyword, found, tt.want)
+				}
+				last = at
+			}
+		})
+	}
+
+	if suggestions, err := repo.Suggest("moonl", 1); err != nil || len(suggestions) != 1 {
+		t.Errorf("Suggest with a limit of 1 returned %d suggestions", len(suggestions))
+	}
+}
@@ -0,0 +1,35 @@
+package serie
+
+import (
+	"rerng_addicted_api/configs"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jmoiron/sqlx"
+)
+
+type SerieRoute struct {
+	App          *fiber.App
+	DBPool       *sqlx.DB
+	SerieHandler *SerieHandler
+}
+
+func NewRoute(app *fiber.App, db_pool *sqlx.DB, popular *PopularQueries, config *configs.CatalogConfig) *SerieRoute {
+	return &SerieRoute{
+		App:          app,
+		DBPool:       db_pool,
+		SerieHandler: NewSerieHandler(db_pool, popular, config),
+	}
+}
+
+func (r *SerieRoute) RegisterSerieRoute() *SerieRoute {
+	// the catalog is public, only stored series are served and the live source is never hit
+	series := r.App.Group("/api/v1/front/series")
+
+	series.Get("/", r.SerieHandler.Show)
+	series.Get("/search", r.SerieHandler.Search)
+	series.Get("/suggest", r.SerieHandler.Suggest)
+	series.Get("/:id", r.SerieHandler.ShowOne)
+	series.Get("/:id/episodes/:number", r.SerieHandler.ShowEpisode)
+
+	return r
+}
@@ -0,0 +1,84 @@
+package serie
+
+import (
+	"rerng_addicted_api/configs"
+	types "rerng_addicted_api/pkg/model"
+	"rerng_addicted_api/pkg/responses"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type SerieServiceCreator interface {
+	Show(req SerieShowRequest) (*SeriesResponse, *responses.ErrorResponse)
+	Search(req SerieSearchRequest) (*SeriesSearchResponse, *responses.ErrorResponse)
+	Suggest(req SerieSuggestRequest) (*SuggestionsResponse, *responses.ErrorResponse)
+	ShowOne(serie_id int) (*SerieDetailResponse, *responses.ErrorResponse)
+	ShowEpisode(serie_id int, number float64) (*EpisodeDetailResponse, *responses.ErrorResponse)
+}
+
+type SerieService struct {
+	DBPool      *sqlx.DB
+	UserContext *types.UserContext
+	SerieRepo   *SerieRepoImpl
+	// Popular is nil when searches are not counted
+	Popular *PopularQueries
+	Config  *configs.CatalogConfig
+}
+
+func NewSerieService(db_pool *sqlx.DB, user_context *types.UserContext) *SerieService {
+	return &SerieService{
+		DBPool:      db_pool,
+		UserContext: user_context,
+		SerieRepo:   NewSerieRepoImpl(db_pool, user_context),
+	}
+}
+
+func (s *SerieService) Show(req SerieShowRequest) (*SeriesResponse, *responses.ErrorResponse) {
+	return s.SerieRepo.Show(req)
+}
+
+func (s *SerieService) Search(req SerieSearchRequest) (*SeriesSearchResponse, *responses.ErrorResponse) {
+	resp, err := s.SerieRepo.Search(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// only searches that found something feed suggestions, and paging through results is not another search
+	if resp.Total > 0 && req.PageOptions.Page <= 1 {
+		s.Popular.Record(req.Keyword)
+	}
+	return resp, nil
+}
+
+func (s *SerieService) Suggest(req SerieSuggestRequest) (*SuggestionsResponse, *responses.ErrorResponse) {
+	limit := req.Limit
+	if limit == 0 {
+		limit = s.Config.SuggestLimit
+	}
+	if limit > s.Config.SuggestMaxLimit {
+		limit = s.Config.SuggestMaxLimit
+	}
+
+	series, err := s.SerieRepo.Suggest(req.Keyword, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	queries := []string{}
+	if req.Popular == nil || *req.Popular {
+		queries = s.Popular.Match(req.Keyword, limit)
+	}
+
+	return &SuggestionsResponse{
+		Series:  series,
+		Queries: queries,
+	}, nil
+}
+
+func (s *SerieService) ShowOne(serie_id int) (*SerieDetailResponse, *responses.ErrorResponse) {
+	return s.SerieRepo.ShowOne(serie_id)
+}
+
+func (s *SerieService) ShowEpisode(serie_id int, number float64) (*EpisodeDetailResponse, *responses.ErrorResponse) {
+	return s.SerieRepo.ShowEpisode(serie_id, number)
+}
@@ -0,0 +1,147 @@
+package image
+
+import (
+	"fmt"
+	"net/http"
+	"rerng_addicted_api/configs"
+	response "rerng_addicted_api/pkg/http/response"
+	"rerng_addicted_api/pkg/imagestore"
+	"rerng_addicted_api/pkg/responses"
+	"rerng_addicted_api/pkg/utils"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jmoiron/sqlx"
+)
+
+// stored images never change, their path is their content hash
+const image_cache_control = "public, max-age=31536000, immutable"
+
+// a serie's thumbnail can be replaced by a later scrape, so resized ones are cached for a day
+const variant_cache_control = "public, max-age=86400"
+
+type ImageHandler struct {
+	DBPool       *sqlx.DB
+	Images       *imagestore.Store
+	ImageService *ImageService
+}
+
+func NewImageHandler(db_pool *sqlx.DB, images *imagestore.Store, config *configs.ImageConfig) *ImageHandler {
+	return &ImageHandler{
+		DBPool:       db_pool,
+		Images:       images,
+		ImageService: NewImageService(db_pool, images, config),
+	}
+}
+
+// Show serves a mirrored image by the path stored in tbl_series.thumbnail_path
+func (ih *ImageHandler) Show(c *fiber.Ctx) error {
+	rel := c.Params("*")
+
+	full, err := ih.Images.Open(rel)
+	if err != nil {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+
+	etag := fmt.Sprintf(`"%s"`, imagestore.Hash(rel))
+	c.Set("Cache-Control", image_cache_control)
+	c.Set("ETag", etag)
+	c.Set("Access-Control-Allow-Origin", "*")
+	if c.Get("If-None-Match") == etag {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	c.Type(imagestore.Extension(rel))
+	return c.SendFile(full)
+}
+
+// SerieThumbnail serves the serie's thumbnail resized to the w, h and fit query params.
+// The output is JPEG unless format=png is asked for or the Accept header prefers PNG.
+func (ih *ImageHandler) SerieThumbnail(c *fiber.Ctx) error {
+	serie_id, err_con := strconv.Atoi(c.Params("id"))
+	if err_con != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("image_resize_failed", nil, c),
+				-2400,
+				fmt.Errorf("%s", utils.Translate("invalid_serie_id", nil, c)),
+			),
+		)
+	}
+
+	var req ResizeRequest
+	if err := c.QueryParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate("image_resize_failed", nil, c),
+				-2400,
+				fmt.Errorf("%s", utils.Translate("invalid_image_size", nil, c)),
+			),
+		)
+	}
+
+	variant, err := ih.ImageService.Variant(req)
+	if err == nil {
+		variant.Format, err = negotiateFormat(c, req.Format)
+	}
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(
+			response.NewResponseError(
+				utils.Translate(err.MessageID, nil, c),
+				-2400,
+				fmt.Errorf("%s", utils.Translate(err.Err.Error(), nil, c)),
+			),
+		)
+	}
+
+	full, name, err := ih.ImageService.SerieThumbnail(serie_id, *variant)
+	if err != nil {
+		status := http.StatusBadGateway
+		switch err.Err.Error() {
+		case "serie_not_found":
+			status = http.StatusNotFound
+		case "image_unsupported", "image_too_large":
+			status = http.StatusUnprocessableEntity
+		case "database_error":
+			status = http.StatusInternalServerError
+		}
+		return c.Status(status).JSON(
+			response.NewResponseError(
+				utils.Translate(err.MessageID, nil, c),
+				-2401,
+				fmt.Errorf("%s", utils.Translate(err.Err.Error(), nil, c)),
+			),
+		)
+	}
+
+	etag := fmt.Sprintf(`"%s"`, name)
+	c.Set("Cache-Control", variant_cache_control)
+	c.Set("ETag", etag)
+	c.Set("Vary", "Accept")
+	c.Set("Access-Control-Allow-Origin", "*")
+	if c.Get("If-None-Match") == etag {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	c.Type(variant.Extension())
+	return c.SendFile(full)
+}
+
+// negotiateFormat honours an explicit format param, otherwise picks whichever of JPEG and PNG the
+// Accept header ranks higher, JPEG winning ties since thumbnails are photos
+func negotiateFormat(c *fiber.Ctx, format string) (string, *responses.ErrorResponse) {
+	switch format {
+	case imagestore.FormatJPEG, "jpg":
+		return imagestore.FormatJPEG, nil
+	case imagestore.FormatPNG:
+		return imagestore.FormatPNG, nil
+	case "":
+	default:
+		return "", (&responses.ErrorResponse{}).NewErrorResponse("image_resize_failed", fmt.Errorf("invalid_image_format"))
+	}
+
+	if c.Accepts("image/jpeg", "image/png") == "image/png" {
+		return imagestore.FormatPNG, nil
+	}
+	return imagestore.FormatJPEG, nil
+}
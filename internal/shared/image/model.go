@@ -0,0 +1,16 @@
+package image
+
+// SerieThumbnail is the thumbnail columns of tbl_series
+type SerieThumbnail struct {
+	ID            int     `db:"id"`
+	Thumbnail     *string `db:"thumbnail"`
+	ThumbnailPath *string `db:"thumbnail_path"`
+}
+
+// ResizeRequest is the query of GET /api/v1/images/series/:id
+type ResizeRequest struct {
+	Width  int    `query:"w"`
+	Height int    `query:"h"`
+	Fit    string `query:"fit"`
+	Format string `query:"format"`
+}
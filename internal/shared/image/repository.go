@@ -0,0 +1,53 @@
+package image
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type ImageRepo interface {
+	SerieThumbnail(serie_id int) (*SerieThumbnail, error)
+	SetThumbnailPath(serie_id int, rel string) error
+}
+
+type ImageRepoImpl struct {
+	DBPool *sqlx.DB
+}
+
+func NewImageRepoImpl(db_pool *sqlx.DB) *ImageRepoImpl {
+	return &ImageRepoImpl{
+		DBPool: db_pool,
+	}
+}
+
+// SerieThumbnail returns nil when the serie does not exist or is deleted
+func (ir *ImageRepoImpl) SerieThumbnail(serie_id int) (*SerieThumbnail, error) {
+	var thumbnail SerieThumbnail
+	err := ir.DBPool.Get(&thumbnail, `
+		SELECT id, thumbnail, thumbnail_path
+		FROM tbl_series
+		WHERE id = $1 AND deleted_at IS NULL
+	`, serie_id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed loading thumbnail of serie %d: %w", serie_id, err)
+	}
+	return &thumbnail, nil
+}
+
+// SetThumbnailPath stores a thumbnail mirrored on demand, series scraped before mirroring existed have none
+func (ir *ImageRepoImpl) SetThumbnailPath(serie_id int, rel string) error {
+	_, err := ir.DBPool.Exec(`
+		UPDATE tbl_series SET thumbnail_path = $2
+		WHERE id = $1 AND thumbnail_path IS NULL
+	`, serie_id, rel)
+	if err != nil {
+		return fmt.Errorf("failed storing thumbnail path of serie %d: %w", serie_id, err)
+	}
+	return nil
+}
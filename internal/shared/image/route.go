@@ -0,0 +1,32 @@
+package image
+
+import (
+	"rerng_addicted_api/configs"
+	"rerng_addicted_api/pkg/imagestore"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jmoiron/sqlx"
+)
+
+type ImageRoute struct {
+	App          *fiber.App
+	DBPool       *sqlx.DB
+	ImageHandler *ImageHandler
+}
+
+func NewRoute(app *fiber.App, db_pool *sqlx.DB, images *imagestore.Store, config *configs.ImageConfig) *ImageRoute {
+	return &ImageRoute{
+		App:          app,
+		DBPool:       db_pool,
+		ImageHandler: NewImageHandler(db_pool, images, config),
+	}
+}
+
+func (ir *ImageRoute) RegisterImageRoute() *ImageRoute {
+	images := ir.App.Group("/api/v1/images")
+
+	images.Get("/series/:id", ir.ImageHandler.SerieThumbnail)
+	images.Get("/*", ir.ImageHandler.Show)
+
+	return ir
+}
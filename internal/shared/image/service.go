@@ -0,0 +1,113 @@
+package image
+
+import (
+	"errors"
+	"fmt"
+	"rerng_addicted_api/configs"
+	"rerng_addicted_api/pkg/imagestore"
+	custom_log "rerng_addicted_api/pkg/logs"
+	"rerng_addicted_api/pkg/responses"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type ImageService struct {
+	ImageRepo ImageRepo
+	Images    *imagestore.Store
+	Config    *configs.ImageConfig
+}
+
+func NewImageService(db_pool *sqlx.DB, images *imagestore.Store, config *configs.ImageConfig) *ImageService {
+	return &ImageService{
+		ImageRepo: NewImageRepoImpl(db_pool),
+		Images:    images,
+		Config:    config,
+	}
+}
+
+// Variant validates the request against the configured limits, format is picked by the handler
+func (is *ImageService) Variant(req ResizeRequest) (*imagestore.Variant, *responses.ErrorResponse) {
+	if req.Width < 0 || req.Height < 0 || req.Width > is.Config.MaxDimension || req.Height > is.Config.MaxDimension {
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("image_resize_failed", fmt.Errorf("invalid_image_size"))
+	}
+
+	fit := strings.ToLower(req.Fit)
+	switch fit {
+	case "":
+		fit = imagestore.FitCover
+	case imagestore.FitCover, imagestore.FitContain, imagestore.FitFill:
+	default:
+		return nil, (&responses.ErrorResponse{}).NewErrorResponse("image_resize_failed", fmt.Errorf("invalid_image_fit"))
+	}
+
+	return &imagestore.Variant{Width: is.snap(req.Width), Height: is.snap(req.Height), Fit: fit}, nil
+}
+
+// snap rounds a requested dimension up to the next configured size, zero keeps following the aspect ratio.
+// Any request maps onto a few variants, so the endpoint cannot be used to fill the disk.
+func (is *ImageService) snap(dimension int) int {
+	if dimension == 0 || len(is.Config.Sizes) == 0 {
+		return dimension
+	}
+	for _, size := range is.Config.Sizes {
+		if size >= dimension {
+			return size
+		}
+	}
+	return is.Config.Sizes[len(is.Config.Sizes)-1]
+}
+
+// SerieThumbnail returns the file of the serie's thumbnail resized to variant along with its variant name
+func (is *ImageService) SerieThumbnail(serie_id int, variant imagestore.Variant) (string, string, *responses.ErrorResponse) {
+	thumbnail, err := is.ImageRepo.SerieThumbnail(serie_id)
+	if err != nil {
+		custom_log.NewCustomLog("image_resize_failed", err.Error(), "error")
+		return "", "", (&responses.ErrorResponse{}).NewErrorResponse("image_resize_failed", fmt.Errorf("database_error"))
+	}
+	if thumbnail == nil {
+		return "", "", (&responses.ErrorResponse{}).NewErrorResponse("image_resize_failed", fmt.Errorf("serie_not_found"))
+	}
+
+	rel, err_resp := is.original(thumbnail)
+	if err_resp != nil {
+		return "", "", err_resp
+	}
+
+	full, err := is.Images.Variant(rel, variant)
+	if err != nil {
+		if errors.Is(err, imagestore.ErrUnsupported) {
+			return "", "", (&responses.ErrorResponse{}).NewErrorResponse("image_resize_failed", fmt.Errorf("image_unsupported"))
+		}
+		if errors.Is(err, imagestore.ErrTooLarge) {
+			return "", "", (&responses.ErrorResponse{}).NewErrorResponse("image_resize_failed", fmt.Errorf("image_too_large"))
+		}
+		custom_log.NewCustomLog("image_resize_failed", err.Error(), "error")
+		return "", "", (&responses.ErrorResponse{}).NewErrorResponse("image_resize_failed", fmt.Errorf("image_unavailable"))
+	}
+	return full, variant.Name(rel), nil
+}
+
+// original returns the mirrored thumbnail, mirroring it now when the serie predates thumbnail mirroring
+func (is *ImageService) original(thumbnail *SerieThumbnail) (string, *responses.ErrorResponse) {
+	if thumbnail.ThumbnailPath != nil {
+		if _, err := is.Images.Open(*thumbnail.ThumbnailPath); err == nil {
+			return *thumbnail.ThumbnailPath, nil
+		}
+	}
+	if thumbnail.Thumbnail == nil || *thumbnail.Thumbnail == "" {
+		return "", (&responses.ErrorResponse{}).NewErrorResponse("image_resize_failed", fmt.Errorf("image_unavailable"))
+	}
+
+	rel, err := is.Images.Mirror(*thumbnail.Thumbnail)
+	if err != nil {
+		custom_log.NewCustomLog("thumbnail_mirror_failed", err.Error(), "warn")
+		return "", (&responses.ErrorResponse{}).NewErrorResponse("image_resize_failed", fmt.Errorf("image_unavailable"))
+	}
+	if thumbnail.ThumbnailPath == nil {
+		if err := is.ImageRepo.SetThumbnailPath(thumbnail.ID, rel); err != nil {
+			custom_log.NewCustomLog("thumbnail_mirror_failed", err.Error(), "warn")
+		}
+	}
+	return rel, nil
+}
@@ -0,0 +1,52 @@
+package image
+
+import (
+	"rerng_addicted_api/configs"
+	"rerng_addicted_api/pkg/imagestore"
+	"testing"
+)
+
+func TestVariantBounds(t *testing.T) {
+	service := &ImageService{Config: &configs.ImageConfig{MaxDimension: 1600, Sizes: []int{160, 320, 640, 1280}}}
+
+	tests := []struct {
+		name     string
+		req      ResizeRequest
+		want     *imagestore.Variant
+		want_err string
+	}{
+		{"defaults to cover", ResizeRequest{Width: 320}, &imagestore.Variant{Width: 320, Fit: imagestore.FitCover}, ""},
+		{"fit is case insensitive", ResizeRequest{Width: 320, Height: 640, Fit: "Contain"}, &imagestore.Variant{Width: 320, Height: 640, Fit: imagestore.FitContain}, ""},
+		{"no size keeps the original size", ResizeRequest{Fit: "fill"}, &imagestore.Variant{Fit: imagestore.FitFill}, ""},
+		{"snaps up to the next size", ResizeRequest{Width: 300, Height: 161}, &imagestore.Variant{Width: 320, Height: 320, Fit: imagestore.FitCover}, ""},
+		{"smallest request", ResizeRequest{Width: 1}, &imagestore.Variant{Width: 160, Fit: imagestore.FitCover}, ""},
+		{"above the largest size", ResizeRequest{Width: 1281}, &imagestore.Variant{Width: 1280, Fit: imagestore.FitCover}, ""},
+		{"largest allowed", ResizeRequest{Width: 1600, Height: 1600}, &imagestore.Variant{Width: 1280, Height: 1280, Fit: imagestore.FitCover}, ""},
+		{"width too large", ResizeRequest{Width: 1601}, nil, "invalid_image_size"},
+		{"height too large", ResizeRequest{Height: 1601}, nil, "invalid_image_size"},
+		{"negative width", ResizeRequest{Width: -1}, nil, "invalid_image_size"},
+		{"negative height", ResizeRequest{Height: -1}, nil, "invalid_image_size"},
+		{"unknown fit", ResizeRequest{Width: 300, Fit: "stretch"}, nil, "invalid_image_fit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := service.Variant(tt.req)
+			if tt.want_err != "" {
+				if err == nil {
+					t.Fatalf("Variant(%+v) succeeded, want %s", tt.req, tt.want_err)
+				}
+				if err.Err.Error() != tt.want_err || err.MessageID != "image_resize_failed" {
+					t.Fatalf("Variant(%+v) error = %s, want %s", tt.req, err.ErrorString(), tt.want_err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Variant(%+v) failed: %s", tt.req, err.ErrorString())
+			}
+			if *got != *tt.want {
+				t.Errorf("Variant(%+v) = %+v, want %+v", tt.req, *got, *tt.want)
+			}
+		})
+	}
+}
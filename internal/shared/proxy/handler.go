@@ -2,39 +2,113 @@ package proxy
 
 import (
 	"bufio"
-	"crypto/tls"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
+	"rerng_addicted_api/configs"
+	"rerng_addicted_api/pkg/browser"
+	"rerng_addicted_api/pkg/cache"
+	"rerng_addicted_api/pkg/hls"
+	response "rerng_addicted_api/pkg/http/response"
+	custom_log "rerng_addicted_api/pkg/logs"
+	"rerng_addicted_api/pkg/netguard"
+	"rerng_addicted_api/pkg/proxyurl"
+	"rerng_addicted_api/pkg/segcache"
+	"rerng_addicted_api/pkg/utils"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/go-rod/rod"
-	"github.com/go-rod/rod/lib/launcher"
 	"github.com/go-rod/rod/lib/proto"
 	"github.com/gofiber/fiber/v2"
 	"github.com/jmoiron/sqlx"
+	"github.com/redis/go-redis/v9"
 )
 
 type ProxyHandler struct {
-	DBPool *sqlx.DB
+	DBPool      *sqlx.DB
+	BrowserPool *browser.Pool
+	Signer      *proxyurl.Signer
+	// Guard keeps every upstream request on allowed public hosts
+	Guard *netguard.Guard
+	// Segments is nil when the segment cache is turned off
+	Segments *segcache.Cache
+	// Media maps a page url to the media url the browser found on it, Speed a client ip to its chunk size
+	Media  cache.Cache
+	Speed  cache.Cache
+	Config *configs.ProxyConfig
+	// Mount is where the proxy routes are mounted, rewritten playlists point back under it
+	Mount string
 }
 
-func NewProxyHandler(db_pool *sqlx.DB) *ProxyHandler {
+func NewProxyHandler(db_pool *sqlx.DB, browser_pool *browser.Pool, deps ProxyDeps, config *configs.ProxyConfig, mount string) *ProxyHandler {
 	return &ProxyHandler{
-		DBPool: db_pool,
+		DBPool:      db_pool,
+		BrowserPool: browser_pool,
+		Signer:      deps.Signer,
+		Guard:       deps.Guard,
+		Segments:    deps.Segments,
+		Media:       cache.New(deps.Redis, "proxy:media:"),
+		Speed:       cache.New(deps.Redis, "proxy:speed:"),
+		Config:      config,
+		Mount:       mount,
 	}
 }
 
-var (
-	mediaCache sync.Map // cache for discovered media URLs
-	speedCache sync.Map // cache for speed test results per IP
-)
+// ProxyDeps are the shared pieces the proxy handler is built from, a nil Redis keeps its caches in memory
+type ProxyDeps struct {
+	Signer   *proxyurl.Signer
+	Guard    *netguard.Guard
+	Segments *segcache.Cache
+	Redis    *redis.Client
+}
+
+// blocked answers 403 for an upstream the guard refused, a host that does not resolve is a 502
+func blocked(c *fiber.Ctx, target string, err error) error {
+	if !netguard.Blocked(err) {
+		log.Println("Error resolving upstream:", err)
+		return c.Status(fiber.StatusBadGateway).SendString(err.Error())
+	}
+	custom_log.NewCustomLog("proxy_upstream_blocked", fmt.Sprintf("%s from %s: %s", target, c.IP(), err.Error()), "warn")
+
+	// the wrapped error carries the host, the i18n key is the sentinel
+	key := netguard.ErrHostNotAllowed
+	for _, sentinel := range []error{netguard.ErrPrivateAddress, netguard.ErrTooManyHops} {
+		if errors.Is(err, sentinel) {
+			key = sentinel
+		}
+	}
+	return c.Status(http.StatusForbidden).JSON(
+		response.NewResponseError(
+			utils.Translate("proxy_access_denied", nil, c),
+			-2601,
+			fmt.Errorf("%s", utils.Translate(key.Error(), nil, c)),
+		),
+	)
+}
+
+// urls signed before the provider was recorded on them all came from kisskh
+const default_provider = "kisskh"
+
+// requestProvider is the provider the signed url was issued for, only its allowlist applies to the request
+func requestProvider(c *fiber.Ctx) string {
+	if provider := c.Query(proxyurl.ParamProvider); provider != "" {
+		return provider
+	}
+	return default_provider
+}
+
+// statuses that mean a discovered media url is gone, rather than upstream having a bad moment
+func mediaGone(status int) bool {
+	return status == http.StatusForbidden || status == http.StatusNotFound || status == http.StatusGone
+}
 
 func (pr *ProxyHandler) M3u8(c *fiber.Ctx) error {
 	pathParam := c.Params("*")
@@ -56,11 +130,15 @@ func (pr *ProxyHandler) M3u8(c *fiber.Ctx) error {
 	} else {
 		target = "https://" + pathParam
 	}
-	// Preserve query params
-	if q := c.Context().QueryArgs().String(); q != "" {
+	// Preserve query params, the signature is ours and stays here
+	if q := proxyurl.UpstreamQuery(c.Context().QueryArgs().String()); q != "" {
 		target += "?" + q
 	}
+	provider := requestProvider(c)
 	log.Println("Fetching upstream URL:", target)
+	if _, err := pr.Guard.CheckURL(c.Context(), provider, target); err != nil {
+		return blocked(c, target, err)
+	}
 
 	// Build request
 	req, err := http.NewRequest("GET", target, nil)
@@ -84,16 +162,25 @@ func (pr *ProxyHandler) M3u8(c *fiber.Ctx) error {
 	}
 	req.Header.Set("X-Forwarded-For", c.IP())
 
-	client := &http.Client{
-		Timeout: 0, // stream until finished
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return nil
-		},
-	}
+	// stream until finished, redirects are checked like the first request
+	client := pr.Guard.Client(provider, 0)
 
-	resp, err := client.Do(req)
+	// segments and keys are the same for every viewer, ranged requests go straight upstream
+	var resp *http.Response
+	if c.Get("Range") == "" && pr.Segments.Cacheable(target) {
+		var served bool
+		if resp, served, err = pr.serveSegment(c, client, req, target); served {
+			return err
+		}
+	}
+	if resp == nil && err == nil {
+		resp, err = client.Do(req)
+	}
 
 	fmt.Println("[RESPONSE] : ", resp)
+	if netguard.Blocked(err) {
+		return blocked(c, target, err)
+	}
 	if err != nil {
 		log.Println("Error fetching upstream:", err)
 		return c.Status(500).SendString(err.Error())
@@ -109,66 +196,17 @@ func (pr *ProxyHandler) M3u8(c *fiber.Ctx) error {
 	c.Set("Content-Type", contentType)
 	c.Status(resp.StatusCode)
 
-	// ✅ Handle .m3u8 playlists (keep your logic untouched)
-	if strings.Contains(contentType, "application/vnd.apple.mpegurl") || strings.HasSuffix(pathParam, ".m3u8") {
-		body, _ := io.ReadAll(resp.Body)
-		lines := strings.Split(string(body), "\n")
-		base := "/video-proxy-2"
-
-		for i, line := range lines {
-			line = strings.TrimSpace(line)
-			if line == "" {
-				continue
-			}
-
-			// Handle EXT-X-KEY separately
-			if strings.HasPrefix(line, "#EXT-X-KEY") {
-				if strings.Contains(line, "URI=") {
-					start := strings.Index(line, "URI=\"") + len("URI=\"")
-					end := strings.Index(line[start:], "\"")
-					if start > -1 && end > -1 {
-						uri := line[start : start+end]
-						u, err := url.Parse(uri)
-						if err == nil {
-							proxyURL := base + "/" + u.Host + u.Path
-							if u.RawQuery != "" {
-								proxyURL += "?" + u.RawQuery
-							}
-							line = strings.Replace(line, uri, proxyURL, 1)
-						}
-					}
-				}
-				lines[i] = line
-				continue
-			}
-
-			// Skip comments
-			if strings.HasPrefix(line, "#") {
-				continue
-			}
-
-			// Absolute URLs
-			if strings.HasPrefix(line, "http://") || strings.HasPrefix(line, "https://") {
-				u, err := url.Parse(line)
-				if err != nil {
-					continue
-				}
-				proxyURL := base + "/" + u.Host + u.Path
-				if u.RawQuery != "" {
-					proxyURL += "?" + u.RawQuery
-				}
-				lines[i] = proxyURL
-			} else {
-				// Relative path
-				if strings.Contains(line, "?") {
-					relParts := strings.SplitN(line, "?", 2)
-					lines[i] = base + "/" + parts[0] + "/" + relParts[0] + "?" + relParts[1]
-				} else {
-					lines[i] = base + "/" + parts[0] + "/" + line
-				}
-			}
+	// playlists are rewritten so every playlist, segment and key is fetched through this route again
+	if hls.IsPlaylist(contentType, pathParam) {
+		c.Set("Content-Type", "application/vnd.apple.mpegurl")
+		// relative uris resolve against where the playlist ended up after redirects,
+		// child urls are signed for the same user as this playlist
+		user_uuid, _ := c.Locals("ProxyUser").(string)
+		if err := hls.Rewrite(resp.Body, c.Response().BodyWriter(), resp.Request.URL, pr.proxyURL(user_uuid, provider)); err != nil {
+			log.Println("Error rewriting playlist:", err)
+			return c.Status(fiber.StatusBadGateway).SendString(err.Error())
 		}
-		return c.SendString(strings.Join(lines, "\n"))
+		return nil
 	}
 
 	// ✅ Passthrough for .ts, .mp4, etc.
@@ -189,64 +227,141 @@ func (pr *ProxyHandler) M3u8(c *fiber.Ctx) error {
 
 }
 
-// adaptiveChunkSize determines the optimal chunk size based on network speed.
-// It caches the result per client IP.
-// ✅ Define adaptiveChunkSize OUTSIDE the route handle
+// serveSegment answers from the segment cache, fetching through it on a miss, and reports whether it did.
+// An upstream answer the cache does not keep comes back to the caller to proxy as it is, so does an upstream error.
+// Only when the cache itself failed are resp and err both nil, the caller then fetches the segment on its own.
+func (pr *ProxyHandler) serveSegment(c *fiber.Ctx, client *http.Client, req *http.Request, target string) (*http.Response, bool, error) {
+	var (
+		passthrough  *http.Response
+		upstream_err error
+	)
+	item, status, err := pr.Segments.Fetch(target, func(w io.Writer) (string, error) {
+		resp, err := client.Do(req)
+		if err != nil {
+			upstream_err = err
+			return "", err
+		}
+
+		content_type := resp.Header.Get("Content-Type")
+		if resp.StatusCode != http.StatusOK || hls.IsPlaylist(content_type, "") || resp.ContentLength > pr.Segments.MaxItemBytes {
+			passthrough = resp
+			return "", segcache.ErrNotCacheable
+		}
+		defer resp.Body.Close()
+
+		body := &readTracker{r: resp.Body}
+		if _, err := io.Copy(w, body); err != nil {
+			if body.err != nil {
+				upstream_err = body.err
+			}
+			return "", err
+		}
+		return content_type, nil
+	})
+	if passthrough != nil {
+		return passthrough, false, nil
+	}
+	if upstream_err != nil {
+		return nil, false, upstream_err
+	}
+	if err != nil {
+		log.Println("Segment not cached:", err)
+		return nil, false, nil
+	}
+
+	// evicted between the lookup and here
+	file, err := os.Open(item.Path)
+	if err != nil {
+		return nil, false, nil
+	}
+
+	c.Set("Access-Control-Allow-Origin", "*")
+	c.Set("Access-Control-Allow-Headers", "*")
+	c.Set("Access-Control-Allow-Methods", "*")
+	c.Set("Content-Type", item.ContentType)
+	c.Set("X-Cache", status)
+	return nil, true, c.Status(http.StatusOK).SendStream(file, int(item.Size))
+}
+
+// readTracker keeps the error reading upstream failed with, telling it apart from the cache failing to write
+type readTracker struct {
+	r   io.Reader
+	err error
+}
+
+func (t *readTracker) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if err != nil && err != io.EOF {
+		t.err = err
+	}
+	return n, err
+}
+
+// CacheStats shows admins how the segment cache is doing
+func (pr *ProxyHandler) CacheStats(c *fiber.Ctx) error {
+	stats := segcache.Stats{}
+	if pr.Segments != nil {
+		stats = pr.Segments.Stats()
+	}
+
+	return c.Status(http.StatusOK).JSON(
+		response.NewResponse(
+			utils.Translate("segment_cache_stats_success", nil, c),
+			2602,
+			stats,
+		),
+	)
+}
+
+// proxyURL maps an upstream url onto the mounted m3u8 route, in the host/path form M3u8 reads back,
+// signed for user_uuid and tagged with the provider of the playlist it was found in
+func (pr *ProxyHandler) proxyURL(user_uuid string, provider string) func(*url.URL) string {
+	return func(u *url.URL) string {
+		rel := "/m3u8/" + u.Host + u.EscapedPath()
+		if u.RawQuery != "" {
+			rel += "?" + u.RawQuery
+		}
+		return pr.Mount + pr.Signer.Sign(proxyurl.WithProvider(rel, provider), user_uuid)
+	}
+}
+
 func (pr *ProxyHandler) Mp4(c *fiber.Ctx) error {
 	pageURL := c.Query("url")
 	if pageURL == "" {
 		return c.Status(fiber.StatusBadRequest).SendString("Missing url query")
 	}
 
+	provider := requestProvider(c)
+	if _, err := pr.Guard.CheckURL(c.Context(), provider, pageURL); err != nil {
+		return blocked(c, pageURL, err)
+	}
+
 	clientIP := c.IP()
-	// fmt.Println("CACHE URL:", mediaCache)
 	log.Println("Starting browser-proxy for:", pageURL, "from", clientIP)
 
-	var mediaURL string
+	var (
+		mediaURL string
+		cached   bool
+		retried  bool
+	)
 
 tryFetch:
 	// --- Step 1: Check media cache ---
-	if val, ok := mediaCache.Load(pageURL); ok {
-		mediaURL = val.(string)
+	if mediaURL, cached = pr.Media.Get(pageURL); cached {
 		log.Println("[CACHE HIT]", mediaURL)
 	} else {
 		// --- Step 2: Use Rod to discover media URL ---
-		l := launcher.New().Headless(true).NoSandbox(true).MustLaunch()
-		browser := rod.New().ControlURL(l).MustConnect()
-		page := browser.MustPage()
-		defer page.MustClose()
-
-		_ = proto.NetworkEnable{}.Call(page)
-
-		done := make(chan string, 1)
-		var once sync.Once
-		requestMap := sync.Map{}
-
-		go page.EachEvent(func(e *proto.NetworkRequestWillBeSent) {
-			requestMap.Store(e.RequestID, e.Request.URL)
-		})()
-
-		go page.EachEvent(func(e *proto.NetworkLoadingFinished) {
-			v, ok := requestMap.Load(e.RequestID)
-			if !ok {
-				return
-			}
-			url := v.(string)
-			if strings.Contains(url, ".mp4") || strings.Contains(url, ".m3u8") || strings.Contains(url, ".ts") {
-				log.Println(">>>> FOUND MEDIA URL >>>", url)
-				once.Do(func() { done <- url })
-			}
-		})()
-
-		page.MustNavigate(pageURL)
-		page.WaitLoad()
-
-		select {
-		case mediaURL = <-done:
-			mediaCache.Store(pageURL, mediaURL)
-		case <-time.After(30 * time.Second):
+		found, err := pr.discoverMedia(pageURL)
+		if err != nil {
+			log.Println("Media discovery failed:", err)
 			return c.Status(fiber.StatusGatewayTimeout).SendString("Timeout: no media found")
 		}
+		// the page decides where the media lives, so check it like a requested url
+		if _, err := pr.Guard.CheckURL(c.Context(), provider, found); err != nil {
+			return blocked(c, found, err)
+		}
+		mediaURL = found
+		pr.Media.Set(pageURL, mediaURL, pr.Config.MediaCacheTTL)
 	}
 
 	// --- Step 3: Build request to real media server ---
@@ -265,7 +380,7 @@ tryFetch:
 
 	if rangeHeader == "" {
 		// no range => start adaptive from 0
-		chunkSize := adaptiveChunkSize(clientIP, mediaURL)
+		chunkSize := pr.adaptiveChunkSize(clientIP, provider, mediaURL)
 		rangeHeader = fmt.Sprintf("bytes=0-%d", chunkSize-1)
 		log.Printf("[ADAPTIVE RANGE] %s -> %d MB", clientIP, chunkSize/(1024*1024))
 	} else {
@@ -290,34 +405,30 @@ tryFetch:
 	req.Header.Set("Accept-Encoding", "identity")
 	req.Header.Set("Range", rangeHeader)
 
-	// at package level (create once, reuse)
-	var mediaTransport = &http.Transport{
-		MaxIdleConns:       100,
-		MaxConnsPerHost:    100,
-		IdleConnTimeout:    90 * time.Second,
-		DisableCompression: true,
-		ForceAttemptHTTP2:  false, // prefer HTTP/1.1; but TLSNextProto override below is more reliable
-		// Disable HTTP/2 completely:
-		TLSNextProto: make(map[string]func(authority string, c *tls.Conn) http.RoundTripper),
-	}
-
-	var mediaClient = &http.Client{
-		Transport: mediaTransport,
-		Timeout:   0, // long-lived streaming
-	}
+	// long-lived streaming, the guard's shared transport reuses connections across requests
+	mediaClient := pr.Guard.Client(provider, 0)
 
 	resp, err := mediaClient.Do(req)
+	if netguard.Blocked(err) {
+		pr.Media.Delete(pageURL)
+		return blocked(c, mediaURL, err)
+	}
 	if err != nil || resp.StatusCode >= 400 {
-		log.Println("Failed proxy request:", err, "status:", resp.StatusCode)
-
-		// --- Step 3.1: Invalidate cache and retry once ---
-		if _, ok := mediaCache.Load(pageURL); ok {
-			log.Println("[CACHE INVALID] Removing old cache and retrying...")
-			mediaCache.Delete(pageURL)
-			if resp != nil {
-				resp.Body.Close()
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+			resp.Body.Close()
+		}
+		log.Println("Failed proxy request:", err, "status:", status)
+
+		// --- Step 3.1: An expired media url is dropped for every instance, rediscover it once ---
+		if err != nil || mediaGone(status) {
+			pr.Media.Delete(pageURL)
+			if cached && !retried {
+				log.Println("[CACHE INVALID] Removing old cache and retrying...")
+				retried = true
+				goto tryFetch
 			}
-			goto tryFetch
 		}
 		return c.Status(fiber.StatusBadGateway).SendString("Failed to fetch media")
 	}
@@ -392,11 +503,15 @@ func (pr *ProxyHandler) Subtitle(c *fiber.Ctx) error {
 	}
 
 	// --- Preserve Query Parameters ---
-	if q := c.Context().QueryArgs().String(); q != "" {
+	if q := proxyurl.UpstreamQuery(c.Context().QueryArgs().String()); q != "" {
 		target += "?" + q
 	}
 
+	provider := requestProvider(c)
 	log.Println("📡 Fetching subtitle from:", target)
+	if _, err := pr.Guard.CheckURL(c.Context(), provider, target); err != nil {
+		return blocked(c, target, err)
+	}
 
 	// --- Build Request ---
 	req, err := http.NewRequest("GET", target, nil)
@@ -408,8 +523,11 @@ func (pr *ProxyHandler) Subtitle(c *fiber.Ctx) error {
 	req.Header.Set("Referer", "https://kisskh.co")
 	req.Header.Set("Origin", "https://kisskh.co")
 
-	client := &http.Client{Timeout: 20 * time.Second}
+	client := pr.Guard.Client(provider, 20*time.Second)
 	resp, err := client.Do(req)
+	if netguard.Blocked(err) {
+		return blocked(c, target, err)
+	}
 	if err != nil {
 		log.Println("❌ Error fetching subtitle:", err)
 		return c.Status(500).SendString(err.Error())
@@ -449,56 +567,32 @@ func (pr *ProxyHandler) Download(c *fiber.Ctx) error {
 	}
 
 	log.Println("Starting download for:", pageURL)
+	provider := requestProvider(c)
+	if _, err := pr.Guard.CheckURL(c.Context(), provider, pageURL); err != nil {
+		return blocked(c, pageURL, err)
+	}
 
 	// --- Step 1: Check cache ---
-	val, ok := mediaCache.Load(pageURL)
-	var mediaURL string
+	mediaURL, ok := pr.Media.Get(pageURL)
 	if ok {
-		mediaURL = val.(string)
 		log.Println("[CACHE HIT]", mediaURL)
 	} else {
 		// --- Step 2: Use Rod to discover media URL ---
-		l := launcher.New().Headless(true).NoSandbox(true).MustLaunch()
-		browser := rod.New().ControlURL(l).MustConnect()
-		page := browser.MustPage()
-		defer page.MustClose()
-
-		_ = proto.NetworkEnable{}.Call(page)
-
-		done := make(chan string, 1)
-		var once sync.Once
-		requestMap := sync.Map{}
-
-		go page.EachEvent(func(e *proto.NetworkRequestWillBeSent) {
-			requestMap.Store(e.RequestID, e.Request.URL)
-		})()
-
-		go page.EachEvent(func(e *proto.NetworkLoadingFinished) {
-			v, ok := requestMap.Load(e.RequestID)
-			if !ok {
-				return
-			}
-			url := v.(string)
-
-			if strings.Contains(url, ".mp4") || strings.Contains(url, ".m3u8") || strings.Contains(url, ".ts") {
-				log.Println(">>>> FOUND MEDIA URL >>>", url)
-				once.Do(func() { done <- url })
-			}
-		})()
-
-		page.MustNavigate(pageURL)
-		page.WaitLoad()
-
-		select {
-		case mediaURL = <-done:
-			mediaCache.Store(pageURL, mediaURL) // cache
-		case <-time.After(30 * time.Second):
+		found, err := pr.discoverMedia(pageURL)
+		if err != nil {
+			log.Println("Media discovery failed:", err)
 			return c.Status(fiber.StatusGatewayTimeout).SendString("Timeout: no media found")
 		}
+		if _, err := pr.Guard.CheckURL(c.Context(), provider, found); err != nil {
+			return blocked(c, found, err)
+		}
+		mediaURL = found
+		pr.Media.Set(pageURL, mediaURL, pr.Config.MediaCacheTTL)
 	}
 
+	// the request context is recycled once the handler returns, read what the download needs first
+	rangeHeader := c.Get("Range")
 	go func() {
-		rangeHeader := c.Get("Range")
 		req, _ := http.NewRequest("GET", mediaURL, nil)
 		req.Header.Set("User-Agent", "Mozilla/5.0")
 		req.Header.Set("Referer", "https://kisskh.co/")
@@ -507,12 +601,17 @@ func (pr *ProxyHandler) Download(c *fiber.Ctx) error {
 		req.Header.Set("Accept-Encoding", "identity")
 		req.Header.Set("Range", rangeHeader)
 
-		resp, err := http.DefaultClient.Do(req)
+		resp, err := pr.Guard.Client(provider, 0).Do(req)
 		if err != nil {
 			log.Println("Download failed:", err)
 			return
 		}
 		defer resp.Body.Close()
+		if mediaGone(resp.StatusCode) {
+			log.Println("Download failed, media url is gone:", resp.StatusCode)
+			pr.Media.Delete(pageURL)
+			return
+		}
 
 		file, _ := os.Create("video.mp4")
 		defer file.Close()
@@ -547,12 +646,75 @@ func (pr *ProxyHandler) Download(c *fiber.Ctx) error {
 	return c.SendString("Download started in background.")
 }
 
-func adaptiveChunkSize(clientIP, mediaURL string) int64 {
+// discoverMedia opens the page on a pooled browser and returns the first media request it finishes loading,
+// callers check pageURL and the media found with the guard. Every request the page makes is held until
+// the guard has checked it too, pages pull scripts from third parties so only private addresses are refused there.
+func (pr *ProxyHandler) discoverMedia(pageURL string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var mediaURL string
+	err := pr.BrowserPool.WithPage(ctx, func(page *rod.Page) error {
+		router := page.HijackRequests()
+		defer router.Stop()
+		err := router.Add("*", "", func(h *rod.Hijack) {
+			target := h.Request.URL().String()
+			if _, err := pr.Guard.CheckPublicURL(ctx, target); err != nil {
+				custom_log.NewCustomLog("proxy_upstream_blocked", fmt.Sprintf("browser request %s on %s: %s", target, pageURL, err.Error()), "warn")
+				h.Response.Fail(proto.NetworkErrorReasonBlockedByClient)
+				return
+			}
+			h.ContinueRequest(&proto.FetchContinueRequest{})
+		})
+		if err != nil {
+			return err
+		}
+		go router.Run()
+
+		_ = proto.NetworkEnable{}.Call(page)
+
+		done := make(chan string, 1)
+		var once sync.Once
+		requestMap := sync.Map{}
+
+		go page.EachEvent(func(e *proto.NetworkRequestWillBeSent) {
+			requestMap.Store(e.RequestID, e.Request.URL)
+		})()
+
+		go page.EachEvent(func(e *proto.NetworkLoadingFinished) {
+			v, ok := requestMap.Load(e.RequestID)
+			if !ok {
+				return
+			}
+			url := v.(string)
+			if strings.Contains(url, ".mp4") || strings.Contains(url, ".m3u8") || strings.Contains(url, ".ts") {
+				log.Println(">>>> FOUND MEDIA URL >>>", url)
+				once.Do(func() { done <- url })
+			}
+		})()
+
+		page.MustNavigate(pageURL)
+		page.WaitLoad()
+
+		select {
+		case mediaURL = <-done:
+			return nil
+		case <-ctx.Done():
+			return fmt.Errorf("no media found on %s", pageURL)
+		}
+	})
+	return mediaURL, err
+}
+
+// adaptiveChunkSize determines the optimal chunk size based on network speed.
+// It caches the result per client IP.
+func (pr *ProxyHandler) adaptiveChunkSize(clientIP, provider, mediaURL string) int64 {
 	// --- Step 1: Check speed cache ---
-	if val, ok := speedCache.Load(clientIP); ok {
-		chunkSize := val.(int64)
-		log.Printf("[SPEED CACHE HIT] IP=%s -> %d MB chunk", clientIP, chunkSize/(1024*1024))
-		return chunkSize
+	if val, ok := pr.Speed.Get(clientIP); ok {
+		if chunkSize, err := strconv.ParseInt(val, 10, 64); err == nil {
+			log.Printf("[SPEED CACHE HIT] IP=%s -> %d MB chunk", clientIP, chunkSize/(1024*1024))
+			return chunkSize
+		}
 	}
 
 	// --- Step 2: Test network speed with 1MB ---
@@ -566,12 +728,16 @@ func adaptiveChunkSize(clientIP, mediaURL string) int64 {
 	req.Header.Set("Referer", "https://kisskh.co/")
 
 	start := time.Now()
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil || resp.StatusCode >= 400 {
+	resp, err := pr.Guard.Client(provider, 0).Do(req)
+	if err != nil {
 		log.Printf("[SPEED TEST FAIL] Using default 4MB chunk for %s", clientIP)
 		return 4 * 1024 * 1024
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		log.Printf("[SPEED TEST FAIL] Using default 4MB chunk for %s", clientIP)
+		return 4 * 1024 * 1024
+	}
 
 	var totalRead int64
 	buf := make([]byte, 64*1024)
@@ -603,7 +769,7 @@ func adaptiveChunkSize(clientIP, mediaURL string) int64 {
 		chunkSize = 16 * 1024 * 1024
 	}
 
-	speedCache.Store(clientIP, chunkSize)
+	pr.Speed.Set(clientIP, strconv.FormatInt(chunkSize, 10), pr.Config.SpeedCacheTTL)
 	log.Printf("[SPEED CACHED] %s -> %d MB chunk", clientIP, chunkSize/(1024*1024))
 	return chunkSize
 }
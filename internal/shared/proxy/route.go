@@ -1,6 +1,11 @@
 package proxy
 
 import (
+	"rerng_addicted_api/configs"
+	"rerng_addicted_api/pkg/browser"
+	"rerng_addicted_api/pkg/middlewares"
+	"rerng_addicted_api/pkg/proxyurl"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/jmoiron/sqlx"
 )
@@ -11,24 +16,28 @@ type ProxyRoute struct {
 	ProxyHandler *ProxyHandler
 }
 
-func NewRoute(app *fiber.App, db_pool *sqlx.DB) *ProxyRoute {
+func NewRoute(app *fiber.App, db_pool *sqlx.DB, browser_pool *browser.Pool, deps ProxyDeps, config *configs.ProxyConfig) *ProxyRoute {
 	return &ProxyRoute{
 		App:          app,
 		DBPool:       db_pool,
-		ProxyHandler: NewProxyHandler(db_pool),
+		ProxyHandler: NewProxyHandler(db_pool, browser_pool, deps, config, proxyurl.Mount),
 	}
 }
 
 func (pr *ProxyRoute) RegisterProxyRoute() *ProxyRoute {
-	proxy := pr.App.Group("/api/v1/admin/proxy")
+	proxy := pr.App.Group(proxyurl.Mount)
+	// players cannot send a jwt with every segment, so the url itself carries the permission
+	signed := middlewares.NewSignedURLMiddleware(pr.ProxyHandler.Signer, proxyurl.Mount)
+
+	proxy.Get("/m3u8/*", signed, pr.ProxyHandler.M3u8)
 
-	proxy.Get("/m3u8/*", pr.ProxyHandler.M3u8)
+	proxy.Get("/mp4", signed, pr.ProxyHandler.Mp4)
 
-	proxy.Get("/mp4", pr.ProxyHandler.Mp4)
+	proxy.Get("/subtitle/*", signed, pr.ProxyHandler.Subtitle)
 
-	proxy.Get("/subtitle/*", pr.ProxyHandler.Subtitle)
+	proxy.Get("/download", signed, pr.ProxyHandler.Download)
 
-	proxy.Get("/download", pr.ProxyHandler.Download)
+	proxy.Get("/cache", middlewares.NewJwtMiddleware(pr.DBPool), pr.ProxyHandler.CacheStats)
 
 	return pr
 }
@@ -5,6 +5,7 @@ import (
 	"rerng_addicted_api/configs"
 	"rerng_addicted_api/db/postgresql"
 	"rerng_addicted_api/handler"
+	"rerng_addicted_api/pkg/browser"
 	"rerng_addicted_api/pkg/logs"
 	"rerng_addicted_api/pkg/redis"
 	"rerng_addicted_api/pkg/swagger"
@@ -31,8 +32,12 @@ func main() {
 		fmt.Println("Error connect database : ", err)
 	}
 
-	// init redis
-	_ = redis.NewRedis()
+	// init redis, everything that uses it runs without it when it is not configured
+	_ = redis.NewOptionalRedis()
+
+	// shared headless browser pool for scraping and proxy discovery
+	browser_pool := browser.NewPool()
+	defer browser_pool.Close()
 
 	// init go fiber framework, cors and handler configuration
 	apps := router.New()
@@ -41,7 +46,12 @@ func main() {
 	swagger.Setup(apps, app_configs.AppHost, app_configs.AppPort)
 
 	// init router
-	handler.NewServiceHandlers(apps, pool)
+	services := handler.NewServiceHandlers(apps, pool, browser_pool)
+
+	// let in-flight scrape jobs finish before the browser pool goes away
+	defer services.Admin.JobRoute.Worker.Stop()
+	defer services.Admin.JobRoute.Scheduler.Stop()
+	defer services.Admin.SourceRoute.Monitor.Stop()
 
 	// http server
 	err = apps.Listen(fmt.Sprintf("%s:%d", app_configs.AppHost, app_configs.AppPort))
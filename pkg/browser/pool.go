@@ -0,0 +1,511 @@
+package browser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"rerng_addicted_api/configs"
+	custom_log "rerng_addicted_api/pkg/logs"
+	"sync"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/launcher"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+var (
+	ErrPoolClosed  = errors.New("browser pool closed")
+	ErrPoolTimeout = errors.New("timed out waiting for a browser page")
+)
+
+var (
+	once sync.Once
+	pool *Pool
+)
+
+// how long a health ping may take before the browser counts as unresponsive
+const ping_timeout = 5 * time.Second
+
+// instance is one launched chrome process
+type instance struct {
+	id        int
+	launcher  *launcher.Launcher
+	browser   *rod.Browser
+	active    int
+	dead      bool
+	launched  time.Time
+	last_used time.Time
+}
+
+// Pool shares a bounded set of headless browsers between every go-rod user in the process.
+// Callers borrow an isolated (incognito) page, and must give it back with Release.
+type Pool struct {
+	config *configs.BrowserConfig
+	slots  chan struct{}
+	stop   chan struct{}
+
+	mu        sync.Mutex
+	instances []*instance
+	next_id   int
+	closed    bool
+	// launching counts browsers being started outside mu, changed is signalled when one finishes
+	launching int
+	changed   *sync.Cond
+
+	launches      int
+	crashes       int
+	recycled      int
+	last_error    string
+	last_error_at *time.Time
+}
+
+type Lease struct {
+	Page      *rod.Page
+	pool      *Pool
+	inst      *instance
+	incognito *rod.Browser
+	released  bool
+}
+
+type InstanceHealth struct {
+	ID          int       `json:"id"`
+	ActivePages int       `json:"active_pages"`
+	Alive       bool      `json:"alive"`
+	LaunchedAt  time.Time `json:"launched_at"`
+	LastUsedAt  time.Time `json:"last_used_at"`
+}
+
+type Health struct {
+	Browsers           []InstanceHealth `json:"browsers"`
+	MaxBrowsers        int              `json:"max_browsers"`
+	MaxPagesPerBrowser int              `json:"max_pages_per_browser"`
+	PagesInUse         int              `json:"pages_in_use"`
+	Capacity           int              `json:"capacity"`
+	Launches           int              `json:"launches"`
+	Crashes            int              `json:"crashes"`
+	Recycled           int              `json:"recycled"`
+	LastError          string           `json:"last_error"`
+	LastErrorAt        *time.Time       `json:"last_error_at"`
+}
+
+// NewPool returns the process-wide pool, browsers are launched lazily on first use
+func NewPool() *Pool {
+	once.Do(func() {
+		pool = newPool(configs.Browser())
+	})
+	return pool
+}
+
+func newPool(config *configs.BrowserConfig) *Pool {
+	if config.BrowserBin == "" {
+		if path, ok := launcher.LookPath(); ok {
+			config.BrowserBin = path
+		}
+	}
+
+	p := &Pool{
+		config: config,
+		slots:  make(chan struct{}, config.MaxBrowsers*config.MaxPagesPerBrowser),
+		stop:   make(chan struct{}),
+	}
+	p.changed = sync.NewCond(&p.mu)
+	go p.janitor()
+
+	return p
+}
+
+// Acquire borrows a fresh incognito page, blocking until one is free or ctx is done
+func (p *Pool) Acquire(ctx context.Context) (*Lease, error) {
+	wait_ctx, cancel := context.WithTimeout(ctx, p.config.AcquireTimeout)
+	defer cancel()
+
+	select {
+	case p.slots <- struct{}{}:
+	case <-p.stop:
+		return nil, ErrPoolClosed
+	case <-wait_ctx.Done():
+		return nil, ErrPoolTimeout
+	}
+
+	inst, err := p.pick()
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+
+	lease := &Lease{pool: p, inst: inst}
+	if err := lease.open(); err != nil {
+		p.checkAlive(inst, err)
+		lease.Release()
+		return nil, err
+	}
+	return lease, nil
+}
+
+// WithPage runs fn on a borrowed page and converts any Must* panic into an error
+func (p *Pool) WithPage(ctx context.Context, fn func(page *rod.Page) error) error {
+	lease, err := p.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer lease.Release()
+
+	var fn_err error
+	if try_err := rod.Try(func() {
+		fn_err = fn(lease.Page.Context(ctx))
+	}); try_err != nil {
+		fn_err = try_err
+	}
+
+	if fn_err != nil {
+		p.checkAlive(lease.inst, fn_err)
+	}
+	return fn_err
+}
+
+// Renew swaps the leased page for a brand new one in the same browser, used between retries
+func (l *Lease) Renew() error {
+	l.close()
+	if err := l.open(); err != nil {
+		l.pool.checkAlive(l.inst, err)
+		return err
+	}
+	return nil
+}
+
+func (l *Lease) Release() {
+	if l.released {
+		return
+	}
+	l.released = true
+	l.close()
+
+	p := l.pool
+	p.mu.Lock()
+	l.inst.active--
+	l.inst.last_used = time.Now()
+	retire := l.inst.dead && l.inst.active == 0
+	if retire {
+		p.removeLocked(l.inst)
+	}
+	p.mu.Unlock()
+
+	if retire {
+		p.kill(l.inst)
+	}
+	<-p.slots
+}
+
+func (l *Lease) open() error {
+	incognito, err := l.inst.browser.Incognito()
+	if err != nil {
+		return fmt.Errorf("open incognito context: %w", err)
+	}
+
+	page, err := incognito.Page(proto.TargetCreateTarget{})
+	if err != nil {
+		_ = incognito.Close()
+		return fmt.Errorf("open page: %w", err)
+	}
+
+	l.incognito = incognito
+	l.Page = page
+	return nil
+}
+
+func (l *Lease) close() {
+	if l.Page != nil {
+		_ = l.Page.Close()
+		l.Page = nil
+	}
+	if l.incognito != nil {
+		_ = l.incognito.Close()
+		l.incognito = nil
+	}
+}
+
+func (p *Pool) Health() Health {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	health := Health{
+		Browsers:           []InstanceHealth{},
+		MaxBrowsers:        p.config.MaxBrowsers,
+		MaxPagesPerBrowser: p.config.MaxPagesPerBrowser,
+		Capacity:           cap(p.slots),
+		Launches:           p.launches,
+		Crashes:            p.crashes,
+		Recycled:           p.recycled,
+		LastError:          p.last_error,
+		LastErrorAt:        p.last_error_at,
+	}
+
+	for _, inst := range p.instances {
+		health.PagesInUse += inst.active
+		health.Browsers = append(health.Browsers, InstanceHealth{
+			ID:          inst.id,
+			ActivePages: inst.active,
+			Alive:       !inst.dead,
+			LaunchedAt:  inst.launched,
+			LastUsedAt:  inst.last_used,
+		})
+	}
+	return health
+}
+
+// Close kills every browser, pending and future Acquire calls fail with ErrPoolClosed
+func (p *Pool) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.stop)
+
+	instances := p.instances
+	p.instances = nil
+	p.changed.Broadcast()
+	p.mu.Unlock()
+
+	// a browser still launching is killed by startLocked once it sees closed
+	for _, inst := range instances {
+		p.kill(inst)
+	}
+}
+
+// pick reserves a page on the least busy live browser, launching a new one while under MaxBrowsers
+func (p *Pool) pick() (*instance, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for {
+		if p.closed {
+			return nil, ErrPoolClosed
+		}
+
+		best, live := p.leastBusyLocked()
+		// prefer spreading load over a new browser when one is still allowed,
+		// browsers still launching count so concurrent picks don't overshoot MaxBrowsers
+		if (best == nil || best.active > 0) && live+p.launching < p.config.MaxBrowsers {
+			inst, err := p.startLocked()
+			switch {
+			case err == nil:
+				best = inst
+			case errors.Is(err, ErrPoolClosed):
+				return nil, err
+			default:
+				// pages may have been released while chrome was starting
+				if best, _ = p.leastBusyLocked(); best == nil {
+					return nil, err
+				}
+			}
+		}
+
+		if best == nil && p.launching > 0 {
+			// another caller's browser will have room once it is up
+			p.changed.Wait()
+			continue
+		}
+		if best == nil {
+			return nil, ErrPoolTimeout
+		}
+
+		best.active++
+		best.last_used = time.Now()
+		return best, nil
+	}
+}
+
+// leastBusyLocked returns the live browser with the fewest pages that still has room, and the live count
+func (p *Pool) leastBusyLocked() (*instance, int) {
+	var best *instance
+	live := 0
+	for _, inst := range p.instances {
+		if inst.dead {
+			continue
+		}
+		live++
+		if inst.active < p.config.MaxPagesPerBrowser && (best == nil || inst.active < best.active) {
+			best = inst
+		}
+	}
+	return best, live
+}
+
+// startLocked launches a browser with mu released so other callers aren't stuck behind chrome's startup,
+// it is called and returns with mu held
+func (p *Pool) startLocked() (*instance, error) {
+	p.launching++
+	p.mu.Unlock()
+	inst, err := p.launch()
+	p.mu.Lock()
+	p.launching--
+	p.changed.Broadcast()
+
+	if err != nil {
+		p.recordErrorLocked(err)
+		return nil, err
+	}
+	if p.closed {
+		// Close has already run, nobody else will kill this one
+		p.mu.Unlock()
+		p.kill(inst)
+		p.mu.Lock()
+		return nil, ErrPoolClosed
+	}
+
+	p.next_id++
+	p.launches++
+	inst.id = p.next_id
+	p.instances = append(p.instances, inst)
+
+	custom_log.NewCustomLog("browser_launched", fmt.Sprintf("browser #%d started (%s)", inst.id, p.config.BrowserBin), "info")
+	return inst, nil
+}
+
+func (p *Pool) launch() (*instance, error) {
+	l := launcher.New().
+		Headless(true).
+		NoSandbox(true).
+		Set("disable-gpu").
+		Set("disable-sync").
+		Set("disable-background-networking").
+		Set("disable-default-apps")
+	if p.config.BrowserBin != "" {
+		l = l.Bin(p.config.BrowserBin)
+	}
+
+	control_url, err := l.Launch()
+	if err != nil {
+		return nil, fmt.Errorf("launch browser: %w", err)
+	}
+
+	b := rod.New().ControlURL(control_url)
+	if err := b.Connect(); err != nil {
+		l.Kill()
+		return nil, fmt.Errorf("connect browser: %w", err)
+	}
+
+	now := time.Now()
+	return &instance{
+		launcher:  l,
+		browser:   b,
+		launched:  now,
+		last_used: now,
+	}, nil
+}
+
+// checkAlive pings the browser after a failure, a dead process is retired once its pages are released
+func (p *Pool) checkAlive(inst *instance, cause error) {
+	if err := ping(inst); err != nil {
+		p.markDead(inst, cause)
+	}
+}
+
+// ping asks the browser for its version, a hung process fails after ping_timeout instead of blocking
+func ping(inst *instance) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ping_timeout)
+	defer cancel()
+
+	_, err := (proto.BrowserGetVersion{}).Call(inst.browser.Context(ctx))
+	return err
+}
+
+func (p *Pool) markDead(inst *instance, cause error) {
+	p.mu.Lock()
+	if inst.dead {
+		p.mu.Unlock()
+		return
+	}
+	inst.dead = true
+	p.crashes++
+	p.recordErrorLocked(fmt.Errorf("browser #%d crashed: %w", inst.id, cause))
+
+	retire := inst.active == 0
+	if retire {
+		p.removeLocked(inst)
+	}
+	p.mu.Unlock()
+
+	if retire {
+		p.kill(inst)
+	}
+}
+
+func (p *Pool) recordErrorLocked(err error) {
+	now := time.Now()
+	p.last_error = err.Error()
+	p.last_error_at = &now
+	custom_log.NewCustomLog("browser_pool_error", err.Error(), "error")
+}
+
+// removeLocked detaches a browser from the pool, the caller kills it once mu is released
+func (p *Pool) removeLocked(inst *instance) {
+	for i, it := range p.instances {
+		if it == inst {
+			p.instances = append(p.instances[:i], p.instances[i+1:]...)
+			break
+		}
+	}
+}
+
+// kill closes the browser process, it can block on a hung chrome so it never runs under mu
+func (p *Pool) kill(inst *instance) {
+	_ = inst.browser.Close()
+	inst.launcher.Kill()
+	inst.launcher.Cleanup()
+}
+
+// janitor recycles idle browsers and drops the ones that stopped answering
+func (p *Pool) janitor() {
+	interval := p.config.IdleTimeout / 2
+	if interval < 10*time.Second {
+		interval = 10 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+
+		p.mu.Lock()
+		idle := []*instance{}
+		for _, inst := range p.instances {
+			if inst.active == 0 {
+				idle = append(idle, inst)
+			}
+		}
+		p.mu.Unlock()
+
+		for _, inst := range idle {
+			ping_err := ping(inst)
+
+			p.mu.Lock()
+			retire := false
+			if inst.active == 0 && !p.closed {
+				if ping_err != nil {
+					p.crashes++
+					p.recordErrorLocked(fmt.Errorf("browser #%d unresponsive: %w", inst.id, ping_err))
+					retire = true
+				} else if time.Since(inst.last_used) > p.config.IdleTimeout {
+					p.recycled++
+					retire = true
+				}
+			}
+			if retire {
+				p.removeLocked(inst)
+			}
+			p.mu.Unlock()
+
+			if retire {
+				p.kill(inst)
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Package cache stores short lived string values with an expiry, in redis when the api has one so every
+// instance sees the same entries, in memory otherwise.
+package cache
+
+import (
+	"context"
+	"errors"
+	custom_log "rerng_addicted_api/pkg/logs"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Cache is best effort, a failing backend reads as a miss and writes are only logged
+type Cache interface {
+	Get(key string) (string, bool)
+	Set(key string, value string, ttl time.Duration)
+	Delete(key string)
+}
+
+// New returns a redis backed cache under prefix, or a memory one when client is nil
+func New(client *redis.Client, prefix string) Cache {
+	if client == nil {
+		return NewMemory()
+	}
+	return &Redis{Client: client, Prefix: prefix}
+}
+
+type Redis struct {
+	Client *redis.Client
+	Prefix string
+}
+
+func (r *Redis) Get(key string) (string, bool) {
+	value, err := r.Client.Get(context.Background(), r.Prefix+key).Result()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			custom_log.NewCustomLog("cache_get_failed", err.Error(), "warn")
+		}
+		return "", false
+	}
+	return value, true
+}
+
+func (r *Redis) Set(key string, value string, ttl time.Duration) {
+	if err := r.Client.Set(context.Background(), r.Prefix+key, value, ttl).Err(); err != nil {
+		custom_log.NewCustomLog("cache_set_failed", err.Error(), "warn")
+	}
+}
+
+func (r *Redis) Delete(key string) {
+	if err := r.Client.Del(context.Background(), r.Prefix+key).Err(); err != nil {
+		custom_log.NewCustomLog("cache_delete_failed", err.Error(), "warn")
+	}
+}
+
+type memory_item struct {
+	value      string
+	expires_at time.Time
+}
+
+// Memory keeps entries in process, expired ones are dropped on read and swept every so many writes
+type Memory struct {
+	mu     sync.Mutex
+	items  map[string]memory_item
+	writes int
+}
+
+// a sweep walks every entry, so it only runs once per this many writes
+const memory_sweep_every = 256
+
+func NewMemory() *Memory {
+	return &Memory{items: map[string]memory_item{}}
+}
+
+func (m *Memory) Get(key string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, ok := m.items[key]
+	if !ok {
+		return "", false
+	}
+	if time.Now().After(item.expires_at) {
+		delete(m.items, key)
+		return "", false
+	}
+	return item.value, true
+}
+
+func (m *Memory) Set(key string, value string, ttl time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.items[key] = memory_item{value: value, expires_at: time.Now().Add(ttl)}
+	m.writes++
+	if m.writes%memory_sweep_every == 0 {
+		now := time.Now()
+		for key, item := range m.items {
+			if now.After(item.expires_at) {
+				delete(m.items, key)
+			}
+		}
+	}
+}
+
+func (m *Memory) Delete(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.items, key)
+}
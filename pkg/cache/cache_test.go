@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestMemoryTTL(t *testing.T) {
+	tests := []struct {
+		name  string
+		ttl   time.Duration
+		wait  time.Duration
+		found bool
+	}{
+		{"fresh entry", time.Minute, 0, true},
+		{"expired entry", 10 * time.Millisecond, 30 * time.Millisecond, false},
+		{"zero ttl", 0, time.Millisecond, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memory := NewMemory()
+			memory.Set("page", "media", tt.ttl)
+			time.Sleep(tt.wait)
+
+			value, ok := memory.Get("page")
+			if ok != tt.found {
+				t.Fatalf("Get found = %v, want %v", ok, tt.found)
+			}
+			if ok && value != "media" {
+				t.Errorf("Get = %q, want media", value)
+			}
+			if !ok && len(memory.items) != 0 {
+				t.Error("an expired entry stayed after it was read")
+			}
+		})
+	}
+}
+
+func TestMemoryOverwriteAndDelete(t *testing.T) {
+	memory := NewMemory()
+	memory.Set("page", "old", time.Minute)
+	memory.Set("page", "new", time.Minute)
+	if value, _ := memory.Get("page"); value != "new" {
+		t.Errorf("Get after overwrite = %q, want new", value)
+	}
+
+	memory.Delete("page")
+	if _, ok := memory.Get("page"); ok {
+		t.Error("Get found a deleted entry")
+	}
+	// deleting what is not there is fine
+	memory.Delete("page")
+}
+
+func TestMemorySweep(t *testing.T) {
+	memory := NewMemory()
+	for i := 0; i < memory_sweep_every-1; i++ {
+		memory.Set("expired-"+strconv.Itoa(i), "v", time.Nanosecond)
+	}
+	time.Sleep(time.Millisecond)
+
+	// the write that completes a round sweeps what expired, without anyone reading it
+	memory.Set("kept", "v", time.Minute)
+	if len(memory.items) != 1 {
+		t.Errorf("%d entries after a sweep, want 1", len(memory.items))
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, ok := New(nil, "media:").(*Memory); !ok {
+		t.Error("New without a client is not a memory cache")
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1, DialTimeout: 100 * time.Millisecond})
+	defer client.Close()
+	shared, ok := New(client, "media:").(*Redis)
+	if !ok || shared.Prefix != "media:" {
+		t.Fatalf("New with a client = %#v, want a redis cache under media:", shared)
+	}
+
+	// an unreachable redis reads as a miss and writes do not fail the caller
+	shared.Set("page", "media", time.Minute)
+	if _, ok := shared.Get("page"); ok {
+		t.Error("Get from an unreachable redis found an entry")
+	}
+	shared.Delete("page")
+}
@@ -0,0 +1,144 @@
+// Package hls reads the parts of HLS playlists the scraper and proxy care about.
+package hls
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Variant is one EXT-X-STREAM-INF entry of a master playlist
+type Variant struct {
+	Bandwidth        int
+	AverageBandwidth int
+	Width            int
+	Height           int
+	Codecs           string
+	FrameRate        float64
+	// URI is resolved against the playlist url, so it is always absolute
+	URI string
+}
+
+type Master struct {
+	Variants []Variant
+}
+
+// ParseMaster reads a master playlist, base is the url it was fetched from and resolves relative variant uris.
+// A media playlist parses to a Master without variants.
+func ParseMaster(r io.Reader, base *url.URL) (*Master, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	master := &Master{Variants: []Variant{}}
+	var (
+		pending *Variant
+		first   = true
+	)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if first {
+			line = strings.TrimPrefix(line, "\ufeff")
+			if line != "#EXTM3U" {
+				return nil, fmt.Errorf("not an m3u8 playlist")
+			}
+			first = false
+			continue
+		}
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "#EXT-X-STREAM-INF:") {
+			variant := parseStreamInf(strings.TrimPrefix(line, "#EXT-X-STREAM-INF:"))
+			pending = &variant
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// the first uri line after a stream tag belongs to it
+		if pending != nil {
+			uri, err := resolve(base, line)
+			if err != nil {
+				return nil, err
+			}
+			pending.URI = uri
+			master.Variants = append(master.Variants, *pending)
+			pending = nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if first {
+		return nil, fmt.Errorf("empty playlist")
+	}
+	return master, nil
+}
+
+func parseStreamInf(attrs string) Variant {
+	var variant Variant
+	for key, value := range ParseAttributes(attrs) {
+		switch key {
+		case "BANDWIDTH":
+			variant.Bandwidth, _ = strconv.Atoi(value)
+		case "AVERAGE-BANDWIDTH":
+			variant.AverageBandwidth, _ = strconv.Atoi(value)
+		case "RESOLUTION":
+			if w, h, ok := strings.Cut(strings.ToLower(value), "x"); ok {
+				variant.Width, _ = strconv.Atoi(w)
+				variant.Height, _ = strconv.Atoi(h)
+			}
+		case "CODECS":
+			variant.Codecs = value
+		case "FRAME-RATE":
+			variant.FrameRate, _ = strconv.ParseFloat(value, 64)
+		}
+	}
+	return variant
+}
+
+// ParseAttributes splits an HLS attribute list, quoted values may contain commas and lose their quotes
+func ParseAttributes(attrs string) map[string]string {
+	values := map[string]string{}
+	for len(attrs) > 0 {
+		eq := strings.IndexByte(attrs, '=')
+		if eq < 0 {
+			break
+		}
+		key := strings.TrimSpace(attrs[:eq])
+		attrs = attrs[eq+1:]
+
+		var value string
+		if strings.HasPrefix(attrs, `"`) {
+			end := strings.IndexByte(attrs[1:], '"')
+			if end < 0 {
+				value, attrs = attrs[1:], ""
+			} else {
+				value, attrs = attrs[1:end+1], attrs[end+2:]
+			}
+			attrs = strings.TrimPrefix(attrs, ",")
+		} else if comma := strings.IndexByte(attrs, ','); comma >= 0 {
+			value, attrs = attrs[:comma], attrs[comma+1:]
+		} else {
+			value, attrs = attrs, ""
+		}
+		values[strings.ToUpper(key)] = strings.TrimSpace(value)
+	}
+	return values
+}
+
+func resolve(base *url.URL, ref string) (string, error) {
+	parsed, err := url.Parse(ref)
+	if err != nil {
+		return "", fmt.Errorf("invalid variant uri %q: %w", ref, err)
+	}
+	if base == nil {
+		return parsed.String(), nil
+	}
+	return base.ResolveReference(parsed).String(), nil
+}
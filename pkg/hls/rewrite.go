@@ -0,0 +1,124 @@
+package hls
+
+import (
+	"bufio"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// tags whose URI attribute points at another resource the player fetches
+var uri_tags = map[string]bool{
+	"#EXT-X-KEY":                true,
+	"#EXT-X-SESSION-KEY":        true,
+	"#EXT-X-MAP":                true,
+	"#EXT-X-MEDIA":              true,
+	"#EXT-X-I-FRAME-STREAM-INF": true,
+	"#EXT-X-SESSION-DATA":       true,
+	"#EXT-X-PART":               true,
+	"#EXT-X-PRELOAD-HINT":       true,
+	"#EXT-X-RENDITION-REPORT":   true,
+}
+
+// IsPlaylist tells from the content type or, failing that, the path whether a response is an m3u8 playlist
+func IsPlaylist(content_type string, path string) bool {
+	content_type = strings.ToLower(content_type)
+	if strings.Contains(content_type, "mpegurl") {
+		return true
+	}
+	return strings.HasSuffix(strings.ToLower(path), ".m3u8")
+}
+
+// Rewrite copies a master or media playlist, resolving every uri line and URI attribute against base
+// (RFC 3986) and replacing it with what rewrite returns for the absolute url.
+// Only http(s) uris are touched, data: and key system uris pass through as they are.
+// Byte ranges stay valid since a rewritten uri still names the same resource.
+func Rewrite(r io.Reader, w io.Writer, base *url.URL, rewrite func(absolute *url.URL) string) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	out := bufio.NewWriter(w)
+
+	first := true
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if first {
+			line = strings.TrimPrefix(line, "\ufeff")
+			first = false
+		}
+
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#"):
+			line = rewriteTag(line, base, rewrite)
+		default:
+			line = rewriteURI(line, base, rewrite)
+		}
+		if _, err := out.WriteString(line + "\n"); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+// rewriteTag swaps the URI attribute in place so the other attributes keep their order and quoting
+func rewriteTag(line string, base *url.URL, rewrite func(*url.URL) string) string {
+	name, attrs, ok := strings.Cut(line, ":")
+	if !ok || !uri_tags[name] {
+		return line
+	}
+
+	start, end, ok := attributeSpan(attrs, "URI")
+	if !ok {
+		return line
+	}
+	value := strings.Trim(attrs[start:end], `"`)
+	return name + ":" + attrs[:start] + `"` + rewriteURI(value, base, rewrite) + `"` + attrs[end:]
+}
+
+func rewriteURI(ref string, base *url.URL, rewrite func(*url.URL) string) string {
+	parsed, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	if base != nil {
+		parsed = base.ResolveReference(parsed)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return ref
+	}
+	return rewrite(parsed)
+}
+
+// attributeSpan finds the raw value of key in an attribute list, quotes included, the same way ParseAttributes splits it
+func attributeSpan(attrs string, key string) (int, int, bool) {
+	i := 0
+	for i < len(attrs) {
+		eq := strings.IndexByte(attrs[i:], '=')
+		if eq < 0 {
+			return 0, 0, false
+		}
+		name := strings.TrimSpace(attrs[i : i+eq])
+		start := i + eq + 1
+
+		end := len(attrs)
+		if start < len(attrs) && attrs[start] == '"' {
+			if quote := strings.IndexByte(attrs[start+1:], '"'); quote >= 0 {
+				end = start + quote + 2
+			}
+		} else if comma := strings.IndexByte(attrs[start:], ','); comma >= 0 {
+			end = start + comma
+		}
+
+		if strings.EqualFold(name, key) {
+			return start, end, true
+		}
+		i = end
+		if i < len(attrs) && attrs[i] == ',' {
+			i++
+		}
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,115 @@
+package hls
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// proxied marks a rewritten uri so the expectations read like the playlist a player gets
+func proxied(u *url.URL) string {
+	return "/proxy/" + u.String()
+}
+
+func TestRewrite(t *testing.T) {
+	base, _ := url.Parse("https://cdn.example.org/show/ep1/index.m3u8?token=abc")
+
+	tests := []struct {
+		name     string
+		playlist string
+		want     string
+	}{
+		{
+			name:     "relative segment",
+			playlist: "#EXTM3U\n#EXTINF:4.0,\nseg-1.ts\n",
+			want:     "#EXTM3U\n#EXTINF:4.0,\n/proxy/https://cdn.example.org/show/ep1/seg-1.ts\n",
+		},
+		{
+			name:     "root relative segment",
+			playlist: "#EXTM3U\n/media/seg-1.ts\n",
+			want:     "#EXTM3U\n/proxy/https://cdn.example.org/media/seg-1.ts\n",
+		},
+		{
+			name:     "parent relative segment keeps its query",
+			playlist: "#EXTM3U\n../720/seg-1.ts?sig=1\n",
+			want:     "#EXTM3U\n/proxy/https://cdn.example.org/show/720/seg-1.ts?sig=1\n",
+		},
+		{
+			name:     "absolute segment on another host",
+			playlist: "#EXTM3U\nhttps://edge.example.net/a/seg-1.ts\n",
+			want:     "#EXTM3U\n/proxy/https://edge.example.net/a/seg-1.ts\n",
+		},
+		{
+			name:     "scheme relative segment",
+			playlist: "#EXTM3U\n//edge.example.net/a/seg-1.ts\n",
+			want:     "#EXTM3U\n/proxy/https://edge.example.net/a/seg-1.ts\n",
+		},
+		{
+			name:     "byte order mark is dropped",
+			playlist: "\ufeff#EXTM3U\nseg-1.ts\n",
+			want:     "#EXTM3U\n/proxy/https://cdn.example.org/show/ep1/seg-1.ts\n",
+		},
+		{
+			name:     "crlf line endings and blank lines",
+			playlist: "#EXTM3U\r\n\r\nseg-1.ts\r\n",
+			want:     "#EXTM3U\n\n/proxy/https://cdn.example.org/show/ep1/seg-1.ts\n",
+		},
+		{
+			name:     "key uri keeps the other attributes in order",
+			playlist: "#EXTM3U\n#EXT-X-KEY:METHOD=AES-128,URI=\"key.bin\",IV=0x1234\n",
+			want:     "#EXTM3U\n#EXT-X-KEY:METHOD=AES-128,URI=\"/proxy/https://cdn.example.org/show/ep1/key.bin\",IV=0x1234\n",
+		},
+		{
+			name:     "map uri",
+			playlist: "#EXTM3U\n#EXT-X-MAP:URI=\"init.mp4\",BYTERANGE=\"720@0\"\n",
+			want:     "#EXTM3U\n#EXT-X-MAP:URI=\"/proxy/https://cdn.example.org/show/ep1/init.mp4\",BYTERANGE=\"720@0\"\n",
+		},
+		{
+			name:     "media uri after a quoted comma",
+			playlist: "#EXTM3U\n#EXT-X-MEDIA:TYPE=AUDIO,NAME=\"en,main\",URI=\"audio/en.m3u8\"\n",
+			want:     "#EXTM3U\n#EXT-X-MEDIA:TYPE=AUDIO,NAME=\"en,main\",URI=\"/proxy/https://cdn.example.org/show/ep1/audio/en.m3u8\"\n",
+		},
+		{
+			name:     "data and key system uris pass through",
+			playlist: "#EXTM3U\n#EXT-X-KEY:METHOD=SAMPLE-AES,URI=\"skd://key-id\"\n#EXT-X-SESSION-DATA:DATA-ID=\"x\",URI=\"data:text/plain,hi\"\n",
+			want:     "#EXTM3U\n#EXT-X-KEY:METHOD=SAMPLE-AES,URI=\"skd://key-id\"\n#EXT-X-SESSION-DATA:DATA-ID=\"x\",URI=\"data:text/plain,hi\"\n",
+		},
+		{
+			name:     "tags without uris are untouched",
+			playlist: "#EXTM3U\n#EXT-X-KEY:METHOD=NONE\n#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360\n360/index.m3u8\n",
+			want:     "#EXTM3U\n#EXT-X-KEY:METHOD=NONE\n#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360\n/proxy/https://cdn.example.org/show/ep1/360/index.m3u8\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			if err := Rewrite(strings.NewReader(tt.playlist), &out, base, proxied); err != nil {
+				t.Fatalf("Rewrite failed: %v", err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("Rewrite =\n%q\nwant\n%q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestIsPlaylist(t *testing.T) {
+	tests := []struct {
+		content_type string
+		path         string
+		want         bool
+	}{
+		{"application/vnd.apple.mpegurl", "", true},
+		{"Application/X-MPEGURL; charset=utf-8", "", true},
+		{"application/octet-stream", "show/index.M3U8", true},
+		{"video/mp2t", "show/seg-1.ts", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsPlaylist(tt.content_type, tt.path); got != tt.want {
+			t.Errorf("IsPlaylist(%q, %q) = %v, want %v", tt.content_type, tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package imagestore
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// fit modes for Resize
+const (
+	// FitCover fills the box and crops the overflow around the center
+	FitCover = "cover"
+	// FitContain scales to fit inside the box, the result may be smaller on one side
+	FitContain = "contain"
+	// FitFill stretches to exactly the box
+	FitFill = "fill"
+)
+
+// Size works out the scaled size before cropping and the final size for a source of src_w x src_h.
+// A zero width or height follows the source aspect ratio.
+func Size(src_w, src_h, width, height int, fit string) (scaled_w, scaled_h, out_w, out_h int) {
+	switch {
+	case width == 0 && height == 0:
+		return src_w, src_h, src_w, src_h
+	case width == 0:
+		width = max(1, int(math.Round(float64(src_w)*float64(height)/float64(src_h))))
+		return width, height, width, height
+	case height == 0:
+		height = max(1, int(math.Round(float64(src_h)*float64(width)/float64(src_w))))
+		return width, height, width, height
+	}
+
+	scale_w := float64(width) / float64(src_w)
+	scale_h := float64(height) / float64(src_h)
+	switch fit {
+	case FitFill:
+		return width, height, width, height
+	case FitContain:
+		scale := math.Min(scale_w, scale_h)
+		scaled_w = max(1, int(math.Round(float64(src_w)*scale)))
+		scaled_h = max(1, int(math.Round(float64(src_h)*scale)))
+		return scaled_w, scaled_h, scaled_w, scaled_h
+	default:
+		scale := math.Max(scale_w, scale_h)
+		scaled_w = max(width, int(math.Round(float64(src_w)*scale)))
+		scaled_h = max(height, int(math.Round(float64(src_h)*scale)))
+		return scaled_w, scaled_h, width, height
+	}
+}
+
+// Resize scales src into the width x height box following fit
+func Resize(src image.Image, width, height int, fit string) image.Image {
+	bounds := src.Bounds()
+	scaled_w, scaled_h, out_w, out_h := Size(bounds.Dx(), bounds.Dy(), width, height, fit)
+
+	scaled := scale(src, scaled_w, scaled_h)
+	if scaled_w == out_w && scaled_h == out_h {
+		return scaled
+	}
+
+	// center crop for cover
+	offset := image.Pt((scaled_w-out_w)/2, (scaled_h-out_h)/2)
+	out := image.NewRGBA(image.Rect(0, 0, out_w, out_h))
+	draw.Draw(out, out.Bounds(), scaled, offset, draw.Src)
+	return out
+}
+
+// scale resamples src to w x h with a separable Catmull-Rom filter, widened when shrinking so
+// every source pixel contributes and thumbnails do not alias
+func scale(src image.Image, w, h int) *image.RGBA {
+	bounds := src.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+	if bounds.Dx() == w && bounds.Dy() == h {
+		return rgba
+	}
+
+	// horizontal pass into a float buffer, then vertical pass into the output
+	src_w, src_h := bounds.Dx(), bounds.Dy()
+	x_weights := weights(src_w, w)
+	tmp := make([]float64, w*src_h*4)
+	for y := 0; y < src_h; y++ {
+		row := rgba.Pix[y*rgba.Stride:]
+		for x, contrib := range x_weights {
+			var r, g, b, a float64
+			for _, c := range contrib {
+				p := row[c.index*4:]
+				r += float64(p[0]) * c.weight
+				g += float64(p[1]) * c.weight
+				b += float64(p[2]) * c.weight
+				a += float64(p[3]) * c.weight
+			}
+			i := (y*w + x) * 4
+			tmp[i], tmp[i+1], tmp[i+2], tmp[i+3] = r, g, b, a
+		}
+	}
+
+	y_weights := weights(src_h, h)
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y, contrib := range y_weights {
+		for x := 0; x < w; x++ {
+			var r, g, b, a float64
+			for _, c := range contrib {
+				i := (c.index*w + x) * 4
+				r += tmp[i] * c.weight
+				g += tmp[i+1] * c.weight
+				b += tmp[i+2] * c.weight
+				a += tmp[i+3] * c.weight
+			}
+			o := out.PixOffset(x, y)
+			out.Pix[o] = clamp(r)
+			out.Pix[o+1] = clamp(g)
+			out.Pix[o+2] = clamp(b)
+			out.Pix[o+3] = clamp(a)
+		}
+	}
+	return out
+}
+
+type contribution struct {
+	index  int
+	weight float64
+}
+
+// weights lists, per output pixel, the source pixels it samples and their normalised weights
+func weights(src_size, dst_size int) [][]contribution {
+	ratio := float64(src_size) / float64(dst_size)
+	support := 2.0
+	filter_scale := 1.0
+	if ratio > 1 {
+		support *= ratio
+		filter_scale = ratio
+	}
+
+	all := make([][]contribution, dst_size)
+	for i := range all {
+		center := (float64(i)+0.5)*ratio - 0.5
+		start := int(math.Floor(center - support))
+		end := int(math.Ceil(center + support))
+
+		contrib := make([]contribution, 0, end-start+1)
+		total := 0.0
+		for j := start; j <= end; j++ {
+			weight := catmullRom((float64(j) - center) / filter_scale)
+			if weight == 0 {
+				continue
+			}
+			index := min(max(j, 0), src_size-1)
+			contrib = append(contrib, contribution{index: index, weight: weight})
+			total += weight
+		}
+		if total != 0 {
+			for k := range contrib {
+				contrib[k].weight /= total
+			}
+		}
+		all[i] = contrib
+	}
+	return all
+}
+
+func catmullRom(x float64) float64 {
+	x = math.Abs(x)
+	switch {
+	case x < 1:
+		return 1.5*x*x*x - 2.5*x*x + 1
+	case x < 2:
+		return -0.5*x*x*x + 2.5*x*x - 4*x + 2
+	}
+	return 0
+}
+
+func clamp(v float64) uint8 {
+	switch {
+	case v <= 0:
+		return 0
+	case v >= 255:
+		return 255
+	}
+	return uint8(v + 0.5)
+}
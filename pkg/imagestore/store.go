@@ -0,0 +1,163 @@
+// Package imagestore mirrors remote images to local disk, addressed by the sha256 of their bytes.
+package imagestore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"rerng_addicted_api/configs"
+	"rerng_addicted_api/pkg/netguard"
+	"strings"
+)
+
+// extension per accepted image type, anything else is refused
+var extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// relative paths look like "ab/cd/<64 hex>.jpg"
+var path_pattern = regexp.MustCompile(`^[0-9a-f]{2}/[0-9a-f]{2}/[0-9a-f]{64}\.(jpg|png|gif|webp)$`)
+
+var (
+	ErrNotImage = errors.New("not a supported image")
+	ErrTooLarge = errors.New("image too large")
+	ErrNotFound = errors.New("image not found")
+)
+
+type Store struct {
+	Dir      string
+	MaxBytes int64
+	// Quality is the JPEG quality resized variants are encoded with
+	Quality int
+	// MaxPixels refuses to decode larger originals for resizing, zero means no limit
+	MaxPixels int64
+	Client    *http.Client
+}
+
+// NewStore fetches through guard, image urls come from scraped pages so they must not reach private addresses
+func NewStore(config *configs.ImageConfig, guard *netguard.Guard) *Store {
+	return &Store{
+		Dir:       config.StorageDir,
+		MaxBytes:  config.MaxBytes,
+		Quality:   config.JPEGQuality,
+		MaxPixels: config.MaxPixels,
+		Client:    guard.PublicClient(config.FetchTimeout),
+	}
+}
+
+// Mirror downloads image_url and returns its path relative to Dir, an image already on disk is not written again
+func (s *Store) Mirror(image_url string) (string, error) {
+	parsed, err := url.Parse(image_url)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return "", fmt.Errorf("invalid image url %q", image_url)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, image_url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0")
+	req.Header.Set("Accept", "image/*")
+	// the image host sees its own origin rather than ours
+	req.Header.Set("Referer", parsed.Scheme+"://"+parsed.Host+"/")
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed fetching %s: %w", image_url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed fetching %s: status %d", image_url, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, s.MaxBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("failed reading %s: %w", image_url, err)
+	}
+	if int64(len(body)) > s.MaxBytes {
+		return "", ErrTooLarge
+	}
+
+	// trust the bytes over the header, CDNs often answer application/octet-stream
+	ext, ok := extensions[http.DetectContentType(body)]
+	if !ok {
+		return "", ErrNotImage
+	}
+
+	return s.Put(body, ext)
+}
+
+// Put stores body under its content hash and returns the relative path
+func (s *Store) Put(body []byte, ext string) (string, error) {
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:])
+	rel := filepath.ToSlash(filepath.Join(hash[0:2], hash[2:4], hash+ext))
+	full := filepath.Join(s.Dir, filepath.FromSlash(rel))
+
+	if _, err := os.Stat(full); err == nil {
+		return rel, nil
+	}
+	if err := writeAtomic(full, body); err != nil {
+		return "", err
+	}
+	return rel, nil
+}
+
+// writeAtomic writes to a temp file first so readers never see a partial image
+func writeAtomic(full string, body []byte) error {
+	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(full), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, bytes.NewReader(body)); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), full); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// Open resolves a relative path from Mirror to a file on disk, rejecting anything that is not a stored image
+func (s *Store) Open(rel string) (string, error) {
+	if !path_pattern.MatchString(rel) {
+		return "", ErrNotFound
+	}
+	full := filepath.Join(s.Dir, filepath.FromSlash(rel))
+	if _, err := os.Stat(full); err != nil {
+		return "", ErrNotFound
+	}
+	return full, nil
+}
+
+// Extension returns the file extension of a stored path without the dot, e.g. "jpg"
+func Extension(rel string) string {
+	return strings.TrimPrefix(filepath.Ext(rel), ".")
+}
+
+// Hash returns the content hash a stored path is named after, handy as an ETag
+func Hash(rel string) string {
+	return strings.TrimSuffix(filepath.Base(rel), filepath.Ext(rel))
+}
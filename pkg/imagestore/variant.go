@@ -0,0 +1,137 @@
+package imagestore
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	// decoders for the stored originals, webp has no pure stdlib decoder
+	_ "image/gif"
+)
+
+// output formats a variant can be encoded to
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+)
+
+// ErrUnsupported means the stored original cannot be decoded for resizing
+var ErrUnsupported = errors.New("image format cannot be resized")
+
+// Variant describes a resized copy of a stored image
+type Variant struct {
+	Width  int
+	Height int
+	Fit    string
+	Format string
+}
+
+// Extension returns the file extension for the variant's format without the dot
+func (v Variant) Extension() string {
+	if v.Format == FormatPNG {
+		return "png"
+	}
+	return "jpg"
+}
+
+// Name is the variant's file name, it changes whenever the original or any parameter does
+func (v Variant) Name(rel string) string {
+	return fmt.Sprintf("%s_%dx%d_%s.%s", Hash(rel), v.Width, v.Height, v.Fit, v.Extension())
+}
+
+// variants being encoded right now, concurrent requests for the same one wait on the first
+var (
+	rendering_mu sync.Mutex
+	rendering    = map[string]*renderCall{}
+)
+
+type renderCall struct {
+	done chan struct{}
+	err  error
+}
+
+// Variant returns the path of the resized copy of rel, rendering and caching it under Dir/variants on first use
+func (s *Store) Variant(rel string, variant Variant) (string, error) {
+	original, err := s.Open(rel)
+	if err != nil {
+		return "", err
+	}
+
+	name := variant.Name(rel)
+	full := filepath.Join(s.Dir, "variants", name[0:2], name)
+	if _, err := os.Stat(full); err == nil {
+		return full, nil
+	}
+
+	rendering_mu.Lock()
+	if call, ok := rendering[full]; ok {
+		rendering_mu.Unlock()
+		<-call.done
+		return full, call.err
+	}
+	call := &renderCall{done: make(chan struct{})}
+	rendering[full] = call
+	rendering_mu.Unlock()
+
+	call.err = s.render(original, full, variant)
+
+	rendering_mu.Lock()
+	delete(rendering, full)
+	rendering_mu.Unlock()
+	close(call.done)
+
+	return full, call.err
+}
+
+func (s *Store) render(original, full string, variant Variant) error {
+	file, err := os.Open(original)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// the header alone tells how large the decoded image would be, a huge original is refused before it is decoded
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		if errors.Is(err, image.ErrFormat) {
+			return ErrUnsupported
+		}
+		return fmt.Errorf("failed decoding %s: %w", original, err)
+	}
+	if s.MaxPixels > 0 && int64(config.Width)*int64(config.Height) > s.MaxPixels {
+		return ErrTooLarge
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	src, _, err := image.Decode(file)
+	if err != nil {
+		if errors.Is(err, image.ErrFormat) {
+			return ErrUnsupported
+		}
+		return fmt.Errorf("failed decoding %s: %w", original, err)
+	}
+
+	resized := Resize(src, variant.Width, variant.Height, variant.Fit)
+
+	var body bytes.Buffer
+	switch variant.Format {
+	case FormatPNG:
+		err = png.Encode(&body, resized)
+	default:
+		err = jpeg.Encode(&body, resized, &jpeg.Options{Quality: s.Quality})
+	}
+	if err != nil {
+		return fmt.Errorf("failed encoding variant: %w", err)
+	}
+
+	return writeAtomic(full, body.Bytes())
+}
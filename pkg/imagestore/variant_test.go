@@ -0,0 +1,122 @@
+package imagestore
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSize(t *testing.T) {
+	tests := []struct {
+		name                             string
+		src_w, src_h, width, height      int
+		fit                              string
+		scaled_w, scaled_h, out_w, out_h int
+	}{
+		{"no box keeps the source", 400, 600, 0, 0, FitCover, 400, 600, 400, 600},
+		{"width only follows the aspect", 400, 600, 200, 0, FitCover, 200, 300, 200, 300},
+		{"height only follows the aspect", 400, 600, 0, 300, FitContain, 200, 300, 200, 300},
+		{"width only never collapses to zero", 1000, 1, 10, 0, FitCover, 10, 1, 10, 1},
+		{"cover overflows then crops to the box", 400, 600, 200, 200, FitCover, 200, 300, 200, 200},
+		{"cover of a wide source", 600, 400, 200, 200, FitCover, 300, 200, 200, 200},
+		{"contain fits inside the box", 400, 600, 200, 200, FitContain, 133, 200, 133, 200},
+		{"contain of a wide source", 600, 400, 200, 200, FitContain, 200, 133, 200, 133},
+		{"fill stretches", 400, 600, 200, 200, FitFill, 200, 200, 200, 200},
+		{"upscale with cover", 100, 100, 300, 200, FitCover, 300, 300, 300, 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scaled_w, scaled_h, out_w, out_h := Size(tt.src_w, tt.src_h, tt.width, tt.height, tt.fit)
+			if scaled_w != tt.scaled_w || scaled_h != tt.scaled_h || out_w != tt.out_w || out_h != tt.out_h {
+				t.Errorf("Size = %dx%d -> %dx%d, want %dx%d -> %dx%d",
+					scaled_w, scaled_h, out_w, out_h, tt.scaled_w, tt.scaled_h, tt.out_w, tt.out_h)
+			}
+		})
+	}
+}
+
+func TestResizeBounds(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 60, 40))
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 60; x++ {
+			src.Set(x, y, color.RGBA{R: uint8(x * 4), G: uint8(y * 6), B: 128, A: 255})
+		}
+	}
+
+	tests := []struct {
+		width, height int
+		fit           string
+		want          image.Rectangle
+	}{
+		{30, 30, FitCover, image.Rect(0, 0, 30, 30)},
+		{30, 30, FitContain, image.Rect(0, 0, 30, 20)},
+		{30, 30, FitFill, image.Rect(0, 0, 30, 30)},
+		{120, 0, FitCover, image.Rect(0, 0, 120, 80)},
+		{1, 1, FitCover, image.Rect(0, 0, 1, 1)},
+	}
+
+	for _, tt := range tests {
+		if got := Resize(src, tt.width, tt.height, tt.fit).Bounds(); got != tt.want {
+			t.Errorf("Resize(%d, %d, %s) bounds = %v, want %v", tt.width, tt.height, tt.fit, got, tt.want)
+		}
+	}
+}
+
+func TestVariant(t *testing.T) {
+	store := &Store{Dir: t.TempDir(), MaxBytes: 1 << 20, Quality: 80}
+
+	var original bytes.Buffer
+	png.Encode(&original, image.NewRGBA(image.Rect(0, 0, 80, 40)))
+	rel, err := store.Put(original.Bytes(), ".png")
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		variant Variant
+		format  string
+		want    image.Point
+	}{
+		{"jpeg cover", Variant{Width: 20, Height: 20, Fit: FitCover, Format: FormatJPEG}, "jpeg", image.Pt(20, 20)},
+		{"png contain", Variant{Width: 20, Height: 20, Fit: FitContain, Format: FormatPNG}, "png", image.Pt(20, 10)},
+		{"width only", Variant{Width: 40, Fit: FitCover}, "jpeg", image.Pt(40, 20)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			full, err := store.Variant(rel, tt.variant)
+			if err != nil {
+				t.Fatalf("Variant failed: %v", err)
+			}
+			file, err := os.Open(full)
+			if err != nil {
+				t.Fatalf("variant was not written: %v", err)
+			}
+			defer file.Close()
+
+			config, format, err := image.DecodeConfig(file)
+			if err != nil {
+				t.Fatalf("variant does not decode: %v", err)
+			}
+			if format != tt.format || config.Width != tt.want.X || config.Height != tt.want.Y {
+				t.Errorf("variant is a %dx%d %s, want %dx%d %s", config.Width, config.Height, format, tt.want.X, tt.want.Y, tt.format)
+			}
+		})
+	}
+
+	if _, err := store.Variant("00/00/"+strings.Repeat("0", 64)+".png", Variant{Width: 10}); err != ErrNotFound {
+		t.Errorf("Variant of a missing original = %v, want ErrNotFound", err)
+	}
+
+	// 80x40 is 3200 pixels, one more than allowed
+	store.MaxPixels = 3199
+	if _, err := store.Variant(rel, Variant{Width: 10, Fit: FitCover}); err != ErrTooLarge {
+		t.Errorf("Variant of an original over MaxPixels = %v, want ErrTooLarge", err)
+	}
+}
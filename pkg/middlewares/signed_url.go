@@ -0,0 +1,72 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	response "rerng_addicted_api/pkg/http/response"
+	"rerng_addicted_api/pkg/proxyurl"
+	"rerng_addicted_api/pkg/utils"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/joho/godotenv"
+)
+
+// NewSignedURLMiddleware only lets proxy requests through when their url was signed by signer,
+// mount is where the proxy routes are mounted. A url bound to a user also needs that user's bearer token.
+// The user the url is bound to is kept in the "ProxyUser" local so child urls can be signed for them too.
+func NewSignedURLMiddleware(signer *proxyurl.Signer, mount string) fiber.Handler {
+	_ = godotenv.Load()
+	secretKey := os.Getenv("JWT_SECRET_KEY")
+
+	return func(c *fiber.Ctx) error {
+		rel := strings.TrimPrefix(c.OriginalURL(), mount)
+
+		user_uuid, err := signer.Verify(rel)
+		if err != nil {
+			return c.Status(http.StatusForbidden).JSON(
+				response.NewResponseError(
+					utils.Translate("proxy_access_denied", nil, c),
+					-2600,
+					fmt.Errorf("%s", utils.Translate(err.Error(), nil, c)),
+				),
+			)
+		}
+
+		if user_uuid != "" && bearerUserUUID(c, secretKey) != user_uuid {
+			return c.Status(http.StatusForbidden).JSON(
+				response.NewResponseError(
+					utils.Translate("proxy_access_denied", nil, c),
+					-2600,
+					fmt.Errorf("%s", utils.Translate("proxy_url_user_mismatch", nil, c)),
+				),
+			)
+		}
+
+		c.Locals("ProxyUser", user_uuid)
+		return c.Next()
+	}
+}
+
+// bearerUserUUID reads user_uuid from a valid bearer token, empty when there is none
+func bearerUserUUID(c *fiber.Ctx, secretKey string) string {
+	parts := strings.Split(c.Get("Authorization"), " ")
+	if len(parts) != 2 || strings.TrimSpace(parts[0]) != "Bearer" {
+		return ""
+	}
+
+	token, err := jwt.Parse(strings.TrimSpace(parts[1]), func(token *jwt.Token) (interface{}, error) {
+		return []byte(secretKey), nil
+	})
+	if err != nil || !token.Valid {
+		return ""
+	}
+	pclaim, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return ""
+	}
+	user_uuid, _ := pclaim["user_uuid"].(string)
+	return user_uuid
+}
@@ -0,0 +1,236 @@
+// Package netguard keeps outgoing proxy requests on allowed public hosts, so the api cannot be used to reach internal addresses.
+package netguard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"rerng_addicted_api/configs"
+	"strings"
+	"sync"
+	"time"
+)
+
+// the messages double as i18n keys
+var (
+	ErrHostNotAllowed = errors.New("upstream_host_not_allowed")
+	ErrPrivateAddress = errors.New("upstream_address_private")
+	ErrTooManyHops    = errors.New("upstream_too_many_redirects")
+)
+
+const max_redirects = 10
+
+// ranges net.IP has no helper for
+var blocked_networks = []*net.IPNet{
+	mustCIDR("0.0.0.0/8"),
+	mustCIDR("100.64.0.0/10"),
+	mustCIDR("192.0.0.0/24"),
+	mustCIDR("198.18.0.0/15"),
+	mustCIDR("240.0.0.0/4"),
+}
+
+// domains each provider is known to serve from, used when the configuration lists none for it
+var default_allowed = map[string][]string{
+	"kisskh": {"kisskh.co", "kisskh.id", "khfullhd.co"},
+}
+
+// Guard checks upstream urls against the allowlist of the provider they belong to and refuses private addresses.
+// An entry allows the domain and its subdomains, a provider without any entry is denied.
+type Guard struct {
+	Allowed      map[string][]string
+	AllowPrivate bool
+
+	dialer    *net.Dialer
+	transport *http.Transport
+}
+
+var (
+	once          sync.Once
+	default_guard *Guard
+)
+
+// Default is the guard built from the environment, shared by the proxy and the scrapers
+func Default() *Guard {
+	once.Do(func() {
+		default_guard = NewGuard(configs.Proxy())
+	})
+	return default_guard
+}
+
+func NewGuard(config *configs.ProxyConfig) *Guard {
+	guard := &Guard{
+		Allowed:      config.AllowedHosts,
+		AllowPrivate: config.AllowPrivate,
+		dialer:       &net.Dialer{Timeout: 15 * time.Second, KeepAlive: 30 * time.Second},
+	}
+	guard.transport = &http.Transport{
+		DialContext:         guard.DialContext,
+		MaxIdleConns:        100,
+		IdleConnTimeout:     90 * time.Second,
+		TLSHandshakeTimeout: 15 * time.Second,
+		ForceAttemptHTTP2:   true,
+	}
+	return guard
+}
+
+// Client returns an http client for provider's hosts that dials through the guard and re-checks every redirect
+func (g *Guard) Client(provider string, timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport:     g.transport,
+		Timeout:       timeout,
+		CheckRedirect: g.RedirectChecker(provider),
+	}
+}
+
+// PublicClient is Client without the allowlist, for hosts no provider lists such as image cdns.
+// Private addresses are still refused on every hop.
+func (g *Guard) PublicClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport:     g.transport,
+		Timeout:       timeout,
+		CheckRedirect: g.checkPublicRedirect,
+	}
+}
+
+// CheckURL rejects urls that are not http(s), whose host is not allowed for provider or that resolve to a private address
+func (g *Guard) CheckURL(ctx context.Context, provider string, raw string) (*url.URL, error) {
+	parsed, err := g.CheckPublicURL(ctx, raw)
+	if err != nil {
+		return nil, err
+	}
+	if host := parsed.Hostname(); !g.AllowedHost(provider, host) {
+		return nil, fmt.Errorf("%w: %s for %s", ErrHostNotAllowed, host, provider)
+	}
+	return parsed, nil
+}
+
+// CheckPublicURL is CheckURL without the allowlist, it only keeps raw off private addresses
+func (g *Guard) CheckPublicURL(ctx context.Context, raw string) (*url.URL, error) {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrHostNotAllowed, err.Error())
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, fmt.Errorf("%w: scheme %q", ErrHostNotAllowed, parsed.Scheme)
+	}
+	if _, err := g.resolve(ctx, parsed.Hostname()); err != nil {
+		return nil, err
+	}
+	return parsed, nil
+}
+
+// RedirectChecker returns an http.Client CheckRedirect that validates every hop like the first request to provider
+func (g *Guard) RedirectChecker(provider string) func(req *http.Request, via []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) >= max_redirects {
+			return ErrTooManyHops
+		}
+		_, err := g.CheckURL(req.Context(), provider, req.URL.String())
+		return err
+	}
+}
+
+func (g *Guard) checkPublicRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= max_redirects {
+		return ErrTooManyHops
+	}
+	_, err := g.CheckPublicURL(req.Context(), req.URL.String())
+	return err
+}
+
+// AllowedHost reports whether host is on the allowlist of provider, the built-in domains stand in for an unconfigured one
+func (g *Guard) AllowedHost(provider string, host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "" {
+		return false
+	}
+
+	domains := g.Allowed[provider]
+	if len(domains) == 0 {
+		domains = default_allowed[provider]
+	}
+	for _, domain := range domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// DialContext resolves the host itself and dials the checked address, so a second lookup cannot swap in a private one
+func (g *Guard) DialContext(ctx context.Context, network string, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := g.resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var last_err error
+	for _, ip := range ips {
+		conn, err := g.dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		last_err = err
+	}
+	return nil, last_err
+}
+
+// resolve looks host up and fails when any of its addresses is private
+func (g *Guard) resolve(ctx context.Context, host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		if !g.AllowPrivate && Private(ip) {
+			return nil, fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+		}
+		return []net.IP{ip}, nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	ips := make([]net.IP, 0, len(addrs))
+	for _, addr := range addrs {
+		if !g.AllowPrivate && Private(addr.IP) {
+			return nil, fmt.Errorf("%w: %s resolves to %s", ErrPrivateAddress, host, addr.IP)
+		}
+		ips = append(ips, addr.IP)
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no addresses for %s", host)
+	}
+	return ips, nil
+}
+
+// Private reports loopback, private, link-local (cloud metadata included) and other non public addresses
+func Private(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return true
+	}
+	for _, network := range blocked_networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Blocked reports whether err, possibly wrapped by the http client, is the guard refusing a request
+func Blocked(err error) bool {
+	return errors.Is(err, ErrHostNotAllowed) || errors.Is(err, ErrPrivateAddress) || errors.Is(err, ErrTooManyHops)
+}
+
+func mustCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
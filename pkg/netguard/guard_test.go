@@ -0,0 +1,192 @@
+package netguard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"rerng_addicted_api/configs"
+	"testing"
+	"time"
+)
+
+func TestPrivate(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"127.0.0.1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"100.64.0.1", true},
+		{"0.0.0.0", true},
+		{"::1", true},
+		{"fe80::1", true},
+		{"fd00::1", true},
+		{"224.0.0.1", true},
+		{"8.8.8.8", false},
+		{"1.1.1.1", false},
+		{"2606:4700:4700::1111", false},
+	}
+
+	for _, tt := range tests {
+		if got := Private(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("Private(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestAllowedHost(t *testing.T) {
+	guard := NewGuard(&configs.ProxyConfig{
+		AllowedHosts: map[string][]string{
+			"other": {"cdn.example.org"},
+		},
+	})
+
+	tests := []struct {
+		name     string
+		provider string
+		host     string
+		want     bool
+	}{
+		{"configured domain", "other", "cdn.example.org", true},
+		{"configured subdomain", "other", "edge.cdn.example.org", true},
+		{"case and trailing dot", "other", "CDN.Example.org.", true},
+		{"suffix that is not a subdomain", "other", "evilcdn.example.org", false},
+		{"another provider's domain", "other", "kisskh.co", false},
+		{"built-in domain of an unconfigured provider", "kisskh", "kisskh.co", true},
+		{"built-in subdomain", "kisskh", "www.kisskh.id", true},
+		{"configured domain of another provider", "kisskh", "cdn.example.org", false},
+		{"unknown provider", "unknown", "cdn.example.org", false},
+		{"empty host", "other", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := guard.AllowedHost(tt.provider, tt.host); got != tt.want {
+				t.Errorf("AllowedHost(%q, %q) = %v, want %v", tt.provider, tt.host, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	guard := NewGuard(&configs.ProxyConfig{
+		AllowedHosts: map[string][]string{
+			"other": {"127.0.0.1", "169.254.169.254", "cdn.example.org"},
+		},
+	})
+
+	tests := []struct {
+		name string
+		raw  string
+		want error
+	}{
+		{"loopback", "http://127.0.0.1:8080/index.m3u8", ErrPrivateAddress},
+		{"cloud metadata", "http://169.254.169.254/latest/meta-data", ErrPrivateAddress},
+		{"host not allowed", "https://8.8.8.8/index.m3u8", ErrHostNotAllowed},
+		{"file scheme", "file:///etc/passwd", ErrHostNotAllowed},
+		{"ftp scheme", "ftp://cdn.example.org/a", ErrHostNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := guard.CheckURL(context.Background(), "other", tt.raw)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("CheckURL(%q) = %v, want %v", tt.raw, err, tt.want)
+			}
+			if !Blocked(err) {
+				t.Errorf("Blocked(%v) = false", err)
+			}
+		})
+	}
+}
+
+func TestCheckPublicURL(t *testing.T) {
+	guard := NewGuard(&configs.ProxyConfig{})
+
+	if _, err := guard.CheckPublicURL(context.Background(), "http://8.8.8.8/a.png"); err != nil {
+		t.Errorf("CheckPublicURL of a public address = %v", err)
+	}
+	if _, err := guard.CheckPublicURL(context.Background(), "http://10.0.0.1/a.png"); !errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("CheckPublicURL of a private address = %v, want ErrPrivateAddress", err)
+	}
+}
+
+func TestRedirects(t *testing.T) {
+	// private addresses are let through so the test server can be reached, the allowlist still applies
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer target.Close()
+
+	hops := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/outside":
+			http.Redirect(w, r, fmt.Sprintf("http://localhost:%d/", target.Listener.Addr().(*net.TCPAddr).Port), http.StatusFound)
+		case "/inside":
+			http.Redirect(w, r, target.URL+"/", http.StatusFound)
+		case "/loop":
+			hops++
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	guard := NewGuard(&configs.ProxyConfig{
+		AllowedHosts: map[string][]string{"other": {"127.0.0.1"}},
+		AllowPrivate: true,
+	})
+	client := guard.Client("other", 5*time.Second)
+
+	tests := []struct {
+		name string
+		path string
+		want error
+	}{
+		{"redirect within the allowlist", "/inside", nil},
+		{"redirect to a host not allowed", "/outside", ErrHostNotAllowed},
+		{"redirect loop", "/loop", ErrTooManyHops},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.Get(server.URL + tt.path)
+			if resp != nil {
+				resp.Body.Close()
+			}
+			if tt.want == nil && err != nil {
+				t.Fatalf("Get(%s) = %v, want it to succeed", tt.path, err)
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Get(%s) = %v, want %v", tt.path, err, tt.want)
+			}
+		})
+	}
+	if hops != max_redirects {
+		t.Errorf("loop was followed %d times, want %d", hops, max_redirects)
+	}
+}
+
+func TestDialRefusesPrivate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	// the check at dial time is what stops a host that resolved public a moment ago
+	guard := NewGuard(&configs.ProxyConfig{})
+	client := guard.PublicClient(5 * time.Second)
+	resp, err := client.Get(server.URL)
+	if resp != nil {
+		resp.Body.Close()
+	}
+	if !errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("Get of a loopback server = %v, want ErrPrivateAddress", err)
+	}
+}
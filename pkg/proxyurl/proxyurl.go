@@ -0,0 +1,189 @@
+// Package proxyurl signs the urls handed out for the media proxy, so it only fetches what this api generated.
+package proxyurl
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"rerng_addicted_api/configs"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mount is where the proxy routes are mounted, signed paths are relative to it
+const Mount = "/api/v1/admin/proxy"
+
+// query params added to a signed url, they are never forwarded upstream
+const (
+	ParamExpires   = "px_exp"
+	ParamUser      = "px_uid"
+	ParamSignature = "px_sig"
+	// ParamProvider is signed along with the url but, like the others, never forwarded upstream
+	ParamProvider = "px_prv"
+)
+
+// the messages double as i18n keys
+var (
+	ErrUnsigned = fmt.Errorf("proxy_url_unsigned")
+	ErrInvalid  = fmt.Errorf("proxy_url_invalid")
+	ErrExpired  = fmt.Errorf("proxy_url_expired")
+)
+
+// Signer signs proxy paths relative to where the proxy routes are mounted,
+// e.g. "/m3u8/host/index.m3u8?token=1", so urls stay valid behind any base
+type Signer struct {
+	Key []byte
+	TTL time.Duration
+}
+
+var (
+	once           sync.Once
+	default_signer *Signer
+)
+
+func NewSigner(config *configs.ProxyConfig) *Signer {
+	return &Signer{
+		Key: []byte(config.SigningKey),
+		TTL: config.URLTTL,
+	}
+}
+
+// Default is the signer built from the environment, shared by scraping and the proxy routes
+func Default() *Signer {
+	once.Do(func() {
+		default_signer = NewSigner(configs.Proxy())
+	})
+	return default_signer
+}
+
+// Sign appends an expiry, the user the url is bound to when given, and the signature to rel.
+// A signature already on rel is replaced.
+func (s *Signer) Sign(rel string, user_uuid string) string {
+	path, query, _ := strings.Cut(rel, "?")
+	query = StripQuery(query)
+
+	expires := strconv.FormatInt(time.Now().Add(s.TTL).Unix(), 10)
+	params := ParamExpires + "=" + expires
+	if user_uuid != "" {
+		params += "&" + ParamUser + "=" + url.QueryEscape(user_uuid)
+	}
+	params += "&" + ParamSignature + "=" + s.signature(path, query, expires, user_uuid)
+
+	if query != "" {
+		return path + "?" + query + "&" + params
+	}
+	return path + "?" + params
+}
+
+// Verify checks rel as it was requested and returns the user the url is bound to, empty when it is not
+func (s *Signer) Verify(rel string) (string, error) {
+	path, raw_query, _ := strings.Cut(rel, "?")
+
+	var expires, user_uuid, signature string
+	kept := []string{}
+	for _, part := range strings.Split(raw_query, "&") {
+		if part == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case ParamExpires:
+			expires = value
+		case ParamUser:
+			user_uuid, _ = url.QueryUnescape(value)
+		case ParamSignature:
+			signature = value
+		default:
+			kept = append(kept, part)
+		}
+	}
+	if expires == "" || signature == "" {
+		return "", ErrUnsigned
+	}
+
+	expected := s.signature(path, strings.Join(kept, "&"), expires, user_uuid)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return "", ErrInvalid
+	}
+	// checked after the signature so a forged expiry reads as invalid
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return "", ErrInvalid
+	}
+	if time.Now().Unix() > unix {
+		return "", ErrExpired
+	}
+	return user_uuid, nil
+}
+
+// Resign gives a url pointing under Mount a fresh signature for user_uuid, or for anyone when it is empty.
+// Urls that do not point at the proxy come back as they are.
+func (s *Signer) Resign(raw string, user_uuid string) string {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Host == "" {
+		return raw
+	}
+	base := parsed.Scheme + "://" + parsed.Host + Mount
+	rel := strings.TrimPrefix(raw, base)
+	if rel == raw || !isProxyPath(rel) {
+		return raw
+	}
+	return base + s.Sign(rel, user_uuid)
+}
+
+func (s *Signer) signature(path string, query string, expires string, user_uuid string) string {
+	mac := hmac.New(sha256.New, s.Key)
+	mac.Write([]byte(path + "?" + query + "\n" + expires + "\n" + user_uuid))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func isProxyPath(rel string) bool {
+	return strings.HasPrefix(rel, "/m3u8/") || strings.HasPrefix(rel, "/subtitle/") || strings.HasPrefix(rel, "/mp4?")
+}
+
+// Strip drops the signing params from a url, which is how proxy urls are stored
+func Strip(raw string) string {
+	path, query, ok := strings.Cut(raw, "?")
+	if !ok {
+		return raw
+	}
+	if query = StripQuery(query); query != "" {
+		return path + "?" + query
+	}
+	return path
+}
+
+// WithProvider tags rel with the provider whose allowlist the proxy checks it against
+func WithProvider(rel string, provider string) string {
+	if strings.Contains(rel, "?") {
+		return rel + "&" + ParamProvider + "=" + url.QueryEscape(provider)
+	}
+	return rel + "?" + ParamProvider + "=" + url.QueryEscape(provider)
+}
+
+// StripQuery drops the signing params from a raw query string, keeping the rest untouched and in order
+func StripQuery(raw_query string) string {
+	return dropParams(raw_query, ParamExpires, ParamUser, ParamSignature)
+}
+
+// UpstreamQuery is what the proxy forwards of a raw query string, StripQuery without the provider tag
+func UpstreamQuery(raw_query string) string {
+	return dropParams(raw_query, ParamExpires, ParamUser, ParamSignature, ParamProvider)
+}
+
+func dropParams(raw_query string, keys ...string) string {
+	kept := []string{}
+	for _, part := range strings.Split(raw_query, "&") {
+		key, _, _ := strings.Cut(part, "=")
+		if part == "" || slices.Contains(keys, key) {
+			continue
+		}
+		kept = append(kept, part)
+	}
+	return strings.Join(kept, "&")
+}
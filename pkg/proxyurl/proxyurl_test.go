@@ -0,0 +1,182 @@
+package proxyurl
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func testSigner() *Signer {
+	return &Signer{Key: []byte("test-key"), TTL: time.Hour}
+}
+
+func TestSignVerify(t *testing.T) {
+	signer := testSigner()
+
+	tests := []struct {
+		name     string
+		rel      string
+		user     string
+		tamper   func(signed string) string
+		want_err error
+	}{
+		{
+			name: "path only",
+			rel:  "/m3u8/cdn.example.org/show/index.m3u8",
+		},
+		{
+			name: "upstream query is kept",
+			rel:  "/m3u8/cdn.example.org/show/index.m3u8?token=1&a=b",
+		},
+		{
+			name: "bound to a user",
+			rel:  "/subtitle/cdn.example.org/en.srt",
+			user: "5f0c6c1e-user",
+		},
+		{
+			name: "an old signature is replaced",
+			rel:  "/mp4?url=x&" + ParamExpires + "=1&" + ParamSignature + "=old",
+		},
+		{
+			name:     "unsigned",
+			rel:      "/m3u8/cdn.example.org/show/index.m3u8",
+			tamper:   Strip,
+			want_err: ErrUnsigned,
+		},
+		{
+			name: "path swapped",
+			rel:  "/m3u8/cdn.example.org/show/index.m3u8",
+			tamper: func(signed string) string {
+				return strings.Replace(signed, "index.m3u8", "other.m3u8", 1)
+			},
+			want_err: ErrInvalid,
+		},
+		{
+			name: "query added",
+			rel:  "/mp4?url=a",
+			tamper: func(signed string) string {
+				return strings.Replace(signed, "url=a", "url=b", 1)
+			},
+			want_err: ErrInvalid,
+		},
+		{
+			name: "user changed",
+			rel:  "/subtitle/cdn.example.org/en.srt",
+			user: "alice",
+			tamper: func(signed string) string {
+				return strings.Replace(signed, ParamUser+"=alice", ParamUser+"=bob", 1)
+			},
+			want_err: ErrInvalid,
+		},
+		{
+			name: "expiry pushed out",
+			rel:  "/subtitle/cdn.example.org/en.srt",
+			tamper: func(signed string) string {
+				path, query, _ := strings.Cut(signed, "?")
+				parts := strings.Split(query, "&")
+				for i, part := range parts {
+					if strings.HasPrefix(part, ParamExpires+"=") {
+						parts[i] = ParamExpires + "=9999999999"
+					}
+				}
+				return path + "?" + strings.Join(parts, "&")
+			},
+			want_err: ErrInvalid,
+		},
+		{
+			name: "provider changed",
+			rel:  WithProvider("/m3u8/cdn.example.org/index.m3u8", "kisskh"),
+			tamper: func(signed string) string {
+				return strings.Replace(signed, ParamProvider+"=kisskh", ParamProvider+"=other", 1)
+			},
+			want_err: ErrInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signed := signer.Sign(tt.rel, tt.user)
+			if tt.tamper != nil {
+				signed = tt.tamper(signed)
+			}
+
+			user, err := signer.Verify(signed)
+			if err != tt.want_err {
+				t.Fatalf("Verify(%q) error = %v, want %v", signed, err, tt.want_err)
+			}
+			if err == nil && user != tt.user {
+				t.Errorf("Verify(%q) user = %q, want %q", signed, user, tt.user)
+			}
+		})
+	}
+}
+
+func TestVerifyExpired(t *testing.T) {
+	signer := &Signer{Key: []byte("test-key"), TTL: -time.Minute}
+	if _, err := signer.Verify(signer.Sign("/m3u8/cdn.example.org/index.m3u8", "")); err != ErrExpired {
+		t.Errorf("Verify of an expired url = %v, want ErrExpired", err)
+	}
+}
+
+func TestVerifyOtherKey(t *testing.T) {
+	signed := testSigner().Sign("/m3u8/cdn.example.org/index.m3u8", "")
+	other := &Signer{Key: []byte("other-key"), TTL: time.Hour}
+	if _, err := other.Verify(signed); err != ErrInvalid {
+		t.Errorf("Verify with another key = %v, want ErrInvalid", err)
+	}
+}
+
+func TestResign(t *testing.T) {
+	signer := testSigner()
+	base := "http://api.example.org:8585" + Mount
+
+	tests := []struct {
+		name    string
+		raw     string
+		resigns bool
+	}{
+		{"m3u8 under the mount", base + "/m3u8/cdn.example.org/index.m3u8", true},
+		{"subtitle under the mount", base + "/subtitle/cdn.example.org/en.srt", true},
+		{"mp4 under the mount", base + "/mp4?url=https%3A%2F%2Fkisskh.co%2Fep", true},
+		{"stale signature", base + "/m3u8/cdn.example.org/index.m3u8?" + ParamExpires + "=1&" + ParamSignature + "=old", true},
+		{"proxy path without the mount", "http://api.example.org:8585/m3u8/cdn.example.org/index.m3u8", false},
+		{"upstream url", "https://cdn.example.org/index.m3u8", false},
+		{"relative url", "/m3u8/cdn.example.org/index.m3u8", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := signer.Resign(tt.raw, "alice")
+			if !tt.resigns {
+				if got != tt.raw {
+					t.Errorf("Resign(%q) = %q, want it unchanged", tt.raw, got)
+				}
+				return
+			}
+			if !strings.HasPrefix(got, base) {
+				t.Fatalf("Resign(%q) = %q, want it under %s", tt.raw, got, base)
+			}
+			user, err := signer.Verify(strings.TrimPrefix(got, base))
+			if err != nil || user != "alice" {
+				t.Errorf("Verify(Resign(%q)) = %q, %v, want alice", tt.raw, user, err)
+			}
+		})
+	}
+}
+
+func TestQueries(t *testing.T) {
+	signed := "a=1&" + ParamExpires + "=2&" + ParamProvider + "=kisskh&b=3&" + ParamUser + "=u&" + ParamSignature + "=s"
+
+	if got, want := StripQuery(signed), "a=1&"+ParamProvider+"=kisskh&b=3"; got != want {
+		t.Errorf("StripQuery = %q, want %q", got, want)
+	}
+	if got, want := UpstreamQuery(signed), "a=1&b=3"; got != want {
+		t.Errorf("UpstreamQuery = %q, want %q", got, want)
+	}
+	if got, want := Strip("/mp4?url=x&"+ParamSignature+"=s"), "/mp4?url=x"; got != want {
+		t.Errorf("Strip = %q, want %q", got, want)
+	}
+	if got, want := WithProvider("/mp4?url=x", "kisskh"), "/mp4?url=x&"+ParamProvider+"=kisskh"; got != want {
+		t.Errorf("WithProvider = %q, want %q", got, want)
+	}
+}
@@ -33,3 +33,11 @@ func NewRedis() *redis.Client {
 
 	return client
 }
+
+// NewOptionalRedis is NewRedis for callers that also work without redis, it is nil when REDIS_HOST is unset
+func NewOptionalRedis() *redis.Client {
+	if configs.Redis().RedisHost == "" {
+		return nil
+	}
+	return NewRedis()
+}
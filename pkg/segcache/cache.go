@@ -0,0 +1,330 @@
+// Package segcache keeps proxied HLS segments and keys on disk, evicting the least recently used past a size limit.
+package segcache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"rerng_addicted_api/configs"
+	custom_log "rerng_addicted_api/pkg/logs"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cache status reported in the X-Cache header
+const (
+	StatusHit       = "HIT"
+	StatusMiss      = "MISS"
+	StatusCoalesced = "COALESCED"
+)
+
+var (
+	ErrTooLarge     = errors.New("segment is larger than the cache allows")
+	ErrNotCacheable = errors.New("response is not a cacheable segment")
+)
+
+// path extensions of what players fetch once per viewer and never changes, playlists are not in here
+var cacheable_extensions = map[string]bool{
+	".ts":  true,
+	".m4s": true,
+	".mp4": true,
+	".m4a": true,
+	".m4v": true,
+	".aac": true,
+	".key": true,
+}
+
+// Item is one cached segment on disk
+type Item struct {
+	Path        string
+	ContentType string
+	Size        int64
+}
+
+type Stats struct {
+	Entries   int   `json:"entries"`
+	Bytes     int64 `json:"bytes"`
+	MaxBytes  int64 `json:"max_bytes"`
+	Inflight  int   `json:"inflight"`
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Coalesced int64 `json:"coalesced"`
+	Evictions int64 `json:"evictions"`
+}
+
+type entry struct {
+	key  string
+	item Item
+}
+
+type meta struct {
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+}
+
+type call struct {
+	done chan struct{}
+	item *Item
+	err  error
+}
+
+// Cache is a size bounded LRU of files under Dir, keyed by the normalized upstream url.
+// Concurrent fetches of the same segment wait for a single upstream request.
+type Cache struct {
+	Dir          string
+	MaxBytes     int64
+	MaxItemBytes int64
+
+	mu       sync.Mutex
+	lru      *list.List // most recently used first, values are *entry
+	entries  map[string]*list.Element
+	inflight map[string]*call
+	size     int64
+	stats    Stats
+}
+
+// NewCache picks up the segments left on disk by a previous run, it returns nil when the cache is disabled
+func NewCache(config *configs.ProxyConfig) *Cache {
+	if config.SegmentCacheMaxBytes <= 0 {
+		return nil
+	}
+
+	cache := &Cache{
+		Dir:          config.SegmentCacheDir,
+		MaxBytes:     config.SegmentCacheMaxBytes,
+		MaxItemBytes: config.SegmentMaxBytes,
+		lru:          list.New(),
+		entries:      map[string]*list.Element{},
+		inflight:     map[string]*call{},
+	}
+	if err := cache.load(); err != nil {
+		custom_log.NewCustomLog("segment_cache_load_failed", err.Error(), "warn")
+	}
+	return cache
+}
+
+// Cacheable reports whether the upstream url looks like a segment or key
+func (c *Cache) Cacheable(raw string) bool {
+	if c == nil {
+		return false
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	return cacheable_extensions[strings.ToLower(path.Ext(parsed.Path))]
+}
+
+// Key hashes the url with its host lowercased, default port, fragment and query order normalized away
+func Key(raw string) string {
+	normalized := raw
+	if parsed, err := url.Parse(raw); err == nil {
+		parsed.Scheme = strings.ToLower(parsed.Scheme)
+		host := strings.ToLower(parsed.Host)
+		if (parsed.Scheme == "https" && strings.HasSuffix(host, ":443")) || (parsed.Scheme == "http" && strings.HasSuffix(host, ":80")) {
+			host = host[:strings.LastIndexByte(host, ':')]
+		}
+		parsed.Host = host
+		parsed.Fragment = ""
+		parsed.RawQuery = parsed.Query().Encode()
+		normalized = parsed.String()
+	}
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// Fetch returns the cached segment for raw, on a miss fetch writes the body and returns its content type.
+// The status tells whether it was a hit, a miss or a wait on another viewer's fetch.
+func (c *Cache) Fetch(raw string, fetch func(w io.Writer) (string, error)) (*Item, string, error) {
+	key := Key(raw)
+
+	c.mu.Lock()
+	if element, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(element)
+		c.stats.Hits++
+		item := element.Value.(*entry).item
+		c.mu.Unlock()
+		return &item, StatusHit, nil
+	}
+	if pending, ok := c.inflight[key]; ok {
+		c.stats.Coalesced++
+		c.mu.Unlock()
+		<-pending.done
+		return pending.item, StatusCoalesced, pending.err
+	}
+	pending := &call{done: make(chan struct{})}
+	c.inflight[key] = pending
+	c.stats.Misses++
+	c.mu.Unlock()
+
+	pending.item, pending.err = c.store(key, fetch)
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	c.mu.Unlock()
+	close(pending.done)
+
+	return pending.item, StatusMiss, pending.err
+}
+
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = c.lru.Len()
+	stats.Bytes = c.size
+	stats.MaxBytes = c.MaxBytes
+	stats.Inflight = len(c.inflight)
+	return stats
+}
+
+func (c *Cache) paths(key string) (string, string) {
+	dir := filepath.Join(c.Dir, key[:2])
+	return filepath.Join(dir, key), filepath.Join(dir, key+".meta")
+}
+
+// store writes the fetched body next to its final path and renames it in, so a half written segment is never served
+func (c *Cache) store(key string, fetch func(w io.Writer) (string, error)) (*Item, error) {
+	data_path, meta_path := c.paths(key)
+	if err := os.MkdirAll(filepath.Dir(data_path), 0o755); err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(data_path), ".segment-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	limited := &limitWriter{w: tmp, remaining: c.MaxItemBytes}
+	content_type, err := fetch(limited)
+	if close_err := tmp.Close(); err == nil {
+		err = close_err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	size := c.MaxItemBytes - limited.remaining
+	raw_meta, _ := json.Marshal(meta{ContentType: content_type, Size: size})
+	if err := os.WriteFile(meta_path, raw_meta, 0o644); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), data_path); err != nil {
+		os.Remove(meta_path)
+		return nil, err
+	}
+
+	item := Item{Path: data_path, ContentType: content_type, Size: size}
+	c.mu.Lock()
+	c.add(key, item)
+	c.evict()
+	c.mu.Unlock()
+	return &item, nil
+}
+
+// add puts an item at the front, callers hold mu
+func (c *Cache) add(key string, item Item) {
+	c.entries[key] = c.lru.PushFront(&entry{key: key, item: item})
+	c.size += item.Size
+}
+
+// evict drops least recently used segments until the cache fits, the newest one always stays. Callers hold mu.
+// A viewer still streaming an evicted file keeps reading it, unlinking does not cut open files.
+func (c *Cache) evict() {
+	for c.size > c.MaxBytes && c.lru.Len() > 1 {
+		oldest := c.lru.Back()
+		evicted := oldest.Value.(*entry)
+		c.lru.Remove(oldest)
+		delete(c.entries, evicted.key)
+		c.size -= evicted.item.Size
+		c.stats.Evictions++
+
+		data_path, meta_path := c.paths(evicted.key)
+		os.Remove(data_path)
+		os.Remove(meta_path)
+	}
+}
+
+// load rebuilds the LRU from disk with the most recently written segments first
+func (c *Cache) load() error {
+	type found struct {
+		key      string
+		item     Item
+		modified time.Time
+	}
+	items := []found{}
+
+	err := filepath.WalkDir(c.Dir, func(meta_path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		// left behind by a fetch that never finished
+		if !d.IsDir() && strings.HasPrefix(d.Name(), ".segment-") {
+			os.Remove(meta_path)
+			return nil
+		}
+		if d.IsDir() || !strings.HasSuffix(meta_path, ".meta") {
+			return nil
+		}
+
+		data_path := strings.TrimSuffix(meta_path, ".meta")
+		info, err := os.Stat(data_path)
+		raw_meta, meta_err := os.ReadFile(meta_path)
+		var stored meta
+		if err != nil || meta_err != nil || json.Unmarshal(raw_meta, &stored) != nil || stored.Size != info.Size() {
+			os.Remove(meta_path)
+			os.Remove(data_path)
+			return nil
+		}
+		items = append(items, found{
+			key:      filepath.Base(data_path),
+			item:     Item{Path: data_path, ContentType: stored.ContentType, Size: stored.Size},
+			modified: info.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].modified.Before(items[j].modified)
+	})
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, found := range items {
+		c.add(found.key, found.item)
+	}
+	c.evict()
+	return nil
+}
+
+// limitWriter fails once more than remaining bytes are written
+type limitWriter struct {
+	w         io.Writer
+	remaining int64
+}
+
+func (l *limitWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) > l.remaining {
+		return 0, ErrTooLarge
+	}
+	n, err := l.w.Write(p)
+	l.remaining -= int64(n)
+	return n, err
+}
@@ -0,0 +1,270 @@
+package segcache
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"rerng_addicted_api/configs"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testCache(t *testing.T, dir string, max_bytes int64) *Cache {
+	t.Helper()
+	return NewCache(&configs.ProxyConfig{
+		SegmentCacheDir:      dir,
+		SegmentCacheMaxBytes: max_bytes,
+		SegmentMaxBytes:      max_bytes,
+	})
+}
+
+// body writes a fixed payload, counting how often upstream was asked
+func body(payload string, calls *int32) func(w io.Writer) (string, error) {
+	return func(w io.Writer) (string, error) {
+		atomic.AddInt32(calls, 1)
+		_, err := io.WriteString(w, payload)
+		return "video/mp2t", err
+	}
+}
+
+func TestCacheable(t *testing.T) {
+	cache := testCache(t, t.TempDir(), 1024)
+
+	tests := []struct {
+		raw  string
+		want bool
+	}{
+		{"https://cdn.example.org/a/seg-1.ts", true},
+		{"https://cdn.example.org/a/seg-1.TS?token=1", true},
+		{"https://cdn.example.org/a/init.m4s", true},
+		{"https://cdn.example.org/a/key.key", true},
+		{"https://cdn.example.org/a/index.m3u8", false},
+		{"https://cdn.example.org/a/seg", false},
+	}
+	for _, tt := range tests {
+		if got := cache.Cacheable(tt.raw); got != tt.want {
+			t.Errorf("Cacheable(%q) = %v, want %v", tt.raw, got, tt.want)
+		}
+	}
+
+	var disabled *Cache
+	if disabled.Cacheable("https://cdn.example.org/a/seg-1.ts") {
+		t.Error("a disabled cache reports segments as cacheable")
+	}
+}
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		same bool
+	}{
+		{"host case", "https://CDN.example.org/a.ts", "https://cdn.example.org/a.ts", true},
+		{"default port", "https://cdn.example.org:443/a.ts", "https://cdn.example.org/a.ts", true},
+		{"query order", "https://cdn.example.org/a.ts?x=1&y=2", "https://cdn.example.org/a.ts?y=2&x=1", true},
+		{"fragment", "https://cdn.example.org/a.ts#t=1", "https://cdn.example.org/a.ts", true},
+		{"path case", "https://cdn.example.org/A.ts", "https://cdn.example.org/a.ts", false},
+		{"query value", "https://cdn.example.org/a.ts?x=1", "https://cdn.example.org/a.ts?x=2", false},
+	}
+	for _, tt := range tests {
+		if got := Key(tt.a) == Key(tt.b); got != tt.same {
+			t.Errorf("%s: Key(%q) == Key(%q) is %v, want %v", tt.name, tt.a, tt.b, got, tt.same)
+		}
+	}
+}
+
+func TestFetch(t *testing.T) {
+	cache := testCache(t, t.TempDir(), 1024)
+	var calls int32
+
+	item, status, err := cache.Fetch("https://cdn.example.org/seg-1.ts", body("segment", &calls))
+	if err != nil || status != StatusMiss {
+		t.Fatalf("first Fetch = %s, %v, want a miss", status, err)
+	}
+	if data, _ := os.ReadFile(item.Path); string(data) != "segment" || item.Size != 7 || item.ContentType != "video/mp2t" {
+		t.Errorf("stored %q (%d bytes, %s)", data, item.Size, item.ContentType)
+	}
+
+	if _, status, err := cache.Fetch("https://CDN.example.org/seg-1.ts", body("other", &calls)); err != nil || status != StatusHit {
+		t.Errorf("second Fetch = %s, %v, want a hit", status, err)
+	}
+	if calls != 1 {
+		t.Errorf("upstream asked %d times, want 1", calls)
+	}
+}
+
+func TestFetchErrors(t *testing.T) {
+	upstream_err := errors.New("upstream down")
+
+	tests := []struct {
+		name  string
+		fetch func(w io.Writer) (string, error)
+		want  error
+	}{
+		{
+			name: "larger than a segment may be",
+			fetch: func(w io.Writer) (string, error) {
+				_, err := io.WriteString(w, strings.Repeat("x", 17))
+				return "video/mp2t", err
+			},
+			want: ErrTooLarge,
+		},
+		{
+			name: "not cacheable",
+			fetch: func(w io.Writer) (string, error) {
+				return "", ErrNotCacheable
+			},
+			want: ErrNotCacheable,
+		},
+		{
+			name: "upstream failure",
+			fetch: func(w io.Writer) (string, error) {
+				io.WriteString(w, "half")
+				return "", upstream_err
+			},
+			want: upstream_err,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			cache := testCache(t, dir, 16)
+
+			if _, _, err := cache.Fetch("https://cdn.example.org/seg-1.ts", tt.fetch); !errors.Is(err, tt.want) {
+				t.Fatalf("Fetch = %v, want %v", err, tt.want)
+			}
+			if stats := cache.Stats(); stats.Entries != 0 || stats.Bytes != 0 {
+				t.Errorf("failed fetch left %d entries, %d bytes", stats.Entries, stats.Bytes)
+			}
+			// nothing half written stays on disk
+			filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+				if err == nil && !d.IsDir() {
+					t.Errorf("left %s behind", path)
+				}
+				return nil
+			})
+		})
+	}
+}
+
+func TestFetchCoalesces(t *testing.T) {
+	cache := testCache(t, t.TempDir(), 1024)
+	var calls int32
+	release := make(chan struct{})
+
+	slow := func(w io.Writer) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		_, err := io.WriteString(w, "segment")
+		return "video/mp2t", err
+	}
+
+	var wg sync.WaitGroup
+	statuses := make(chan string, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, status, err := cache.Fetch("https://cdn.example.org/seg-1.ts", slow)
+			if err != nil {
+				t.Errorf("Fetch = %v", err)
+			}
+			statuses <- status
+		}()
+	}
+	// let every viewer reach the cache before upstream answers
+	for cache.Stats().Coalesced+cache.Stats().Misses < 5 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+	close(statuses)
+
+	if calls != 1 {
+		t.Errorf("upstream asked %d times, want 1", calls)
+	}
+	counts := map[string]int{}
+	for status := range statuses {
+		counts[status]++
+	}
+	if counts[StatusMiss] != 1 || counts[StatusCoalesced] != 4 {
+		t.Errorf("statuses %v, want one miss and four coalesced", counts)
+	}
+}
+
+func TestEviction(t *testing.T) {
+	cache := testCache(t, t.TempDir(), 20)
+	var calls int32
+
+	// 8 bytes each, only two fit
+	fetch := func(raw string) {
+		if _, _, err := cache.Fetch(raw, body("12345678", &calls)); err != nil {
+			t.Fatalf("Fetch(%s) = %v", raw, err)
+		}
+	}
+	fetch("https://cdn.example.org/1.ts")
+	fetch("https://cdn.example.org/2.ts")
+	// touching 1 makes 2 the least recently used
+	fetch("https://cdn.example.org/1.ts")
+	fetch("https://cdn.example.org/3.ts")
+
+	tests := []struct {
+		raw    string
+		cached bool
+	}{
+		{"https://cdn.example.org/1.ts", true},
+		{"https://cdn.example.org/2.ts", false},
+		{"https://cdn.example.org/3.ts", true},
+	}
+	for _, tt := range tests {
+		data_path, _ := cache.paths(Key(tt.raw))
+		_, err := os.Stat(data_path)
+		if cached := err == nil; cached != tt.cached {
+			t.Errorf("%s on disk = %v, want %v", tt.raw, cached, tt.cached)
+		}
+	}
+
+	stats := cache.Stats()
+	if stats.Entries != 2 || stats.Bytes != 16 || stats.Evictions != 1 {
+		t.Errorf("stats %+v, want 2 entries, 16 bytes, 1 eviction", stats)
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	cache := testCache(t, dir, 1024)
+	var calls int32
+
+	kept, _, _ := cache.Fetch("https://cdn.example.org/kept.ts", body("kept", &calls))
+	broken, _, _ := cache.Fetch("https://cdn.example.org/broken.ts", body("broken", &calls))
+
+	// a segment whose size no longer matches its meta and a temp file of a fetch that never finished
+	os.WriteFile(broken.Path, []byte("truncated-and-longer"), 0o644)
+	leftover := filepath.Join(filepath.Dir(kept.Path), ".segment-123")
+	os.WriteFile(leftover, []byte("partial"), 0o644)
+
+	reloaded := testCache(t, dir, 1024)
+	if stats := reloaded.Stats(); stats.Entries != 1 || stats.Bytes != 4 {
+		t.Errorf("reloaded stats %+v, want the one intact segment", stats)
+	}
+	if _, status, err := reloaded.Fetch("https://cdn.example.org/kept.ts", body("kept", &calls)); err != nil || status != StatusHit {
+		t.Errorf("Fetch of a reloaded segment = %s, %v, want a hit", status, err)
+	}
+
+	for _, path := range []string{broken.Path, broken.Path + ".meta", leftover} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s survived the reload", path)
+		}
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	if cache := testCache(t, t.TempDir(), 0); cache != nil {
+		t.Error("NewCache with no size limit returned a cache")
+	}
+}
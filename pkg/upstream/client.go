@@ -0,0 +1,270 @@
+package upstream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"rerng_addicted_api/configs"
+	custom_log "rerng_addicted_api/pkg/logs"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	ErrCircuitOpen = errors.New("upstream circuit open")
+	ErrDecode      = errors.New("upstream response decode failed")
+)
+
+// StatusError is returned when upstream still answers with a non 2xx status after retries
+type StatusError struct {
+	Code int
+	URL  string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("upstream %s returned %d", e.URL, e.Code)
+}
+
+// Client is a shared http client for one upstream origin. It keeps the origin's cookies in a jar,
+// rate limits every request, retries 429/5xx with jittered backoff and fails fast while the origin is down.
+type Client struct {
+	BaseURL   string
+	UserAgent string
+
+	config  *configs.UpstreamConfig
+	http    *http.Client
+	jar     *cookiejar.Jar
+	limiter *TokenBucket
+	breaker *Breaker
+
+	session_mu sync.Mutex
+	session_at time.Time
+}
+
+func NewClient(base_url string, user_agent string, transport http.RoundTripper, config *configs.UpstreamConfig) *Client {
+	jar, _ := cookiejar.New(nil)
+
+	return &Client{
+		BaseURL:   base_url,
+		UserAgent: user_agent,
+		config:    config,
+		http: &http.Client{
+			Timeout:   config.RequestTimeout,
+			Transport: transport,
+			Jar:       jar,
+		},
+		jar:     jar,
+		limiter: NewTokenBucket(config.RatePerSecond, config.Burst),
+		breaker: NewBreaker(config.BreakerThreshold, config.BreakerCooldown),
+	}
+}
+
+// Breaker exposes the circuit state, mostly for health reporting
+func (c *Client) Breaker() *Breaker {
+	return c.breaker
+}
+
+// EnsureSession visits the origin's home page when the jar has no live cookies or the session is older than SessionTTL
+func (c *Client) EnsureSession(ctx context.Context) error {
+	c.session_mu.Lock()
+	defer c.session_mu.Unlock()
+
+	base, err := url.Parse(c.BaseURL + "/")
+	if err != nil {
+		return err
+	}
+	// the jar drops expired cookies itself, the ttl covers cookies without an expiry
+	if len(c.jar.Cookies(base)) > 0 && time.Since(c.session_at) < c.config.SessionTTL {
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	c.session_at = time.Now()
+	return nil
+}
+
+// ResetSession forces the next EnsureSession to fetch fresh cookies
+func (c *Client) ResetSession() {
+	c.session_mu.Lock()
+	defer c.session_mu.Unlock()
+
+	c.session_at = time.Time{}
+}
+
+// Do sends req through the rate limiter and circuit breaker, retrying 429/5xx and network errors.
+// A request body is only resent when GetBody can rewind it. The breaker judges the whole call,
+// so retries inside one call count as a single failure.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" && c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+
+	max_retries := c.config.MaxRetries
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		max_retries = 0
+	}
+
+	if !c.breaker.Allow() {
+		return nil, ErrCircuitOpen
+	}
+
+	var last_err error
+	for attempt := 0; attempt <= max_retries; attempt++ {
+		if attempt > 0 {
+			if err := sleep(req.Context(), c.backoff(attempt, last_err)); err != nil {
+				c.breaker.Abort()
+				return nil, err
+			}
+		}
+		if err := c.limiter.Wait(req.Context()); err != nil {
+			c.breaker.Abort()
+			return nil, err
+		}
+
+		attempt_req := req.Clone(req.Context())
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				c.breaker.Abort()
+				return nil, err
+			}
+			attempt_req.Body = body
+		}
+
+		resp, err := c.http.Do(attempt_req)
+		if err != nil {
+			if req.Context().Err() != nil {
+				c.breaker.Abort()
+				return nil, err
+			}
+			last_err = err
+			continue
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+			last_err = &retryableStatus{StatusError{Code: resp.StatusCode, URL: req.URL.String()}, retryAfter(resp)}
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			continue
+		}
+
+		c.breaker.Success()
+		return resp, nil
+	}
+
+	c.breaker.Failure()
+	custom_log.NewCustomLog("upstream_failed", last_err.Error(), "error")
+	var status *retryableStatus
+	if errors.As(last_err, &status) {
+		return nil, &status.StatusError
+	}
+	return nil, last_err
+}
+
+// GetJSON fetches path relative to BaseURL with the session cookies and decodes the body into out
+func (c *Client) GetJSON(ctx context.Context, path string, headers map[string]string, out interface{}) error {
+	if err := c.EnsureSession(ctx); err != nil {
+		return err
+	}
+
+	for refreshed := false; ; refreshed = true {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+path, nil)
+		if err != nil {
+			return err
+		}
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+
+		resp, err := c.Do(req)
+		if err != nil {
+			return err
+		}
+
+		// a rejected session is refreshed once before giving up
+		if (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden) && !refreshed {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			c.ResetSession()
+			if err := c.EnsureSession(ctx); err != nil {
+				return err
+			}
+			continue
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return &StatusError{Code: resp.StatusCode, URL: req.URL.String()}
+		}
+		if err := json.Unmarshal(body, out); err != nil {
+			return fmt.Errorf("%w: %v", ErrDecode, err)
+		}
+		return nil
+	}
+}
+
+type retryableStatus struct {
+	StatusError
+	retry_after time.Duration
+}
+
+// backoff doubles per attempt up to MaxBackoff with full jitter, a Retry-After header wins when longer
+func (c *Client) backoff(attempt int, last_err error) time.Duration {
+	delay := c.config.BaseBackoff << (attempt - 1)
+	if delay <= 0 || delay > c.config.MaxBackoff {
+		delay = c.config.MaxBackoff
+	}
+	if delay > 0 {
+		delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+	}
+
+	var status *retryableStatus
+	if errors.As(last_err, &status) && status.retry_after > delay {
+		delay = status.retry_after
+		if delay > c.config.MaxBackoff {
+			delay = c.config.MaxBackoff
+		}
+	}
+	return delay
+}
+
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,115 @@
+package upstream
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"rerng_addicted_api/configs"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testConfig(max_retries int, threshold int) *configs.UpstreamConfig {
+	return &configs.UpstreamConfig{
+		RatePerSecond:    1000,
+		Burst:            1000,
+		MaxRetries:       max_retries,
+		BaseBackoff:      time.Millisecond,
+		MaxBackoff:       time.Millisecond,
+		BreakerThreshold: threshold,
+		BreakerCooldown:  time.Minute,
+		SessionTTL:       time.Minute,
+		RequestTimeout:   time.Second,
+	}
+}
+
+func TestDoRetriesOnlyRewindableBodies(t *testing.T) {
+	tests := []struct {
+		name         string
+		request      func(url string) *http.Request
+		want_calls   int32
+		want_payload string
+	}{
+		{
+			name: "bodiless",
+			request: func(url string) *http.Request {
+				req, _ := http.NewRequest(http.MethodGet, url, nil)
+				return req
+			},
+			want_calls: 3,
+		},
+		{
+			name: "rewindable body",
+			request: func(url string) *http.Request {
+				req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader("payload"))
+				return req
+			},
+			want_calls:   3,
+			want_payload: "payload",
+		},
+		{
+			name: "one shot body",
+			request: func(url string) *http.Request {
+				req, _ := http.NewRequest(http.MethodPost, url, io.NopCloser(strings.NewReader("payload")))
+				return req
+			},
+			want_calls:   1,
+			want_payload: "payload",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&calls, 1)
+				if body, _ := io.ReadAll(r.Body); string(body) != tt.want_payload {
+					t.Errorf("attempt sent body %q, want %q", body, tt.want_payload)
+				}
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}))
+			defer server.Close()
+
+			client := NewClient(server.URL, "test", nil, testConfig(2, 100))
+			if _, err := client.Do(tt.request(server.URL)); err == nil {
+				t.Fatal("Do succeeded against a failing upstream")
+			}
+			if got := atomic.LoadInt32(&calls); got != tt.want_calls {
+				t.Errorf("upstream saw %d attempts, want %d", got, tt.want_calls)
+			}
+		})
+	}
+}
+
+func TestDoCountsOneBreakerFailurePerCall(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	// three attempts per call, the breaker only opens on the second failed call
+	client := NewClient(server.URL, "test", nil, testConfig(2, 2))
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	if _, err := client.Do(req); err == nil {
+		t.Fatal("Do succeeded against a failing upstream")
+	}
+	if state := client.Breaker().State(); state != StateClosed {
+		t.Fatalf("breaker %s after one failed call, want closed", state)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, server.URL, nil)
+	if _, err := client.Do(req); err == nil {
+		t.Fatal("Do succeeded against a failing upstream")
+	}
+	if state := client.Breaker().State(); state != StateOpen {
+		t.Fatalf("breaker %s after two failed calls, want open", state)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, server.URL, nil)
+	if _, err := client.Do(req); err != ErrCircuitOpen {
+		t.Errorf("Do with an open breaker = %v, want ErrCircuitOpen", err)
+	}
+}